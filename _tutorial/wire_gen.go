@@ -1,5 +1,7 @@
 // Code generated by Wire. DO NOT EDIT.
 
+//wire:version (devel)
+//wire:options 31d965d7a96e6a35c79402054943587fb0c5182de1236dc2adee545917edbb3d
 //go:generate go run -mod=mod github.com/google/wire/cmd/wire
 //go:build !wireinject
 // +build !wireinject
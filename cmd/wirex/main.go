@@ -45,6 +45,7 @@ func main() {
     subcommands.Register(subcommands.HelpCommand(), "")
     subcommands.Register(&checkCmd{}, "")
     subcommands.Register(&diffCmd{}, "")
+    subcommands.Register(&explainCmd{}, "")
     subcommands.Register(&genCmd{}, "")
     subcommands.Register(&showCmd{}, "")
     flag.Parse()
@@ -64,6 +65,7 @@ func main() {
         "flags":    true, // builtin
         "check":    true,
         "diff":     true,
+        "explain":  true,
         "gen":      true,
         "show":     true,
     }
@@ -106,6 +108,22 @@ type genCmd struct {
     parallel       bool
     workers        int
     lazyLoad       bool
+    platforms      string
+    deprecatedErr  bool
+    unusedErr      bool
+    nearestWins    bool
+    annotate       bool
+    noInjectorsErr bool
+    requireVersion string
+    identPrefix    string
+    checkRecover   bool
+    checkGlobals   bool
+    checkConvs     bool
+    runtimeCleanup bool
+    buildTag       string
+    wrapErrors     string
+    force          bool
+    benchmarks     bool
 }
 
 func (*genCmd) Name() string { return "gen" }
@@ -116,12 +134,63 @@ func (*genCmd) Usage() string {
     return `gen [packages]
 
   Given one or more packages, gen creates the wire_gen.go file for each.
+  A .go file may be given instead of a package, for the package that it
+  belongs to, such as the files that a pre-commit hook sees changed.
 
   If no packages are listed, it defaults to ".".
 
   Use -parallel for faster generation on large codebases with many packages.
   Use -lazy for lazy loading of dependencies (reduces initial load time).
   Combine -parallel and -lazy for maximum performance on very large projects.
+
+  Use -platforms to generate one file per target platform, for injectors
+  behind platform build constraints. Each platform is written as GOOS or
+  GOOS/GOARCH, e.g. -platforms linux,darwin/arm64 writes wire_gen_linux.go
+  and wire_gen_darwin_arm64.go.
+
+  Uses of providers and provider sets whose doc comment has a "Deprecated:"
+  paragraph are reported as warnings, or as errors with -deprecated_as_error.
+  Likewise, injector parameters that the injector does not use are reported
+  as warnings, or as errors with -unused_params_as_error.
+
+  Use -annotate to comment each statement of the generated injectors with
+  the type it provides and the provider it calls.
+
+  Use -check_cleanup_recover to warn about cleanup functions of providers
+  that call recover, which can swallow panics. A //wire:allow-recover
+  comment allows such a call.
+
+  Use -check_conversions to fail on providers that only convert one named
+  type to another with the same underlying type, such as UserID to
+  SessionID. A //wire:allow-conversion comment on the provider allows it.
+
+  Use -runtime_cleanup to make injectors return the Run method of a
+  wire.CleanupFuncs instead of a closure, which keeps running the other
+  cleanup functions when one panics.
+
+  Use -identifier_prefix to start the names of the package-level variables
+  that gen writes besides the injectors, such as those holding wire.Value
+  expressions, with a prefix, to avoid collisions with other generators.
+
+  Use -build_tag to mark injector files with a build tag other than
+  wireinject, e.g. -build_tag di_spec. Injector files still constrained to
+  wireinject are then an error.
+
+  Use -wrap_errors to wrap the errors that providers return in the generated
+  injectors with fmt.Errorf and %w. Its value is a text/template for the
+  message, with {{.Type}} and {{.Provider}} naming the type being provided
+  and the provider, e.g. -wrap_errors "initializing {{.Type}}".
+
+  gen refuses to overwrite a wire_gen.go file that was edited by hand since
+  it was generated; move the edits into a provider, or use -force to discard
+  them.
+
+  If none of the packages have injectors, gen says so but still succeeds,
+  unless -no_injectors_as_error is given.
+
+  Use -require_version to fail unless this wirex satisfies a version
+  constraint, e.g. -require_version ">=v0.6.0, <v0.8.0". Injector files can
+  require a minimum version with a //wire:minversion directive.
 `
 }
 func (cmd *genCmd) SetFlags(f *flag.FlagSet) {
@@ -131,6 +200,22 @@ func (cmd *genCmd) SetFlags(f *flag.FlagSet) {
     f.BoolVar(&cmd.parallel, "parallel", false, "enable parallel processing for faster generation on large codebases")
     f.IntVar(&cmd.workers, "workers", 0, "number of parallel workers (default: number of CPUs, only used with -parallel)")
     f.BoolVar(&cmd.lazyLoad, "lazy", false, "enable lazy loading of dependencies (reduces initial load time for large projects)")
+    f.StringVar(&cmd.platforms, "platforms", "", "comma-separated list of GOOS or GOOS/GOARCH targets to generate platform-specific files for")
+    f.BoolVar(&cmd.deprecatedErr, "deprecated_as_error", false, "report uses of deprecated providers as errors instead of warnings")
+    f.BoolVar(&cmd.unusedErr, "unused_params_as_error", false, "report unused injector parameters as errors instead of warnings")
+    f.BoolVar(&cmd.nearestWins, "nearest_wins", false, "let providers that wire.Build includes through fewer provider sets override others of the same type")
+    f.BoolVar(&cmd.annotate, "annotate", false, "comment each generated statement with the provider it calls")
+    f.BoolVar(&cmd.noInjectorsErr, "no_injectors_as_error", false, "fail if none of the packages have injectors")
+    f.StringVar(&cmd.requireVersion, "require_version", "", "fail unless this version of wirex satisfies the given semantic version constraint")
+    f.StringVar(&cmd.identPrefix, "identifier_prefix", "", "string to prepend to the names of generated package-level identifiers other than injectors")
+    f.BoolVar(&cmd.checkRecover, "check_cleanup_recover", false, "warn about cleanup functions of providers that call recover")
+    f.BoolVar(&cmd.checkGlobals, "check_global_reads", false, "warn about providers that read package-level variables of other packages")
+    f.BoolVar(&cmd.checkConvs, "check_conversions", false, "fail on providers that only convert between named types with the same underlying type")
+    f.BoolVar(&cmd.runtimeCleanup, "runtime_cleanup", false, "run cleanup functions with wire.CleanupFuncs instead of a generated closure")
+    f.StringVar(&cmd.buildTag, "build_tag", "wireinject", "build tag that marks injector files")
+    f.StringVar(&cmd.wrapErrors, "wrap_errors", "", "template for the message that provider errors are wrapped with")
+    f.BoolVar(&cmd.force, "force", false, "overwrite generated files that were edited by hand")
+    f.BoolVar(&cmd.benchmarks, "emit_benchmarks", false, "also generate a benchmark for each injector in wire_gen_bench_test.go")
 }
 
 func (cmd *genCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
@@ -152,26 +237,41 @@ func (cmd *genCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interfa
 
     opts.PrefixOutputFile = cmd.prefixFileName
     opts.Tags = cmd.tags
+    opts.DeprecatedAsError = cmd.deprecatedErr
+    opts.UnusedParamsAsError = cmd.unusedErr
+    opts.NearestWins = cmd.nearestWins
+    opts.AnnotateOutput = cmd.annotate
+    opts.RequireVersion = cmd.requireVersion
+    opts.IdentifierPrefix = cmd.identPrefix
+    opts.CheckCleanupRecover = cmd.checkRecover
+    opts.CheckGlobalReads = cmd.checkGlobals
+    opts.CheckConversions = cmd.checkConvs
+    opts.UseRuntimeCleanup = cmd.runtimeCleanup
+    opts.BuildTag = cmd.buildTag
+    opts.WrapErrors = cmd.wrapErrors
+    opts.Force = cmd.force
+    opts.EmitBenchmarks = cmd.benchmarks
+    opts.LazyLoad = cmd.lazyLoad
+    if cmd.parallel {
+        // A workers value of 0 means one per CPU.
+        opts.Concurrency = cmd.workers
+        if cmd.workers <= 0 {
+            opts.Concurrency = -1
+        }
+    }
 
     var outs []wire.GenerateResult
     var errs []error
 
-    // Select the appropriate generation strategy based on flags
     switch {
-    case cmd.parallel && cmd.lazyLoad:
-        // Maximum performance: parallel + lazy loading
-        log.Println("using parallel processing with lazy loading")
-        outs, errs = wire.GenerateParallelWithLazyLoad(ctx, wd, os.Environ(), packages(f), opts, cmd.workers)
-    case cmd.parallel:
-        // Parallel processing only
-        log.Println("using parallel processing")
-        outs, errs = wire.GenerateParallel(ctx, wd, os.Environ(), packages(f), opts, cmd.workers)
-    case cmd.lazyLoad:
-        // Lazy loading only
-        log.Println("using lazy loading")
-        outs, errs = wire.GenerateWithLazyLoad(ctx, wd, os.Environ(), packages(f), opts)
+    case cmd.platforms != "":
+        platforms, err := parsePlatforms(cmd.platforms)
+        if err != nil {
+            log.Println(err)
+            return subcommands.ExitFailure
+        }
+        outs, errs = wire.GenerateForPlatforms(ctx, wd, os.Environ(), packages(f), opts, platforms)
     default:
-        // Standard generation
         outs, errs = wire.Generate(ctx, wd, os.Environ(), packages(f), opts)
     }
 
@@ -180,11 +280,22 @@ func (cmd *genCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interfa
         log.Println("generate failed")
         return subcommands.ExitFailure
     }
+    summary := wire.Summarize(outs)
+    for _, path := range summary.Ignored {
+        log.Printf("%s: ignored\n", path)
+    }
+    if err := summary.Err(); err != nil {
+        log.Printf("%v in %s\n", err, strings.Join(packages(f), " "))
+        if cmd.noInjectorsErr {
+            return subcommands.ExitFailure
+        }
+    }
     if len(outs) == 0 {
         return subcommands.ExitSuccess
     }
     success := true
     for _, out := range outs {
+        logWarnings(out.Warnings)
         if len(out.Errs) > 0 {
             logErrors(out.Errs)
             log.Printf("%s: generate failed\n", out.PkgPath)
@@ -196,6 +307,9 @@ func (cmd *genCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interfa
         }
         if err := out.Commit(); err == nil {
             log.Printf("%s: wrote %s\n", out.PkgPath, out.OutputPath)
+            if out.Benchmarks != nil {
+                log.Printf("%s: wrote %s\n", out.PkgPath, out.Benchmarks.OutputPath)
+            }
         } else {
             log.Printf("%s: failed to write %s: %v\n", out.PkgPath, out.OutputPath, err)
             success = false
@@ -208,9 +322,34 @@ func (cmd *genCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interfa
     return subcommands.ExitSuccess
 }
 
+// parsePlatforms parses a comma-separated list of GOOS or GOOS/GOARCH pairs.
+func parsePlatforms(s string) ([]wire.Platform, error) {
+    var platforms []wire.Platform
+    for _, field := range strings.Split(s, ",") {
+        field = strings.TrimSpace(field)
+        if field == "" {
+            continue
+        }
+        goos, goarch, _ := strings.Cut(field, "/")
+        if goos == "" || strings.Contains(goarch, "/") {
+            return nil, fmt.Errorf("invalid platform %q; want GOOS or GOOS/GOARCH", field)
+        }
+        platforms = append(platforms, wire.Platform{GOOS: goos, GOARCH: goarch})
+    }
+    return platforms, nil
+}
+
 type diffCmd struct {
-    headerFile string
-    tags       string
+    headerFile     string
+    tags           string
+    ignoreVersion  bool
+    nearestWins    bool
+    annotate       bool
+    runtimeCleanup bool
+    benchmarks     bool
+    identPrefix    string
+    buildTag       string
+    wrapErrors     string
 }
 
 func (*diffCmd) Name() string { return "diff" }
@@ -226,12 +365,21 @@ func (*diffCmd) Usage() string {
   If no packages are listed, it defaults to ".".
 
   Similar to the diff command, it returns 0 if no diff, 1 if different, 2
-  plus an error if trouble.
+  plus an error if trouble. With -ignore_version, files that differ only in
+  the version of Wire that generated them are not reported.
 `
 }
 func (cmd *diffCmd) SetFlags(f *flag.FlagSet) {
     f.StringVar(&cmd.headerFile, "header_file", "", "path to file to insert as a header in wire_gen.go")
     f.StringVar(&cmd.tags, "tags", "", "append build tags to the default wirebuild")
+    f.BoolVar(&cmd.ignoreVersion, "ignore_version", false, "ignore differences in the recorded Wire version")
+    f.BoolVar(&cmd.nearestWins, "nearest_wins", false, "let providers that wire.Build includes through fewer provider sets override others of the same type")
+    f.BoolVar(&cmd.annotate, "annotate", false, "comment each generated statement with the provider it calls")
+    f.BoolVar(&cmd.runtimeCleanup, "runtime_cleanup", false, "run cleanup functions with wire.CleanupFuncs instead of a generated closure")
+    f.BoolVar(&cmd.benchmarks, "emit_benchmarks", false, "also generate a benchmark for each injector in wire_gen_bench_test.go")
+    f.StringVar(&cmd.identPrefix, "identifier_prefix", "", "string to prepend to the names of generated package-level identifiers other than injectors")
+    f.StringVar(&cmd.buildTag, "build_tag", "wireinject", "build tag that marks injector files")
+    f.StringVar(&cmd.wrapErrors, "wrap_errors", "", "template for the message that provider errors are wrapped with")
 }
 func (cmd *diffCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
     const (
@@ -250,6 +398,13 @@ func (cmd *diffCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interf
     }
 
     opts.Tags = cmd.tags
+    opts.NearestWins = cmd.nearestWins
+    opts.AnnotateOutput = cmd.annotate
+    opts.UseRuntimeCleanup = cmd.runtimeCleanup
+    opts.EmitBenchmarks = cmd.benchmarks
+    opts.IdentifierPrefix = cmd.identPrefix
+    opts.BuildTag = cmd.buildTag
+    opts.WrapErrors = cmd.wrapErrors
 
     outs, errs := wire.Generate(ctx, wd, os.Environ(), packages(f), opts)
     if len(errs) > 0 {
@@ -272,20 +427,24 @@ func (cmd *diffCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interf
             // No Wire output. Maybe errors, maybe no Wire directives.
             continue
         }
-        // Assumes the current file is empty if we can't read it.
+        // Assumes the current files are empty if we can't read them.
         cur, _ := ioutil.ReadFile(out.OutputPath)
-        if diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
-            A: difflib.SplitLines(string(cur)),
-            B: difflib.SplitLines(string(out.Content)),
-        }); err == nil {
-            if diff != "" {
-                // Print the actual diff to stdout, not stderr.
-                fmt.Printf("%s: diff from %s:\n%s\n", out.PkgPath, out.OutputPath, diff)
+        if !cmd.ignoreVersion || !wire.EqualIgnoringVersion(cur, out.Content) {
+            if info, ok := wire.GeneratedFileInfo(cur); ok && info.OptionsFingerprint != out.OptionsFingerprint {
+                // The options are part of the diff below, but call them out:
+                // the file is stale even if its inputs have not changed.
+                fmt.Printf("%s: %s was generated with different options\n", out.PkgPath, out.OutputPath)
                 hadDiff = true
             }
-        } else {
-            log.Printf("%s: failed to diff %s: %v\n", out.PkgPath, out.OutputPath, err)
-            success = false
+            if !printDiff(out.PkgPath, out.OutputPath, cur, out.Content, &hadDiff) {
+                success = false
+            }
+        }
+        if out.Benchmarks != nil {
+            cur, _ := ioutil.ReadFile(out.Benchmarks.OutputPath)
+            if !printDiff(out.PkgPath, out.Benchmarks.OutputPath, cur, out.Benchmarks.Content, &hadDiff) {
+                success = false
+            }
         }
     }
     if !success {
@@ -298,8 +457,29 @@ func (cmd *diffCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interf
     return subcommands.ExitSuccess
 }
 
+// printDiff prints the unified diff from cur to content of the file at path
+// to stdout, setting *hadDiff if there is one. It reports whether the
+// diff could be computed.
+func printDiff(pkgPath, path string, cur, content []byte, hadDiff *bool) bool {
+    diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+        A: difflib.SplitLines(string(cur)),
+        B: difflib.SplitLines(string(content)),
+    })
+    if err != nil {
+        log.Printf("%s: failed to diff %s: %v\n", pkgPath, path, err)
+        return false
+    }
+    if diff != "" {
+        // Print the actual diff to stdout, not stderr.
+        fmt.Printf("%s: diff from %s:\n%s\n", pkgPath, path, diff)
+        *hadDiff = true
+    }
+    return true
+}
+
 type showCmd struct {
-    tags string
+    tags     string
+    buildTag string
 }
 
 func (*showCmd) Name() string { return "show" }
@@ -319,6 +499,7 @@ func (*showCmd) Usage() string {
 }
 func (cmd *showCmd) SetFlags(f *flag.FlagSet) {
     f.StringVar(&cmd.tags, "tags", "", "append build tags to the default wirebuild")
+    f.StringVar(&cmd.buildTag, "build_tag", "wireinject", "build tag that marks injector files")
 }
 func (cmd *showCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
     wd, err := os.Getwd()
@@ -326,7 +507,7 @@ func (cmd *showCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interf
         log.Println("failed to get working directory: ", err)
         return subcommands.ExitFailure
     }
-    info, errs := wire.Load(ctx, wd, os.Environ(), cmd.tags, packages(f))
+    info, errs := wire.LoadWithBuildTag(ctx, wd, os.Environ(), cmd.buildTag, cmd.tags, packages(f))
     if info != nil {
         keys := make([]wire.ProviderSetID, 0, len(info.Sets))
         for k := range info.Sets {
@@ -358,6 +539,10 @@ func (cmd *showCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interf
                         out[types.TypeString(t, nil)] = v.Pos
                     case *wire.Field:
                         out[types.TypeString(t, nil)] = v.Pos
+                    case *wire.Slice:
+                        out[types.TypeString(t, nil)] = v.Pos
+                    case *wire.Map:
+                        out[types.TypeString(t, nil)] = v.Pos
                     default:
                         panic("unreachable")
                     }
@@ -390,8 +575,54 @@ func (cmd *showCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interf
     return subcommands.ExitSuccess
 }
 
+type explainCmd struct{}
+
+func (*explainCmd) Name() string { return "explain" }
+func (*explainCmd) Synopsis() string {
+    return "explain which provider an injector uses for a type"
+}
+func (*explainCmd) Usage() string {
+    return `explain injector type [package]
+
+  Given an injector function and a type, explain prints the provider that the
+  injector uses for the type, the provider sets through which the injector
+  includes it, and the other providers of the type in the provider sets of
+  the package's dependencies, along with why the injector does not use them.
+  The type is written as it would be in the injector's file, such as
+  "*sql.DB".
+
+  If no package is given, it defaults to ".".
+`
+}
+func (*explainCmd) SetFlags(f *flag.FlagSet) {}
+func (*explainCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+    if f.NArg() < 2 || f.NArg() > 3 {
+        log.Println("explain takes an injector, a type and at most one package")
+        return subcommands.ExitUsageError
+    }
+    pattern := "."
+    if f.NArg() == 3 {
+        pattern = f.Arg(2)
+    }
+    wd, err := os.Getwd()
+    if err != nil {
+        log.Println("failed to get working directory: ", err)
+        return subcommands.ExitFailure
+    }
+    e, errs := wire.Explain(ctx, wd, os.Environ(), pattern, f.Arg(0), f.Arg(1))
+    if len(errs) > 0 {
+        logErrors(errs)
+        log.Println("explain failed")
+        return subcommands.ExitFailure
+    }
+    fmt.Print(e)
+    return subcommands.ExitSuccess
+}
+
 type checkCmd struct {
-    tags string
+    tags     string
+    buildTag string
+    sarif    string
 }
 
 func (*checkCmd) Name() string { return "check" }
@@ -399,16 +630,21 @@ func (*checkCmd) Synopsis() string {
     return "print any Wire errors found"
 }
 func (*checkCmd) Usage() string {
-    return `check [-tags tag,list] [packages]
+    return `check [-tags tag,list] [-sarif file] [packages]
 
   Given one or more packages, check prints any type-checking or Wire errors
   found with top-level variable provider sets or injector functions.
 
+  With -sarif, the errors are also written to the file as a SARIF 2.1.0 log
+  for code scanning tools, or to standard output if the file is "-".
+
   If no packages are listed, it defaults to ".".
 `
 }
 func (cmd *checkCmd) SetFlags(f *flag.FlagSet) {
     f.StringVar(&cmd.tags, "tags", "", "append build tags to the default wirebuild")
+    f.StringVar(&cmd.buildTag, "build_tag", "wireinject", "build tag that marks injector files")
+    f.StringVar(&cmd.sarif, "sarif", "", "write the errors as a SARIF log to this file, or to standard output if it is -")
 }
 func (cmd *checkCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
     wd, err := os.Getwd()
@@ -416,7 +652,13 @@ func (cmd *checkCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...inter
         log.Println("failed to get working directory: ", err)
         return subcommands.ExitFailure
     }
-    _, errs := wire.Load(ctx, wd, os.Environ(), cmd.tags, packages(f))
+    _, errs := wire.LoadWithBuildTag(ctx, wd, os.Environ(), cmd.buildTag, cmd.tags, packages(f))
+    if cmd.sarif != "" {
+        if err := writeSARIF(cmd.sarif, errs, nil); err != nil {
+            log.Println(err)
+            return subcommands.ExitFailure
+        }
+    }
     if len(errs) > 0 {
         logErrors(errs)
         log.Println("error loading packages")
@@ -545,6 +787,55 @@ func gather(info *wire.Info, key wire.ProviderSetID) (_ []outGroup, imports map[
                     inputs:  in,
                     outputs: out,
                 })
+            case pv.IsSlice() || pv.IsMap():
+                // Try to see if any element inputs haven't been visited.
+                var lit interface{}
+                var inputs []types.Type
+                if pv.IsSlice() {
+                    lit, inputs = pv.Slice(), pv.Slice().Inputs()
+                } else {
+                    lit, inputs = pv.Map(), pv.Map().Inputs()
+                }
+                allPresent := true
+                for _, t := range inputs {
+                    if inputVisited.At(t) == nil {
+                        allPresent = false
+                    }
+                }
+                if !allPresent {
+                    stk = append(stk, curr)
+                    for _, t := range inputs {
+                        if inputVisited.At(t) == nil {
+                            stk = append(stk, t)
+                        }
+                    }
+                    continue dfs
+                }
+                in := new(typeutil.Map)
+                in.SetHasher(hash)
+                for _, t := range inputs {
+                    i := inputVisited.At(t).(int)
+                    if i == -1 {
+                        in.Set(t, true)
+                    } else {
+                        mergeTypeSets(in, groups[i].inputs)
+                    }
+                }
+                for i := range groups {
+                    if sameTypeKeys(groups[i].inputs, in) {
+                        groups[i].outputs.Set(curr, lit)
+                        inputVisited.Set(curr, i)
+                        continue dfs
+                    }
+                }
+                out := new(typeutil.Map)
+                out.SetHasher(hash)
+                out.Set(curr, lit)
+                inputVisited.Set(curr, len(groups))
+                groups = append(groups, outGroup{
+                    inputs:  in,
+                    outputs: out,
+                })
             case pv.IsField():
                 // Try to see if the parent struct hasn't been visited.
                 f := pv.Field()
@@ -640,8 +931,31 @@ func formatProviderSetName(importPath, varName string) string {
     return strconv.Quote(importPath) + "." + varName
 }
 
+// writeSARIF writes errs and warnings as a SARIF log to the file name, or
+// to standard output if name is "-".
+func writeSARIF(name string, errs []error, warnings []wire.Warning) error {
+    data, err := wire.EncodeSARIF(errs, warnings)
+    if err != nil {
+        return fmt.Errorf("failed to encode SARIF: %v", err)
+    }
+    if name == "-" {
+        _, err = os.Stdout.Write(data)
+        return err
+    }
+    if err := ioutil.WriteFile(name, data, 0666); err != nil {
+        return fmt.Errorf("failed to write SARIF: %v", err)
+    }
+    return nil
+}
+
 func logErrors(errs []error) {
     for _, err := range errs {
         log.Println(strings.Replace(err.Error(), "\n", "\n\t", -1))
     }
 }
+
+func logWarnings(warnings []error) {
+    for _, w := range warnings {
+        log.Println("warning: " + strings.Replace(w.Error(), "\n", "\n\t", -1))
+    }
+}
@@ -0,0 +1,118 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/subcommands"
+)
+
+// writePackage writes a module with one package, example.com/foo, whose
+// injector file has the given //wire:options directive, to a new GOPATH.
+// It changes to the package's directory and points GOPATH at it for the
+// rest of the test.
+func writePackage(t *testing.T, directive string) {
+	t.Helper()
+	wireGo, err := ioutil.ReadFile(filepath.Join("..", "..", "wire.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gopath := t.TempDir()
+	wireDir := filepath.Join(gopath, "src", "github.com", "google", "wire")
+	files := map[string]string{
+		filepath.Join(wireDir, "wire.go"): string(wireGo),
+		filepath.Join(wireDir, "go.mod"):  "module github.com/google/wire\n",
+		filepath.Join(gopath, "src", "example.com", "go.mod"): "module example.com\n\ngo 1.19\n\n" +
+			"require github.com/google/wire v0.1.0\nreplace github.com/google/wire => " + wireDir + "\n",
+		filepath.Join(gopath, "src", "example.com", "foo", "foo.go"): `package foo
+
+import "errors"
+
+type Foo int
+
+type Bar int
+
+func provideFoo() (Foo, error) { return 0, errors.New("no foo") }
+
+func provideBar(foo Foo) (Bar, func()) { return Bar(foo), func() {} }
+`,
+		filepath.Join(gopath, "src", "example.com", "foo", "wire.go"): `//go:build wireinject
+
+` + directive + `
+
+package foo
+
+import "github.com/google/wire"
+
+func injectBar() (Bar, func(), error) {
+	panic(wire.Build(provideFoo, provideBar))
+}
+`,
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("GOPATH", gopath)
+	t.Chdir(filepath.Join(gopath, "src", "example.com", "foo"))
+}
+
+// execute runs cmd with the given flags and arguments, as the wirex
+// command line would.
+func execute(t *testing.T, cmd subcommands.Command, args ...string) subcommands.ExitStatus {
+	t.Helper()
+	f := flag.NewFlagSet(cmd.Name(), flag.ContinueOnError)
+	cmd.SetFlags(f)
+	if err := f.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Execute(context.Background(), f)
+}
+
+func TestDiffAfterGen(t *testing.T) {
+	tests := []struct {
+		name      string
+		directive string
+		flags     []string
+	}{
+		{name: "Default"},
+		{name: "PackageOptions", directive: "//wire:options wrap_errors=true"},
+		{name: "Annotate", flags: []string{"-annotate"}},
+		{name: "RuntimeCleanup", flags: []string{"-runtime_cleanup"}},
+		{name: "NearestWins", flags: []string{"-nearest_wins"}},
+		{name: "EmitBenchmarks", flags: []string{"-emit_benchmarks"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			writePackage(t, test.directive)
+			if got := execute(t, new(genCmd), test.flags...); got != subcommands.ExitSuccess {
+				t.Fatalf("gen exited with %d", got)
+			}
+			if got := execute(t, new(diffCmd), test.flags...); got != subcommands.ExitSuccess {
+				t.Errorf("diff right after gen exited with %d; want 0", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Wirexvet reports misuse of the wire marker functions. It is meant to be run
+// through go vet with the wireinject build tag set:
+//
+//	go vet -tags=wireinject -vettool=$(which wirexvet) ./...
+//
+// Without the tag, go vet leaves injector files out of the build, so their
+// wire.Build calls and injector bodies are never checked.
+package main
+
+import (
+	"github.com/google/wire/internal/wire"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() { singlechecker.Main(wire.Analyzer) }
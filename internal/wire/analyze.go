@@ -21,6 +21,7 @@ import (
 	"go/token"
 	"go/types"
 	"sort"
+	"strconv"
 	"strings"
 
 	"golang.org/x/tools/go/types/typeutil"
@@ -33,6 +34,8 @@ const (
 	structProvider
 	valueExpr
 	selectorExpr
+	sliceLit
+	mapLit
 )
 
 // A call represents a step of an injector function.  It may be either a
@@ -45,21 +48,46 @@ type call struct {
 	// out is the type this step produces.
 	out types.Type
 
+	// outs is the list of types that a provider function with several
+	// results produces, in result order. out is one of them. The call
+	// assigns one local variable for each result. outs is nil for
+	// providers with a single result.
+	outs []types.Type
+
+	// usedOuts reports which elements of outs the injector uses. Unused
+	// results are assigned to the blank identifier.
+	usedOuts []bool
+
 	// pkg and name identify one of the following:
 	// 1) the provider to call for kind == funcProviderCall;
 	// 2) the type to construct for kind == structProvider;
 	// 3) the name to select for kind == selectorExpr.
+	// All are empty for kind == sliceLit and kind == mapLit.
 	pkg  *types.Package
 	name string
 
+	// pos is the position of the provider, value or field that this step
+	// uses.
+	pos token.Pos
+
+	// typeArgs is the list of type arguments to instantiate a generic
+	// provider function or struct type with, for kind == funcProviderCall
+	// or kind == structProvider.
+	typeArgs []types.Type
+
 	// args is a list of arguments to call the provider with. Each element is:
 	// a) one of the givens (args[i] < len(given)),
-	// b) the result of a previous provider call (args[i] >= len(given))
+	// b) a local variable assigned by a previous call (args[i] >= len(given)).
+	//    Locals are numbered in call order, and a call with outs assigns
+	//    len(outs) consecutive locals.
 	//
 	// This will be nil for kind == valueExpr.
 	//
 	// If kind == selectorExpr, then the length of this slice will be 1 and the
 	// "argument" will be the value to access fields from.
+	//
+	// If kind == sliceLit or kind == mapLit, then there is one argument for
+	// each element.
 	args []int
 
 	// varargs is true if the provider function is variadic.
@@ -85,14 +113,99 @@ type call struct {
 	valueExpr     ast.Expr
 	valueTypeInfo *types.Info
 
+	// cleanupRecovers lists the positions of the recover calls in the
+	// cleanup function of the provider, as in Provider.CleanupRecovers.
+	cleanupRecovers []token.Pos
+	// globalReads lists the reads of package-level variables of other
+	// packages by the provider, as in Provider.GlobalReads.
+	globalReads []GlobalRead
+	// convertsFrom is the type that the provider only converts, as in
+	// Provider.ConvertsFrom.
+	convertsFrom types.Type
+
 	// The following are only set for kind == selectorExpr:
 
 	ptrToField bool
+
+	// The following are only set for kind == mapLit:
+
+	// keys lists the key of each entry, in the order of args.
+	keys []string
+}
+
+// solveInjector is solve for an injector with output type out. If the
+// injector uses wire.CleanupInto and calls a provider that returns a cleanup
+// function, the calls also produce the registry, as early as they can.
+func solveInjector(fset *token.FileSet, out types.Type, given *types.Tuple, set *ProviderSet) ([]call, []error) {
+	calls, errs := solve(fset, out, given, set)
+	reg := set.CleanupRegistry
+	if len(errs) == 0 && reg != nil && hasCleanupCall(calls) {
+		if set.For(reg.Type).IsNil() {
+			return nil, []error{notePosition(fset.Position(reg.Pos),
+				fmt.Errorf("no provider found for %s, the registry of wire.CleanupInto", types.TypeString(reg.Type, nil)))}
+		}
+		calls, errs = solve(fset, out, given, set, reg.Type)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if errs := checkCallsExported(fset, set, calls); len(errs) > 0 {
+		return nil, errs
+	}
+	return calls, nil
+}
+
+// checkCallsExported reports the providers that calls use but that are not
+// exported by their packages, if those are not the package of the injector
+// whose provider set is set. A provider set of another package may include
+// its own unexported providers, but the generated code could not call them.
+func checkCallsExported(fset *token.FileSet, set *ProviderSet, calls []call) []error {
+	ec := new(errorCollector)
+	for _, c := range calls {
+		if c.kind != funcProviderCall && c.kind != structProvider {
+			continue
+		}
+		if c.pkg == nil || c.pkg.Path() == set.PkgPath || ast.IsExported(c.name) {
+			continue
+		}
+		what := "provider"
+		if c.kind == structProvider {
+			what = "struct provider"
+		}
+		ec.add(fmt.Errorf("%s %s (%v) is not exported by package %s, so the injector cannot use it; export it or move the injector into %s",
+			what, c.name, fset.Position(c.pos), c.pkg.Path(), c.pkg.Path()))
+	}
+	return ec.errors
+}
+
+// hasCleanupCall reports whether any of calls returns a cleanup function.
+func hasCleanupCall(calls []call) bool {
+	for _, c := range calls {
+		if c.hasCleanup {
+			return true
+		}
+	}
+	return false
 }
 
 // solve finds the sequence of calls required to produce an output type
-// with an optional set of provided inputs.
-func solve(fset *token.FileSet, out types.Type, given *types.Tuple, set *ProviderSet) ([]call, []error) {
+// with an optional set of provided inputs. The calls also produce the types
+// in extra, which are visited first, so that the calls for them come as
+// early as the calls they depend on allow.
+func solve(fset *token.FileSet, out types.Type, given *types.Tuple, set *ProviderSet, extra ...types.Type) ([]call, []error) {
+	calls, used, errs := solveCalls(fset, out, given, set, extra...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if errs := verifyArgsUsed(set, used); len(errs) > 0 {
+		return nil, errs
+	}
+	return calls, nil
+}
+
+// solveCalls is solve without checking that the calls use everything that
+// set declares. It also returns the sources that the calls use.
+func solveCalls(fset *token.FileSet, out types.Type, given *types.Tuple, set *ProviderSet, extra ...types.Type) ([]call, []*providerSetSrc, []error) {
 	ec := new(errorCollector)
 
 	// Start building the mapping of type to local variable of the given type.
@@ -109,12 +222,42 @@ func solve(fset *token.FileSet, out types.Type, given *types.Tuple, set *Provide
 	errAbort := errors.New("failed to visit")
 	var used []*providerSetSrc
 	var calls []call
+	// locals is the number of local variables assigned by calls so far.
+	locals := 0
 	type frame struct {
 		t    types.Type
 		from types.Type
 		up   *frame
 	}
 	stk := []frame{{t: out}}
+	for i := len(extra) - 1; i >= 0; i-- {
+		stk = append(stk, frame{t: extra[i]})
+	}
+	// visitFirst pushes curr back on the stack followed by the types in deps
+	// that have not been visited yet, unless there are none, and reports
+	// whether curr can be handled now.
+	visitFirst := func(curr frame, deps []types.Type) bool {
+		ready := true
+		for i := len(deps) - 1; i >= 0; i-- {
+			if index.At(deps[i]) == nil {
+				if ready {
+					stk = append(stk, curr)
+					ready = false
+				}
+				stk = append(stk, frame{t: deps[i], from: curr.t, up: &curr})
+			}
+		}
+		return ready
+	}
+	// aborted reports whether visiting any of deps failed.
+	aborted := func(deps []types.Type) bool {
+		for _, d := range deps {
+			if index.At(d) == errAbort {
+				return true
+			}
+		}
+		return false
+	}
 dfs:
 	for len(stk) > 0 {
 		curr := stk[len(stk)-1]
@@ -125,21 +268,28 @@ dfs:
 
 		pv := set.For(curr.t)
 		if pv.IsNil() {
-			if curr.from == nil {
-				ec.add(fmt.Errorf("no provider found for %s, output of injector", types.TypeString(curr.t, nil)))
-				index.Set(curr.t, errAbort)
-				continue
-			}
 			sb := new(strings.Builder)
 			fmt.Fprintf(sb, "no provider found for %s", types.TypeString(curr.t, nil))
+			if curr.from == nil {
+				sb.WriteString(", output of injector")
+			}
 			for f := curr.up; f != nil; f = f.up {
 				fmt.Fprintf(sb, "\nneeded by %s in %s", types.TypeString(f.t, nil), set.srcMap.At(f.t).(*providerSetSrc).description(fset, f.t))
 			}
-			ec.add(errors.New(sb.String()))
+			ec.add(missingProviderError(fset, set, curr.t, sb.String()))
 			index.Set(curr.t, errAbort)
 			continue
 		}
 		src := set.srcMap.At(curr.t).(*providerSetSrc)
+		if src.origin(curr.t).Keyed != nil {
+			if keyed := set.keyedOf(curr.t); len(keyed) > 1 {
+				// Several keyed providers are only a conflict when the
+				// type is injected itself.
+				ec.add(bindingConflictError(fset, curr.t, set, &providerSetSrc{Keyed: keyed[1]}, &providerSetSrc{Keyed: keyed[0]}))
+				index.Set(curr.t, errAbort)
+				continue
+			}
+		}
 		used = append(used, src)
 		if concrete := pv.Type(); !types.Identical(concrete, curr.t) {
 			// Interface binding does not create a call.
@@ -186,33 +336,62 @@ dfs:
 				}
 				args[i] = v.(int)
 			}
-			index.Set(curr.t, given.Len()+len(calls))
 			kind := funcProviderCall
 			fieldNames := []string(nil)
 			if p.IsStruct {
+				if _, ok := unalias(curr.t).(*types.Pointer); !ok {
+					if lock := copyLock(curr.t); lock != nil {
+						ts := types.TypeString(curr.t, nil)
+						ec.add(notePosition(fset.Position(p.Pos),
+							fmt.Errorf("%s cannot be provided by value because it contains %s, which must not be copied; use *%s instead", ts, types.TypeString(lock, nil), ts)))
+						index.Set(curr.t, errAbort)
+						continue dfs
+					}
+				}
 				kind = structProvider
 				for _, arg := range p.Args {
 					fieldNames = append(fieldNames, arg.FieldName)
 				}
 			}
+			var outs []types.Type
+			if !p.IsStruct && len(p.Out) > 1 {
+				// One call provides all of the results.
+				outs = p.Out
+				for i, t := range outs {
+					index.Set(t, given.Len()+locals+i)
+				}
+				locals += len(outs)
+			} else {
+				index.Set(curr.t, given.Len()+locals)
+				locals++
+			}
 			calls = append(calls, call{
 				kind:       kind,
 				pkg:        p.Pkg,
 				name:       p.Name,
+				pos:        p.Pos,
+				typeArgs:   p.TypeArgs,
 				args:       args,
 				varargs:    p.Varargs,
 				fieldNames: fieldNames,
 				ins:        ins,
 				out:        curr.t,
+				outs:       outs,
 				hasCleanup: p.HasCleanup,
 				hasErr:     p.HasErr,
+
+				cleanupRecovers: p.CleanupRecovers,
+				globalReads:     p.GlobalReads,
+				convertsFrom:    p.ConvertsFrom,
 			})
 		case pv.IsValue():
 			v := pv.Value()
-			index.Set(curr.t, given.Len()+len(calls))
+			index.Set(curr.t, given.Len()+locals)
+			locals++
 			calls = append(calls, call{
 				kind:          valueExpr,
 				out:           curr.t,
+				pos:           v.Pos,
 				valueExpr:     v.expr,
 				valueTypeInfo: v.info,
 			})
@@ -224,7 +403,8 @@ dfs:
 				stk = append(stk, curr, frame{t: f.Parent, from: curr.t, up: &curr})
 				continue
 			}
-			index.Set(curr.t, given.Len()+len(calls))
+			index.Set(curr.t, given.Len()+locals)
+			locals++
 			v := index.At(f.Parent)
 			if v == errAbort {
 				index.Set(curr.t, errAbort)
@@ -239,21 +419,313 @@ dfs:
 				kind:       selectorExpr,
 				pkg:        f.Pkg,
 				name:       f.Name,
+				pos:        f.Pos,
 				out:        curr.t,
 				args:       args,
 				ptrToField: ptrToField,
 			})
+		case pv.IsSlice():
+			s := pv.Slice()
+			if !visitFirst(curr, s.Inputs()) {
+				continue
+			}
+			if aborted(s.Inputs()) {
+				index.Set(curr.t, errAbort)
+				continue
+			}
+			used = append(used, s.srcs...)
+			// Elements of the element type itself are not in index, so
+			// they are made right before the slice.
+			args := make([]int, len(s.Elems))
+			ins := make([]types.Type, len(s.Elems))
+			for i, e := range s.Elems {
+				ins[i] = e.Type()
+				switch {
+				case !types.Identical(e.Type(), s.Elem()):
+					args[i] = index.At(e.Type()).(int)
+				case e.IsArg():
+					args[i] = e.Arg().Index
+				default:
+					calls = append(calls, elemCall(e, index))
+					args[i] = given.Len() + locals
+					locals++
+				}
+			}
+			index.Set(curr.t, given.Len()+locals)
+			locals++
+			calls = append(calls, call{
+				kind: sliceLit,
+				out:  curr.t,
+				pos:  s.Pos,
+				args: args,
+				ins:  ins,
+			})
+		case pv.IsMap():
+			m := pv.Map()
+			if !visitFirst(curr, m.Inputs()) {
+				continue
+			}
+			if aborted(m.Inputs()) {
+				index.Set(curr.t, errAbort)
+				continue
+			}
+			used = append(used, m.srcs...)
+			args := make([]int, len(m.Entries))
+			ins := make([]types.Type, len(m.Entries))
+			keys := make([]string, len(m.Entries))
+			for i, e := range m.Entries {
+				ins[i] = m.Elem()
+				keys[i] = e.Key
+				if m.provides[i] {
+					args[i] = index.At(m.Elem()).(int)
+					continue
+				}
+				calls = append(calls, elemCall(ProvidedType{t: m.Elem(), p: e.Provider}, index))
+				args[i] = given.Len() + locals
+				locals++
+			}
+			index.Set(curr.t, given.Len()+locals)
+			locals++
+			calls = append(calls, call{
+				kind: mapLit,
+				out:  curr.t,
+				pos:  m.Pos,
+				args: args,
+				ins:  ins,
+				keys: keys,
+			})
 		default:
 			panic("unknown return value from ProviderSet.For")
 		}
 	}
 	if len(ec.errors) > 0 {
-		return nil, ec.errors
+		return nil, nil, ec.errors
 	}
-	if errs := verifyArgsUsed(set, used); len(errs) > 0 {
-		return nil, errs
+	results := []int{index.At(out).(int)}
+	for _, t := range extra {
+		results = append(results, index.At(t).(int))
 	}
-	return calls, nil
+	markUsedOuts(calls, given.Len(), results)
+	return calls, used, nil
+}
+
+// elemCall returns the call that makes e, an element of a wire.Slice that
+// a provider, value or field of the element type provides. index must hold
+// the local variables of the types that e depends on.
+func elemCall(e ProvidedType, index *typeutil.Map) call {
+	switch {
+	case e.IsProvider():
+		p := e.Provider()
+		c := call{
+			kind:       funcProviderCall,
+			pkg:        p.Pkg,
+			name:       p.Name,
+			pos:        p.Pos,
+			typeArgs:   p.TypeArgs,
+			args:       make([]int, len(p.Args)),
+			varargs:    p.Varargs,
+			ins:        make([]types.Type, len(p.Args)),
+			out:        e.Type(),
+			hasCleanup: p.HasCleanup,
+			hasErr:     p.HasErr,
+
+			cleanupRecovers: p.CleanupRecovers,
+			globalReads:     p.GlobalReads,
+			convertsFrom:    p.ConvertsFrom,
+		}
+		for i, a := range p.Args {
+			c.args[i] = index.At(a.Type).(int)
+			c.ins[i] = a.Type
+		}
+		if p.IsStruct {
+			c.kind = structProvider
+			for _, a := range p.Args {
+				c.fieldNames = append(c.fieldNames, a.FieldName)
+			}
+		}
+		return c
+	case e.IsValue():
+		v := e.Value()
+		return call{
+			kind:          valueExpr,
+			out:           e.Type(),
+			pos:           v.Pos,
+			valueExpr:     v.expr,
+			valueTypeInfo: v.info,
+		}
+	case e.IsField():
+		f := e.Field()
+		return call{
+			kind:       selectorExpr,
+			pkg:        f.Pkg,
+			name:       f.Name,
+			pos:        f.Pos,
+			out:        e.Type(),
+			args:       []int{index.At(f.Parent).(int)},
+			ptrToField: len(f.Out) == 2 && types.Identical(e.Type(), f.Out[1]),
+		}
+	}
+	panic("slice element is not a provider, value or field")
+}
+
+// markUsedOuts fills in usedOuts for the calls that have several results,
+// given the number of injector arguments and the local variables that the
+// injector uses besides the arguments of calls.
+func markUsedOuts(calls []call, numGiven int, results []int) {
+	read := make(map[int]bool)
+	for _, r := range results {
+		read[r] = true
+	}
+	for _, c := range calls {
+		for _, a := range c.args {
+			read[a] = true
+		}
+	}
+	local := numGiven
+	for i := range calls {
+		c := &calls[i]
+		if c.outs == nil {
+			local++
+			continue
+		}
+		c.usedOuts = make([]bool, len(c.outs))
+		for j := range c.outs {
+			c.usedOuts[j] = read[local+j]
+		}
+		local += len(c.outs)
+	}
+}
+
+// lockerType is the method set of sync.Locker.
+var lockerType = func() *types.Interface {
+	nullary := types.NewSignatureType(nil, nil, nil, nil, nil, false)
+	methods := []*types.Func{
+		types.NewFunc(token.NoPos, nil, "Lock", nullary),
+		types.NewFunc(token.NoPos, nil, "Unlock", nullary),
+	}
+	return types.NewInterfaceType(methods, nil).Complete()
+}()
+
+// copyLock returns the type of a lock that a value of type t holds
+// directly, or nil if t can be copied safely. It follows vet's copylocks
+// check: a lock is a struct whose pointer, but not the value itself,
+// implements sync.Locker, or sync.noCopy. Fields and array elements are
+// searched; pointers, slices and maps are not, since copying them does
+// not copy the lock.
+func copyLock(t types.Type) types.Type {
+	return findCopyLock(t, make(map[types.Type]bool))
+}
+
+func findCopyLock(t types.Type, seen map[types.Type]bool) types.Type {
+	if t == nil || seen[t] {
+		return nil
+	}
+	seen[t] = true
+	for {
+		arr, ok := t.Underlying().(*types.Array)
+		if !ok {
+			break
+		}
+		t = arr.Elem()
+	}
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok {
+		return nil
+	}
+	isLock := types.Implements(types.NewPointer(t), lockerType) && !types.Implements(t, lockerType)
+	if named, ok := unalias(t).(*types.Named); ok {
+		if obj := named.Obj(); obj.Pkg() != nil && obj.Pkg().Path() == "sync" && (isLock || obj.Name() == "noCopy") {
+			return t
+		}
+	}
+	// Prefer the lock a field holds, so that a struct embedding sync.Mutex
+	// names the mutex rather than itself.
+	for i := 0; i < st.NumFields(); i++ {
+		if lock := findCopyLock(st.Field(i).Type(), seen); lock != nil {
+			return lock
+		}
+	}
+	if isLock {
+		return t
+	}
+	return nil
+}
+
+// checkTestOnly returns an error if set, the provider set of the injector
+// at pos, includes a test-only provider set and the injector is not in a
+// _test.go file. The error shows how the injector includes the set.
+func checkTestOnly(fset *token.FileSet, pos token.Pos, set *ProviderSet) error {
+	if strings.HasSuffix(fset.Position(pos).Filename, "_test.go") {
+		return nil
+	}
+	path := testOnlyPath(set, make(map[*ProviderSet]bool))
+	if path == nil {
+		return nil
+	}
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "provider set %q (%s) is test-only and may only be used by injectors in _test.go files", path[0].VarName, fset.Position(path[0].Pos))
+	// The last set in path is the injector's own.
+	for _, s := range path[1 : len(path)-1] {
+		fmt.Fprintf(sb, "\nincluded by provider set %q (%s)", s.VarName, fset.Position(s.Pos))
+	}
+	return errors.New(sb.String())
+}
+
+// testOnlyPath returns the chain of provider sets from the first test-only
+// set that set includes, directly or through imports, up to set itself. It
+// returns nil if set includes no test-only set.
+func testOnlyPath(set *ProviderSet, visited map[*ProviderSet]bool) []*ProviderSet {
+	if visited[set] {
+		return nil
+	}
+	visited[set] = true
+	if set.TestOnly {
+		return []*ProviderSet{set}
+	}
+	for _, imp := range set.Imports {
+		if path := testOnlyPath(imp, visited); path != nil {
+			return append(path, set)
+		}
+	}
+	return nil
+}
+
+// A deprecatedSrc is a deprecated provider or provider set that contributed
+// to a solved injector.
+type deprecatedSrc struct {
+	src  *providerSetSrc
+	typ  types.Type
+	text string
+}
+
+// findDeprecated returns the deprecated providers and provider sets that the
+// given calls were drawn from, in call order. Each provider or set is
+// returned at most once.
+func findDeprecated(set *ProviderSet, calls []call) []deprecatedSrc {
+	var deps []deprecatedSrc
+	seen := make(map[interface{}]bool)
+	add := func(key interface{}, src *providerSetSrc, typ types.Type, text string) {
+		if text == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		deps = append(deps, deprecatedSrc{src: src, typ: typ, text: text})
+	}
+	for i := range calls {
+		t := calls[i].out
+		src, _ := set.srcMap.At(t).(*providerSetSrc)
+		for src != nil {
+			if src.Import == nil {
+				if src.Provider != nil {
+					add(src.Provider, src, t, src.Provider.Deprecated)
+				}
+				break
+			}
+			add(src.Import, src, t, src.Import.Deprecated)
+			src, _ = src.Import.srcMap.At(t).(*providerSetSrc)
+		}
+	}
+	return deps
 }
 
 // verifyArgsUsed ensures that all of the arguments in set were used during solve.
@@ -323,19 +795,221 @@ func verifyArgsUsed(set *ProviderSet, used []*providerSetSrc) []error {
 			errs = append(errs, fmt.Errorf("unused field %q.%s", f.Parent, f.Name))
 		}
 	}
+	for _, s := range set.Slices {
+		found := false
+		for _, u := range used {
+			if u.Slice == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Errorf("unused slice of type %s", types.TypeString(s.Out, nil)))
+		}
+	}
+	for _, m := range set.Maps {
+		found := false
+		for _, u := range used {
+			if u.Map == m {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Errorf("unused map of type %s", types.TypeString(m.Out, nil)))
+		}
+	}
+	for _, k := range set.Keyed {
+		found := false
+		for _, u := range used {
+			if u.Keyed == k {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Errorf("unused provider %q for key %q", k.Provider.Pkg.Name()+"."+k.Provider.Name, k.Key))
+		}
+	}
 	return errs
 }
 
-// buildProviderMap creates the providerMap and srcMap fields for a given
-// provider set. The given provider set's providerMap and srcMap fields are
-// ignored.
-func buildProviderMap(fset *token.FileSet, hasher typeutil.Hasher, set *ProviderSet) (*typeutil.Map, *typeutil.Map, []error) {
+// checkParamsUsed returns an error for each of the injector parameters
+// params that the solved calls do not use, other than those named "_". A
+// parameter is used if it is the injector's output or registry, or an
+// argument of some call. Since calls only includes what out needs, a
+// parameter whose fields are provided with wire.FieldsOf but never used
+// counts as unused too.
+func checkParamsUsed(params *types.Tuple, calls []call, set *ProviderSet, out types.Type) []error {
+	used := make([]bool, params.Len())
+	for _, c := range calls {
+		for _, a := range c.args {
+			if a < len(used) {
+				used[a] = true
+			}
+		}
+	}
+	useArg := func(t types.Type) {
+		if pv := set.For(t); pv.IsArg() {
+			used[pv.Arg().Index] = true
+		}
+	}
+	useArg(out)
+	if set.CleanupRegistry != nil {
+		useArg(set.CleanupRegistry.Type)
+	}
+	var errs []error
+	for i := 0; i < params.Len(); i++ {
+		p := params.At(i)
+		if used[i] || p.Name() == "_" {
+			continue
+		}
+		name := p.Name()
+		if name == "" {
+			name = strconv.Itoa(i + 1)
+		}
+		errs = append(errs, fmt.Errorf("parameter %s of type %s is unused", name, types.TypeString(p.Type(), nil)))
+	}
+	return errs
+}
+
+// keyedProviders returns the keyed providers of set and of the sets that it
+// imports: those of the imported sets in import order, followed by its own.
+func keyedProviders(set *ProviderSet) []keyedSrc {
+	var keyed []keyedSrc
+	for _, imp := range set.Imports {
+		src := &providerSetSrc{Import: imp}
+		for _, ks := range imp.keyed {
+			keyed = append(keyed, keyedSrc{ks.keyed, src})
+		}
+	}
+	for _, k := range set.Keyed {
+		keyed = append(keyed, keyedSrc{k, &providerSetSrc{Keyed: k}})
+	}
+	return keyed
+}
+
+// buildProviderMap creates the providerMap, srcMap and pendingBindings
+// fields for a given provider set. The given provider set's providerMap,
+// srcMap and pendingBindings fields are ignored.
+//
+// A binding whose concrete type the set does not provide is pending until
+// the set is used in wire.Build, where the concrete type must be one of the
+// injector's arguments. Other providers of the concrete type in an
+// importing set do not satisfy it: a set must provide the concrete types of
+// its own bindings.
+//
+// Whatever provides the element type of a wire.Slice that the set declares
+// or imports contributes an element to the slice instead of providing the
+// element type, so several of them do not conflict. Keyed providers of the
+// same type do not conflict either, until the type is injected. The keyed
+// field of set must already be filled in, for the entries of wire.Map.
+//
+// If set.nearestWins is true, a provider, binding, value or field that the
+// set includes through fewer provider sets takes the place of another
+// source of the same type instead of conflicting with it, and the sources
+// it takes the place of are recorded in set.overrides.
+func buildProviderMap(fset *token.FileSet, hasher typeutil.Hasher, set *ProviderSet) (*typeutil.Map, *typeutil.Map, []pendingBinding, []error) {
 	providerMap := new(typeutil.Map)
 	providerMap.SetHasher(hasher)
 	srcMap := new(typeutil.Map) // to *providerSetSrc
 	srcMap.SetHasher(hasher)
 
 	ec := new(errorCollector)
+	set.overrides = nil
+	// resolve decides between src and prev, the source of typ so far. It
+	// reports whether src takes the place of prev, or returns an error if
+	// they conflict.
+	resolve := func(typ types.Type, src, prev *providerSetSrc) (bool, error) {
+		if set.nearestWins && overridable(src.origin(typ)) && overridable(prev.origin(typ)) {
+			switch d, prevDepth := src.depth(typ), prev.depth(typ); {
+			case d < prevDepth:
+				set.overrides = append(set.overrides, override{typ: typ, src: prev.origin(typ)})
+				return true, nil
+			case d > prevDepth:
+				set.overrides = append(set.overrides, override{typ: typ, src: src.origin(typ)})
+				return false, nil
+			}
+		}
+		return false, bindingConflictError(fset, typ, set, src, prev)
+	}
+	// Process the slices that the set declares or imports first, since
+	// whatever provides their element types contributes an element instead.
+	slices := new(typeutil.Map) // element type to *Slice
+	slices.SetHasher(hasher)
+	addSlice := func(s *Slice, src *providerSetSrc) {
+		if prevSrc := srcMap.At(s.Out); prevSrc != nil {
+			ec.add(bindingConflictError(fset, s.Out, set, src, prevSrc.(*providerSetSrc)))
+			return
+		}
+		if prev, _ := slices.At(s.Elem()).(*Slice); prev != nil {
+			ec.add(notePosition(fset.Position(s.Pos), fmt.Errorf("wire.Slice of %s collects %s, as does wire.Slice of %s (%s)",
+				types.TypeString(s.Out, nil), types.TypeString(s.Elem(), nil), types.TypeString(prev.Out, nil), fset.Position(prev.Pos))))
+			return
+		}
+		merged := &Slice{Pos: s.Pos, Out: s.Out}
+		providerMap.Set(s.Out, &ProvidedType{t: s.Out, s: merged})
+		srcMap.Set(s.Out, src)
+		slices.Set(s.Elem(), merged)
+	}
+	for _, s := range set.Slices {
+		src := &providerSetSrc{Slice: s}
+		addSlice(s, src)
+		if merged, _ := slices.At(s.Elem()).(*Slice); merged != nil {
+			merged.srcs = append(merged.srcs, src)
+		}
+	}
+	var maps []*Map
+	addMap := func(m *Map, src *providerSetSrc) {
+		if prevSrc := srcMap.At(m.Out); prevSrc != nil {
+			ec.add(bindingConflictError(fset, m.Out, set, src, prevSrc.(*providerSetSrc)))
+			return
+		}
+		merged := &Map{Pos: m.Pos, Out: m.Out, srcs: []*providerSetSrc{src}}
+		providerMap.Set(m.Out, &ProvidedType{t: m.Out, m: merged})
+		srcMap.Set(m.Out, src)
+		maps = append(maps, merged)
+	}
+	for _, m := range set.Maps {
+		addMap(m, &providerSetSrc{Map: m})
+	}
+	for _, imp := range set.Imports {
+		imp.providerMap.Iterate(func(k types.Type, v interface{}) {
+			pt := v.(*ProvidedType)
+			switch {
+			case srcMap.At(k) != nil:
+			case pt.IsSlice():
+				addSlice(pt.Slice(), &providerSetSrc{Import: imp})
+			case pt.IsMap():
+				addMap(pt.Map(), &providerSetSrc{Import: imp})
+			}
+		})
+	}
+	addElem := func(s *Slice, e ProvidedType, src *providerSetSrc) {
+		if e.IsProvider() && !e.Provider().IsStruct && len(e.Provider().Out) > 1 && types.Identical(e.Type(), s.Elem()) {
+			ec.add(fmt.Errorf("%s cannot contribute to %s, since it has several results", e.src().description(fset, e.Type()), types.TypeString(s.Out, nil)))
+			return
+		}
+		for _, prev := range s.Elems {
+			if prev == e {
+				ec.add(fmt.Errorf("%s contributes to %s more than once", e.src().description(fset, e.Type()), types.TypeString(s.Out, nil)))
+				return
+			}
+		}
+		s.Elems = append(s.Elems, e)
+		s.srcs = append(s.srcs, src)
+	}
+	// The set's own elements follow those of the sets it imports, so they
+	// are added last.
+	type ownElem struct {
+		s   *Slice
+		e   ProvidedType
+		src *providerSetSrc
+	}
+	var own []ownElem
+	addOwn := func(s *Slice, e ProvidedType, src *providerSetSrc) {
+		own = append(own, ownElem{s, e, src})
+	}
 	// Process injector arguments.
 	if set.InjectorArgs != nil {
 		givens := set.InjectorArgs.Tuple
@@ -343,6 +1017,10 @@ func buildProviderMap(fset *token.FileSet, hasher typeutil.Hasher, set *Provider
 			typ := givens.At(i).Type()
 			arg := &InjectorArg{Args: set.InjectorArgs, Index: i}
 			src := &providerSetSrc{InjectorArg: arg}
+			if s, _ := slices.At(typ).(*Slice); s != nil {
+				addOwn(s, ProvidedType{t: typ, a: arg}, src)
+				continue
+			}
 			if prevSrc := srcMap.At(typ); prevSrc != nil {
 				ec.add(bindingConflictError(fset, typ, set, src, prevSrc.(*providerSetSrc)))
 				continue
@@ -352,81 +1030,257 @@ func buildProviderMap(fset *token.FileSet, hasher typeutil.Hasher, set *Provider
 		}
 	}
 	// Process imports, verifying that there are no conflicts between sets.
+	type importedBinding struct {
+		pendingBinding
+		src *providerSetSrc
+	}
+	var imported []importedBinding
 	for _, imp := range set.Imports {
 		src := &providerSetSrc{Import: imp}
+		for _, pb := range imp.pendingBindings {
+			imported = append(imported, importedBinding{pb, src})
+		}
 		imp.providerMap.Iterate(func(k types.Type, v interface{}) {
-			if prevSrc := srcMap.At(k); prevSrc != nil {
-				ec.add(bindingConflictError(fset, k, set, src, prevSrc.(*providerSetSrc)))
+			pt := v.(*ProvidedType)
+			if pt.IsSlice() {
+				if s, _ := slices.At(pt.Slice().Elem()).(*Slice); s != nil && types.Identical(s.Out, k) {
+					s.srcs = append(s.srcs, src)
+					for _, e := range pt.Slice().Elems {
+						addElem(s, e, src)
+					}
+					return
+				}
+			}
+			if prev, _ := providerMap.At(k).(*ProvidedType); pt.IsMap() && prev != nil && prev.IsMap() {
+				// The map collects the entries of the imported sets too.
+				prev.Map().srcs = append(prev.Map().srcs, src)
 				return
 			}
+			if s, _ := slices.At(k).(*Slice); s != nil {
+				addElem(s, *pt, src)
+				return
+			}
+			if prevSrc := srcMap.At(k); prevSrc != nil {
+				if prevSrc.(*providerSetSrc).origin(k).Keyed != nil && src.origin(k).Keyed != nil {
+					return
+				}
+				if replace, err := resolve(k, src, prevSrc.(*providerSetSrc)); !replace {
+					ec.add(err)
+					return
+				}
+			}
 			providerMap.Set(k, v)
 			srcMap.Set(k, src)
 		})
 	}
 	if len(ec.errors) > 0 {
-		return nil, nil, ec.errors
+		return nil, nil, nil, ec.errors
 	}
 
 	// Process non-binding providers in new set.
 	for _, p := range set.Providers {
 		src := &providerSetSrc{Provider: p}
 		for _, typ := range p.Out {
-			if prevSrc := srcMap.At(typ); prevSrc != nil {
-				ec.add(bindingConflictError(fset, typ, set, src, prevSrc.(*providerSetSrc)))
+			if s, _ := slices.At(typ).(*Slice); s != nil {
+				addOwn(s, ProvidedType{t: typ, p: p}, src)
 				continue
 			}
+			if prevSrc := srcMap.At(typ); prevSrc != nil {
+				if replace, err := resolve(typ, src, prevSrc.(*providerSetSrc)); !replace {
+					ec.add(err)
+					continue
+				}
+			}
 			providerMap.Set(typ, &ProvidedType{t: typ, p: p})
 			srcMap.Set(typ, src)
 		}
 	}
+	for _, k := range set.Keyed {
+		src := &providerSetSrc{Keyed: k}
+		typ := k.Provider.Out[0]
+		if prevSrc := srcMap.At(typ); prevSrc != nil {
+			if prevSrc.(*providerSetSrc).origin(typ).Keyed == nil {
+				ec.add(bindingConflictError(fset, typ, set, src, prevSrc.(*providerSetSrc)))
+			}
+			continue
+		}
+		providerMap.Set(typ, &ProvidedType{t: typ, p: k.Provider})
+		srcMap.Set(typ, src)
+	}
 	for _, v := range set.Values {
 		src := &providerSetSrc{Value: v}
-		if prevSrc := srcMap.At(v.Out); prevSrc != nil {
-			ec.add(bindingConflictError(fset, v.Out, set, src, prevSrc.(*providerSetSrc)))
+		if s, _ := slices.At(v.Out).(*Slice); s != nil {
+			addOwn(s, ProvidedType{t: v.Out, v: v}, src)
 			continue
 		}
+		if prevSrc := srcMap.At(v.Out); prevSrc != nil {
+			if replace, err := resolve(v.Out, src, prevSrc.(*providerSetSrc)); !replace {
+				ec.add(err)
+				continue
+			}
+		}
 		providerMap.Set(v.Out, &ProvidedType{t: v.Out, v: v})
 		srcMap.Set(v.Out, src)
 	}
 	for _, f := range set.Fields {
 		src := &providerSetSrc{Field: f}
 		for _, typ := range f.Out {
-			if prevSrc := srcMap.At(typ); prevSrc != nil {
-				ec.add(bindingConflictError(fset, typ, set, src, prevSrc.(*providerSetSrc)))
+			if s, _ := slices.At(typ).(*Slice); s != nil {
+				addOwn(s, ProvidedType{t: typ, f: f}, src)
 				continue
 			}
+			if prevSrc := srcMap.At(typ); prevSrc != nil {
+				if replace, err := resolve(typ, src, prevSrc.(*providerSetSrc)); !replace {
+					ec.add(err)
+					continue
+				}
+			}
 			providerMap.Set(typ, &ProvidedType{t: typ, f: f})
 			srcMap.Set(typ, src)
 		}
 	}
 	if len(ec.errors) > 0 {
-		return nil, nil, ec.errors
+		return nil, nil, nil, ec.errors
 	}
 
 	// Process bindings in set. Must happen after the other providers to
 	// ensure the concrete type is being provided.
+	setName := set.VarName
+	if setName == "" {
+		setName = "provider set"
+	}
+	var pending []pendingBinding
 	for _, b := range set.Bindings {
 		src := &providerSetSrc{Binding: b}
-		if prevSrc := srcMap.At(b.Iface); prevSrc != nil {
-			ec.add(bindingConflictError(fset, b.Iface, set, src, prevSrc.(*providerSetSrc)))
-			continue
+		s, _ := slices.At(b.Iface).(*Slice)
+		if prevSrc := srcMap.At(b.Iface); prevSrc != nil && s == nil {
+			if replace, err := resolve(b.Iface, src, prevSrc.(*providerSetSrc)); !replace {
+				ec.add(err)
+				continue
+			}
 		}
 		concrete := providerMap.At(b.Provided)
 		if concrete == nil {
-			setName := set.VarName
-			if setName == "" {
-				setName = "provider set"
+			if set.InjectorArgs == nil && s == nil {
+				pending = append(pending, pendingBinding{binding: b, setName: setName})
+				continue
 			}
-			ec.add(notePosition(fset.Position(b.Pos), fmt.Errorf("wire.Bind of concrete type %q to interface %q, but %s does not include a provider for %q", b.Provided, b.Iface, setName, b.Provided)))
+			ec.add(missingConcreteError(fset, b, setName))
+			continue
+		}
+		if s != nil {
+			addOwn(s, *concrete.(*ProvidedType), src)
 			continue
 		}
 		providerMap.Set(b.Iface, concrete)
 		srcMap.Set(b.Iface, src)
 	}
+	for _, ib := range imported {
+		b := ib.binding
+		concrete, _ := providerMap.At(b.Provided).(*ProvidedType)
+		switch {
+		case concrete != nil && concrete.IsArg():
+			if s, _ := slices.At(b.Iface).(*Slice); s != nil {
+				addElem(s, *concrete, ib.src)
+				continue
+			}
+			if prevSrc := srcMap.At(b.Iface); prevSrc != nil {
+				ec.add(bindingConflictError(fset, b.Iface, set, ib.src, prevSrc.(*providerSetSrc)))
+				continue
+			}
+			providerMap.Set(b.Iface, concrete)
+			srcMap.Set(b.Iface, ib.src)
+		case concrete == nil && set.InjectorArgs == nil:
+			pending = append(pending, ib.pendingBinding)
+		default:
+			ec.add(missingConcreteError(fset, b, ib.setName))
+		}
+	}
+	sort.SliceStable(own, func(i, j int) bool { return own[i].src.pos() < own[j].src.pos() })
+	for _, o := range own {
+		addElem(o.s, o.e, o.src)
+	}
+	for _, m := range maps {
+		keyed := set.keyedOf(m.Elem())
+		byKey := make(map[string]*KeyedProvider)
+		for _, ks := range set.keyed {
+			k := ks.keyed
+			if !types.Identical(k.Provider.Out[0], m.Elem()) {
+				continue
+			}
+			if prev := byKey[k.Key]; prev != nil {
+				ec.add(notePosition(fset.Position(set.Pos), fmt.Errorf("duplicate key %q for %s\ncurrent:\n<- %s\nprevious:\n<- %s",
+					k.Key, types.TypeString(m.Out, nil), (&providerSetSrc{Keyed: k}).description(fset, m.Elem()), (&providerSetSrc{Keyed: prev}).description(fset, m.Elem()))))
+				continue
+			}
+			byKey[k.Key] = k
+			m.Entries = append(m.Entries, k)
+			m.srcs = append(m.srcs, ks.src)
+		}
+		sort.Slice(m.Entries, func(i, j int) bool { return m.Entries[i].Key < m.Entries[j].Key })
+		pt, _ := providerMap.At(m.Elem()).(*ProvidedType)
+		for _, k := range m.Entries {
+			m.provides = append(m.provides, len(keyed) == 1 && pt != nil && pt.p == k.Provider)
+		}
+	}
 	if len(ec.errors) > 0 {
-		return nil, nil, ec.errors
+		return nil, nil, nil, ec.errors
 	}
-	return providerMap, srcMap, nil
+	return providerMap, srcMap, pending, nil
+}
+
+// namedCleanupDirective makes an injector return its cleanup functions as
+// a *wire.Cleanups, named after the types they clean up, instead of a
+// single func().
+const namedCleanupDirective = "//wire:named-cleanup"
+
+// autoBindDirective makes Wire bind the interface that an injector returns
+// to the only type in the injector's provider set that implements it.
+const autoBindDirective = "//wire:autobind"
+
+// autoBind adds a synthesized binding of the interface out to the one type
+// that set provides and that implements out, for the injector at pos. set
+// is left as it is if it already provides out.
+func autoBind(hasher typeutil.Hasher, set *ProviderSet, out types.Type, pos token.Pos) error {
+	iface, ok := out.Underlying().(*types.Interface)
+	if !ok {
+		return fmt.Errorf("%s requires the injector to return an interface, not %s", autoBindDirective, types.TypeString(out, nil))
+	}
+	if !set.For(out).IsNil() {
+		return nil
+	}
+	var candidates []types.Type
+	set.providerMap.Iterate(func(t types.Type, _ interface{}) {
+		if !types.IsInterface(t) && types.Implements(t, iface) {
+			candidates = append(candidates, t)
+		}
+	})
+	switch len(candidates) {
+	case 0:
+		return fmt.Errorf("%s: no provided type implements %s", autoBindDirective, types.TypeString(out, nil))
+	case 1:
+	default:
+		names := make([]string, len(candidates))
+		for i, t := range candidates {
+			names[i] = types.TypeString(t, nil)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("%s: %s is implemented by several provided types, %s; choose one with wire.Bind", autoBindDirective, types.TypeString(out, nil), strings.Join(names, ", "))
+	}
+	b := &IfaceBinding{Iface: out, Provided: candidates[0], Pos: pos, Synthesized: true}
+	set.Bindings = append(set.Bindings, b)
+	set.providerMap.Set(out, set.providerMap.At(b.Provided))
+	set.srcMap.Set(out, &providerSetSrc{Binding: b})
+	if errs := verifyAcyclic(set.providerMap, hasher); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// missingConcreteError reports that the set named setName binds b without
+// providing its concrete type.
+func missingConcreteError(fset *token.FileSet, b *IfaceBinding, setName string) error {
+	return notePosition(fset.Position(b.Pos), fmt.Errorf("wire.Bind of concrete type %q to interface %q, but %s does not include a provider for %q", b.Provided, b.Iface, setName, b.Provided))
 }
 
 func verifyAcyclic(providerMap *typeutil.Map, hasher typeutil.Hasher) []error {
@@ -439,8 +1293,14 @@ func verifyAcyclic(providerMap *typeutil.Map, hasher typeutil.Hasher) []error {
 	visited.SetHasher(hasher)
 	ec := new(errorCollector)
 	// Sort output types so that errors about cycles are consistent.
+	// The names are computed once rather than in each comparison, which
+	// dominates the time to check a wide set.
 	outputs := providerMap.Keys()
-	sort.Slice(outputs, func(i, j int) bool { return types.TypeString(outputs[i], nil) < types.TypeString(outputs[j], nil) })
+	names := make(map[types.Type]string, len(outputs))
+	for _, t := range outputs {
+		names[t] = types.TypeString(t, nil)
+	}
+	sort.Slice(outputs, func(i, j int) bool { return names[outputs[i]] < names[outputs[j]] })
 	for _, root := range outputs {
 		// Depth-first search using a stack of trails through the provider map.
 		stk := [][]types.Type{{root}}
@@ -463,14 +1323,19 @@ func verifyAcyclic(providerMap *typeutil.Map, hasher typeutil.Hasher) []error {
 				// Leaf: values do not have dependencies.
 			case pt.IsArg():
 				// Injector arguments do not have dependencies.
-			case pt.IsProvider() || pt.IsField():
+			case pt.IsProvider() || pt.IsField() || pt.IsSlice() || pt.IsMap():
 				var args []types.Type
-				if pt.IsProvider() {
+				switch {
+				case pt.IsProvider():
 					for _, arg := range pt.Provider().Args {
 						args = append(args, arg.Type)
 					}
-				} else {
+				case pt.IsField():
 					args = append(args, pt.Field().Parent)
+				case pt.IsSlice():
+					args = pt.Slice().Inputs()
+				default:
+					args = pt.Map().Inputs()
 				}
 				for _, a := range args {
 					hasCycle := false
@@ -480,10 +1345,15 @@ func verifyAcyclic(providerMap *typeutil.Map, hasher typeutil.Hasher) []error {
 							fmt.Fprintf(sb, "cycle for %s:\n", types.TypeString(a, nil))
 							for j := i; j < len(curr); j++ {
 								t := providerMap.At(curr[j]).(*ProvidedType)
-								if t.IsProvider() {
+								switch {
+								case t.IsProvider():
 									p := t.Provider()
 									fmt.Fprintf(sb, "%s (%s.%s) ->\n", types.TypeString(curr[j], nil), p.Pkg.Path(), p.Name)
-								} else {
+								case t.IsSlice():
+									fmt.Fprintf(sb, "%s (wire.Slice) ->\n", types.TypeString(curr[j], nil))
+								case t.IsMap():
+									fmt.Fprintf(sb, "%s (wire.Map) ->\n", types.TypeString(curr[j], nil))
+								default:
 									p := t.Field()
 									fmt.Fprintf(sb, "%s (%s.%s) ->\n", types.TypeString(curr[j], nil), p.Parent, p.Name)
 								}
@@ -507,6 +1377,12 @@ func verifyAcyclic(providerMap *typeutil.Map, hasher typeutil.Hasher) []error {
 	return ec.errors
 }
 
+// overridable reports whether nearest-wins precedence applies to the source
+// src. Slices, maps and keyed providers collect their entries instead.
+func overridable(src *providerSetSrc) bool {
+	return src.Slice == nil && src.Map == nil && src.Keyed == nil
+}
+
 // bindingConflictError creates a new error describing multiple bindings
 // for the same output type.
 func bindingConflictError(fset *token.FileSet, typ types.Type, set *ProviderSet, cur, prev *providerSetSrc) error {
@@ -517,5 +1393,191 @@ func bindingConflictError(fset *token.FileSet, typ types.Type, set *ProviderSet,
 	fmt.Fprintf(sb, "multiple bindings for %s\n", types.TypeString(typ, nil))
 	fmt.Fprintf(sb, "current:\n<- %s\n", strings.Join(cur.trace(fset, typ), "\n<- "))
 	fmt.Fprintf(sb, "previous:\n<- %s", strings.Join(prev.trace(fset, typ), "\n<- "))
-	return notePosition(fset.Position(set.Pos), errors.New(sb.String()))
+	cur, prev = cur.origin(typ), prev.origin(typ)
+	return notePosition(fset.Position(set.Pos), &conflictError{
+		msg:      sb.String(),
+		current:  cur.description(fset, typ),
+		previous: prev.description(fset, typ),
+		curPos:   cur.position(fset),
+		prevPos:  prev.position(fset),
+	})
+}
+
+// A conflictError is the error for multiple bindings of a type. It keeps the
+// positions of the conflicting bindings for structured output such as
+// EncodeSARIF.
+type conflictError struct {
+	msg               string
+	current, previous string
+	curPos, prevPos   token.Position
+}
+
+func (e *conflictError) Error() string {
+	return e.msg
+}
+
+// A MissingProviderError is the error for a type that an injector needs but
+// its provider set does not provide. If the set provides types whose names
+// are close to the missing type's, such as db.Conn for a missing
+// db.Connection or a type of the same name in another package, the error
+// suggests them, and tools can offer them as quick fixes.
+type MissingProviderError struct {
+	// Type is the missing type.
+	Type types.Type
+	// Suggestions are up to three provided types that may have been meant
+	// instead of Type, closest first.
+	Suggestions []ProviderSuggestion
+	// SuggestedFixes are changes to the injector that would provide Type,
+	// for the cases where one change is clearly what is missing, such as a
+	// wire.Bind to the only provided type that implements an interface.
+	SuggestedFixes []SuggestedFix
+
+	msg string
+}
+
+// A ProviderSuggestion is a provided type that a MissingProviderError
+// suggests in place of the missing one.
+type ProviderSuggestion struct {
+	Type types.Type
+	// Source describes what provides Type as error messages do, for
+	// example `provider "NewConn" (db.go:12:6)`, and Pos is its position.
+	Source string
+	Pos    token.Position
+}
+
+func (e *MissingProviderError) Error() string {
+	return e.msg
+}
+
+// maxSuggestions is the most types that a MissingProviderError suggests.
+const maxSuggestions = 3
+
+// missingProviderError returns the error for typ, which set does not
+// provide, with the message msg followed by a line for each suggestion.
+func missingProviderError(fset *token.FileSet, set *ProviderSet, typ types.Type, msg string) error {
+	type scored struct {
+		t     types.Type
+		name  string
+		score int
+	}
+	var candidates []scored
+	for _, t := range set.Outputs() {
+		if score, ok := typoDistance(typ, t); ok {
+			candidates = append(candidates, scored{t, types.TypeString(t, nil), score})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score < candidates[j].score
+		}
+		return candidates[i].name < candidates[j].name
+	})
+	if len(candidates) > maxSuggestions {
+		candidates = candidates[:maxSuggestions]
+	}
+	e := &MissingProviderError{Type: typ}
+	sb := new(strings.Builder)
+	sb.WriteString(msg)
+	for _, c := range candidates {
+		src := set.srcMap.At(c.t).(*providerSetSrc).origin(c.t)
+		sug := ProviderSuggestion{
+			Type:   c.t,
+			Source: src.description(fset, c.t),
+			Pos:    src.position(fset),
+		}
+		e.Suggestions = append(e.Suggestions, sug)
+		fmt.Fprintf(sb, "\ndid you mean %s, from %s?", c.name, sug.Source)
+	}
+	e.msg = sb.String()
+	return e
+}
+
+// typoDistance reports whether want, a missing type, may be a typo for
+// have, a provided one, and if so how far apart they are. Both must be
+// named types or pointers to them. Their names must be close in the same
+// package, where the shorter name may also be a prefix of the other, or
+// equal in different packages. Types that differ only in pointers or type
+// arguments are close too.
+func typoDistance(want, have types.Type) (int, bool) {
+	wantObj, wantPtrs := namedObj(want)
+	haveObj, havePtrs := namedObj(have)
+	if wantObj == nil || haveObj == nil || wantObj.Pkg() == nil || haveObj.Pkg() == nil {
+		return 0, false
+	}
+	dist := wantPtrs - havePtrs
+	if dist < 0 {
+		dist = -dist
+	}
+	wantName, haveName := wantObj.Name(), haveObj.Name()
+	if wantObj.Pkg().Path() != haveObj.Pkg().Path() {
+		return dist + 1, wantName == haveName
+	}
+	d := editDistance(strings.ToLower(wantName), strings.ToLower(haveName))
+	short, long := strings.ToLower(wantName), strings.ToLower(haveName)
+	if len(short) > len(long) {
+		short, long = long, short
+	}
+	// Short names that are close, like Bar and Baz, are usually different
+	// names rather than typos.
+	maxDist := 0
+	switch {
+	case len(short) >= 8:
+		maxDist = 2
+	case len(short) >= 5:
+		maxDist = 1
+	}
+	ok := d <= maxDist || (len(short) >= 4 && strings.HasPrefix(long, short))
+	return dist + d, ok
+}
+
+// namedObj returns the type name of t after removing any pointers, and the
+// number of pointers removed. It returns nil if t is not a named type or a
+// pointer to one.
+func namedObj(t types.Type) (*types.TypeName, int) {
+	ptrs := 0
+	for {
+		p, ok := t.(*types.Pointer)
+		if !ok {
+			break
+		}
+		t = p.Elem()
+		ptrs++
+	}
+	named, ok := t.(*types.Named)
+	if !ok {
+		return nil, 0
+	}
+	return named.Obj(), ptrs
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
 }
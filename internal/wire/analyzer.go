@@ -0,0 +1,301 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"go/ast"
+	"go/build/constraint"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+const analyzerDoc = `report misuse of the wire marker functions
+
+The wirex analyzer reports problems that can be found without solving any
+provider graphs:
+
+  - wire.Build in a file that is not constrained to wireinject builds, or
+    to builds with the tag given by the -build_tag flag
+  - injectors whose body is more than the wire.Build placeholder
+  - wire marker functions called at run time, outside of an injector or a
+    provider set declaration
+  - wire.Struct calls naming fields that the struct does not have
+  - wire.Bind, wire.Slice and wire.Map arguments that are not new(T)
+    expressions`
+
+// Analyzer reports misuse of the wire marker functions that can be found
+// without a full solve. It is suitable for use with go vet -vettool.
+var Analyzer = &analysis.Analyzer{
+	Name: "wirex",
+	Doc:  analyzerDoc,
+	Run:  runAnalyzer,
+}
+
+// analyzerBuildTag is the build tag of injector files, set by the
+// analyzer's -build_tag flag.
+var analyzerBuildTag = defaultBuildTag
+
+func init() {
+	Analyzer.Flags.StringVar(&analyzerBuildTag, "build_tag", defaultBuildTag, "build tag of injector files")
+}
+
+func runAnalyzer(pass *analysis.Pass) (interface{}, error) {
+	for _, f := range pass.Files {
+		injectorFile := requiresTag(fileConstraint(f), analyzerBuildTag)
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				checkMarkerCalls(pass, decl)
+				continue
+			}
+			buildCall, err := findInjectorBuild(pass.TypesInfo, fn)
+			if err != nil {
+				pass.Reportf(fn.Name.Pos(), "injector %s: %v", fn.Name.Name, err)
+			}
+			if buildCall == nil && err == nil {
+				reportRuntimeMarkerCalls(pass, fn)
+				continue
+			}
+			if !injectorFile {
+				pass.Reportf(fn.Name.Pos(), "injector %s is declared in a file that is not constrained to %s builds", fn.Name.Name, analyzerBuildTag)
+			}
+			checkMarkerCalls(pass, fn)
+		}
+	}
+	return nil, nil
+}
+
+// reportRuntimeMarkerCalls reports calls to wire marker functions inside the
+// body of fn, which is not an injector. The marker functions do nothing when
+// called, so these calls are mistakes.
+func reportRuntimeMarkerCalls(pass *analysis.Pass, fn *ast.FuncDecl) {
+	if fn.Body == nil {
+		return
+	}
+	ast.Inspect(fn.Body, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		if name := markerName(pass.TypesInfo, call); name != "" {
+			pass.Reportf(call.Pos(), "wire.%s called at run time in %s; marker functions may only be used in injectors and provider set declarations", name, fn.Name.Name)
+			return false
+		}
+		return true
+	})
+}
+
+// checkMarkerCalls validates the arguments of the wire marker calls in node.
+func checkMarkerCalls(pass *analysis.Pass, node ast.Node) {
+	ast.Inspect(node, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		switch markerName(pass.TypesInfo, call) {
+		case "Bind":
+			for _, arg := range call.Args {
+				if !isNewCall(pass.TypesInfo, arg) {
+					pass.Reportf(arg.Pos(), "argument to wire.Bind must be a new(T) expression")
+				}
+			}
+		case "CleanupInto":
+			if len(call.Args) != 1 || !isNewCall(pass.TypesInfo, call.Args[0]) {
+				pass.Reportf(call.Pos(), "argument to wire.CleanupInto must be a new(T) expression")
+			}
+		case "Slice", "Map":
+			if len(call.Args) != 1 || !isNewCall(pass.TypesInfo, call.Args[0]) {
+				pass.Reportf(call.Pos(), "argument to wire.%s must be a new(T) expression", markerName(pass.TypesInfo, call))
+			}
+		case "Struct":
+			if len(call.Args) == 0 || !isNewCall(pass.TypesInfo, call.Args[0]) {
+				pass.Reportf(call.Pos(), "first argument to wire.Struct must be a new(T) expression")
+				break
+			}
+			if _, err := processStructProvider(pass.Fset, pass.TypesInfo, call); err != nil {
+				if w, ok := err.(*wireErr); ok {
+					err = w.error
+				}
+				pass.Reportf(call.Pos(), "%v", err)
+			}
+		}
+		return true
+	})
+}
+
+// markerName returns the name of the wire marker function that call
+// invokes, or the empty string if call is not a marker call.
+func markerName(info *types.Info, call *ast.CallExpr) string {
+	obj := qualifiedIdentObject(info, call.Fun)
+	if _, ok := obj.(*types.Func); !ok || obj.Pkg() == nil || !isWireImport(obj.Pkg().Path()) {
+		return ""
+	}
+	if obj.Name() == "NewCleanups" {
+		// Generated injectors call it at run time.
+		return ""
+	}
+	return obj.Name()
+}
+
+// isNewCall reports whether expr is a call to the new builtin.
+func isNewCall(info *types.Info, expr ast.Expr) bool {
+	call, ok := astutil.Unparen(expr).(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	return qualifiedIdentObject(info, call.Fun) == types.Universe.Lookup("new")
+}
+
+// fileConstraint returns the build constraint of f, or nil if f has none.
+// A //go:build line takes precedence over // +build lines.
+func fileConstraint(f *ast.File) constraint.Expr {
+	var plusBuild constraint.Expr
+	for _, group := range f.Comments {
+		if group.Pos() >= f.Package {
+			break
+		}
+		for _, c := range group.List {
+			switch {
+			case constraint.IsGoBuild(c.Text):
+				if x, err := constraint.Parse(c.Text); err == nil {
+					return x
+				}
+			case constraint.IsPlusBuild(c.Text):
+				x, err := constraint.Parse(c.Text)
+				if err != nil {
+					continue
+				}
+				if plusBuild == nil {
+					plusBuild = x
+				} else {
+					plusBuild = &constraint.AndExpr{X: plusBuild, Y: x}
+				}
+			}
+		}
+	}
+	return plusBuild
+}
+
+// requiresTag reports whether x can only be satisfied when tag is set.
+func requiresTag(x constraint.Expr, tag string) bool {
+	return x != nil && !satisfiable(x, tag, false)
+}
+
+// excludesTag reports whether x can only be satisfied when tag is not set.
+func excludesTag(x constraint.Expr, tag string) bool {
+	return x != nil && !satisfiable(x, tag, true)
+}
+
+// maxConstraintTags bounds the number of tags satisfiable searches over.
+// Deciding satisfiability takes time exponential in the number of tags, so
+// beyond this many the answer is treated as unknown.
+const maxConstraintTags = 12
+
+// satisfiable reports whether some assignment of the other tags in x
+// satisfies x when tag has the given value. It reports true if x still
+// mentions more than maxConstraintTags other tags once tag is substituted,
+// so requiresTag and excludesTag only hold when they can be shown to.
+func satisfiable(x constraint.Expr, tag string, value bool) bool {
+	x, known := assignTag(x, tag, value)
+	if x == nil {
+		return known
+	}
+	var others []string
+	seen := make(map[string]bool)
+	x.Eval(func(t string) bool {
+		if !seen[t] {
+			seen[t] = true
+			others = append(others, t)
+		}
+		return false
+	})
+	if len(others) > maxConstraintTags {
+		return true
+	}
+	return searchTags(x, others)
+}
+
+// searchTags reports whether some assignment of tags satisfies x, which
+// mentions no other tags. It assigns the tags one at a time, simplifying x
+// after each so that it can stop as soon as x becomes constant.
+func searchTags(x constraint.Expr, tags []string) bool {
+	for _, value := range []bool{true, false} {
+		y, known := assignTag(x, tags[0], value)
+		if y == nil {
+			if known {
+				return true
+			}
+			continue
+		}
+		if searchTags(y, tags[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// assignTag returns x simplified with tag replaced by value. If that makes x
+// constant, assignTag returns nil and the constant instead.
+func assignTag(x constraint.Expr, tag string, value bool) (constraint.Expr, bool) {
+	switch x := x.(type) {
+	case *constraint.TagExpr:
+		if x.Tag == tag {
+			return nil, value
+		}
+	case *constraint.NotExpr:
+		y, known := assignTag(x.X, tag, value)
+		if y == nil {
+			return nil, !known
+		}
+		if y != x.X {
+			return &constraint.NotExpr{X: y}, false
+		}
+	case *constraint.AndExpr:
+		l, lv := assignTag(x.X, tag, value)
+		if l == nil && !lv {
+			return nil, false
+		}
+		r, rv := assignTag(x.Y, tag, value)
+		switch {
+		case r == nil && !rv:
+			return nil, false
+		case l == nil:
+			return r, rv
+		case r == nil:
+			return l, false
+		case l != x.X || r != x.Y:
+			return &constraint.AndExpr{X: l, Y: r}, false
+		}
+	case *constraint.OrExpr:
+		l, lv := assignTag(x.X, tag, value)
+		if l == nil && lv {
+			return nil, true
+		}
+		r, rv := assignTag(x.Y, tag, value)
+		switch {
+		case r == nil && rv:
+			return nil, true
+		case l == nil:
+			return r, rv
+		case r == nil:
+			return l, false
+		case l != x.X || r != x.Y:
+			return &constraint.OrExpr{X: l, Y: r}, false
+		}
+	}
+	return x, false
+}
@@ -0,0 +1,148 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	// The analyzer test cases live under testdata/_analyzer so that TestWire
+	// skips them. They are loaded in GOPATH mode, so the marker package
+	// needs to be copied into the same GOPATH.
+	gopath := t.TempDir()
+	src := filepath.Join("testdata", "_analyzer")
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return writeFile(filepath.Join(gopath, rel), data)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wireGo, err := ioutil.ReadFile(filepath.Join("..", "..", "wire.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(filepath.Join(gopath, "src", "github.com", "google", "wire", "wire.go"), wireGo); err != nil {
+		t.Fatal(err)
+	}
+	// Load injector files too, so that their bodies are checked.
+	t.Setenv("GOFLAGS", "-tags=wireinject")
+	analysistest.Run(analysisT{t}, gopath, Analyzer, "example.com/a")
+}
+
+// analysisT passes test failures through to t. analysistest.Run skips any
+// testing.TB on Go 1.24 and later because of an export data change, but the
+// packages here are loaded from source, so only t's Errorf is exposed.
+type analysisT struct {
+	t *testing.T
+}
+
+func (at analysisT) Errorf(format string, args ...interface{}) {
+	at.t.Helper()
+	at.t.Errorf(format, args...)
+}
+
+func TestRequiresTag(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"package p", false},
+		{"//go:build wireinject\n\npackage p", true},
+		{"// +build wireinject\n\npackage p", true},
+		{"//go:build !wireinject\n\npackage p", false},
+		{"//go:build wireinject && linux\n\npackage p", true},
+		{"//go:build wireinject || linux\n\npackage p", false},
+		{"//go:build (wireinject || tools) && !tools\n\npackage p", true},
+		{"// +build wireinject,linux\n\npackage p", true},
+		{"// +build linux\n// +build wireinject\n\npackage p", true},
+		{"//go:build wireinject && (" + tagList("t", 30, " || ") + ")\n\npackage p", true},
+		{"//go:build wireinject || (" + tagList("t", 30, " && ") + ")\n\npackage p", false},
+		// Too many tags to tell, so not known to require wireinject.
+		{"//go:build (wireinject || " + tagList("t", 30, " || ") + ") && " + tagList("!t", 30, " && ") + "\n\npackage p", false},
+	}
+	for _, test := range tests {
+		f, err := parser.ParseFile(token.NewFileSet(), "p.go", test.src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := requiresTag(fileConstraint(f), "wireinject"); got != test.want {
+			t.Errorf("requiresTag(%q) = %t; want %t", test.src, got, test.want)
+		}
+	}
+}
+
+func TestExcludesTag(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"package p", false},
+		{"//go:build wireinject\n\npackage p", false},
+		{"//go:build !wireinject\n\npackage p", true},
+		{"// +build !wireinject\n\npackage p", true},
+		{"//go:build !wireinject && linux\n\npackage p", true},
+		{"//go:build !wireinject || linux\n\npackage p", false},
+		{"//go:build !wireinject && (" + tagList("t", 30, " || ") + ")\n\npackage p", true},
+		{"//go:build !wireinject || (" + tagList("t", 30, " && ") + ")\n\npackage p", false},
+		// Too many tags to tell, so not known to exclude wireinject.
+		{"//go:build (!wireinject || " + tagList("t", 30, " || ") + ") && " + tagList("!t", 30, " && ") + "\n\npackage p", false},
+	}
+	for _, test := range tests {
+		f, err := parser.ParseFile(token.NewFileSet(), "p.go", test.src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := excludesTag(fileConstraint(f), "wireinject"); got != test.want {
+			t.Errorf("excludesTag(%q) = %t; want %t", test.src, got, test.want)
+		}
+	}
+}
+
+// tagList returns n tags named prefix0, prefix1, ... joined by sep.
+func tagList(prefix string, n int, sep string) string {
+	tags := make([]string, n)
+	for i := range tags {
+		tags[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+	return strings.Join(tags, sep)
+}
+
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0666)
+}
@@ -16,16 +16,58 @@ package wire
 
 import (
     "context"
+    "fmt"
     "path/filepath"
-    "runtime"
+    "strings"
+    "sync"
+    "sync/atomic"
     "testing"
+    "time"
+
+    "golang.org/x/tools/go/packages"
 )
 
+// replayOptions returns opts with the package loads of a run of Generate
+// on the Chain test case replayed from a snapshot, so that the benchmarks
+// measure parsing, solving and code generation without the variance of go
+// list. BenchmarkLoad measures loading.
+func replayOptions(b *testing.B, wd string, opts GenerateOptions) *GenerateOptions {
+    b.Helper()
+    snap := new(loadSnapshot)
+    recording := opts
+    recording.loader = snap.record(packages.Load)
+    if _, errs := Generate(context.Background(), wd, nil, []string{"."}, &recording); len(errs) > 0 {
+        b.Fatalf("Generate failed: %v", errs)
+    }
+    opts.loader = snap.replay(b.TempDir())
+    return &opts
+}
+
+// loadStatsMetrics makes opts keep the LoadStats of its last run and returns
+// a function that reports them as metrics of b, to compare the packages
+// loaded with and without LazyLoad.
+func loadStatsMetrics(b *testing.B, opts *GenerateOptions) func() {
+    var last LoadStats
+    opts.LoadStats = func(s LoadStats) { last = s }
+    return func() {
+        var d time.Duration
+        for _, l := range last.Loads {
+            d += l.Duration
+        }
+        b.ReportMetric(float64(last.PackagesLoadedEager), "eager-pkgs")
+        b.ReportMetric(float64(last.PackagesLoadedLazy), "lazy-pkgs")
+        b.ReportMetric(float64(last.PackagesRequested), "requested-pkgs")
+        b.ReportMetric(float64(len(last.Loads)), "loads")
+        b.ReportMetric(float64(d.Nanoseconds()), "load-ns")
+    }
+}
+
 // BenchmarkGenerate benchmarks the standard Generate function.
 func BenchmarkGenerate(b *testing.B) {
     ctx := context.Background()
     wd := filepath.Join("testdata", "Chain", "foo")
-    opts := &GenerateOptions{}
+    opts := replayOptions(b, wd, GenerateOptions{})
+    report := loadStatsMetrics(b, opts)
 
     b.ResetTimer()
     for i := 0; i < b.N; i++ {
@@ -34,39 +76,176 @@ func BenchmarkGenerate(b *testing.B) {
             b.Fatalf("Generate failed: %v", errs)
         }
     }
+    report()
 }
 
-// BenchmarkGenerateOptimized benchmarks the optimized Generate function.
-func BenchmarkGenerateOptimized(b *testing.B) {
+// BenchmarkGenerateParallel benchmarks Generate with one worker per CPU.
+func BenchmarkGenerateParallel(b *testing.B) {
     ctx := context.Background()
     wd := filepath.Join("testdata", "Chain", "foo")
-    opts := &GenerateOptions{}
+    opts := replayOptions(b, wd, GenerateOptions{Concurrency: -1})
 
     b.ResetTimer()
     for i := 0; i < b.N; i++ {
-        _, errs := GenerateOptimized(ctx, wd, nil, []string{"."}, opts)
+        _, errs := Generate(ctx, wd, nil, []string{"."}, opts)
         if len(errs) > 0 {
-            b.Fatalf("GenerateOptimized failed: %v", errs)
+            b.Fatalf("Generate failed: %v", errs)
         }
     }
 }
 
-// BenchmarkGenerateParallel benchmarks the parallel Generate function.
-func BenchmarkGenerateParallel(b *testing.B) {
+// BenchmarkGenerateManyPackages compares Generate on 20 packages with one
+// worker and with one worker per CPU. Each worker formats the output of the
+// packages it generates, so formatting is part of what runs in parallel.
+func BenchmarkGenerateManyPackages(b *testing.B) {
+    const numPkgs = 20
+    files := make(map[string]string)
+    for i := 0; i < numPkgs; i++ {
+        pkg := fmt.Sprintf("example.com/p%d", i)
+        files[pkg+"/foo.go"] = `package main
+
+func main() {}
+
+type Foo int
+type Bar int
+type Baz int
+
+func provideFoo() Foo { return 41 }
+func provideBar(foo Foo) Bar { return Bar(foo) + 1 }
+func provideBaz(foo Foo, bar Bar) (Baz, func(), error) { return Baz(foo + Foo(bar)), func() {}, nil }
+`
+        files[pkg+"/wire.go"] = `//go:build wireinject
+
+package main
+
+import "github.com/google/wire"
+
+func injectBar() Bar {
+	panic(wire.Build(provideFoo, provideBar))
+}
+
+func injectBaz() (Baz, func(), error) {
+	panic(wire.Build(provideFoo, provideBar, provideBaz))
+}
+`
+    }
+    wd, env := materialize(b, files)
+    patterns := []string{"example.com/..."}
+
     ctx := context.Background()
-    wd := filepath.Join("testdata", "Chain", "foo")
-    opts := &GenerateOptions{}
-    maxWorkers := runtime.GOMAXPROCS(0)
+    snap := new(loadSnapshot)
+    recording := &GenerateOptions{loader: snap.record(packages.Load)}
+    if _, errs := Generate(ctx, wd, env, patterns, recording); len(errs) > 0 {
+        b.Fatalf("Generate failed: %v", errs)
+    }
+    replay := snap.replay(b.TempDir())
 
-    b.ResetTimer()
-    for i := 0; i < b.N; i++ {
-        _, errs := GenerateParallel(ctx, wd, nil, []string{"."}, opts, maxWorkers)
-        if len(errs) > 0 {
-            b.Fatalf("GenerateParallel failed: %v", errs)
+    for _, concurrency := range []int{1, -1} {
+        name := "Serial"
+        if concurrency != 1 {
+            name = "Parallel"
         }
+        opts := &GenerateOptions{Concurrency: concurrency, loader: replay}
+        b.Run(name, func(b *testing.B) {
+            for i := 0; i < b.N; i++ {
+                outs, errs := Generate(ctx, wd, env, patterns, opts)
+                if len(errs) > 0 {
+                    b.Fatalf("Generate failed: %v", errs)
+                }
+                if len(outs) != numPkgs {
+                    b.Fatalf("Generate returned %d results; want %d", len(outs), numPkgs)
+                }
+            }
+        })
     }
 }
 
+// BenchmarkGenerateMulti compares Generate called for two working
+// directories in turn with GenerateMulti for both. Neither replays a
+// snapshot, since GenerateMulti saves on loading.
+func BenchmarkGenerateMulti(b *testing.B) {
+    ctx := context.Background()
+    dirs := []string{
+        filepath.Join("testdata", "Chain", "foo"),
+        filepath.Join("testdata", "Cleanup", "foo"),
+    }
+    opts := &GenerateOptions{Concurrency: -1}
+
+    b.Run("Sequential", func(b *testing.B) {
+        for i := 0; i < b.N; i++ {
+            for _, dir := range dirs {
+                if _, errs := Generate(ctx, dir, nil, []string{"."}, opts); len(errs) > 0 {
+                    b.Fatalf("Generate failed: %v", errs)
+                }
+            }
+        }
+    })
+    b.Run("Multi", func(b *testing.B) {
+        for i := 0; i < b.N; i++ {
+            roots, errs := GenerateMulti(ctx, dirs, nil, []string{"."}, opts)
+            if len(errs) > 0 {
+                b.Fatalf("GenerateMulti failed: %v", errs)
+            }
+            for _, root := range roots {
+                if len(root.Errs) > 0 {
+                    b.Fatalf("GenerateMulti failed in %s: %v", root.Dir, root.Errs)
+                }
+            }
+        }
+    })
+}
+
+// BenchmarkMarkerCalls benchmarks Generate on a package whose provider set
+// and injectors make many calls to the wire marker functions, each of which
+// Wire checks against the wire package. IsWireImport measures the check on
+// its own, for the import paths of the package and its dependencies.
+func BenchmarkMarkerCalls(b *testing.B) {
+    const numTypes = 200
+    var src, set, inject strings.Builder
+    src.WriteString("package main\n\nfunc main() {}\n\ntype Runner interface{ Run() }\n")
+    for i := 0; i < numTypes; i++ {
+        fmt.Fprintf(&src, "\ntype T%d struct{ N int }\n\nfunc (*T%d) Run() {}\n\ntype R%d interface{ Run() }\n", i, i, i)
+        fmt.Fprintf(&set, "\twire.Struct(new(T%d), \"*\"),\n\twire.Bind(new(R%d), new(*T%d)),\n", i, i, i)
+        fmt.Fprintf(&inject, "\nfunc injectR%d(n int) R%d {\n\tpanic(wire.Build(Set))\n}\n", i, i)
+    }
+    wd, env := materialize(b, map[string]string{
+        "example.com/foo/foo.go": src.String(),
+        "example.com/foo/wire.go": "//go:build wireinject\n\npackage main\n\nimport \"github.com/google/wire\"\n\nvar Set = wire.NewSet(\n" +
+            set.String() + ")\n" + inject.String(),
+    })
+    ctx := context.Background()
+    snap := new(loadSnapshot)
+    recording := &GenerateOptions{loader: snap.record(packages.Load)}
+    gens, errs := Generate(ctx, wd, env, []string{"example.com/foo"}, recording)
+    if len(errs) > 0 {
+        b.Fatalf("Generate failed: %v", errs)
+    }
+    if len(gens[0].Errs) > 0 {
+        b.Fatalf("Generate failed: %v", gens[0].Errs)
+    }
+    var paths []string
+    packages.Visit([]*packages.Package{gens[0].pkg}, func(p *packages.Package) bool {
+        paths = append(paths, p.PkgPath)
+        return true
+    }, nil)
+    opts := &GenerateOptions{loader: snap.replay(b.TempDir())}
+
+    b.Run("Generate", func(b *testing.B) {
+        for i := 0; i < b.N; i++ {
+            if _, errs := Generate(ctx, wd, env, []string{"example.com/foo"}, opts); len(errs) > 0 {
+                b.Fatalf("Generate failed: %v", errs)
+            }
+        }
+    })
+    b.Run("IsWireImport", func(b *testing.B) {
+        for i := 0; i < b.N; i++ {
+            for _, path := range paths {
+                isWireImport(path)
+            }
+        }
+    })
+}
+
 // BenchmarkProviderSetCache benchmarks the cache operations.
 func BenchmarkProviderSetCache(b *testing.B) {
     cache := NewProviderSetCache()
@@ -77,36 +256,112 @@ func BenchmarkProviderSetCache(b *testing.B) {
         PkgPath: "example.com/test",
         VarName: "TestSet",
     }
+    key := ProviderSetKey{PkgPath: "example.com/test", VarName: "TestSet", Filename: files[0]}
+    missKey := ProviderSetKey{PkgPath: "example.com/nonexistent", VarName: "TestSet", Filename: files[0]}
 
     b.Run("CacheSet", func(b *testing.B) {
         for i := 0; i < b.N; i++ {
-            cache.CacheSet("example.com/test", "TestSet", dummySet, files)
+            cache.CacheSet(key, dummySet, files)
         }
     })
 
     b.Run("GetCachedSet_Hit", func(b *testing.B) {
-        cache.CacheSet("example.com/test", "TestSet", dummySet, files)
+        cache.CacheSet(key, dummySet, files)
         b.ResetTimer()
         for i := 0; i < b.N; i++ {
-            _, _ = cache.GetCachedSet("example.com/test", "TestSet", files)
+            _, _ = cache.GetCachedSet(key, files)
         }
     })
 
     b.Run("GetCachedSetFast_Hit", func(b *testing.B) {
-        cache.CacheSet("example.com/test", "TestSet", dummySet, files)
+        cache.CacheSet(key, dummySet, files)
         b.ResetTimer()
         for i := 0; i < b.N; i++ {
-            _, _ = cache.GetCachedSetFast("example.com/test", "TestSet", files)
+            _, _ = cache.GetCachedSetFast(key, files)
         }
     })
 
     b.Run("GetCachedSet_Miss", func(b *testing.B) {
         for i := 0; i < b.N; i++ {
-            _, _ = cache.GetCachedSet("example.com/nonexistent", "TestSet", files)
+            _, _ = cache.GetCachedSet(missKey, files)
+        }
+    })
+
+    // The parallel benchmarks spread lookups over many keys, as
+    // GenerateParallel does, so that they measure contention between
+    // workers rather than on a single entry.
+    keys := make([]ProviderSetKey, 256)
+    for i := range keys {
+        keys[i] = ProviderSetKey{PkgPath: fmt.Sprintf("example.com/test%d", i), VarName: "TestSet", Filename: files[0]}
+        cache.CacheSet(keys[i], dummySet, files)
+    }
+    missKeys := make([]ProviderSetKey, len(keys))
+    for i := range missKeys {
+        missKeys[i] = ProviderSetKey{PkgPath: fmt.Sprintf("example.com/nonexistent%d", i), VarName: "TestSet", Filename: files[0]}
+    }
+    runParallel := func(name string, op func(i int)) {
+        b.Run(name, func(b *testing.B) {
+            var next uint32
+            b.RunParallel(func(pb *testing.PB) {
+                // Start each goroutine at a different key.
+                i := int(atomic.AddUint32(&next, 97))
+                for pb.Next() {
+                    op(i)
+                    i++
+                }
+            })
+        })
+    }
+    runParallel("Parallel_Hit", func(i int) {
+        _, _ = cache.GetCachedSetFast(keys[i%len(keys)], files)
+    })
+    runParallel("Parallel_Miss", func(i int) {
+        _, _ = cache.GetCachedSetFast(missKeys[i%len(missKeys)], files)
+    })
+    runParallel("Parallel_Set", func(i int) {
+        cache.CacheSet(keys[i%len(keys)], dummySet, files)
+    })
+    runParallel("Parallel_Mixed", func(i int) {
+        // Mostly hits, with some misses and a few sets.
+        switch k := i % 16; {
+        case k == 0:
+            cache.CacheSet(keys[i%len(keys)], dummySet, files)
+        case k < 4:
+            _, _ = cache.GetCachedSetFast(missKeys[i%len(missKeys)], files)
+        default:
+            _, _ = cache.GetCachedSetFast(keys[i%len(keys)], files)
         }
     })
 }
 
+// BenchmarkWarmCache benchmarks Generate with an empty build cache, with
+// and without a run of WarmCache beforehand, which is not timed.
+func BenchmarkWarmCache(b *testing.B) {
+    ctx := context.Background()
+    wd := filepath.Join("testdata", "Chain", "foo")
+    for _, warm := range []bool{false, true} {
+        name := "Cold"
+        if warm {
+            name = "Warmed"
+        }
+        b.Run(name, func(b *testing.B) {
+            for i := 0; i < b.N; i++ {
+                b.StopTimer()
+                opts := &GenerateOptions{GoCache: b.TempDir()}
+                if warm {
+                    if _, errs := WarmCache(ctx, wd, nil, []string{"."}, opts, 0); len(errs) > 0 {
+                        b.Fatalf("WarmCache failed: %v", errs)
+                    }
+                }
+                b.StartTimer()
+                if _, errs := Generate(ctx, wd, nil, []string{"."}, opts); len(errs) > 0 {
+                    b.Fatalf("Generate failed: %v", errs)
+                }
+            }
+        })
+    }
+}
+
 // BenchmarkLoad benchmarks the package loading function.
 func BenchmarkLoad(b *testing.B) {
     ctx := context.Background()
@@ -114,42 +369,73 @@ func BenchmarkLoad(b *testing.B) {
 
     b.ResetTimer()
     for i := 0; i < b.N; i++ {
-        _, errs := load(ctx, wd, nil, "", []string{"."})
+        _, errs := load(ctx, wd, nil, defaultBuildTag, "", nil, []string{"."})
         if len(errs) > 0 {
             b.Fatalf("load failed: %v", errs)
         }
     }
 }
 
-// BenchmarkGenerateWithLazyLoad benchmarks the lazy loading Generate function.
+// BenchmarkListInjectors compares ListInjectors with Generate on the same
+// package. Neither replays a snapshot, since ListInjectors is meant to save
+// the loading that a snapshot leaves out.
+func BenchmarkListInjectors(b *testing.B) {
+    ctx := context.Background()
+    wd := filepath.Join("testdata", "Chain", "foo")
+
+    b.Run("ListInjectors", func(b *testing.B) {
+        for i := 0; i < b.N; i++ {
+            infos, errs := ListInjectors(ctx, wd, nil, []string{"."})
+            if len(errs) > 0 {
+                b.Fatalf("ListInjectors failed: %v", errs)
+            }
+            if len(infos) == 0 {
+                b.Fatal("ListInjectors found no injectors")
+            }
+        }
+    })
+    b.Run("Generate", func(b *testing.B) {
+        for i := 0; i < b.N; i++ {
+            _, errs := Generate(ctx, wd, nil, []string{"."}, &GenerateOptions{})
+            if len(errs) > 0 {
+                b.Fatalf("Generate failed: %v", errs)
+            }
+        }
+    })
+}
+
+// BenchmarkGenerateWithLazyLoad benchmarks Generate with lazy loading.
 func BenchmarkGenerateWithLazyLoad(b *testing.B) {
     ctx := context.Background()
     wd := filepath.Join("testdata", "Chain", "foo")
-    opts := &GenerateOptions{}
+    opts := replayOptions(b, wd, GenerateOptions{LazyLoad: true})
+    report := loadStatsMetrics(b, opts)
 
     b.ResetTimer()
     for i := 0; i < b.N; i++ {
-        _, errs := GenerateWithLazyLoad(ctx, wd, nil, []string{"."}, opts)
+        _, errs := Generate(ctx, wd, nil, []string{"."}, opts)
         if len(errs) > 0 {
-            b.Fatalf("GenerateWithLazyLoad failed: %v", errs)
+            b.Fatalf("Generate failed: %v", errs)
         }
     }
+    report()
 }
 
 // BenchmarkGenerateParallelWithLazyLoad benchmarks parallel + lazy loading.
 func BenchmarkGenerateParallelWithLazyLoad(b *testing.B) {
     ctx := context.Background()
     wd := filepath.Join("testdata", "Chain", "foo")
-    opts := &GenerateOptions{}
-    maxWorkers := runtime.GOMAXPROCS(0)
+    opts := replayOptions(b, wd, GenerateOptions{Concurrency: -1, LazyLoad: true})
+    report := loadStatsMetrics(b, opts)
 
     b.ResetTimer()
     for i := 0; i < b.N; i++ {
-        _, errs := GenerateParallelWithLazyLoad(ctx, wd, nil, []string{"."}, opts, maxWorkers)
+        _, errs := Generate(ctx, wd, nil, []string{"."}, opts)
         if len(errs) > 0 {
-            b.Fatalf("GenerateParallelWithLazyLoad failed: %v", errs)
+            b.Fatalf("Generate failed: %v", errs)
         }
     }
+    report()
 }
 
 // BenchmarkLazyLoadPackage benchmarks the lazy package loading.
@@ -158,14 +444,15 @@ func BenchmarkLazyLoadPackage(b *testing.B) {
     wd := filepath.Join("testdata", "Chain", "foo")
 
     // First load the initial packages
-    pkgs, errs := load(ctx, wd, nil, "", []string{"."})
+    imports := newImportCache(ctx, wd, nil, defaultBuildTag, "", nil)
+    pkgs, errs := imports.load([]string{"."})
     if len(errs) > 0 {
         b.Fatalf("load failed: %v", errs)
     }
 
     b.Run("WithLazyLoadEnabled", func(b *testing.B) {
         for i := 0; i < b.N; i++ {
-            oc := newObjectCacheWithLazyLoad(pkgs, ctx, wd, nil)
+            oc := newObjectCacheWithLazyLoad(pkgs, imports)
             // Try to get a package that's already loaded (fast path)
             _, _ = oc.getPackage(pkgs[0].PkgPath)
         }
@@ -177,4 +464,37 @@ func BenchmarkLazyLoadPackage(b *testing.B) {
             _, _ = oc.getPackage(pkgs[0].PkgPath)
         }
     })
+
+    // Many concurrent misses, as when several provider sets refer to
+    // packages that were not loaded up front. The misses share go list runs.
+    b.Run("ManyMisses", func(b *testing.B) {
+        misses := []string{
+            "container/list", "container/ring", "encoding", "image/color",
+            "math/bits", "unicode", "unicode/utf16", "unicode/utf8",
+        }
+        var mu sync.Mutex
+        launches := 0
+        for i := 0; i < b.N; i++ {
+            // A fresh importCache, so that every iteration misses.
+            oc := newObjectCacheWithLazyLoad(pkgs, newImportCache(ctx, wd, nil, defaultBuildTag, "", nil))
+            oc.loader.load = func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+                mu.Lock()
+                launches++
+                mu.Unlock()
+                return packages.Load(cfg, patterns...)
+            }
+            var wg sync.WaitGroup
+            for _, path := range misses {
+                wg.Add(1)
+                go func(path string) {
+                    defer wg.Done()
+                    if _, err := oc.getPackage(path); err != nil {
+                        b.Error(err)
+                    }
+                }(path)
+            }
+            wg.Wait()
+        }
+        b.ReportMetric(float64(launches)/float64(b.N), "loads/op")
+    })
 }
@@ -0,0 +1,191 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package wire
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// benchmarksFileSuffix takes the place of .go in the name of the generated
+// file to name the file of benchmarks that GenerateOptions.EmitBenchmarks
+// asks for.
+const benchmarksFileSuffix = "_bench_test.go"
+
+// benchmarkFixturePrefix starts the name of the function that provides the
+// arguments of an injector to its benchmark, such as
+// BenchmarkFixtureInitApp for InitApp.
+const benchmarkFixturePrefix = "BenchmarkFixture"
+
+// A generatedInjector is an injector that Wire generated code for.
+type generatedInjector struct {
+	pos token.Pos
+	// name is the name of the injector declaration, which warnings refer
+	// to, and genName the name of the generated function.
+	name    string
+	genName string
+	sig     *types.Signature
+	// once reports that the injector is marked with onceDirective.
+	once bool
+}
+
+// benchmarks returns the result for the file of benchmarks of the
+// injectors that g generated result for, along with warnings about their
+// fixtures.
+func (g *gen) benchmarks(result *GenerateResult, opts *GenerateOptions) (*GenerateResult, []error) {
+	bench := &GenerateResult{
+		PkgPath:    result.PkgPath,
+		OutputPath: strings.TrimSuffix(result.OutputPath, ".go") + benchmarksFileSuffix,
+		pkg:        result.pkg,
+		buildTag:   result.buildTag,
+		force:      result.force,
+	}
+	bg := newGen(g.pkg)
+	bg.constraint = g.constraint
+	bg.companion = true
+	testing := bg.qualifyImport("testing", "testing")
+	benchNames := make(map[string]bool)
+	var warnings []error
+	for _, inj := range g.generated {
+		name := disambiguate("Benchmark"+export(inj.genName), func(name string) bool {
+			// The benchmarks share the package scope with the generated file.
+			return benchNames[name] || bg.nameInFileScope(name) || g.nameInFileScope(name)
+		})
+		benchNames[name] = true
+		fixture, skip, err := g.benchmarkFixture(inj, result.buildTag)
+		if err != nil {
+			warnings = append(warnings, injectorError(g.pkg.Fset.Position(inj.pos), inj.name, notePosition(g.pkg.Fset.Position(fixture.Pos()), err)))
+		}
+		bg.p("func %s(b *%s.B) {\n", name, testing)
+		if skip != "" {
+			bg.p("\tb.Skip(%q)\n", skip)
+		} else {
+			bg.benchmarkBody(inj, fixture)
+		}
+		bg.p("}\n\n")
+	}
+	// Verify would not see the generated injectors that the benchmarks call.
+	bopts := *opts
+	bopts.VerifyOutput = false
+	bg.finish(bench, &bopts)
+	return bench, warnings
+}
+
+// benchmarkFixture returns the fixture function for the benchmark of inj,
+// or the reason that the benchmark skips. If the package declares a
+// fixture that does not suit inj, benchmarkFixture also returns it with an
+// error that says why.
+func (g *gen) benchmarkFixture(inj generatedInjector, buildTag string) (fixture *types.Func, skip string, _ error) {
+	if inj.once {
+		return nil, fmt.Sprintf("%s is marked %s, so only its first call builds anything", inj.genName, onceDirective), nil
+	}
+	name := benchmarkFixturePrefix + export(inj.genName)
+	fixture, _ = g.pkg.Types.Scope().Lookup(name).(*types.Func)
+	params := inj.sig.Params()
+	if fixture == nil {
+		if params.Len() == 0 {
+			return nil, "", nil
+		}
+		return nil, fmt.Sprintf("no %s function provides the arguments of %s", name, inj.genName), nil
+	}
+	skip = fmt.Sprintf("%s does not suit %s", name, inj.genName)
+	for _, f := range g.pkg.Syntax {
+		if f.Pos() <= fixture.Pos() && fixture.Pos() < f.End() && requiresTag(fileConstraint(f), buildTag) {
+			return fixture, skip, fmt.Errorf("benchmark fixture %s is declared in an injector file, which the benchmark is not built with", name)
+		}
+	}
+	sig := fixture.Type().(*types.Signature)
+	results := sig.Results()
+	ok := sig.TypeParams().Len() == 0 && sig.Params().Len() == 0 && results.Len() == params.Len()
+	for i := 0; ok && i < params.Len(); i++ {
+		ok = types.AssignableTo(results.At(i).Type(), params.At(i).Type())
+	}
+	if !ok {
+		want := make([]string, params.Len())
+		for i := range want {
+			want[i] = types.TypeString(params.At(i).Type(), g.describeQualifier)
+		}
+		err := errors.New("benchmark fixture " + name + " must take no arguments and return nothing")
+		if len(want) > 0 {
+			err = fmt.Errorf("benchmark fixture %s must take no arguments and return the arguments of %s: (%s)", name, inj.genName, strings.Join(want, ", "))
+		}
+		return fixture, skip, err
+	}
+	return fixture, "", nil
+}
+
+// benchmarkBody emits the body of the benchmark of inj, which calls
+// fixture, if not nil, for the injector's arguments.
+func (g *gen) benchmarkBody(inj generatedInjector, fixture *types.Func) {
+	// The locals must not hide the injector or the fixture.
+	taken := map[string]bool{"b": true, "i": true, inj.genName: true}
+	if fixture != nil {
+		taken[fixture.Name()] = true
+	}
+	local := func(name string) string {
+		name = disambiguate(name, func(name string) bool { return taken[name] })
+		taken[name] = true
+		return name
+	}
+	params := inj.sig.Params()
+	args := make([]string, params.Len())
+	for i := range args {
+		args[i] = local(fmt.Sprintf("arg%d", i))
+	}
+	if fixture != nil {
+		if len(args) > 0 {
+			g.p("\t%s := %s()\n", strings.Join(args, ", "), fixture.Name())
+		} else {
+			g.p("\t%s()\n", fixture.Name())
+		}
+	}
+	if inj.sig.Variadic() {
+		args[len(args)-1] += "..."
+	}
+	out, _ := funcOutput(inj.sig)
+	lhs := []string{"_"}
+	var cleanup, err string
+	if out.cleanup {
+		cleanup = local("cleanup")
+		lhs = append(lhs, cleanup)
+	}
+	if out.err {
+		err = local("err")
+		lhs = append(lhs, err)
+	}
+	g.p("\tb.ReportAllocs()\n")
+	g.p("\tb.ResetTimer()\n")
+	g.p("\tfor i := 0; i < b.N; i++ {\n")
+	call := fmt.Sprintf("%s(%s)", inj.genName, strings.Join(args, ", "))
+	if len(lhs) == 1 {
+		g.p("\t\t%s\n", call)
+	} else {
+		g.p("\t\t%s := %s\n", strings.Join(lhs, ", "), call)
+	}
+	if err != "" {
+		g.p("\t\tif %s != nil {\n", err)
+		g.p("\t\t\tb.Fatal(%s)\n", err)
+		g.p("\t\t}\n")
+	}
+	switch {
+	case out.namedCleanup:
+		g.p("\t\t%s.Close()\n", cleanup)
+	case out.cleanup:
+		g.p("\t\t%s()\n", cleanup)
+	}
+	g.p("\t}\n")
+}
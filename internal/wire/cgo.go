@@ -0,0 +1,67 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"go/ast"
+	"strconv"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// cgoGenerated reports whether f is a compiled file of pkg that cgo wrote
+// from scratch rather than translated from one of pkg's Go files.
+func cgoGenerated(pkg *packages.Package, f *ast.File) bool {
+	name := pkg.Fset.File(f.Pos()).Name()
+	return !cgoTranslated(pkg, f) && contains(pkg.CompiledGoFiles, name) && !contains(pkg.GoFiles, name)
+}
+
+// cgoTranslated reports whether f is cgo's translation of one of pkg's Go
+// files.
+func cgoTranslated(pkg *packages.Package, f *ast.File) bool {
+	return pkg.Fset.File(f.Pos()).Name() != pkg.Fset.Position(f.Pos()).Filename
+}
+
+// cgoImport reports whether impt, an import of f, is the one that cgo puts
+// in place of import "C" when it translates f. It is not part of the
+// package's source.
+func cgoImport(pkg *packages.Package, f *ast.File, impt *ast.ImportSpec) bool {
+	if impt.Name == nil || impt.Name.Name != "_" {
+		return false
+	}
+	path, err := strconv.Unquote(impt.Path.Value)
+	return err == nil && path == "unsafe" && cgoTranslated(pkg, f)
+}
+
+func contains(list []string, s string) bool {
+	for _, t := range list {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
+
+// importsC reports whether any of files imports "C".
+func importsC(files []*ast.File) bool {
+	for _, f := range files {
+		for _, impt := range f.Imports {
+			if impt.Path.Value == `"C"` {
+				return true
+			}
+		}
+	}
+	return false
+}
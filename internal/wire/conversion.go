@@ -0,0 +1,78 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// allowConversionDirective allows a provider function to only convert its
+// parameter to its result. It goes in the doc comment of the provider.
+const allowConversionDirective = "//wire:allow-conversion"
+
+// conversionFrom returns the type of the parameter of fn that the body of
+// fn only converts to its result, if that is all the body does and the
+// two are distinct named types with the same underlying type, as in
+//
+//	func provideSessionID(id UserID) SessionID { return SessionID(id) }
+//
+// It returns nil otherwise, and for a function whose doc comment has an
+// allowConversionDirective. Such a provider makes any value of one type
+// satisfy a need for the other, which is more often a mistake in the graph
+// than a real relation between the two.
+func (oc *objectCache) conversionFrom(fn *types.Func) types.Type {
+	pkg, _, path := oc.declPath(fn)
+	decl := enclosingFuncDecl(path)
+	if decl == nil || decl.Body == nil || len(decl.Body.List) != 1 || hasDirective(decl.Doc, allowConversionDirective) {
+		return nil
+	}
+	ret, ok := decl.Body.List[0].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) != 1 {
+		return nil
+	}
+	call, ok := astutil.Unparen(ret.Results[0]).(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 || !pkg.TypesInfo.Types[call.Fun].IsType() {
+		return nil
+	}
+	id, ok := astutil.Unparen(call.Args[0]).(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	param, ok := pkg.TypesInfo.Uses[id].(*types.Var)
+	if !ok || !isParam(fn, param) {
+		return nil
+	}
+	from, to := param.Type(), pkg.TypesInfo.Types[call.Fun].Type
+	_, fromNamed := unalias(from).(*types.Named)
+	_, toNamed := unalias(to).(*types.Named)
+	if !fromNamed || !toNamed || types.Identical(from, to) || !types.Identical(from.Underlying(), to.Underlying()) {
+		return nil
+	}
+	return from
+}
+
+// isParam reports whether v is a parameter of fn.
+func isParam(fn *types.Func, v *types.Var) bool {
+	params := fn.Type().(*types.Signature).Params()
+	for i := 0; i < params.Len(); i++ {
+		if params.At(i) == v {
+			return true
+		}
+	}
+	return false
+}
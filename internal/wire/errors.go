@@ -15,7 +15,9 @@
 package wire
 
 import (
+	"fmt"
 	"go/token"
+	"strings"
 )
 
 // errorCollector manages a list of errors. The zero value is an empty list.
@@ -82,3 +84,108 @@ func (w *wireErr) Error() string {
 	}
 	return w.position.String() + ": " + w.error.Error()
 }
+
+// Unwrap returns the error without its position, so that errors.As can
+// find an *InjectorError.
+func (w *wireErr) Unwrap() error {
+	return w.error
+}
+
+// An InjectorError is an error in one or more injectors. Generate reports
+// an error that several injectors of a package share, such as a type that
+// is missing from a provider set they all use, once for all of them.
+type InjectorError struct {
+	// Injectors are the names of the injectors that the error applies to,
+	// in the order they are declared.
+	Injectors []string
+	// Positions are the positions of the injectors, in the same order.
+	Positions []token.Position
+	// Err is the error, without an injector name or position.
+	Err error
+}
+
+// injectorError returns err as an error of the injector name at pos,
+// positioned at err's own position if it has one.
+func injectorError(pos token.Position, name string, err error) error {
+	ie := &InjectorError{Injectors: []string{name}, Positions: []token.Position{pos}, Err: err}
+	if w, ok := err.(*wireErr); ok {
+		ie.Err = w.error
+		return &wireErr{error: ie, position: w.position}
+	}
+	return &wireErr{error: ie, position: pos}
+}
+
+// Error returns the error message prefixed by the names of the injectors.
+func (e *InjectorError) Error() string {
+	return "inject " + strings.Join(e.Injectors, ", ") + ": " + e.Err.Error()
+}
+
+// Unwrap returns e.Err.
+func (e *InjectorError) Unwrap() error {
+	return e.Err
+}
+
+// groupErrors merges the InjectorErrors in errs that are the same apart
+// from the injector they apply to into one, at the position of the first.
+// Other errors are kept as they are.
+func groupErrors(errs []error) []error {
+	var grouped []error
+	index := make(map[string]int)
+	for _, err := range errs {
+		w, ok := err.(*wireErr)
+		if !ok {
+			grouped = append(grouped, err)
+			continue
+		}
+		ie, ok := w.error.(*InjectorError)
+		if !ok {
+			grouped = append(grouped, err)
+			continue
+		}
+		// The error is positioned at the injector unless its cause has a
+		// position of its own.
+		key := ie.Err.Error()
+		if w.position != ie.Positions[0] {
+			key = w.position.String() + ": " + key
+		}
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(grouped)
+			// Copy ie so that adding injectors to it does not modify err.
+			ie = &InjectorError{Injectors: ie.Injectors, Positions: ie.Positions, Err: ie.Err}
+			grouped = append(grouped, &wireErr{error: ie, position: w.position})
+			continue
+		}
+		g := grouped[i].(*wireErr).error.(*InjectorError)
+		g.Injectors = append(g.Injectors[:len(g.Injectors):len(g.Injectors)], ie.Injectors...)
+		g.Positions = append(g.Positions[:len(g.Positions):len(g.Positions)], ie.Positions...)
+	}
+	return grouped
+}
+
+// A LoadInterruptedError reports that loading packages stopped because its
+// context was canceled or its deadline passed, rather than because the
+// packages failed to load.
+type LoadInterruptedError struct {
+	// Err is the error of the context, such as context.DeadlineExceeded.
+	Err error
+	// Parsed is the number of packages that had been parsed when loading
+	// stopped.
+	Parsed int
+}
+
+func (e *LoadInterruptedError) Error() string {
+	return fmt.Sprintf("loading packages interrupted after %d packages were parsed: %v", e.Parsed, e.Err)
+}
+
+// Unwrap returns the context's error, so that errors.Is(err,
+// context.DeadlineExceeded) reports whether a load ran out of time.
+func (e *LoadInterruptedError) Unwrap() error {
+	return e.Err
+}
+
+// internalError returns the error for a panic recovered while analyzing the
+// named injector, positioned at the injector.
+func internalError(pos token.Position, injector string, r interface{}) error {
+	return injectorError(pos, injector, fmt.Errorf("internal error: %v; please report this as a bug", r))
+}
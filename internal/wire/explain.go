@@ -0,0 +1,314 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// An Explanation describes how an injector provides a type, as returned by
+// Explain.
+type Explanation struct {
+	Fset     *token.FileSet
+	Injector *Injector
+	Type     types.Type
+
+	// Chosen is what provides Type in the injector, or nil if the
+	// injector's provider set does not provide Type.
+	Chosen *ExplainedSource
+
+	// Bound explains the concrete type that Type is bound to if Chosen is
+	// a wire.Bind, and is nil otherwise.
+	Bound *Explanation
+
+	// Alternatives are the other sources of Type in the provider sets
+	// declared by the loaded packages, which the injector does not use,
+	// sorted by position.
+	Alternatives []*ExplainedSource
+}
+
+// An ExplainedSource is a provider, binding, value, field or injector
+// argument that provides a type.
+type ExplainedSource struct {
+	// Description describes the source as Wire's error messages do, for
+	// example `provider "NewLogger" (logger.go:12:6)`.
+	Description string
+	Pos         token.Pos
+
+	// Sets is the chain of provider sets that include the source, from the
+	// set that declares it to the outermost one. For the chosen source, the
+	// outermost set is the one built by the injector's call to wire.Build.
+	Sets []*ProviderSet
+
+	// Reason says why the injector does not use an alternative. It is
+	// empty for the chosen source.
+	Reason string
+}
+
+// Explain reports which provider the injector named injectorName, in the
+// package matching pattern, uses for the type typeExpr, through which
+// provider sets the injector includes it, and which other providers of the
+// type the loaded provider sets declare and why the injector does not use
+// them. typeExpr is a type expression as it would be written in the
+// injector's file, such as "Logger" or "*sql.DB".
+//
+// Wire reports a type that two providers in an injector's provider set
+// provide as a conflict rather than choosing one, so an alternative is
+// left out because the injector does not include it or because its
+// provider set is test-only, unless the package sets nearest_wins in its
+// //wire:options, in which case an alternative may also be overridden by
+// one that wire.Build includes through fewer provider sets. Only the
+// provider sets of the packages that pattern depends on are searched for
+// alternatives.
+//
+// wd and env are as for Generate.
+func Explain(ctx context.Context, wd string, env []string, pattern, injectorName, typeExpr string) (*Explanation, []error) {
+	pkgs, errs := load(ctx, wd, env, defaultBuildTag, "", nil, []string{pattern})
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if len(pkgs) != 1 {
+		return nil, []error{fmt.Errorf("pattern %q matches %d packages; want 1", pattern, len(pkgs))}
+	}
+	pkg := pkgs[0]
+	decls, errs := FindInjectors(pkg)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	var decl *InjectorDecl
+	for i := range decls {
+		if decls[i].Name() == injectorName {
+			decl = &decls[i]
+			break
+		}
+	}
+	if decl == nil {
+		return nil, []error{fmt.Errorf("package %s has no injector named %s", pkg.PkgPath, injectorName)}
+	}
+	fset := pkg.Fset
+	tv, err := types.Eval(fset, pkg.Types, decl.Build.Pos(), typeExpr)
+	if err != nil {
+		return nil, []error{fmt.Errorf("type %q: %v", typeExpr, err)}
+	}
+	if !tv.IsType() {
+		return nil, []error{fmt.Errorf("%q is not a type", typeExpr)}
+	}
+
+	fn := decl.Func
+	sig := pkg.TypesInfo.ObjectOf(fn.Name).Type().(*types.Signature)
+	ins, out, err := injectorFuncSignature(sig)
+	if err != nil {
+		return nil, []error{injectorError(fset.Position(fn.Pos()), fn.Name.Name, err)}
+	}
+	injectorArgs := &InjectorArgs{
+		Name:  fn.Name.Name,
+		Tuple: ins,
+		Pos:   fn.Pos(),
+	}
+	opts, errs := packageOptions(pkg, &GenerateOptions{})
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	oc := newObjectCache(pkgs)
+	oc.nearestWins = opts.NearestWins
+	set, errs := oc.processNewSet(pkg.TypesInfo, pkg.PkgPath, decl.Build, injectorArgs, "")
+	if len(errs) > 0 {
+		return nil, notePositionAll(fset.Position(fn.Pos()), errs)
+	}
+	if hasDirective(fn.Doc, autoBindDirective) {
+		if err := autoBind(oc.hasher, set, out.out, fn.Pos()); err != nil {
+			return nil, []error{injectorError(fset.Position(fn.Pos()), fn.Name.Name, err)}
+		}
+	}
+	ex := &explainer{
+		oc:       oc,
+		set:      set,
+		injector: &Injector{ImportPath: pkg.PkgPath, FuncName: fn.Name.Name},
+		testFile: strings.HasSuffix(fset.Position(fn.Pos()).Filename, "_test.go"),
+	}
+	return ex.explain(tv.Type), nil
+}
+
+// An explainer explains the types of one injector.
+type explainer struct {
+	oc       *objectCache
+	set      *ProviderSet
+	injector *Injector
+	// testFile reports whether the injector is in a _test.go file, and so
+	// may include test-only sets.
+	testFile bool
+}
+
+func (ex *explainer) explain(typ types.Type) *Explanation {
+	e := &Explanation{
+		Fset:     ex.oc.fset,
+		Injector: ex.injector,
+		Type:     typ,
+	}
+	var leaf *providerSetSrc
+	e.Chosen, leaf = explainSource(ex.oc.fset, ex.set, typ)
+	if leaf != nil && leaf.Binding != nil {
+		e.Bound = ex.explain(leaf.Binding.Provided)
+	}
+
+	// Find the other sources of typ in the provider sets of the loaded
+	// packages. A source may be reachable from several sets; keep the
+	// shortest chain, which starts at the set that declares it.
+	paths := make([]string, 0, len(ex.oc.packages))
+	for path := range ex.oc.packages {
+		if !isWireImport(path) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	alts := make(map[token.Pos]*ExplainedSource)
+	for _, path := range paths {
+		pkg := ex.oc.packages[path]
+		if pkg.Types == nil || pkg.TypesInfo == nil {
+			continue
+		}
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			obj := scope.Lookup(name)
+			if _, ok := obj.(*types.Var); !ok || !isProviderSetType(obj.Type()) {
+				continue
+			}
+			item, errs := ex.oc.get(obj)
+			if len(errs) > 0 {
+				continue
+			}
+			alt, _ := explainSource(ex.oc.fset, item.(*ProviderSet), typ)
+			if alt == nil || (e.Chosen != nil && alt.Pos == e.Chosen.Pos) {
+				continue
+			}
+			if prev := alts[alt.Pos]; prev != nil && len(prev.Sets) <= len(alt.Sets) {
+				continue
+			}
+			alt.Reason = ex.reason(typ, e.Chosen, alt)
+			alts[alt.Pos] = alt
+		}
+	}
+	for _, alt := range alts {
+		e.Alternatives = append(e.Alternatives, alt)
+	}
+	sort.Slice(e.Alternatives, func(i, j int) bool {
+		return e.Alternatives[i].Pos < e.Alternatives[j].Pos
+	})
+	return e
+}
+
+// reason says why the injector does not use alt as the source of typ, given
+// the source that it chose, if any.
+func (ex *explainer) reason(typ types.Type, chosen, alt *ExplainedSource) string {
+	if chosen != nil {
+		for _, o := range ex.set.overridden(typ) {
+			if o.pos() == alt.Pos {
+				return fmt.Sprintf("overridden by %s, which wire.Build includes through fewer provider sets", chosen.Description)
+			}
+		}
+	}
+	sets := alt.Sets
+	if !ex.testFile {
+		for _, s := range sets {
+			if s.TestOnly {
+				return fmt.Sprintf("provider set %q is test-only and may only be used by injectors in _test.go files", s.VarName)
+			}
+		}
+	}
+	return fmt.Sprintf("the injector does not include provider set %q", sets[len(sets)-1].VarName)
+}
+
+// explainSource returns the source of typ in set, along with the
+// providerSetSrc it describes, or nil if set does not provide typ.
+func explainSource(fset *token.FileSet, set *ProviderSet, typ types.Type) (*ExplainedSource, *providerSetSrc) {
+	src, _ := set.srcMap.At(typ).(*providerSetSrc)
+	if src == nil {
+		return nil, nil
+	}
+	sets := []*ProviderSet{set}
+	for src.Import != nil {
+		parent, _ := src.Import.srcMap.At(typ).(*providerSetSrc)
+		if parent == nil {
+			break
+		}
+		sets = append(sets, src.Import)
+		src = parent
+	}
+	// Order the sets from the one that declares the source outwards.
+	for i, j := 0, len(sets)-1; i < j; i, j = i+1, j-1 {
+		sets[i], sets[j] = sets[j], sets[i]
+	}
+	return &ExplainedSource{
+		Description: src.description(fset, typ),
+		Pos:         src.pos(),
+		Sets:        sets,
+	}, src
+}
+
+// String renders e as text, for example:
+//
+//	"example.com/api".InitializeAPI provides example.com/log.Logger with
+//		wire.Bind (log.go:20:2)
+//		in provider set "ProdSet" (log.go:18:14)
+//		in wire.Build (wire.go:12:8)
+//	example.com/log.Logger is bound to *example.com/log.ZapLogger, which the injector provides with
+//		provider "NewZapLogger" (log.go:30:6)
+//		in provider set "ProdSet" (log.go:18:14)
+//		in wire.Build (wire.go:12:8)
+//	Alternatives for example.com/log.Logger:
+//		wire.Value (log.go:25:2)
+//		in provider set "DevSet" (log.go:24:13)
+//		not used: the injector does not include provider set "DevSet"
+func (e *Explanation) String() string {
+	sb := new(strings.Builder)
+	e.write(sb, fmt.Sprintf("%v provides %s with", e.Injector, types.TypeString(e.Type, nil)))
+	return sb.String()
+}
+
+// write renders e to sb, starting with the line intro.
+func (e *Explanation) write(sb *strings.Builder, intro string) {
+	if e.Chosen == nil {
+		fmt.Fprintf(sb, "%v does not provide %s\n", e.Injector, types.TypeString(e.Type, nil))
+	} else {
+		fmt.Fprintf(sb, "%s\n", intro)
+		e.writeSource(sb, e.Chosen)
+	}
+	if e.Bound != nil {
+		e.Bound.write(sb, fmt.Sprintf("%s is bound to %s, which the injector provides with", types.TypeString(e.Type, nil), types.TypeString(e.Bound.Type, nil)))
+	}
+	if len(e.Alternatives) > 0 {
+		fmt.Fprintf(sb, "Alternatives for %s:\n", types.TypeString(e.Type, nil))
+		for _, alt := range e.Alternatives {
+			e.writeSource(sb, alt)
+			fmt.Fprintf(sb, "\tnot used: %s\n", alt.Reason)
+		}
+	}
+}
+
+func (e *Explanation) writeSource(sb *strings.Builder, src *ExplainedSource) {
+	fmt.Fprintf(sb, "\t%s\n", src.Description)
+	for _, s := range src.Sets {
+		if s.VarName == "" {
+			fmt.Fprintf(sb, "\tin wire.Build (%s)\n", e.Fset.Position(s.Pos))
+			continue
+		}
+		fmt.Fprintf(sb, "\tin provider set %q (%s)\n", s.VarName, e.Fset.Position(s.Pos))
+	}
+}
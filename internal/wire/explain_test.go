@@ -0,0 +1,152 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	wd, env := materialize(t, map[string]string{
+		"example.com/foo/foo.go": `package foo
+
+import "github.com/google/wire"
+
+type Logger interface{ Log(string) }
+
+type ZapLogger struct{}
+
+func (*ZapLogger) Log(string) {}
+
+func NewZapLogger() *ZapLogger { return new(ZapLogger) }
+
+type nopLogger struct{}
+
+func (nopLogger) Log(string) {}
+
+func NewTestLogger() Logger { return nopLogger{} }
+
+type API struct{ Logger Logger }
+
+func NewAPI(l Logger) *API { return &API{Logger: l} }
+
+var ProdSet = wire.NewSet(NewZapLogger, wire.Bind(new(Logger), new(*ZapLogger)))
+
+var DevSet = wire.NewSet(wire.InterfaceValue(new(Logger), nopLogger{}))
+
+//wire:testonly
+var TestSet = wire.NewSet(NewTestLogger)
+
+var APISet = wire.NewSet(ProdSet, NewAPI)
+`,
+		"example.com/foo/wire.go": `//go:build wireinject
+
+package foo
+
+import "github.com/google/wire"
+
+func InitializeAPI() *API {
+	panic(wire.Build(APISet))
+}
+`,
+	})
+
+	e, errs := Explain(context.Background(), wd, env, "./foo", "InitializeAPI", "Logger")
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	sets := func(src *ExplainedSource) []string {
+		var names []string
+		for _, s := range src.Sets {
+			names = append(names, s.VarName)
+		}
+		return names
+	}
+	if e.Chosen == nil || !strings.HasPrefix(e.Chosen.Description, "wire.Bind") {
+		t.Fatalf("Chosen = %+v; want the wire.Bind in ProdSet", e.Chosen)
+	}
+	if got, want := strings.Join(sets(e.Chosen), " "), "ProdSet APISet "; got != want {
+		t.Errorf("Chosen.Sets = %q; want %q", got, want)
+	}
+	if e.Bound == nil || e.Bound.Chosen == nil || !strings.HasPrefix(e.Bound.Chosen.Description, `provider "NewZapLogger"`) {
+		t.Fatalf("Bound = %+v; want NewZapLogger", e.Bound)
+	}
+	if len(e.Alternatives) != 2 {
+		t.Fatalf("got %d alternatives; want 2:\n%v", len(e.Alternatives), e)
+	}
+	// NewTestLogger is declared before the value in DevSet.
+	testOnly, dev := e.Alternatives[0], e.Alternatives[1]
+	if !strings.HasPrefix(dev.Description, "wire.Value") || dev.Reason != `the injector does not include provider set "DevSet"` {
+		t.Errorf("second alternative = %+v; want the value in DevSet, not included", dev)
+	}
+	if !strings.HasPrefix(testOnly.Description, `provider "NewTestLogger"`) || !strings.Contains(testOnly.Reason, "test-only") {
+		t.Errorf("first alternative = %+v; want NewTestLogger in the test-only TestSet", testOnly)
+	}
+
+	text := e.String()
+	for _, want := range []string{
+		`"example.com/foo".InitializeAPI provides example.com/foo.Logger with`,
+		`example.com/foo.Logger is bound to *example.com/foo.ZapLogger, which the injector provides with`,
+		`not used: the injector does not include provider set "DevSet"`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("String() does not contain %q:\n%s", want, text)
+		}
+	}
+
+	if _, errs := Explain(context.Background(), wd, env, "./foo", "InitializeAPI", "*Missing"); len(errs) == 0 {
+		t.Error("Explain of an undefined type succeeded")
+	}
+	if _, errs := Explain(context.Background(), wd, env, "./foo", "initializeOther", "Logger"); len(errs) == 0 {
+		t.Error("Explain of a missing injector succeeded")
+	}
+}
+
+func TestExplainNearestWins(t *testing.T) {
+	wireGo, err := ioutil.ReadFile(filepath.Join("..", "..", "wire.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test, err := loadTestCase(filepath.Join("testdata", "NearestWins"), wireGo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gopath := t.TempDir()
+	if err := test.materialize(gopath); err != nil {
+		t.Fatal(err)
+	}
+	wd := filepath.Join(gopath, "src", "example.com")
+	env := append(os.Environ(), "GOPATH="+gopath)
+
+	e, errs := Explain(context.Background(), wd, env, "./foo", "injectMessage", "Greeting")
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if e.Chosen == nil || !strings.HasPrefix(e.Chosen.Description, `provider "provideTestGreeting"`) {
+		t.Fatalf("Chosen = %+v; want provideTestGreeting", e.Chosen)
+	}
+	if len(e.Alternatives) != 1 {
+		t.Fatalf("got %d alternatives; want 1:\n%v", len(e.Alternatives), e)
+	}
+	alt := e.Alternatives[0]
+	if !strings.HasPrefix(alt.Description, `provider "provideGreeting"`) || !strings.HasPrefix(alt.Reason, `overridden by provider "provideTestGreeting"`) {
+		t.Errorf("alternative = %+v; want provideGreeting, overridden by provideTestGreeting", alt)
+	}
+}
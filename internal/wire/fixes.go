@@ -0,0 +1,287 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// A TextEdit replaces the text of a file from Pos up to End with NewText.
+// Pos and End are equal for an insertion. Both have a Filename and an
+// Offset, along with the Line and Column that Offset is at.
+type TextEdit struct {
+	Pos, End token.Position
+	NewText  string
+}
+
+// A SuggestedFix is a change to the source that resolves an error, as edits
+// that must be applied together. The edits do not overlap, and applying
+// them from the last offset to the first keeps the offsets of the others
+// valid.
+type SuggestedFix struct {
+	// Message describes the change, such as "bind io.Reader to
+	// *bytes.Buffer".
+	Message string
+	Edits   []TextEdit
+}
+
+// suggestFixes fills in the SuggestedFixes of the MissingProviderErrors in
+// errs, which solving the injector fn with the provider set set returned.
+// buildCall is the wire.Build call of fn. A fix is only suggested where one
+// change is clearly what is missing:
+//
+//   - a context.Context that the injector can take as a parameter;
+//   - an interface that exactly one provided type implements, which
+//     wire.Bind can bind to it;
+//   - a type whose pointer, or whose pointee, is provided, which a provider
+//     added to the injector's file can convert.
+//
+// Fixes are not suggested if the injector's file would need to import a
+// package for them, apart from context.
+func suggestFixes(pkg *packages.Package, fn *ast.FuncDecl, buildCall *ast.CallExpr, set *ProviderSet, errs []error) {
+	var f *ast.File
+	tf := pkg.Fset.File(fn.Pos())
+	for _, file := range pkg.Syntax {
+		if pkg.Fset.File(file.Pos()) == tf {
+			f = file
+		}
+	}
+	if f == nil {
+		return
+	}
+	fx := &fixer{pkg: pkg, file: f, tf: tf, fn: fn, buildCall: buildCall, set: set}
+	for _, err := range errs {
+		var missing *MissingProviderError
+		if errors.As(err, &missing) && missing.SuggestedFixes == nil {
+			if fix, ok := fx.fix(missing.Type); ok {
+				missing.SuggestedFixes = []SuggestedFix{fix}
+			}
+		}
+	}
+}
+
+// A fixer suggests fixes for the types that one injector is missing.
+type fixer struct {
+	pkg       *packages.Package
+	file      *ast.File
+	tf        *token.File
+	fn        *ast.FuncDecl
+	buildCall *ast.CallExpr
+	set       *ProviderSet
+}
+
+// fix returns the fix for the missing type t, if there is one.
+func (fx *fixer) fix(t types.Type) (SuggestedFix, bool) {
+	if obj, ptrs := namedObj(t); obj != nil && ptrs == 0 && obj.Pkg() != nil && obj.Pkg().Path() == "context" && obj.Name() == "Context" {
+		return fx.contextParam()
+	}
+	if iface, ok := t.Underlying().(*types.Interface); ok {
+		return fx.bind(t, iface)
+	}
+	return fx.convert(t)
+}
+
+// contextParam adds a context.Context parameter to the injector, and an
+// import of context if its file lacks one.
+func (fx *fixer) contextParam() (SuggestedFix, bool) {
+	var edits []TextEdit
+	qual, imported := fx.importName("context")
+	switch {
+	case imported && qual == "_":
+		return SuggestedFix{}, false
+	case !imported:
+		if fx.declared("context") {
+			return SuggestedFix{}, false
+		}
+		qual = "context"
+		edits = append(edits, fx.insert(fx.file.Name.End(), "\n\nimport \"context\""))
+	}
+	typ := "Context"
+	if qual != "." {
+		typ = qual + "." + typ
+	}
+	params := fx.fn.Type.Params
+	name := disambiguate("ctx", func(name string) bool {
+		for _, field := range params.List {
+			for _, id := range field.Names {
+				if id.Name == name {
+					return true
+				}
+			}
+		}
+		return fx.declared(name)
+	})
+	text := name + " " + typ
+	if len(params.List) > 0 {
+		text += ", "
+	}
+	edits = append(edits, fx.insert(params.Opening+1, text))
+	return SuggestedFix{Message: "add a context.Context parameter to the injector", Edits: edits}, true
+}
+
+// bind binds the interface type t, whose method set is iface, to the one
+// provided type that implements it. A type and a pointer to it count as
+// one, and the pointer is bound.
+func (fx *fixer) bind(t types.Type, iface *types.Interface) (SuggestedFix, bool) {
+	var impls []types.Type
+	for _, out := range fx.set.Outputs() {
+		if _, isIface := out.Underlying().(*types.Interface); !isIface && types.Implements(out, iface) {
+			impls = append(impls, out)
+		}
+	}
+	var concrete types.Type
+	for _, impl := range impls {
+		ptrProvided := false
+		for _, other := range impls {
+			if p, ok := other.(*types.Pointer); ok && types.Identical(p.Elem(), impl) {
+				ptrProvided = true
+			}
+		}
+		if ptrProvided {
+			continue
+		}
+		if concrete != nil {
+			return SuggestedFix{}, false
+		}
+		concrete = impl
+	}
+	if concrete == nil {
+		return SuggestedFix{}, false
+	}
+	wire, ok := fx.wireQualifier()
+	ifaceStr, ok1 := fx.typeString(t)
+	concreteStr, ok2 := fx.typeString(concrete)
+	if !ok || !ok1 || !ok2 {
+		return SuggestedFix{}, false
+	}
+	return SuggestedFix{
+		Message: fmt.Sprintf("bind %s to %s", ifaceStr, concreteStr),
+		Edits:   []TextEdit{fx.buildArg(fmt.Sprintf("%sBind(new(%s), new(%s))", wire, ifaceStr, concreteStr))},
+	}, true
+}
+
+// convert adds a provider of t to the injector's file and to its wire.Build
+// call, if the set provides a pointer to t or, for a missing pointer, the
+// type it points to.
+func (fx *fixer) convert(t types.Type) (SuggestedFix, bool) {
+	var from types.Type
+	var body, kind string
+	if p, ok := t.(*types.Pointer); ok && !fx.set.For(p.Elem()).IsNil() {
+		from, body, kind = p.Elem(), "return &v", "Ptr"
+	} else if p := types.NewPointer(t); !fx.set.For(p).IsNil() {
+		from, body, kind = p, "return *v", "Value"
+	} else {
+		return SuggestedFix{}, false
+	}
+	obj, _ := namedObj(t)
+	fromStr, ok1 := fx.typeString(from)
+	toStr, ok2 := fx.typeString(t)
+	if obj == nil || !ok1 || !ok2 {
+		return SuggestedFix{}, false
+	}
+	name := disambiguate("provide"+export(obj.Name())+kind, fx.declared)
+	end := fx.tf.Pos(fx.tf.Size())
+	stub := fmt.Sprintf("\nfunc %s(v %s) %s {\n\t%s\n}\n", name, fromStr, toStr, body)
+	return SuggestedFix{
+		Message: fmt.Sprintf("add %s, which provides %s from the %s that the set provides", name, toStr, fromStr),
+		Edits:   []TextEdit{fx.buildArg(name), fx.insert(end, stub)},
+	}, true
+}
+
+// buildArg appends arg to the arguments of the wire.Build call.
+func (fx *fixer) buildArg(arg string) TextEdit {
+	args := fx.buildCall.Args
+	if len(args) == 0 {
+		return fx.insert(fx.buildCall.Rparen, arg)
+	}
+	return fx.insert(args[len(args)-1].End(), ", "+arg)
+}
+
+// insert returns the edit that inserts text at pos.
+func (fx *fixer) insert(pos token.Pos, text string) TextEdit {
+	p := fx.tf.Position(pos)
+	return TextEdit{Pos: p, End: p, NewText: text}
+}
+
+// importName returns the name that the injector's file imports path as, or
+// false if it does not import it. The name is "." for a dot import and "_"
+// for a blank one.
+func (fx *fixer) importName(path string) (string, bool) {
+	for _, imp := range fx.file.Imports {
+		if p, err := strconv.Unquote(imp.Path.Value); err != nil || p != path {
+			continue
+		}
+		if imp.Name != nil {
+			return imp.Name.Name, true
+		}
+		if pkg := fx.pkg.Imports[path]; pkg != nil {
+			return pkg.Name, true
+		}
+		return "", false
+	}
+	return "", false
+}
+
+// wireQualifier returns the prefix that the injector's file refers to the
+// wire package with, such as "wire.".
+func (fx *fixer) wireQualifier() (string, bool) {
+	switch name := wireImportName(fx.file); name {
+	case "":
+		return "", false
+	case ".":
+		return "", true
+	default:
+		return name + ".", true
+	}
+}
+
+// typeString returns t as the injector's file can write it, or false if
+// the file does not import a package that t refers to.
+func (fx *fixer) typeString(t types.Type) (string, bool) {
+	ok := true
+	s := types.TypeString(t, func(p *types.Package) string {
+		if p.Path() == fx.pkg.PkgPath {
+			return ""
+		}
+		name, imported := fx.importName(p.Path())
+		switch {
+		case !imported || name == "_":
+			ok = false
+		case name == ".":
+			return ""
+		}
+		return name
+	})
+	return s, ok
+}
+
+// declared reports whether name is declared in the injector's package or
+// file.
+func (fx *fixer) declared(name string) bool {
+	if fx.pkg.Types.Scope().Lookup(name) != nil {
+		return true
+	}
+	if scope := fx.pkg.TypesInfo.Scopes[fx.file]; scope != nil && scope.Lookup(name) != nil {
+		return true
+	}
+	return false
+}
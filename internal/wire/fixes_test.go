@@ -0,0 +1,188 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestSuggestedFixes(t *testing.T) {
+	const fooGo = `package main
+
+import (
+	"context"
+	"fmt"
+)
+
+func main() { fmt.Println(injectApp) }
+
+type Logger interface{ Log(string) }
+
+type stdLogger struct{}
+
+func (*stdLogger) Log(msg string) { fmt.Println(msg) }
+
+func newLogger() *stdLogger { return new(stdLogger) }
+
+type Config struct{ Name string }
+
+func newConfig() *Config { return &Config{Name: "app"} }
+
+type App struct{}
+
+func newApp(ctx context.Context, l Logger, c Config) *App { return new(App) }
+`
+	tests := []struct {
+		name    string
+		wireGo  string
+		message string
+		// edits lists the new text of each edit, in order.
+		edits []string
+	}{
+		{
+			name: "Bind",
+			wireGo: `//go:build wireinject
+
+package main
+
+import (
+	"context"
+
+	"github.com/google/wire"
+)
+
+func provideConfig() Config { return Config{} }
+
+func injectApp(ctx context.Context) *App {
+	panic(wire.Build(newLogger, provideConfig, newApp))
+}
+`,
+			message: "bind Logger to *stdLogger",
+			edits:   []string{", wire.Bind(new(Logger), new(*stdLogger))"},
+		},
+		{
+			name: "Pointer",
+			wireGo: `//go:build wireinject
+
+package main
+
+import (
+	"context"
+
+	"github.com/google/wire"
+)
+
+func injectApp(ctx context.Context) *App {
+	panic(wire.Build(
+		newLogger,
+		wire.Bind(new(Logger), new(*stdLogger)),
+		newConfig,
+		newApp,
+	))
+}
+`,
+			message: "add provideConfigValue, which provides Config from the *Config that the set provides",
+			edits:   []string{", provideConfigValue", "\nfunc provideConfigValue(v *Config) Config {\n\treturn *v\n}\n"},
+		},
+		{
+			name: "Context",
+			wireGo: `//go:build wireinject
+
+package main
+
+import "github.com/google/wire"
+
+func provideConfig() Config { return Config{} }
+
+func injectApp() *App {
+	panic(wire.Build(newLogger, wire.Bind(new(Logger), new(*stdLogger)), provideConfig, newApp))
+}
+`,
+			message: "add a context.Context parameter to the injector",
+			edits:   []string{"\n\nimport \"context\"", "ctx context.Context"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			wd, env := materialize(t, map[string]string{
+				"example.com/foo/foo.go":  fooGo,
+				"example.com/foo/wire.go": test.wireGo,
+			})
+			generate := func() []error {
+				gens, errs := Generate(context.Background(), wd, env, []string{"example.com/foo"}, nil)
+				for _, gen := range gens {
+					errs = append(errs, gen.Errs...)
+				}
+				return errs
+			}
+
+			errs := generate()
+			var fixes []SuggestedFix
+			for _, err := range errs {
+				var missing *MissingProviderError
+				if errors.As(err, &missing) {
+					fixes = append(fixes, missing.SuggestedFixes...)
+				}
+			}
+			if len(fixes) != 1 {
+				t.Fatalf("got %d suggested fixes for %v; want 1", len(fixes), errs)
+			}
+			fix := fixes[0]
+			if fix.Message != test.message {
+				t.Errorf("Message = %q; want %q", fix.Message, test.message)
+			}
+			var got []string
+			for _, e := range fix.Edits {
+				got = append(got, e.NewText)
+			}
+			if len(got) != len(test.edits) {
+				t.Fatalf("edits = %q; want %q", got, test.edits)
+			}
+			for i := range got {
+				if got[i] != test.edits[i] {
+					t.Errorf("edit %d = %q; want %q", i, got[i], test.edits[i])
+				}
+			}
+
+			applyEdits(t, fix.Edits)
+			if errs := generate(); len(errs) > 0 {
+				src, _ := ioutil.ReadFile(filepath.Join(wd, "foo", "wire.go"))
+				t.Errorf("Generate after applying the fix: %v\n%s", errs, src)
+			}
+		})
+	}
+}
+
+// applyEdits applies edits to the files they name.
+func applyEdits(t *testing.T, edits []TextEdit) {
+	t.Helper()
+	edits = append([]TextEdit(nil), edits...)
+	sort.Slice(edits, func(i, j int) bool { return edits[i].Pos.Offset > edits[j].Pos.Offset })
+	for _, e := range edits {
+		src, err := ioutil.ReadFile(e.Pos.Filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		src = append(src[:e.Pos.Offset:e.Pos.Offset], append([]byte(e.NewText), src[e.End.Offset:]...)...)
+		if err := ioutil.WriteFile(e.Pos.Filename, src, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// fuzzPackage is the package that FuzzMarkerCalls type-checks. The fuzzed
+// input replaces the %s verb, so it is the argument list of a provider set.
+const fuzzPackage = `package fuzz
+
+import "github.com/google/wire"
+
+type Foo struct {
+	A   Bar
+	B   string
+	mu  *Bar ` + "`wire:\"-\"`" + `
+	Ptr *Foo
+}
+
+type Bar int
+
+type Fooer interface{ Foo() }
+
+type Reader interface{ Read([]byte) (int, error) }
+
+func (*Bar) Foo() {}
+
+type Box[T any] struct{ V T }
+
+func NewFoo(b Bar) Foo { return Foo{A: b} }
+
+func NewBar() Bar { return 0 }
+
+func NewGeneric[T any]() *T { return nil }
+
+var (
+	v   = 42
+	fp  *Foo
+	eof error
+)
+
+var Set = wire.NewSet(%s)
+
+func Inject() Foo {
+	wire.Build(Set)
+	return Foo{}
+}
+`
+
+// FuzzMarkerCalls checks that no argument list to wire.NewSet that
+// type-checks makes Wire panic or report an internal error.
+func FuzzMarkerCalls(f *testing.F) {
+	for _, seed := range []string{
+		"NewFoo, NewBar",
+		"nil",
+		"true",
+		"v",
+		"eof",
+		"NewGeneric[Foo]",
+		"NewGeneric",
+		"wire.Struct(fp)",
+		"wire.Struct(new(struct{ X int }))",
+		"wire.Struct(new(Foo), \"*\")",
+		"wire.Struct(new(Box[int]), \"V\")",
+		"wire.Struct(new(Foo), 1)",
+		"wire.Struct(new(Foo), \"mu\")",
+		"wire.Struct(NewGeneric[Foo]())",
+		"wire.FieldsOf(new(*Foo), \"A\")",
+		"wire.FieldsOf(new(*int), \"A\")",
+		"wire.FieldsOf(new(Foo), NewBar)",
+		"wire.AllFieldsOf(new(**Foo))",
+		"wire.Bind(new(Fooer), new(*Bar)), NewBar",
+		"wire.Bind(new(Fooer), nil)",
+		"wire.Value(nil)",
+		"wire.Value(Foo{})",
+		"wire.InterfaceValue(new(Reader), nil)",
+		"wire.NewSet()",
+		"Foo{}",
+		"(NewFoo)",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, args string) {
+		pkg := loadSource(t, fmt.Sprintf(fuzzPackage, args))
+		if pkg == nil {
+			t.Skip("input does not type-check")
+		}
+		var errs []error
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("wire.NewSet(%s) panicked: %v", args, r)
+				}
+			}()
+			oc := newObjectCache([]*packages.Package{pkg})
+			_, errs = oc.get(pkg.Types.Scope().Lookup("Set"))
+			_, genErrs := generateInjectors(newGen(pkg), pkg)
+			errs = append(errs, genErrs...)
+		}()
+		for _, err := range errs {
+			if strings.Contains(err.Error(), "internal error") {
+				t.Errorf("wire.NewSet(%s): %v", args, err)
+			}
+		}
+	})
+}
+
+// loadSource type-checks src as the package example.com/fuzz, which may only
+// import the wire package. It returns nil if src does not
+// type-check.
+func loadSource(t *testing.T, src string) *packages.Package {
+	fset := token.NewFileSet()
+	wirePkg := checkSource(t, fset, "github.com/google/wire", filepath.Join("..", "..", "wire.go"), nil, nil)
+	if wirePkg == nil {
+		t.Fatal("wire package does not type-check")
+	}
+	return checkSource(t, fset, "example.com/fuzz", "fuzz.go", src, map[string]*packages.Package{
+		wirePkg.PkgPath: wirePkg,
+	})
+}
+
+// checkSource parses and type-checks a single-file package whose imports are
+// all in deps.
+func checkSource(t *testing.T, fset *token.FileSet, path, filename string, src interface{}, deps map[string]*packages.Package) *packages.Package {
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil
+	}
+	pkg := &packages.Package{
+		ID:      path,
+		Name:    f.Name.Name,
+		PkgPath: path,
+		Fset:    fset,
+		Syntax:  []*ast.File{f},
+		Imports: make(map[string]*packages.Package),
+		TypesInfo: &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Implicits:  make(map[ast.Node]types.Object),
+			Instances:  make(map[*ast.Ident]types.Instance),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+			Scopes:     make(map[ast.Node]*types.Scope),
+		},
+	}
+	conf := &types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			if dep := deps[path]; dep != nil {
+				pkg.Imports[path] = dep
+				return dep.Types, nil
+			}
+			return nil, fmt.Errorf("cannot import %s", path)
+		}),
+	}
+	pkg.Types, err = conf.Check(path, fset, pkg.Syntax, pkg.TypesInfo)
+	if err != nil {
+		return nil
+	}
+	return pkg
+}
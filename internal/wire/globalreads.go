@@ -0,0 +1,118 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// allowGlobalReadDirective allows a provider to read package-level
+// variables of other packages. It may be put in the doc comment of the
+// provider, or in a comment on or just above the read.
+const allowGlobalReadDirective = "//wire:allow-global-read"
+
+// A GlobalRead is a read of a package-level variable of another package in
+// the body of a provider function.
+type GlobalRead struct {
+	// Pos is the position of the read.
+	Pos token.Pos
+	// Var is the variable read.
+	Var *types.Var
+}
+
+// globalReads returns the reads of package-level variables of packages
+// other than its own, outside of the standard library, in the body of fn,
+// except for those allowed by an allowGlobalReadDirective. Such a variable
+// may be set by an init function that the developer expects to have run,
+// so reading it when the injector runs is worth a second look.
+//
+// Only reads in the body itself are reported, not those in function
+// literals, which may run later, such as cleanup functions, nor those in
+// the functions that fn calls. Assignments to a variable and taking its
+// address are not reads.
+func (oc *objectCache) globalReads(fn *types.Func) []GlobalRead {
+	pkg, file, path := oc.declPath(fn)
+	decl := enclosingFuncDecl(path)
+	if decl == nil || decl.Body == nil || hasDirective(decl.Doc, allowGlobalReadDirective) {
+		return nil
+	}
+	var allowed []int
+	for _, cg := range file.Comments {
+		for _, c := range cg.List {
+			if strings.HasPrefix(c.Text, allowGlobalReadDirective) {
+				allowed = append(allowed, oc.fset.Position(c.Pos()).Line)
+			}
+		}
+	}
+	// notRead holds the identifiers that are assigned to or whose address
+	// is taken.
+	notRead := make(map[*ast.Ident]bool)
+	lhsIdent := func(e ast.Expr) *ast.Ident {
+		switch e := e.(type) {
+		case *ast.Ident:
+			return e
+		case *ast.SelectorExpr:
+			return e.Sel
+		}
+		return nil
+	}
+	var reads []GlobalRead
+	ast.Inspect(decl.Body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.AssignStmt:
+			if node.Tok == token.ASSIGN || node.Tok == token.DEFINE {
+				for _, e := range node.Lhs {
+					if id := lhsIdent(e); id != nil {
+						notRead[id] = true
+					}
+				}
+			}
+		case *ast.UnaryExpr:
+			if node.Op == token.AND {
+				if id := lhsIdent(node.X); id != nil {
+					notRead[id] = true
+				}
+			}
+		case *ast.Ident:
+			v, ok := pkg.TypesInfo.Uses[node].(*types.Var)
+			if !ok || notRead[node] || v.Pkg() == nil || v.Pkg() == fn.Pkg() || v.Parent() != v.Pkg().Scope() || isStandardPath(v.Pkg().Path()) {
+				return true
+			}
+			line := oc.fset.Position(node.Pos()).Line
+			for _, l := range allowed {
+				if l == line || l == line-1 {
+					return true
+				}
+			}
+			reads = append(reads, GlobalRead{Pos: node.Pos(), Var: v})
+		}
+		return true
+	})
+	return reads
+}
+
+// isStandardPath reports whether the import path belongs to the standard
+// library, whose first element contains no dot.
+func isStandardPath(path string) bool {
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		path = path[:i]
+	}
+	return !strings.Contains(path, ".")
+}
@@ -0,0 +1,107 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// goGenerateVars are the variables that go generate sets for a directive
+// and that GenerateFromGoGenerate needs.
+var goGenerateVars = []string{"GOFILE", "GOLINE", "GOPACKAGE", "PWD"}
+
+// GenerateFromGoGenerate generates the package of the file whose
+// //go:generate directive go generate is running, such as
+//
+//	//go:generate go run ./tools/wiregen
+//
+// in an injector file, and writes the output with GenerateResult.Commit.
+// It finds the file, the line of the directive, the package name and the
+// package directory in the GOFILE, GOLINE, GOPACKAGE and PWD variables of
+// the environment, and fails if any of them is missing, as when the program
+// is not run by go generate.
+//
+// All the injectors of the package are generated, whichever file the
+// directive is in: they share one output file, which builds use in place
+// of the injector files, so generating only some of them would drop the
+// others from the build. GOLINE only places the errors that
+// GenerateFromGoGenerate reports about the directive itself.
+//
+// The returned errors are those of loading the package, of writing the
+// output, and ErrNoInjectors if the package has none. The errors and
+// warnings of generating the package are in the result, as for Generate.
+// A package skipped by a //wire:ignore directive or a .wireignore file is
+// not an error.
+func GenerateFromGoGenerate(ctx context.Context, opts *GenerateOptions) (GenerateResult, []error) {
+	return generateFromGoGenerate(ctx, os.Environ(), opts)
+}
+
+// generateFromGoGenerate is GenerateFromGoGenerate with the variables of
+// go generate read from env, which is also passed on to Generate.
+func generateFromGoGenerate(ctx context.Context, env []string, opts *GenerateOptions) (GenerateResult, []error) {
+	vars := make(map[string]string)
+	for _, kv := range env {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			vars[k] = v
+		}
+	}
+	var missing []string
+	for _, k := range goGenerateVars {
+		if vars[k] == "" {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) > 0 {
+		return GenerateResult{}, []error{fmt.Errorf("%s not set; GenerateFromGoGenerate must be run by go generate", strings.Join(missing, ", "))}
+	}
+	dir, file := vars["PWD"], vars["GOFILE"]
+	pos := file + ":" + vars["GOLINE"]
+	if _, err := strconv.Atoi(vars["GOLINE"]); err != nil {
+		return GenerateResult{}, []error{fmt.Errorf("GOLINE is %q; want a line number", vars["GOLINE"])}
+	}
+	if filepath.Base(file) != file || !strings.HasSuffix(file, ".go") {
+		return GenerateResult{}, []error{fmt.Errorf("GOFILE is %q; want the name of a .go file", file)}
+	}
+
+	gens, errs := Generate(ctx, dir, env, []string{file}, opts)
+	if len(errs) > 0 {
+		return GenerateResult{}, errs
+	}
+	if len(gens) != 1 {
+		return GenerateResult{}, []error{fmt.Errorf("%s: %s matched %d packages; want 1", pos, file, len(gens))}
+	}
+	gen := gens[0]
+	if gen.Ignored {
+		return gen, nil
+	}
+	if gen.pkg != nil && gen.pkg.Name != vars["GOPACKAGE"] {
+		return gen, []error{fmt.Errorf("%s: GOPACKAGE is %q, but %s is in package %s", pos, vars["GOPACKAGE"], file, gen.pkg.Name)}
+	}
+	if len(gen.Errs) > 0 {
+		return gen, nil
+	}
+	if gen.Injectors == 0 {
+		return gen, []error{fmt.Errorf("%s: %w in %s", pos, ErrNoInjectors, gen.PkgPath)}
+	}
+	if err := gen.Commit(); err != nil {
+		return gen, []error{fmt.Errorf("%s: %v", pos, err)}
+	}
+	return gen, nil
+}
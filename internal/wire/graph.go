@@ -0,0 +1,265 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// A TypeRef refers to a type for a Graph, such as a type of a package loaded
+// with golang.org/x/tools/go/packages.
+type TypeRef struct {
+	typ types.Type
+}
+
+// TypeOf returns a TypeRef for t.
+func TypeOf(t types.Type) TypeRef {
+	return TypeRef{typ: t}
+}
+
+// TypeOfObject returns a TypeRef for the type of obj: the type it declares
+// if it is a *types.TypeName, and the type of the variable, constant or
+// function otherwise.
+func TypeOfObject(obj types.Object) TypeRef {
+	return TypeRef{typ: obj.Type()}
+}
+
+// Type returns the type that r refers to.
+func (r TypeRef) Type() types.Type {
+	return r.typ
+}
+
+func (r TypeRef) String() string {
+	return types.TypeString(r.typ, nil)
+}
+
+// A ProviderSpec describes a provider function for a Graph. The function
+// need not exist as Go source yet.
+type ProviderSpec struct {
+	// Pkg and Name identify the function.
+	Pkg  *types.Package
+	Name string
+
+	// Pos is the position of the function in the Graph's Fset, for
+	// errors, or token.NoPos.
+	Pos token.Pos
+
+	// Args are the types of the function's parameters, in order.
+	Args []TypeRef
+
+	// Out are the types that the function provides, in order, without
+	// the cleanup function and error. There must be at least one.
+	Out []TypeRef
+
+	// HasCleanup and HasErr report whether the function also returns a
+	// cleanup function and an error, in that order.
+	HasCleanup bool
+	HasErr     bool
+}
+
+// A Graph is a set of providers, interface bindings and inputs built
+// without Go source, which Solve orders into the calls that provide some
+// types, as Wire does for an injector. Build one with NewGraph and its Add
+// methods, which return the Graph so that calls can be chained; errors in
+// what is added are reported by Solve.
+//
+// Unlike the arguments of wire.Build, providers and bindings that Solve
+// does not need are not an error. Two providers of the same type are.
+type Graph struct {
+	// Fset resolves the positions of providers in errors. If it is nil,
+	// errors have no positions.
+	Fset *token.FileSet
+
+	providers []*Provider
+	bindings  []*IfaceBinding
+	inputs    []TypeRef
+	errs      []error
+}
+
+// NewGraph returns an empty Graph.
+func NewGraph() *Graph {
+	return new(Graph)
+}
+
+// AddProvider adds the provider function that spec describes.
+func (g *Graph) AddProvider(spec ProviderSpec) *Graph {
+	if spec.Name == "" || spec.Pkg == nil {
+		g.errs = append(g.errs, errors.New("provider must have a name and a package"))
+		return g
+	}
+	if len(spec.Out) == 0 {
+		g.errs = append(g.errs, fmt.Errorf("provider %s.%s must provide at least one type", spec.Pkg.Name(), spec.Name))
+		return g
+	}
+	p := &Provider{
+		Pkg:        spec.Pkg,
+		Name:       spec.Name,
+		Pos:        spec.Pos,
+		HasCleanup: spec.HasCleanup,
+		HasErr:     spec.HasErr,
+	}
+	for _, a := range spec.Args {
+		p.Args = append(p.Args, ProviderInput{Type: a.typ})
+	}
+	for _, t := range spec.Out {
+		p.Out = append(p.Out, t.typ)
+	}
+	g.providers = append(g.providers, p)
+	return g
+}
+
+// AddBinding declares that concrete, which must implement the interface
+// type iface, is used wherever iface is needed, as wire.Bind does.
+func (g *Graph) AddBinding(iface, concrete TypeRef) *Graph {
+	methodSet, ok := iface.typ.Underlying().(*types.Interface)
+	switch {
+	case !ok:
+		g.errs = append(g.errs, fmt.Errorf("cannot bind %s, which is not an interface type", iface))
+	case types.Identical(iface.typ, concrete.typ):
+		g.errs = append(g.errs, errors.New("cannot bind interface to itself"))
+	case !types.Implements(concrete.typ, methodSet):
+		g.errs = append(g.errs, notImplementedError(concrete.typ, iface.typ))
+	default:
+		g.bindings = append(g.bindings, &IfaceBinding{Iface: iface.typ, Provided: concrete.typ})
+	}
+	return g
+}
+
+// AddInput adds a value of type t that Solve may use without calling a
+// provider, like an argument of an injector.
+func (g *Graph) AddInput(t TypeRef) *Graph {
+	g.inputs = append(g.inputs, t)
+	return g
+}
+
+// A Plan is the order in which to call the providers of a Graph to provide
+// some types. The values that the calls work with are numbered: first the
+// inputs of the Graph, in the order they were added, then the results of
+// each step in turn.
+type Plan struct {
+	// Steps are the calls, in an order in which each call's arguments are
+	// available before it.
+	Steps []PlanStep
+
+	// Outputs are the numbers of the values of the types passed to Solve,
+	// in order.
+	Outputs []int
+}
+
+// A PlanStep is a call to a provider in a Plan.
+type PlanStep struct {
+	// Provider is the provider to call, as it was added to the Graph.
+	Provider ProviderSpec
+
+	// Args are the numbers of the values to pass as the provider's
+	// arguments, in order.
+	Args []int
+
+	// Results are the numbers that the values of Provider.Out get.
+	Results []int
+}
+
+// Solve returns the plan for providing outputs from the Graph's providers,
+// bindings and inputs, or the errors that Wire would report for an injector
+// that used them, such as a *MissingProviderError for a type that nothing
+// provides.
+func (g *Graph) Solve(outputs []TypeRef) (*Plan, []error) {
+	if len(g.errs) > 0 {
+		return nil, g.errs
+	}
+	if len(outputs) == 0 {
+		return nil, []error{errors.New("no outputs to solve for")}
+	}
+	fset := g.Fset
+	if fset == nil {
+		fset = token.NewFileSet()
+	}
+	hasher := typeutil.MakeHasher()
+	vars := make([]*types.Var, len(g.inputs))
+	for i, t := range g.inputs {
+		vars[i] = types.NewParam(token.NoPos, nil, fmt.Sprintf("input%d", i), t.typ)
+	}
+	given := types.NewTuple(vars...)
+	set := &ProviderSet{
+		Providers:    g.providers,
+		Bindings:     g.bindings,
+		InjectorArgs: &InjectorArgs{Name: "Graph.Solve", Tuple: given},
+	}
+	var errs []error
+	set.providerMap, set.srcMap, set.pendingBindings, errs = buildProviderMap(fset, hasher, set)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if errs := verifyAcyclic(set.providerMap, hasher); len(errs) > 0 {
+		return nil, errs
+	}
+	extra := make([]types.Type, len(outputs)-1)
+	for i, t := range outputs[1:] {
+		extra[i] = t.typ
+	}
+	calls, _, errs := solveCalls(fset, outputs[0].typ, given, set, extra...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	plan := new(Plan)
+	values := new(typeutil.Map) // type to value number
+	values.SetHasher(hasher)
+	for i, t := range g.inputs {
+		values.Set(t.typ, i)
+	}
+	n := len(g.inputs)
+	for _, c := range calls {
+		// The set has only providers, bindings and inputs, so every call
+		// is a provider call.
+		outs := c.outs
+		if outs == nil {
+			outs = []types.Type{c.out}
+		}
+		step := PlanStep{Provider: providerSpec(set.For(c.out).Provider()), Args: c.args}
+		for _, t := range outs {
+			values.Set(t, n)
+			step.Results = append(step.Results, n)
+			n++
+		}
+		plan.Steps = append(plan.Steps, step)
+	}
+	for _, t := range outputs {
+		// Follow bindings to the concrete type that has a value.
+		typ := t.typ
+		for values.At(typ) == nil {
+			typ = set.For(typ).Type()
+		}
+		plan.Outputs = append(plan.Outputs, values.At(typ).(int))
+	}
+	return plan, nil
+}
+
+// providerSpec returns the ProviderSpec that p was made from.
+func providerSpec(p *Provider) ProviderSpec {
+	spec := ProviderSpec{Pkg: p.Pkg, Name: p.Name, Pos: p.Pos, HasCleanup: p.HasCleanup, HasErr: p.HasErr}
+	for _, a := range p.Args {
+		spec.Args = append(spec.Args, TypeOf(a.Type))
+	}
+	for _, t := range p.Out {
+		spec.Out = append(spec.Out, TypeOf(t))
+	}
+	return spec
+}
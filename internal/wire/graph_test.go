@@ -0,0 +1,115 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"errors"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestGraphSolve(t *testing.T) {
+	pkg := types.NewPackage("example.com/svc", "svc")
+	named := func(name string, underlying types.Type) *types.Named {
+		return types.NewNamed(types.NewTypeName(token.NoPos, pkg, name, nil), underlying, nil)
+	}
+	config := named("Config", types.NewStruct(nil, nil))
+	db := types.NewPointer(named("DB", types.NewStruct(nil, nil)))
+	server := types.NewPointer(named("Server", types.NewStruct(nil, nil)))
+	logSig := types.NewSignatureType(nil, nil, nil, types.NewTuple(types.NewParam(token.NoPos, pkg, "msg", types.Typ[types.String])), nil, false)
+	logger := named("Logger", types.NewInterfaceType([]*types.Func{types.NewFunc(token.NoPos, pkg, "Log", logSig)}, nil).Complete())
+	zap := named("ZapLogger", types.NewStruct(nil, nil))
+	recv := types.NewVar(token.NoPos, pkg, "z", types.NewPointer(zap))
+	zap.AddMethod(types.NewFunc(token.NoPos, pkg, "Log", types.NewSignatureType(recv, nil, nil, logSig.Params(), nil, false)))
+	zapPtr := types.NewPointer(zap)
+
+	newGraph := func() *Graph {
+		return NewGraph().
+			AddInput(TypeOf(config)).
+			AddProvider(ProviderSpec{Pkg: pkg, Name: "NewDB", Args: []TypeRef{TypeOf(config)}, Out: []TypeRef{TypeOf(db)}, HasCleanup: true, HasErr: true}).
+			AddProvider(ProviderSpec{Pkg: pkg, Name: "NewZapLogger", Out: []TypeRef{TypeOf(zapPtr)}}).
+			AddBinding(TypeOfObject(logger.Obj()), TypeOf(zapPtr)).
+			AddProvider(ProviderSpec{Pkg: pkg, Name: "NewServer", Args: []TypeRef{TypeOf(db), TypeOf(logger)}, Out: []TypeRef{TypeOf(server)}})
+	}
+
+	t.Run("Plan", func(t *testing.T) {
+		// An unused provider is not an error.
+		g := newGraph().AddProvider(ProviderSpec{Pkg: pkg, Name: "NewUnused", Out: []TypeRef{TypeOf(types.Typ[types.Int])}})
+		plan, errs := g.Solve([]TypeRef{TypeOf(server), TypeOf(logger)})
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		type step struct {
+			Name          string
+			Args, Results []int
+		}
+		var got []step
+		for _, s := range plan.Steps {
+			got = append(got, step{s.Provider.Name, s.Args, s.Results})
+		}
+		// The extra outputs come as early as they can.
+		want := []step{
+			{"NewZapLogger", nil, []int{1}},
+			{"NewDB", []int{0}, []int{2}},
+			{"NewServer", []int{2, 1}, []int{3}},
+		}
+		if diff := cmp.Diff(want, got, cmpopts.EquateEmpty()); diff != "" {
+			t.Errorf("steps (-want +got):\n%s", diff)
+		}
+		if want := []int{3, 1}; !cmp.Equal(plan.Outputs, want) {
+			t.Errorf("Outputs = %v; want %v", plan.Outputs, want)
+		}
+		if s := plan.Steps[1].Provider; !s.HasCleanup || !s.HasErr || s.Pkg != pkg {
+			t.Errorf("Steps[1].Provider = %+v; want NewDB as added", s)
+		}
+	})
+	t.Run("MissingProvider", func(t *testing.T) {
+		_, errs := NewGraph().
+			AddProvider(ProviderSpec{Pkg: pkg, Name: "NewDB", Args: []TypeRef{TypeOf(config)}, Out: []TypeRef{TypeOf(db)}}).
+			Solve([]TypeRef{TypeOf(db)})
+		var missing *MissingProviderError
+		if len(errs) != 1 || !errors.As(errs[0], &missing) || !types.Identical(missing.Type, config) {
+			t.Errorf("Solve errors = %v; want a MissingProviderError for Config", errs)
+		}
+	})
+	t.Run("Conflict", func(t *testing.T) {
+		_, errs := newGraph().
+			AddProvider(ProviderSpec{Pkg: pkg, Name: "NewOtherDB", Out: []TypeRef{TypeOf(db)}}).
+			Solve([]TypeRef{TypeOf(server)})
+		if len(errs) != 1 || !strings.Contains(errs[0].Error(), "multiple bindings for *example.com/svc.DB") {
+			t.Errorf("Solve errors = %v; want multiple bindings for *DB", errs)
+		}
+	})
+	t.Run("Cycle", func(t *testing.T) {
+		_, errs := NewGraph().
+			AddProvider(ProviderSpec{Pkg: pkg, Name: "NewDB", Args: []TypeRef{TypeOf(server)}, Out: []TypeRef{TypeOf(db)}}).
+			AddProvider(ProviderSpec{Pkg: pkg, Name: "NewServer", Args: []TypeRef{TypeOf(db)}, Out: []TypeRef{TypeOf(server)}}).
+			Solve([]TypeRef{TypeOf(server)})
+		if len(errs) == 0 || !strings.Contains(errs[0].Error(), "cycle") {
+			t.Errorf("Solve errors = %v; want a cycle", errs)
+		}
+	})
+	t.Run("InvalidBinding", func(t *testing.T) {
+		_, errs := newGraph().AddBinding(TypeOf(logger), TypeOf(db)).Solve([]TypeRef{TypeOf(server)})
+		if len(errs) != 1 || errs[0].Error() != "*example.com/svc.DB does not implement example.com/svc.Logger: missing method Log" {
+			t.Errorf("Solve errors = %v; want *DB does not implement Logger: missing method Log", errs)
+		}
+	})
+}
@@ -0,0 +1,296 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"errors"
+	"fmt"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A DiffReport lists how the injectors of two loads of the same packages
+// differ, as returned by GraphDiff.
+type DiffReport struct {
+	// Injectors are the injectors that were added, removed or changed,
+	// sorted by import path and name.
+	Injectors []InjectorDiff
+}
+
+// An InjectorDiff is an injector that was added, removed or changed.
+type InjectorDiff struct {
+	ImportPath string
+	FuncName   string
+	Status     DiffStatus
+	// Changes are the changes to a changed injector: signature changes
+	// first, then changes to the types it provides, sorted by type.
+	Changes []DiffChange
+}
+
+// A DiffStatus says whether an InjectorDiff is for an added, removed or
+// changed injector.
+type DiffStatus int
+
+const (
+	InjectorAdded DiffStatus = iota
+	InjectorRemoved
+	InjectorChanged
+)
+
+func (s DiffStatus) String() string {
+	switch s {
+	case InjectorAdded:
+		return "added"
+	case InjectorRemoved:
+		return "removed"
+	case InjectorChanged:
+		return "changed"
+	default:
+		return "unknown"
+	}
+}
+
+// A DiffChange is a change to an injector.
+type DiffChange struct {
+	Kind ChangeKind
+	// Type is the type whose source changed, fully qualified, or the empty
+	// string for a change to the signature.
+	Type string
+	// Old and New are the signatures or sources before and after the
+	// change, as in InjectorStep.Source. Old is empty for an added type
+	// and New for a removed one. For a moved source, they are the base
+	// names of the files.
+	Old, New string
+}
+
+// A ChangeKind classifies a DiffChange.
+type ChangeKind int
+
+const (
+	// SignatureChanged is a change to the types of the parameters or
+	// results of the injector.
+	SignatureChanged ChangeKind = iota
+	// ParamsRenamed is a change to the names of the parameters or results
+	// of the injector alone. It is cosmetic.
+	ParamsRenamed
+	// TypeAdded is a type that the injector now provides.
+	TypeAdded
+	// TypeRemoved is a type that the injector no longer provides.
+	TypeRemoved
+	// SourceChanged is a type that another source now provides.
+	SourceChanged
+	// SourceMoved is a type whose source moved to another file. It is
+	// cosmetic.
+	SourceMoved
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case SignatureChanged:
+		return "signature changed"
+	case ParamsRenamed:
+		return "parameters renamed"
+	case TypeAdded:
+		return "added"
+	case TypeRemoved:
+		return "removed"
+	case SourceChanged:
+		return "source changed"
+	case SourceMoved:
+		return "source moved"
+	default:
+		return "unknown"
+	}
+}
+
+// Cosmetic reports whether changes of kind k leave what the injector does
+// as it was.
+func (k ChangeKind) Cosmetic() bool {
+	return k == ParamsRenamed || k == SourceMoved
+}
+
+// GraphDiff compares the injectors of two loads of the same packages,
+// usually of two revisions, as returned by Load. Injectors are matched by
+// import path and name, and the types they provide by their fully
+// qualified names. A source that moved to another line of the same file
+// is not a change.
+func GraphDiff(old, new *Info) (*DiffReport, error) {
+	if old == nil || new == nil {
+		return nil, errors.New("GraphDiff needs two non-nil Infos")
+	}
+	oldInj, err := injectorsByName(old)
+	if err != nil {
+		return nil, fmt.Errorf("old: %v", err)
+	}
+	newInj, err := injectorsByName(new)
+	if err != nil {
+		return nil, fmt.Errorf("new: %v", err)
+	}
+	report := &DiffReport{}
+	for name, o := range oldInj {
+		if _, ok := newInj[name]; !ok {
+			report.Injectors = append(report.Injectors, InjectorDiff{ImportPath: o.ImportPath, FuncName: o.FuncName, Status: InjectorRemoved})
+		}
+	}
+	for name, n := range newInj {
+		o, ok := oldInj[name]
+		if !ok {
+			report.Injectors = append(report.Injectors, InjectorDiff{ImportPath: n.ImportPath, FuncName: n.FuncName, Status: InjectorAdded})
+			continue
+		}
+		if changes := diffInjector(old, o, new, n); len(changes) > 0 {
+			report.Injectors = append(report.Injectors, InjectorDiff{ImportPath: n.ImportPath, FuncName: n.FuncName, Status: InjectorChanged, Changes: changes})
+		}
+	}
+	sort.Slice(report.Injectors, func(i, j int) bool {
+		a, b := report.Injectors[i], report.Injectors[j]
+		if a.ImportPath != b.ImportPath {
+			return a.ImportPath < b.ImportPath
+		}
+		return a.FuncName < b.FuncName
+	})
+	return report, nil
+}
+
+// injectorsByName returns the injectors of info by their String.
+func injectorsByName(info *Info) (map[string]*Injector, error) {
+	m := make(map[string]*Injector, len(info.Injectors))
+	for _, in := range info.Injectors {
+		name := in.String()
+		if m[name] != nil {
+			return nil, fmt.Errorf("injector %s appears twice", name)
+		}
+		m[name] = in
+	}
+	return m, nil
+}
+
+// diffInjector returns the changes from o, an injector of old, to n, the
+// same injector in new.
+func diffInjector(old *Info, o *Injector, new *Info, n *Injector) []DiffChange {
+	var changes []DiffChange
+	if os, ns := signatureString(o.Sig, false), signatureString(n.Sig, false); os != ns {
+		changes = append(changes, DiffChange{Kind: SignatureChanged, Old: os, New: ns})
+	} else if os, ns := signatureString(o.Sig, true), signatureString(n.Sig, true); os != ns {
+		changes = append(changes, DiffChange{Kind: ParamsRenamed, Old: os, New: ns})
+	}
+	oldSteps := stepsByType(o)
+	newSteps := stepsByType(n)
+	var typeChanges []DiffChange
+	for typ, os := range oldSteps {
+		ns, ok := newSteps[typ]
+		switch {
+		case !ok:
+			typeChanges = append(typeChanges, DiffChange{Kind: TypeRemoved, Type: typ, Old: os.Source})
+		case os.Source != ns.Source:
+			typeChanges = append(typeChanges, DiffChange{Kind: SourceChanged, Type: typ, Old: os.Source, New: ns.Source})
+		default:
+			of, nf := stepFile(old, os), stepFile(new, ns)
+			if of != nf {
+				typeChanges = append(typeChanges, DiffChange{Kind: SourceMoved, Type: typ, Old: of, New: nf})
+			}
+		}
+	}
+	for typ, ns := range newSteps {
+		if _, ok := oldSteps[typ]; !ok {
+			typeChanges = append(typeChanges, DiffChange{Kind: TypeAdded, Type: typ, New: ns.Source})
+		}
+	}
+	sort.Slice(typeChanges, func(i, j int) bool {
+		return typeChanges[i].Type < typeChanges[j].Type
+	})
+	return append(changes, typeChanges...)
+}
+
+// stepsByType returns the steps of in by the fully qualified names of
+// their types.
+func stepsByType(in *Injector) map[string]InjectorStep {
+	m := make(map[string]InjectorStep, len(in.Steps))
+	for _, s := range in.Steps {
+		m[types.TypeString(s.Type, nil)] = s
+	}
+	return m
+}
+
+// stepFile returns the base name of the file of the source of s, a step of
+// an injector of info.
+func stepFile(info *Info, s InjectorStep) string {
+	if !s.Pos.IsValid() || info.Fset == nil {
+		return ""
+	}
+	return filepath.Base(info.Fset.Position(s.Pos).Filename)
+}
+
+// signatureString returns sig as a string with fully qualified types, with
+// the names of the parameters and results if names is true.
+func signatureString(sig *types.Signature, names bool) string {
+	if sig == nil {
+		return ""
+	}
+	if names {
+		return types.TypeString(sig, nil)
+	}
+	tuple := func(t *types.Tuple, variadic bool) string {
+		ts := make([]string, t.Len())
+		for i := range ts {
+			typ := t.At(i).Type()
+			if variadic && i == t.Len()-1 {
+				ts[i] = "..." + types.TypeString(typ.(*types.Slice).Elem(), nil)
+				continue
+			}
+			ts[i] = types.TypeString(typ, nil)
+		}
+		return strings.Join(ts, ", ")
+	}
+	s := "func(" + tuple(sig.Params(), sig.Variadic()) + ")"
+	switch res := sig.Results(); res.Len() {
+	case 0:
+	case 1:
+		s += " " + tuple(res, false)
+	default:
+		s += " (" + tuple(res, false) + ")"
+	}
+	return s
+}
+
+// String renders r as text, one line for each injector followed by an
+// indented line for each change, with cosmetic changes marked as such.
+func (r *DiffReport) String() string {
+	sb := new(strings.Builder)
+	for _, in := range r.Injectors {
+		fmt.Fprintf(sb, "%s.%s: %v\n", in.ImportPath, in.FuncName, in.Status)
+		for _, c := range in.Changes {
+			sb.WriteString("\t")
+			if c.Type != "" {
+				sb.WriteString(c.Type + ": ")
+			}
+			switch c.Kind {
+			case TypeAdded:
+				fmt.Fprintf(sb, "added, from %s", c.New)
+			case TypeRemoved:
+				fmt.Fprintf(sb, "removed, was from %s", c.Old)
+			default:
+				fmt.Fprintf(sb, "%v: %s -> %s", c.Kind, c.Old, c.New)
+			}
+			if c.Kind.Cosmetic() {
+				sb.WriteString(" (cosmetic)")
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,154 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGraphDiff(t *testing.T) {
+	load := func(t *testing.T, files map[string]string) *Info {
+		t.Helper()
+		wd, env := materialize(t, files)
+		info, errs := Load(context.Background(), wd, env, "", []string{"example.com/foo"})
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		return info
+	}
+	const fooGo = `package foo
+
+import "example.com/db"
+
+type Store interface{ Get() string }
+
+type store struct{}
+
+func (*store) Get() string { return "" }
+
+func newStore(*db.Pool) *store { return new(store) }
+
+type cachedStore struct{}
+
+func (*cachedStore) Get() string { return "" }
+
+func newCachedStore(*db.Pool) *cachedStore { return new(cachedStore) }
+
+type App struct{}
+
+func NewApp(Store) *App { return new(App) }
+`
+	old := load(t, map[string]string{
+		"example.com/db/db.go": `package db
+
+import "github.com/google/wire"
+
+type Size int
+
+type Pool struct{}
+
+func NewPool(Size) *Pool { return new(Pool) }
+
+var Set = wire.NewSet(NewPool)
+`,
+		"example.com/foo/foo.go": fooGo,
+		"example.com/foo/wire.go": `//go:build wireinject
+
+package foo
+
+import (
+	"example.com/db"
+	"github.com/google/wire"
+)
+
+func InitApp(size db.Size) *App {
+	panic(wire.Build(db.Set, newStore, wire.Bind(new(Store), new(*store)), NewApp))
+}
+
+func InitPool(size db.Size) *db.Pool {
+	panic(wire.Build(db.Set))
+}
+
+func InitGone(size db.Size) *db.Pool {
+	panic(wire.Build(db.Set))
+}
+`,
+	})
+	new := load(t, map[string]string{
+		"example.com/db/db.go": `package db
+
+import "github.com/google/wire"
+
+type Size int
+
+var Set = wire.NewSet(NewPool)
+`,
+		"example.com/db/pool.go": `package db
+
+type Pool struct{}
+
+func NewPool(Size) *Pool { return new(Pool) }
+`,
+		"example.com/foo/foo.go": fooGo,
+		"example.com/foo/wire.go": `//go:build wireinject
+
+package foo
+
+import (
+	"example.com/db"
+	"github.com/google/wire"
+)
+
+func InitApp(sz db.Size) *App {
+	panic(wire.Build(db.Set, newCachedStore, wire.Bind(new(Store), new(*cachedStore)), NewApp))
+}
+
+func InitPool(size db.Size) (*db.Pool, error) {
+	panic(wire.Build(db.Set))
+}
+
+func InitNew(size db.Size) *db.Pool {
+	panic(wire.Build(db.Set))
+}
+`,
+	})
+
+	report, err := GraphDiff(old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `example.com/foo.InitApp: changed
+	parameters renamed: func(size example.com/db.Size) *example.com/foo.App -> func(sz example.com/db.Size) *example.com/foo.App (cosmetic)
+	*example.com/db.Pool: source moved: db.go -> pool.go (cosmetic)
+	*example.com/foo.cachedStore: added, from example.com/foo.newCachedStore
+	*example.com/foo.store: removed, was from example.com/foo.newStore
+	example.com/foo.Store: source changed: wire.Bind(*example.com/foo.store) -> wire.Bind(*example.com/foo.cachedStore)
+example.com/foo.InitGone: removed
+example.com/foo.InitNew: added
+example.com/foo.InitPool: changed
+	signature changed: func(example.com/db.Size) *example.com/db.Pool -> func(example.com/db.Size) (*example.com/db.Pool, error)
+	*example.com/db.Pool: source moved: db.go -> pool.go (cosmetic)
+`
+	if diff := cmp.Diff(want, report.String()); diff != "" {
+		t.Errorf("GraphDiff report (-want +got):\n%s", diff)
+	}
+
+	if report, err := GraphDiff(old, old); err != nil || len(report.Injectors) > 0 {
+		t.Errorf("GraphDiff(old, old) = %v, %v; want no changes", report, err)
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/ast"
+	"runtime/debug"
+	"strings"
+
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/go/packages"
+)
+
+// GeneratedHeader is the line that marks a file as generated by Wire. It
+// follows the Go convention for generated files described at
+// https://golang.org/s/generatedcode, and comes after any header from
+// GenerateOptions.Header.
+const GeneratedHeader = "// Code generated by Wire. DO NOT EDIT."
+
+// Directives that follow GeneratedHeader in a generated file.
+const (
+	versionDirective     = "//wire:version "
+	fingerprintDirective = "//wire:options "
+	checksumDirective    = "//wire:checksum "
+)
+
+// minVersionDirective, in an injector file, gives the oldest version of
+// Wire that may generate the package.
+const minVersionDirective = "//wire:minversion "
+
+// version is the value of Version. Tests replace it.
+var version = moduleVersion()
+
+// Version returns the version of Wire recorded in generated files: the
+// version of the github.com/google/wire module that the program was built
+// with, or "(devel)" if it is not known.
+func Version() string {
+	return version
+}
+
+func moduleVersion() string {
+	const modulePath = "github.com/google/wire"
+	const devel = "(devel)"
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return devel
+	}
+	mod := &info.Main
+	if mod.Path != modulePath {
+		mod = nil
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				mod = dep
+				break
+			}
+		}
+	}
+	if mod == nil || mod.Version == "" {
+		return devel
+	}
+	if mod.Replace != nil && mod.Replace.Version != "" {
+		return mod.Replace.Version
+	}
+	return mod.Version
+}
+
+// FileInfo describes a file generated by Wire, as recorded in its header.
+type FileInfo struct {
+	// Version is the version of Wire that generated the file. It is empty
+	// if the file does not record it.
+	Version string
+	// OptionsFingerprint is the GenerateOptions.OptionsFingerprint of the
+	// options the file was generated with. It is empty if the file does not
+	// record it. A generated file is stale if its fingerprint differs from
+	// that of the options it would be generated with now.
+	OptionsFingerprint string
+	// Checksum is the checksum of the file's content when Wire wrote it. It
+	// is empty if the file does not record it. A file whose content no
+	// longer matches it has been edited by hand.
+	Checksum string
+}
+
+// IsGeneratedFile reports whether content is the source of a file
+// generated by Wire.
+func IsGeneratedFile(content []byte) bool {
+	_, ok := GeneratedFileInfo(content)
+	return ok
+}
+
+// GeneratedFileInfo returns the information recorded in the header of a
+// file generated by Wire. It reports false if content was not generated by
+// Wire. Only the comments before the package clause are examined.
+func GeneratedFileInfo(content []byte) (*FileInfo, bool) {
+	info := new(FileInfo)
+	generated := false
+	for len(content) > 0 {
+		var line []byte
+		line, content, _ = bytes.Cut(content, []byte("\n"))
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if bytes.HasPrefix(line, []byte("package ")) {
+			break
+		}
+		switch {
+		case string(line) == GeneratedHeader:
+			generated = true
+		case bytes.HasPrefix(line, []byte(versionDirective)):
+			info.Version = string(bytes.TrimSpace(line[len(versionDirective):]))
+		case bytes.HasPrefix(line, []byte(fingerprintDirective)):
+			info.OptionsFingerprint = string(bytes.TrimSpace(line[len(fingerprintDirective):]))
+		case bytes.HasPrefix(line, []byte(checksumDirective)):
+			info.Checksum = string(bytes.TrimSpace(line[len(checksumDirective):]))
+		}
+	}
+	if !generated {
+		return nil, false
+	}
+	return info, true
+}
+
+// writeGeneratedHeader writes the lines that identify a generated file and
+// record how it was generated.
+func writeGeneratedHeader(buf *bytes.Buffer, opts *GenerateOptions) {
+	buf.WriteString(GeneratedHeader + "\n\n")
+	buf.WriteString(versionDirective + version + "\n")
+	buf.WriteString(fingerprintDirective + opts.OptionsFingerprint() + "\n")
+}
+
+// addChecksum returns src, a formatted generated file, with a line that
+// records its checksum after the options fingerprint.
+func addChecksum(src []byte) []byte {
+	i := bytes.Index(src, []byte("\n"+fingerprintDirective))
+	if i < 0 {
+		return src
+	}
+	i++
+	i += bytes.IndexByte(src[i:], '\n') + 1
+	line := checksumDirective + checksum(src) + "\n"
+	out := make([]byte, 0, len(src)+len(line))
+	out = append(out, src[:i]...)
+	out = append(out, line...)
+	return append(out, src[i:]...)
+}
+
+// checksum returns the hex-encoded SHA-256 hash of content without the
+// lines that record how it was generated. Line endings are normalized, so
+// that checking out the file with CRLF line endings does not change it.
+func checksum(content []byte) string {
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	sum := sha256.Sum256(stripGeneratedHeader(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// editedByHand reports whether content, a file generated by Wire, no longer
+// matches the checksum recorded in it. Files that do not record one are
+// assumed to be unedited.
+func editedByHand(content []byte) bool {
+	info, ok := GeneratedFileInfo(content)
+	return ok && info.Checksum != "" && info.Checksum != checksum(content)
+}
+
+// stripGeneratedHeader returns src without the lines that record how it was
+// generated, so that they do not affect GenerateResult.ContentHash.
+// AnnotateOutput is part of the options fingerprint, for instance, but does
+// not change the code.
+func stripGeneratedHeader(src []byte) []byte {
+	lines := bytes.SplitAfter(src, []byte("\n"))
+	kept := lines[:0]
+	for _, line := range lines {
+		if bytes.HasPrefix(line, []byte(versionDirective)) || bytes.HasPrefix(line, []byte(fingerprintDirective)) || bytes.HasPrefix(line, []byte(checksumDirective)) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return bytes.Join(kept, nil)
+}
+
+// EqualIgnoringVersion reports whether the generated files a and b are the
+// same apart from the version of Wire that they record, so that a newer
+// Wire does not make otherwise identical output look stale.
+func EqualIgnoringVersion(a, b []byte) bool {
+	return bytes.Equal(stripVersion(a), stripVersion(b))
+}
+
+func stripVersion(src []byte) []byte {
+	lines := bytes.SplitAfter(src, []byte("\n"))
+	kept := lines[:0]
+	for _, line := range lines {
+		if !bytes.HasPrefix(line, []byte(versionDirective)) {
+			kept = append(kept, line)
+		}
+	}
+	return bytes.Join(kept, nil)
+}
+
+// checkRequiredVersion reports an error if the running Wire does not
+// satisfy opts.RequireVersion or the //wire:minversion directives of the
+// injector files of pkg.
+func checkRequiredVersion(pkg *packages.Package, opts *GenerateOptions) []error {
+	var errs []error
+	if opts.RequireVersion != "" {
+		if err := checkVersion(version, opts.RequireVersion); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, f := range sourceFiles(pkg) {
+		if !requiresTag(fileConstraint(f), opts.buildTag()) {
+			continue
+		}
+		for _, c := range minVersionComments(f) {
+			min := strings.TrimSpace(strings.TrimPrefix(c.Text, minVersionDirective))
+			var err error
+			switch {
+			case !semver.IsValid(min):
+				err = fmt.Errorf("%s: %q is not a semantic version", strings.TrimSpace(minVersionDirective), min)
+			case semver.IsValid(version) && semver.Compare(version, min) < 0:
+				err = fmt.Errorf("wire %s is older than %s, the minimum version for this package", version, min)
+			}
+			if err != nil {
+				errs = append(errs, notePosition(pkg.Fset.Position(c.Pos()), err))
+			}
+		}
+	}
+	return errs
+}
+
+// minVersionComments returns the //wire:minversion directives of f.
+func minVersionComments(f *ast.File) []*ast.Comment {
+	var dirs []*ast.Comment
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			if strings.HasPrefix(c.Text, minVersionDirective) {
+				dirs = append(dirs, c)
+			}
+		}
+	}
+	return dirs
+}
+
+// checkVersion reports an error if v does not satisfy constraint, a
+// comma-separated list of comparisons with semantic versions such as
+// ">=v0.6.0, <v0.8.0". A version without an operator is a minimum. A v that
+// is not a semantic version, as for a development build, satisfies every
+// constraint.
+func checkVersion(v, constraint string) error {
+	for _, cmp := range strings.Split(constraint, ",") {
+		cmp = strings.TrimSpace(cmp)
+		want := strings.TrimLeft(cmp, "<>=!")
+		op := strings.TrimSpace(cmp[:len(cmp)-len(want)])
+		want = strings.TrimSpace(want)
+		if !semver.IsValid(want) {
+			return fmt.Errorf("invalid version constraint %q: %q is not a semantic version", constraint, want)
+		}
+		c := semver.Compare(v, want)
+		var ok bool
+		switch op {
+		case "", ">=":
+			ok = c >= 0
+		case ">":
+			ok = c > 0
+		case "<=":
+			ok = c <= 0
+		case "<":
+			ok = c < 0
+		case "=", "==":
+			ok = c == 0
+		case "!=":
+			ok = c != 0
+		default:
+			return fmt.Errorf("invalid version constraint %q: unknown operator %q", constraint, op)
+		}
+		if !ok && semver.IsValid(v) {
+			return fmt.Errorf("wire %s does not satisfy version constraint %q", v, constraint)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,319 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGeneratedFileInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *FileInfo
+	}{
+		{
+			name:    "Generated",
+			content: GeneratedHeader + "\n\n" + versionDirective + "v1.2.3\n" + fingerprintDirective + "abc\n" + checksumDirective + "def\n//+build !wireinject\n\npackage foo\n",
+			want:    &FileInfo{Version: "v1.2.3", OptionsFingerprint: "abc", Checksum: "def"},
+		},
+		{
+			name:    "CustomHeader",
+			content: "// Copyright 2018 The Wire Authors\r\n\r\n" + GeneratedHeader + "\r\n\r\npackage foo\r\n",
+			want:    &FileInfo{},
+		},
+		{
+			name:    "NotGenerated",
+			content: "package foo\n",
+		},
+		{
+			name:    "HeaderAfterPackageClause",
+			content: "package foo\n\n" + GeneratedHeader + "\n",
+		},
+		{
+			name:    "OtherGenerator",
+			content: "// Code generated by stringer. DO NOT EDIT.\n\npackage foo\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			want := test.want != nil
+			if got := IsGeneratedFile([]byte(test.content)); got != want {
+				t.Errorf("IsGeneratedFile(...) = %t; want %t", got, want)
+			}
+			got, ok := GeneratedFileInfo([]byte(test.content))
+			if ok != want {
+				t.Fatalf("GeneratedFileInfo(...) reports %t; want %t", ok, want)
+			}
+			if ok && *got != *test.want {
+				t.Errorf("GeneratedFileInfo(...) = %+v; want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCommitDoesNotOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "wire_gen.go")
+	gen := GenerateResult{
+		OutputPath: path,
+		Content:    []byte(GeneratedHeader + "\n\npackage foo\n"),
+	}
+	if err := gen.Commit(); err != nil {
+		t.Fatal("Commit of a new file:", err)
+	}
+	if err := gen.Commit(); err != nil {
+		t.Fatal("Commit over a generated file:", err)
+	}
+
+	const handWritten = "package foo\n\nfunc main() {}\n"
+	if err := ioutil.WriteFile(path, []byte(handWritten), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := gen.Commit(); err == nil {
+		t.Error("Commit over a hand-written file succeeded; want error")
+	}
+	if got, err := ioutil.ReadFile(path); err != nil || string(got) != handWritten {
+		t.Errorf("after Commit, file = %q, %v; want %q", got, err, handWritten)
+	}
+}
+
+func TestCommitEditedByHand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wire_gen.go")
+	generated := func(body string) []byte {
+		src := GeneratedHeader + "\n\n" + versionDirective + "v1.2.3\n" + fingerprintDirective + "abc\n\npackage foo\n" + body
+		return addChecksum([]byte(src))
+	}
+	old := GenerateResult{OutputPath: path, Content: generated("\nvar x = 1\n")}
+	if err := old.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := GeneratedFileInfo(old.Content); info.Checksum == "" || editedByHand(old.Content) {
+		t.Fatalf("generated file has checksum %q, edited = %t; want a matching checksum", info.Checksum, editedByHand(old.Content))
+	}
+	crlf := bytes.ReplaceAll(old.Content, []byte("\n"), []byte("\r\n"))
+	if editedByHand(crlf) {
+		t.Error("editedByHand reports a file with CRLF line endings as edited")
+	}
+
+	// Regenerating over an unedited file succeeds.
+	gen := GenerateResult{OutputPath: path, Content: generated("\nvar x = 2\n")}
+	if err := gen.Commit(); err != nil {
+		t.Fatal("Commit over an unedited file:", err)
+	}
+
+	edited := bytes.Replace(gen.Content, []byte("var x = 2"), []byte("var x = 3"), 1)
+	if err := ioutil.WriteFile(path, edited, 0666); err != nil {
+		t.Fatal(err)
+	}
+	// The same content is left alone, even over an edited file.
+	same := GenerateResult{OutputPath: path, Content: edited}
+	if err := same.Commit(); err != nil {
+		t.Error("Commit of the file's own content:", err)
+	}
+	err := gen.Commit()
+	if err == nil || !strings.Contains(err.Error(), "edited by hand") || !strings.Contains(err.Error(), path) {
+		t.Errorf("Commit over an edited file = %v; want an error naming %s", err, path)
+	}
+	if got, _ := ioutil.ReadFile(path); !bytes.Equal(got, edited) {
+		t.Errorf("Commit overwrote the edited file:\n%s", got)
+	}
+
+	gen.force = true
+	if err := gen.Commit(); err != nil {
+		t.Fatal("Commit with Force:", err)
+	}
+	if got, _ := ioutil.ReadFile(path); !bytes.Equal(got, gen.Content) {
+		t.Errorf("Commit with Force wrote:\n%s\nwant:\n%s", got, gen.Content)
+	}
+}
+
+func TestCommitAll(t *testing.T) {
+	dir := t.TempDir()
+	content := func(s string) []byte {
+		return []byte(GeneratedHeader + "\n\npackage foo\n\n" + s + "\n")
+	}
+	path := func(pkg string) string {
+		return filepath.Join(dir, pkg+"_gen.go")
+	}
+	// a was generated before, b was not, and c is hand-written.
+	if err := ioutil.WriteFile(path("a"), content("// old"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	const handWritten = "package foo\n"
+	if err := ioutil.WriteFile(path("c"), []byte(handWritten), 0666); err != nil {
+		t.Fatal(err)
+	}
+	results := []*GenerateResult{
+		{PkgPath: "example.com/a", OutputPath: path("a"), Content: content("// new")},
+		{PkgPath: "example.com/b", OutputPath: path("b"), Content: content("// new")},
+		{PkgPath: "example.com/c", OutputPath: path("c"), Content: content("// new")},
+	}
+	check := func(t *testing.T) {
+		t.Helper()
+		if got, _ := ioutil.ReadFile(path("a")); !bytes.Equal(got, content("// old")) {
+			t.Errorf("a_gen.go = %q; want the old content", got)
+		}
+		if _, err := os.Stat(path("b")); !os.IsNotExist(err) {
+			t.Errorf("b_gen.go exists (%v); want it not to", err)
+		}
+		if got, _ := ioutil.ReadFile(path("c")); string(got) != handWritten {
+			t.Errorf("c_gen.go = %q; want %q", got, handWritten)
+		}
+	}
+
+	t.Run("CheckFails", func(t *testing.T) {
+		err := CommitAll(results)
+		if err == nil || !strings.HasPrefix(err.Error(), "example.com/c: ") || !strings.Contains(err.Error(), "not generated by Wire") {
+			t.Errorf("CommitAll = %v; want an error for example.com/c", err)
+		}
+		check(t)
+	})
+	t.Run("WriteFails", func(t *testing.T) {
+		results := append(results[:2:2], &GenerateResult{PkgPath: "example.com/d", OutputPath: path("d"), Content: content("// new")})
+		defer func() { commitWriteFile = ioutil.WriteFile }()
+		commitWriteFile = func(name string, data []byte, perm os.FileMode) error {
+			if name == path("d") {
+				ioutil.WriteFile(name, data[:1], perm)
+				return errors.New("no space left on device")
+			}
+			return ioutil.WriteFile(name, data, perm)
+		}
+		err := CommitAll(results)
+		if want := "example.com/d: no space left on device"; err == nil || err.Error() != want {
+			t.Errorf("CommitAll = %v; want %q", err, want)
+		}
+		check(t)
+		if _, err := os.Stat(path("d")); !os.IsNotExist(err) {
+			t.Errorf("d_gen.go exists (%v); want it removed", err)
+		}
+	})
+	t.Run("Succeeds", func(t *testing.T) {
+		if err := CommitAll(results[:2]); err != nil {
+			t.Fatal(err)
+		}
+		for _, pkg := range []string{"a", "b"} {
+			if got, _ := ioutil.ReadFile(path(pkg)); !bytes.Equal(got, content("// new")) {
+				t.Errorf("%s_gen.go = %q; want the new content", pkg, got)
+			}
+		}
+	})
+}
+
+func TestCheckVersion(t *testing.T) {
+	tests := []struct {
+		version    string
+		constraint string
+		want       bool
+	}{
+		{"v0.6.0", "v0.6.0", true},
+		{"v0.5.9", "v0.6.0", false},
+		{"v0.6.0", ">= v0.6.0, <v0.8.0", true},
+		{"v0.8.0", ">= v0.6.0, <v0.8.0", false},
+		{"v0.7.1", "<=v0.7.1", true},
+		{"v0.7.1", ">v0.7.1", false},
+		{"v0.7.1", "=v0.7.1", true},
+		{"v0.7.1", "!=v0.7.1", false},
+		{"v0.7.1-0.20240101000000-abcdefabcdef", ">=v0.7.0", true},
+		{"(devel)", ">=v9.0.0", true},
+	}
+	for _, test := range tests {
+		err := checkVersion(test.version, test.constraint)
+		if got := err == nil; got != test.want {
+			t.Errorf("checkVersion(%q, %q) = %v; want satisfied = %t", test.version, test.constraint, err, test.want)
+		}
+	}
+	for _, constraint := range []string{"0.6.0", "~>v0.6.0", ">=v0.6.0,"} {
+		if err := checkVersion("(devel)", constraint); err == nil {
+			t.Errorf("checkVersion(\"(devel)\", %q) succeeded; want invalid constraint error", constraint)
+		}
+	}
+}
+
+func TestEqualIgnoringVersion(t *testing.T) {
+	gen := func(version, fingerprint string) []byte {
+		return []byte(GeneratedHeader + "\n\n" + versionDirective + version + "\n" + fingerprintDirective + fingerprint + "\n\npackage foo\n")
+	}
+	if !EqualIgnoringVersion(gen("v0.6.0", "abc"), gen("v0.7.0", "abc")) {
+		t.Error("files that differ in version are not equal")
+	}
+	if EqualIgnoringVersion(gen("v0.6.0", "abc"), gen("v0.6.0", "def")) {
+		t.Error("files that differ in options are equal")
+	}
+}
+
+func TestGenerateRequireVersion(t *testing.T) {
+	wd, env := materialize(t, map[string]string{
+		"example.com/foo/foo.go": `package main
+
+func main() {}
+
+func provideMessage() string { return "hello" }
+`,
+		"example.com/foo/wire.go": `//go:build wireinject
+
+// Requires the versions below.
+//
+//wire:minversion v0.7.0
+
+package main
+
+import "github.com/google/wire"
+
+func injectMessage() string {
+	panic(wire.Build(provideMessage))
+}
+`,
+	})
+	defer func(v string) { version = v }(version)
+
+	tests := []struct {
+		version, require string
+		wantErr          string
+	}{
+		{"v0.7.0", "", ""},
+		{"(devel)", "", ""},
+		{"v0.6.0", "", "wire v0.6.0 is older than v0.7.0"},
+		{"v0.7.0", ">=v0.7.0, <v0.8.0", ""},
+		{"v0.8.0", ">=v0.7.0, <v0.8.0", "does not satisfy version constraint"},
+	}
+	for _, tc := range tests {
+		version = tc.version
+		opts := &GenerateOptions{RequireVersion: tc.require}
+		gens, errs := Generate(context.Background(), wd, env, []string{"example.com/foo"}, opts)
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		gen := gens[0]
+		if tc.wantErr == "" {
+			if len(gen.Errs) > 0 {
+				t.Errorf("version %s, constraint %q: %v", tc.version, tc.require, gen.Errs)
+			} else if info, _ := GeneratedFileInfo(gen.Content); info.Version != tc.version {
+				t.Errorf("version %s: generated file records %q", tc.version, info.Version)
+			}
+			continue
+		}
+		if len(gen.Errs) != 1 || !strings.Contains(gen.Errs[0].Error(), tc.wantErr) {
+			t.Errorf("version %s, constraint %q: errors = %v; want one containing %q", tc.version, tc.require, gen.Errs, tc.wantErr)
+		}
+	}
+}
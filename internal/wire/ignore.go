@@ -0,0 +1,151 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// ignoreDirective, in a comment before the package clause of any file of a
+// package, makes Wire skip the package when it matches the patterns, such
+// as for generated mocks of the wire package whose calls look like
+// injectors. Text after the directive, such as a reason, is ignored.
+const ignoreDirective = "//wire:ignore"
+
+// ignoreFileName is the name of the file at the root of a module that lists
+// globs of the packages that Wire skips, one per line, as directories
+// relative to the root. A glob ending in "/..." also matches the
+// directories below it. Blank lines and lines starting with # are ignored.
+const ignoreFileName = ".wireignore"
+
+// An ignoreGlobs is the content of a .wireignore file.
+type ignoreGlobs struct {
+	globs []string
+	err   error
+}
+
+// match reports whether the directory rel, relative to the module root and
+// separated by slashes, matches one of the globs.
+func (ig *ignoreGlobs) match(rel string) bool {
+	for _, g := range ig.globs {
+		if prefix := strings.TrimSuffix(g, "/..."); prefix != g {
+			if prefix == "." || rel == prefix || strings.HasPrefix(rel, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(g, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIgnoreFile parses the .wireignore file at filename, whose content is
+// src.
+func parseIgnoreFile(filename string, src []byte) *ignoreGlobs {
+	ig := new(ignoreGlobs)
+	sc := bufio.NewScanner(bytes.NewReader(src))
+	for line := 1; sc.Scan(); line++ {
+		g := strings.TrimSpace(sc.Text())
+		if g == "" || strings.HasPrefix(g, "#") {
+			continue
+		}
+		g = strings.TrimSuffix(path.Clean(g), "/")
+		if _, err := path.Match(strings.TrimSuffix(g, "/..."), ""); err != nil {
+			ig.err = fmt.Errorf("%s:%d: invalid glob %q: %v", filename, line, g, err)
+			return ig
+		}
+		ig.globs = append(ig.globs, g)
+	}
+	return ig
+}
+
+// filterIgnored returns pkgs without the packages that a //wire:ignore
+// directive or a .wireignore file excludes, and the import paths of those,
+// in the order of pkgs. It only reads the headers of the files, so the
+// ignored packages are never type-checked unless other packages import
+// them.
+func (ic *importCache) filterIgnored(pkgs []*packages.Package) (kept []*packages.Package, ignored []string, _ []error) {
+	files := make(map[string]*ignoreGlobs) // by module root
+	var errs []error
+	for _, pkg := range pkgs {
+		skip := false
+		if m := pkg.Module; m != nil && m.Dir != "" && len(pkg.GoFiles) > 0 {
+			ig, ok := files[m.Dir]
+			if !ok {
+				ig = new(ignoreGlobs)
+				name := filepath.Join(m.Dir, ignoreFileName)
+				if src, err := ic.readFile(name); err == nil {
+					ig = parseIgnoreFile(name, src)
+					if ig.err != nil {
+						errs = append(errs, ig.err)
+					}
+				}
+				files[m.Dir] = ig
+			}
+			rel, err := filepath.Rel(m.Dir, filepath.Dir(pkg.GoFiles[0]))
+			skip = err == nil && ig.match(filepath.ToSlash(rel))
+		}
+		if !skip {
+			skip = ic.hasIgnoreDirective(pkg)
+		}
+		if skip {
+			ignored = append(ignored, pkg.PkgPath)
+		} else {
+			kept = append(kept, pkg)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, nil, errs
+	}
+	return kept, ignored, nil
+}
+
+// hasIgnoreDirective reports whether a file of pkg has an ignoreDirective
+// before its package clause.
+func (ic *importCache) hasIgnoreDirective(pkg *packages.Package) bool {
+	fset := token.NewFileSet()
+	for _, name := range pkg.GoFiles {
+		src, err := ic.readFile(name)
+		if err != nil || !bytes.Contains(src, []byte(ignoreDirective)) {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, src, parser.PackageClauseOnly|parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		for _, cg := range f.Comments {
+			if cg.Pos() > f.Package {
+				break
+			}
+			for _, c := range cg.List {
+				if c.Text == ignoreDirective || strings.HasPrefix(c.Text, ignoreDirective+" ") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,767 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// metadataMode is the load mode for the go list part of loading packages.
+// An importCache does the parsing and type-checking itself.
+const metadataMode = packages.NeedName |
+	packages.NeedFiles |
+	packages.NeedCompiledGoFiles |
+	packages.NeedImports |
+	packages.NeedDeps |
+	packages.NeedTypesSizes |
+	packages.NeedModule
+
+// An importCache type-checks the packages loaded during one run of Wire.
+// Standard library packages are imported from the export data in the go
+// command's build cache instead of being type-checked from source, and every
+// other package is type-checked at most once, however many loads and
+// goroutines ask for it. Packages checked by the same importCache therefore
+// share their types and their token.FileSet.
+//
+// The importCaches that forDir derives from one another, one per working
+// directory, share the packages that they check.
+type importCache struct {
+	ctx        context.Context
+	fset       *token.FileSet
+	wd         string
+	env        []string
+	buildFlags []string
+	// overlay maps the absolute paths of files to contents that replace
+	// those on disk, as with packages.Config.Overlay.
+	overlay map[string][]byte
+	// loadPackages runs go list. It is packages.Load unless a test or
+	// benchmark replays a loadSnapshot.
+	loadPackages packageLoader
+
+	*checkedPackages
+	// keys caches the checkKey of each package loaded by this importCache.
+	// It is guarded by mu.
+	keys map[*packages.Package]string
+
+	// stats, if not nil, records each run of loadPackages, including those
+	// of batch loaders that share the importCache.
+	stats *loadStats
+
+	// skipBodies type-checks the packages other than the roots, the
+	// packages that load and checkRoots are asked for, without their
+	// function bodies, which Wire does not look into unless it checks the
+	// bodies of providers. The syntax of the bodies is still there, and the
+	// declarations are checked in full, so positions and objects are the
+	// same either way.
+	skipBodies bool
+
+	// ignored holds the import paths of the packages matching the patterns
+	// of the last load that filterIgnored skipped.
+	ignored []string
+}
+
+// checkedPackages holds the packages type-checked by the importCaches that
+// share it.
+type checkedPackages struct {
+	// stdMu serializes use of std, which is not safe for concurrent use.
+	stdMu sync.Mutex
+	std   types.Importer
+
+	mu sync.Mutex
+	// exports maps the import paths of standard library packages to their
+	// export data files, or to the empty string if the go command did not
+	// produce one.
+	exports map[string]string
+	// checked maps the checkKey of each package to the package.
+	checked map[string]*checkedPackage
+	// roots holds the checkKeys of the roots, which are type-checked with
+	// their function bodies even when they are imported first.
+	roots map[string]bool
+}
+
+// A checkedPackage is a package that is being or has been type-checked.
+// done is closed once pkg is filled in.
+type checkedPackage struct {
+	done chan struct{}
+	pkg  *packages.Package
+	// bodies reports that the function bodies of pkg were checked.
+	bodies bool
+}
+
+func newImportCache(ctx context.Context, wd string, env []string, buildTag, tags string, overlay map[string][]byte) *importCache {
+	ic := &importCache{
+		ctx:        ctx,
+		fset:       token.NewFileSet(),
+		wd:         canonicalPath(wd),
+		env:        completeEnv(env),
+		buildFlags: []string{"-tags=" + buildTag, "-mod=readonly"},
+		overlay:    canonicalOverlay(overlay),
+		checkedPackages: &checkedPackages{
+			exports: make(map[string]string),
+			checked: make(map[string]*checkedPackage),
+			roots:   make(map[string]bool),
+		},
+		keys: make(map[*packages.Package]string),
+
+		loadPackages: packages.Load,
+	}
+	if len(tags) > 0 {
+		ic.buildFlags[0] += " " + tags
+	}
+	ic.std = importer.ForCompiler(ic.fset, "gc", ic.lookupExport)
+	return ic
+}
+
+// forDir returns an importCache that loads packages from the working
+// directory wd, with ctx, and otherwise as ic does. The two share the
+// packages that they type-check, so a package that the modules of both
+// directories depend on, built from the same files and dependencies, is
+// checked once. Each reports its own loads to ic.stats.
+func (ic *importCache) forDir(ctx context.Context, wd string) *importCache {
+	return &importCache{
+		ctx:             ctx,
+		fset:            ic.fset,
+		wd:              canonicalPath(wd),
+		env:             ic.env,
+		buildFlags:      ic.buildFlags,
+		overlay:         ic.overlay,
+		loadPackages:    ic.loadPackages,
+		checkedPackages: ic.checkedPackages,
+		keys:            make(map[*packages.Package]string),
+		stats:           ic.stats,
+		skipBodies:      ic.skipBodies,
+	}
+}
+
+// checkKey returns the key of pkg in ic.checked: its import path for a
+// standard library package, and otherwise a hash of its import path, its
+// directory and the keys of its imports, since importCaches for different
+// modules may load different versions of a package with the same import
+// path, or the same version built against different dependencies. ic.mu
+// must be held.
+func (ic *importCache) checkKey(pkg *packages.Package) string {
+	if isStandard(pkg) {
+		return pkg.PkgPath
+	}
+	if key, ok := ic.keys[pkg]; ok {
+		return key
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", pkg.PkgPath)
+	for _, f := range compiledGoFiles(pkg) {
+		fmt.Fprintf(h, "%s\n", f)
+	}
+	paths := make([]string, 0, len(pkg.Imports))
+	for path := range pkg.Imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(h, "%s %s\n", path, ic.checkKey(pkg.Imports[path]))
+	}
+	key := pkg.PkgPath + "@" + hex.EncodeToString(h.Sum(nil))
+	ic.keys[pkg] = key
+	return key
+}
+
+// canonicalPath returns path with symbolic links resolved, so that a package
+// reached through a symlinked checkout has the same directory, file names
+// and, outside of modules, import path as when reached directly. If path
+// does not exist, only its directory is resolved. If that fails too, path
+// is returned as is.
+func canonicalPath(path string) string {
+	if path == "" {
+		return path
+	}
+	if p, err := filepath.EvalSymlinks(path); err == nil {
+		return p
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		return filepath.Join(dir, filepath.Base(path))
+	}
+	return path
+}
+
+// canonicalOverlay returns overlay with its paths passed through
+// canonicalPath, to match the file names that go list reports.
+func canonicalOverlay(overlay map[string][]byte) map[string][]byte {
+	if len(overlay) == 0 {
+		return overlay
+	}
+	m := make(map[string][]byte, len(overlay))
+	for path, content := range overlay {
+		m[canonicalPath(path)] = content
+	}
+	return m
+}
+
+// config returns the packages.Config for running go list with mode.
+func (ic *importCache) config(mode packages.LoadMode) *packages.Config {
+	return &packages.Config{
+		Context:    ic.ctx,
+		Mode:       mode,
+		Dir:        ic.wd,
+		Env:        ic.env,
+		BuildFlags: append([]string(nil), ic.buildFlags...),
+		Overlay:    ic.overlay,
+	}
+}
+
+// load loads the packages matching patterns along with their dependencies.
+// A pattern ending in .go names a file, which stands for the package that
+// the file belongs to, as resolved by filePackages.
+func (ic *importCache) load(patterns []string) ([]*packages.Package, []error) {
+	progress := newLoadProgress(ic.ctx)
+	pkgs, errs := ic.listRoots(patterns, progress)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return ic.checkRoots(pkgs, progress)
+}
+
+// listRoots is the first half of load: it lists the packages matching
+// patterns without checking them, leaving out those that filterIgnored
+// skips.
+func (ic *importCache) listRoots(patterns []string, progress *loadProgress) ([]*packages.Package, []error) {
+	pkgs, errs := ic.list(patterns, progress)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	pkgs, ic.ignored, errs = ic.filterIgnored(pkgs)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return pkgs, nil
+}
+
+// checkRoots is the second half of load: it type-checks the packages that
+// listRoots returned and reports their errors.
+func (ic *importCache) checkRoots(pkgs []*packages.Package, progress *loadProgress) ([]*packages.Package, []error) {
+	var errs []error
+	ic.markRoots(pkgs)
+	if err := ic.check(pkgs, progress); err != nil {
+		return nil, []error{err}
+	}
+	for _, p := range pkgs {
+		for _, e := range p.Errors {
+			errs = append(errs, explainLoadError(p, e)...)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return pkgs, nil
+}
+
+// markRoots records that pkgs are roots, whose function bodies are checked
+// even if another root imports them and is checked first.
+func (ic *importCache) markRoots(pkgs []*packages.Package) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	for _, p := range pkgs {
+		ic.roots[ic.checkKey(p)] = true
+	}
+}
+
+// list runs go list for the packages matching patterns and their
+// dependencies with metadataMode, without checking them. Patterns are as
+// for load.
+func (ic *importCache) list(patterns []string, progress *loadProgress) ([]*packages.Package, []error) {
+	patterns, errs := ic.filePackages(patterns)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	escaped := make([]string, len(patterns))
+	for i := range patterns {
+		escaped[i] = "pattern=" + patterns[i]
+	}
+	start := time.Now()
+	pkgs, err := ic.loadPackages(ic.config(metadataMode), escaped...)
+	ic.stats.loaded(false, patterns, pkgs, time.Since(start))
+	if err := progress.interrupted(); err != nil {
+		return nil, []error{err}
+	}
+	if err != nil {
+		return nil, []error{err}
+	}
+	return pkgs, nil
+}
+
+// filePackages returns patterns with each pattern that ends in .go, which
+// names a file relative to the working directory, replaced by the import
+// path of the package that the file belongs to, as with the file arguments
+// of go build but without making up a package of its own for them. Files of
+// the same package, and files of a package that other patterns also name,
+// lead to the package only once. A file that belongs to no package with the
+// build tags of ic, such as one that its build constraints exclude, is an
+// error.
+func (ic *importCache) filePackages(patterns []string) ([]string, []error) {
+	var files []string
+	for _, p := range patterns {
+		if strings.HasSuffix(p, ".go") {
+			files = append(files, p)
+		}
+	}
+	if len(files) == 0 {
+		return patterns, nil
+	}
+	queries := make([]string, len(files))
+	abs := make(map[string]string, len(files)) // file pattern to absolute path
+	for i, f := range files {
+		path := f
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(ic.wd, path)
+		}
+		abs[f] = canonicalPath(path)
+		queries[i] = "file=" + abs[f]
+	}
+	pkgs, err := ic.loadPackages(ic.config(packages.NeedName|packages.NeedFiles), queries...)
+	if err != nil {
+		return nil, []error{err}
+	}
+	owner := make(map[string]string) // absolute path to import path
+	for _, pkg := range pkgs {
+		for _, f := range pkg.GoFiles {
+			owner[canonicalPath(f)] = pkg.PkgPath
+		}
+	}
+	var out []string
+	var errs []error
+	seen := make(map[string]bool)
+	for _, p := range patterns {
+		if path, ok := abs[p]; ok {
+			pkgPath := owner[path]
+			if pkgPath == "" {
+				errs = append(errs, fmt.Errorf("%s is not part of any package built with %s; its build constraints may exclude it", p, ic.buildFlags[0]))
+				continue
+			}
+			p = pkgPath
+		}
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return out, nil
+}
+
+// explainLoadError returns e, an error of pkg, or errors that explain it
+// in terms of Wire. The go command reports an import of an internal package
+// of another module, as a replace directive can allow during development,
+// at the import. If pkg refers to provider sets of that package, each
+// reference is reported instead, along with the injector it is in. A name
+// that is undefined because it is declared only in files that the build
+// constraints exclude, such as a provider in a _windows.go file, is
+// reported along with those files.
+func explainLoadError(pkg *packages.Package, e packages.Error) []error {
+	if files := excludedDecl(pkg, e); len(files) > 0 {
+		e.Msg += fmt.Sprintf(" (declared only in %s, which the build constraints exclude with the current GOOS, GOARCH and tags)", strings.Join(files, ", "))
+		return []error{e}
+	}
+	const prefix, suffix = "use of internal package ", " not allowed"
+	if !strings.HasPrefix(e.Msg, prefix) || !strings.HasSuffix(e.Msg, suffix) || pkg.TypesInfo == nil {
+		return []error{e}
+	}
+	path := strings.TrimSuffix(strings.TrimPrefix(e.Msg, prefix), suffix)
+	var errs []error
+	for _, f := range sourceFiles(pkg) {
+		for _, decl := range f.Decls {
+			fn, _ := decl.(*ast.FuncDecl)
+			var buildCall *ast.CallExpr
+			if fn != nil {
+				buildCall, _ = findInjectorBuild(pkg.TypesInfo, fn)
+			}
+			ast.Inspect(decl, func(node ast.Node) bool {
+				id, ok := node.(*ast.Ident)
+				if !ok {
+					return true
+				}
+				obj, ok := pkg.TypesInfo.Uses[id].(*types.Var)
+				if !ok || obj.Pkg() == nil || obj.Pkg().Path() != path || !isProviderSetType(obj.Type()) {
+					return true
+				}
+				err := notePosition(pkg.Fset.Position(id.Pos()), internalSetError(obj, pkg.PkgPath))
+				if buildCall != nil {
+					err = injectorError(pkg.Fset.Position(fn.Pos()), fn.Name.Name, err)
+				}
+				errs = append(errs, err)
+				return true
+			})
+		}
+	}
+	if len(errs) == 0 {
+		return []error{e}
+	}
+	return errs
+}
+
+// excludedDecl returns the base names of the ignored files that declare
+// the name that e, a type error of pkg, reports as undefined, as in
+// "undefined: bar.NewConsole". The name is looked up in the imports of pkg
+// that have the qualifier as their package name, or in pkg itself if it is
+// not qualified.
+func excludedDecl(pkg *packages.Package, e packages.Error) []string {
+	if e.Kind != packages.TypeError {
+		return nil
+	}
+	name := strings.TrimPrefix(e.Msg, "undefined: ")
+	if name == e.Msg {
+		return nil
+	}
+	candidates := []*packages.Package{pkg}
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		qual := name[:i]
+		name = name[i+1:]
+		candidates = nil
+		for _, imp := range pkg.Imports {
+			if imp.Name == qual {
+				candidates = append(candidates, imp)
+			}
+		}
+	}
+	var files []string
+	fset := token.NewFileSet()
+	for _, p := range candidates {
+		for _, filename := range p.IgnoredFiles {
+			f, err := parser.ParseFile(fset, filename, nil, parser.SkipObjectResolution)
+			if err != nil || f.Name.Name != p.Name || !declares(f, name) {
+				continue
+			}
+			files = append(files, filepath.Base(filename))
+		}
+	}
+	return files
+}
+
+// declares reports whether f declares name at package level. Methods are
+// not package-level declarations.
+func declares(f *ast.File, name string) bool {
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv == nil && decl.Name.Name == name {
+				return true
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.ValueSpec:
+					for _, id := range spec.Names {
+						if id.Name == name {
+							return true
+						}
+					}
+				case *ast.TypeSpec:
+					if spec.Name.Name == name {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// internalSetError returns the error for a reference to the provider set
+// set from the package with import path from, which may not import it.
+func internalSetError(set types.Object, from string) error {
+	return fmt.Errorf("provider set %s.%s is in an internal package not importable from %s", set.Pkg().Path(), set.Name(), from)
+}
+
+// importAllowed reports whether the package with import path from may
+// import path under the go command's rule for internal packages: a package
+// whose path has an internal element may only be imported from the tree
+// rooted at the parent of that element.
+func importAllowed(from, path string) bool {
+	var parent string
+	switch {
+	case strings.HasSuffix(path, "/internal"):
+		parent = strings.TrimSuffix(path, "/internal")
+	case strings.Contains(path, "/internal/"):
+		parent = path[:strings.LastIndex(path, "/internal/")]
+	default:
+		// Paths that start with internal belong to the standard library.
+		return true
+	}
+	return from == parent || strings.HasPrefix(from, parent+"/")
+}
+
+// check fills in the syntax and type information of pkgs, which must have
+// been loaded with metadataMode or less, and of their dependencies. If a
+// package was already checked by ic, its fields are copied from the earlier
+// package, so that types are shared. Errors are recorded in the packages,
+// except for the error of a cut-short load, which is returned.
+func (ic *importCache) check(pkgs []*packages.Package, progress *loadProgress) error {
+	if err := ic.findExports(pkgs); err != nil {
+		return err
+	}
+	for _, p := range pkgs {
+		ic.checkPackage(p, true, progress)
+	}
+	return progress.interrupted()
+}
+
+// isStandard reports whether pkg belongs to the standard library. Outside of a module, paths whose first element
+// contains no dot are reserved for the standard library.
+func isStandard(pkg *packages.Package) bool {
+	if pkg.Module != nil {
+		return false
+	}
+	return isStandardPath(pkg.PkgPath)
+}
+
+// findExports records the export data files of the standard library
+// packages imported by the packages that are type-checked from source: pkgs
+// themselves and the packages outside of the standard library that they
+// depend on. The export data file of a package describes all of the types
+// it refers to, so the standard library packages imported only by other
+// standard library packages are not needed.
+func (ic *importCache) findExports(pkgs []*packages.Package) error {
+	var need []string
+	seen := make(map[string]bool)
+	root := make(map[*packages.Package]bool)
+	for _, p := range pkgs {
+		root[p] = true
+	}
+	ic.mu.Lock()
+	packages.Visit(pkgs, func(p *packages.Package) bool {
+		if (isStandard(p) && !root[p]) || seen[p.PkgPath] {
+			return false
+		}
+		seen[p.PkgPath] = true
+		for path, imp := range p.Imports {
+			if _, ok := ic.exports[path]; !ok && path != "unsafe" && isStandard(imp) && !seen[path] {
+				seen[path] = true
+				need = append(need, path)
+			}
+		}
+		return true
+	}, nil)
+	ic.mu.Unlock()
+	if len(need) == 0 {
+		return nil
+	}
+	listed, err := ic.loadPackages(ic.config(packages.NeedName|packages.NeedExportFile), need...)
+	if err != nil {
+		return err
+	}
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	for _, path := range need {
+		ic.exports[path] = ""
+	}
+	for _, p := range listed {
+		if len(p.Errors) == 0 {
+			ic.exports[p.PkgPath] = p.ExportFile
+		}
+	}
+	return nil
+}
+
+// lookupExport opens the export data file of the package with import path
+// pkgPath. It is the lookup function of ic.std.
+func (ic *importCache) lookupExport(pkgPath string) (io.ReadCloser, error) {
+	ic.mu.Lock()
+	file := ic.exports[pkgPath]
+	ic.mu.Unlock()
+	if file == "" {
+		return nil, fmt.Errorf("no export data for %s", pkgPath)
+	}
+	return os.Open(file)
+}
+
+// checkPackage fills in pkg and its dependencies, type-checking them if no
+// other call has done so yet and waiting for that call otherwise. A root,
+// a package asked for by name, is type-checked from source, since Wire needs
+// its syntax, unless it was already imported from export data.
+func (ic *importCache) checkPackage(pkg *packages.Package, root bool, progress *loadProgress) {
+	ic.mu.Lock()
+	key := ic.checkKey(pkg)
+	bodies := !ic.skipBodies || ic.roots[key]
+	c := ic.checked[key]
+	if c != nil && bodies && !c.bodies {
+		// The package was checked without its bodies before it became a
+		// root, as by another importCache. Check it again on its own.
+		key += " bodies"
+		c = ic.checked[key]
+	}
+	if c != nil {
+		ic.mu.Unlock()
+		<-c.done
+		if c.pkg != pkg {
+			pkg.Imports = c.pkg.Imports
+			pkg.Fset = c.pkg.Fset
+			pkg.Syntax = c.pkg.Syntax
+			pkg.Types = c.pkg.Types
+			pkg.TypesInfo = c.pkg.TypesInfo
+			pkg.TypesSizes = c.pkg.TypesSizes
+			pkg.IllTyped = c.pkg.IllTyped
+			pkg.Errors = c.pkg.Errors
+		}
+		return
+	}
+	c = &checkedPackage{done: make(chan struct{}), pkg: pkg, bodies: bodies}
+	ic.checked[key] = c
+	fromExport := !root && ic.exports[pkg.PkgPath] != ""
+	ic.mu.Unlock()
+	defer close(c.done)
+
+	pkg.Fset = ic.fset
+	if pkg.PkgPath == "unsafe" {
+		pkg.Types = types.Unsafe
+		return
+	}
+	if fromExport {
+		ic.stdMu.Lock()
+		tpkg, err := ic.std.Import(pkg.PkgPath)
+		ic.stdMu.Unlock()
+		if err == nil {
+			pkg.Types = tpkg
+			return
+		}
+	}
+	for _, imp := range pkg.Imports {
+		ic.checkPackage(imp, false, progress)
+	}
+	ic.typeCheck(pkg, bodies, progress)
+}
+
+// readFile returns the contents of the file filename, from the overlay if
+// it has them.
+func (ic *importCache) readFile(filename string) ([]byte, error) {
+	if src, ok := ic.overlay[filename]; ok {
+		return src, nil
+	}
+	return os.ReadFile(filename)
+}
+
+// compiledGoFiles returns the files of pkg that the compiler sees. Drivers
+// named by GOPACKAGESDRIVER, such as Bazel's, may leave CompiledGoFiles
+// empty, in which case GoFiles are used instead.
+func compiledGoFiles(pkg *packages.Package) []string {
+	if len(pkg.CompiledGoFiles) == 0 {
+		return pkg.GoFiles
+	}
+	return pkg.CompiledGoFiles
+}
+
+// typeCheck parses and type-checks pkg from source, the way go/packages
+// does, once its imports have been checked. Unless bodies is set, the
+// bodies of its functions are parsed but not checked.
+func (ic *importCache) typeCheck(pkg *packages.Package, bodies bool, progress *loadProgress) {
+	appendError := func(err error) {
+		switch err := err.(type) {
+		case packages.Error:
+			pkg.Errors = append(pkg.Errors, err)
+		case *os.PathError:
+			pkg.Errors = append(pkg.Errors, packages.Error{Pos: err.Path + ":1", Msg: err.Err.Error(), Kind: packages.ParseError})
+		case scanner.ErrorList:
+			for _, err := range err {
+				pkg.Errors = append(pkg.Errors, packages.Error{Pos: err.Pos.String(), Msg: err.Msg, Kind: packages.ParseError})
+			}
+		case types.Error:
+			pkg.TypeErrors = append(pkg.TypeErrors, err)
+			pkg.Errors = append(pkg.Errors, packages.Error{Pos: err.Fset.Position(err.Pos).String(), Msg: err.Msg, Kind: packages.TypeError})
+		default:
+			pkg.Errors = append(pkg.Errors, packages.Error{Pos: "-", Msg: err.Error(), Kind: packages.UnknownError})
+		}
+	}
+
+	pkg.Types = types.NewPackage(pkg.PkgPath, pkg.Name)
+	for _, filename := range compiledGoFiles(pkg) {
+		if ic.ctx.Err() != nil {
+			return
+		}
+		src, err := ic.readFile(filename)
+		if err != nil {
+			appendError(err)
+			continue
+		}
+		f, err := progress.parseFile(ic.fset, filename, src)
+		if f != nil {
+			pkg.Syntax = append(pkg.Syntax, f)
+		}
+		if err != nil {
+			appendError(err)
+		}
+	}
+	if ic.ctx.Err() != nil {
+		return
+	}
+
+	pkg.TypesInfo = &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Instances:  make(map[*ast.Ident]types.Instance),
+		Scopes:     make(map[ast.Node]*types.Scope),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	tc := &types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			if path == "unsafe" {
+				return types.Unsafe, nil
+			}
+			imp := pkg.Imports[path]
+			if imp == nil {
+				return nil, fmt.Errorf("no metadata for %s", path)
+			}
+			if imp.Types == nil || !imp.Types.Complete() {
+				return nil, fmt.Errorf("could not import %s", path)
+			}
+			return imp.Types, nil
+		}),
+		Error:            appendError,
+		Sizes:            pkg.TypesSizes,
+		IgnoreFuncBodies: !bodies,
+	}
+	if pkg.Module != nil && pkg.Module.GoVersion != "" {
+		tc.GoVersion = "go" + pkg.Module.GoVersion
+	}
+	// Files still importing "C" were not translated by cgo, perhaps for
+	// lack of a C toolchain. Faking the import lets the rest of the
+	// package be checked; Wire does not need the types of C names.
+	tc.FakeImportC = importsC(pkg.Syntax)
+	err := types.NewChecker(tc, ic.fset, pkg.Types, pkg.TypesInfo).Files(pkg.Syntax)
+	if err != nil && len(pkg.Errors) == 0 {
+		appendError(err)
+	}
+	pkg.IllTyped = len(pkg.Errors) > 0
+	for _, imp := range pkg.Imports {
+		pkg.IllTyped = pkg.IllTyped || imp.IllTyped
+	}
+}
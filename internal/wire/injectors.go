@@ -0,0 +1,414 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// defaultBuildTag is the build tag of injector files unless
+// GenerateOptions.BuildTag names another.
+const defaultBuildTag = "wireinject"
+
+// IsInjectorFile reports whether Wire treats f as a file of injector
+// templates when generating with the given build tags: f's build constraint
+// must be satisfied when the wireinject tag is set, and must not be satisfied
+// when it is not. tags is a space- or comma-separated list of the other tags
+// that are set, as passed to wire gen -tags; platform tags such as linux are
+// only set if listed.
+//
+// Both //go:build lines, including boolean expressions such as
+// "wireinject && !race", and legacy // +build lines are understood. A file
+// with no build constraint is never an injector file.
+func IsInjectorFile(f *ast.File, tags string) bool {
+	return IsInjectorFileWithTag(f, defaultBuildTag, tags)
+}
+
+// IsInjectorFileWithTag is like IsInjectorFile, but for injector files
+// marked with buildTag instead of wireinject, as with
+// GenerateOptions.BuildTag.
+func IsInjectorFileWithTag(f *ast.File, buildTag, tags string) bool {
+	x := fileConstraint(f)
+	if x == nil {
+		return false
+	}
+	set := make(map[string]bool)
+	for _, tag := range strings.FieldsFunc(tags, func(r rune) bool { return r == ' ' || r == ',' }) {
+		set[tag] = true
+	}
+	inject := x.Eval(func(tag string) bool { return tag == buildTag || set[tag] })
+	regular := x.Eval(func(tag string) bool { return tag != buildTag && set[tag] })
+	return inject && !regular
+}
+
+// checkBuildTag reports the files of pkg that are constrained to the
+// wireinject tag when buildTag is another tag. Wire loads pkg without
+// wireinject set, so it would leave their injectors out without a word.
+func checkBuildTag(pkg *packages.Package, buildTag string) []error {
+	if buildTag == defaultBuildTag {
+		return nil
+	}
+	files := sourceFiles(pkg)
+	for _, name := range pkg.IgnoredFiles {
+		f, err := parser.ParseFile(pkg.Fset, name, nil, parser.PackageClauseOnly|parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		files = append(files, f)
+	}
+	var errs []error
+	for _, f := range files {
+		if requiresTag(fileConstraint(f), defaultBuildTag) {
+			err := fmt.Errorf("file is constrained to the %s build tag, but the build tag of injector files is %q", defaultBuildTag, buildTag)
+			errs = append(errs, notePosition(pkg.Fset.Position(f.Package), err))
+		}
+	}
+	return errs
+}
+
+// outputConstraint returns the build constraint of the file generated from
+// the injector files: their own constraint with buildTag negated, so that
+// the other terms, such as !prod in "wireinject && !prod", still apply.
+// Injector files with different constraints give an expression that matches
+// if any of them does, and a file whose constraint has no other terms makes
+// it simply the negated tag.
+func outputConstraint(files []*ast.File, buildTag string) constraint.Expr {
+	var (
+		out  constraint.Expr
+		seen = make(map[string]bool)
+	)
+	for _, f := range files {
+		x := fileConstraint(f)
+		if !requiresTag(x, buildTag) {
+			return notTag(buildTag)
+		}
+		x = negateTag(x, buildTag)
+		s := x.String()
+		if s == "!"+buildTag {
+			return x
+		}
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		if out == nil {
+			out = x
+		} else {
+			out = &constraint.OrExpr{X: out, Y: x}
+		}
+	}
+	if out == nil {
+		return notTag(buildTag)
+	}
+	return out
+}
+
+// negateTag returns x with every use of tag negated.
+func negateTag(x constraint.Expr, tag string) constraint.Expr {
+	switch x := x.(type) {
+	case *constraint.TagExpr:
+		if x.Tag == tag {
+			return &constraint.NotExpr{X: x}
+		}
+	case *constraint.NotExpr:
+		if t, ok := x.X.(*constraint.TagExpr); ok && t.Tag == tag {
+			return t
+		}
+		return &constraint.NotExpr{X: negateTag(x.X, tag)}
+	case *constraint.AndExpr:
+		return &constraint.AndExpr{X: negateTag(x.X, tag), Y: negateTag(x.Y, tag)}
+	case *constraint.OrExpr:
+		return &constraint.OrExpr{X: negateTag(x.X, tag), Y: negateTag(x.Y, tag)}
+	}
+	return x
+}
+
+// notTag returns the build constraint that excludes tag.
+func notTag(tag string) constraint.Expr {
+	return &constraint.NotExpr{X: &constraint.TagExpr{Tag: tag}}
+}
+
+// An InjectorDecl is an injector template declared in a package: a function
+// whose body calls wire.Build.
+type InjectorDecl struct {
+	// Func is the declaration of the injector function.
+	Func *ast.FuncDecl
+	// Build is the call to wire.Build in the function's body.
+	Build *ast.CallExpr
+	// File is the file that declares the injector.
+	File *ast.File
+}
+
+// Name returns the name of the injector function.
+func (d InjectorDecl) Name() string {
+	return d.Func.Name.Name
+}
+
+// Pos returns the position of the injector function's name.
+func (d InjectorDecl) Pos() token.Pos {
+	return d.Func.Name.Pos()
+}
+
+// FindInjectors returns the injector templates declared in pkg, in source
+// order, as the generator finds them. pkg must have been loaded with syntax
+// and type information, usually with the wireinject tag set. Functions that
+// call wire.Build but are not valid injector templates are reported as
+// errors.
+func FindInjectors(pkg *packages.Package) ([]InjectorDecl, []error) {
+	var decls []InjectorDecl
+	ec := new(errorCollector)
+	for _, f := range sourceFiles(pkg) {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			buildCall, err := findInjectorBuild(pkg.TypesInfo, fn)
+			if err != nil {
+				ec.add(notePosition(pkg.Fset.Position(fn.Pos()), err))
+				continue
+			}
+			if buildCall == nil {
+				continue
+			}
+			decls = append(decls, InjectorDecl{Func: fn, Build: buildCall, File: f})
+		}
+	}
+	return decls, ec.errors
+}
+
+// sourceFiles returns the syntax of pkg that Wire reads, sorted by file
+// name, so that the generated code does not depend on the order in which
+// the files were loaded. The files that cgo writes from scratch are left
+// out: the compiled files of a package that imports "C" are the output of
+// cgo, a translation of each Go file, which starts with a //line directive
+// naming the original so that positions map back to it, and a few files
+// declaring the Go side of the C names the package uses. Wire never finds
+// injectors or providers in the latter.
+func sourceFiles(pkg *packages.Package) []*ast.File {
+	files := make([]*ast.File, 0, len(pkg.Syntax))
+	for _, f := range pkg.Syntax {
+		if !cgoGenerated(pkg, f) {
+			files = append(files, f)
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return pkg.Fset.Position(files[i].Pos()).Filename < pkg.Fset.Position(files[j].Pos()).Filename
+	})
+	return files
+}
+
+// An InjectorInfo describes an injector found by ListInjectors. Types are
+// the type expressions as written in the injector's file.
+type InjectorInfo struct {
+	// PkgPath is the import path of the package that declares the
+	// injector.
+	PkgPath string
+	// Name is the name of the injector function.
+	Name string
+	// Params are the injector's parameters, in order.
+	Params []InjectorParam
+	// Results are the types of the injector's results, in order.
+	Results []string
+	// Pos is the position of the injector function's name.
+	Pos token.Position
+}
+
+// An InjectorParam is a parameter of an injector found by ListInjectors.
+// Name is empty for an unnamed parameter.
+type InjectorParam struct {
+	Name string
+	Type string
+}
+
+// ListInjectors lists the injectors declared in the packages matching
+// patterns, in package and then source order. It is much faster than
+// Generate but only looks at syntax: it lists the files of the packages
+// without type-checking anything and reports the functions in injector
+// files whose bodies call wire.Build, whether or not they are valid
+// injectors. The same patterns may give errors from Generate that
+// ListInjectors does not report.
+//
+// wd and env are as for Generate.
+func ListInjectors(ctx context.Context, wd string, env []string, patterns []string) ([]InjectorInfo, []error) {
+	ic := newImportCache(ctx, wd, env, defaultBuildTag, "", nil)
+	escaped := make([]string, len(patterns))
+	for i := range patterns {
+		escaped[i] = "pattern=" + patterns[i]
+	}
+	pkgs, err := ic.loadPackages(ic.config(packages.NeedName|packages.NeedFiles), escaped...)
+	if err != nil {
+		return nil, []error{err}
+	}
+	var (
+		infos []InjectorInfo
+		errs  []error
+	)
+	fset := token.NewFileSet()
+	for _, pkg := range pkgs {
+		for _, e := range pkg.Errors {
+			errs = append(errs, e)
+		}
+		files := append([]string(nil), pkg.GoFiles...)
+		sort.Strings(files)
+		for _, name := range files {
+			if err := ctx.Err(); err != nil {
+				return nil, []error{err}
+			}
+			src, err := ic.readFile(name)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			f, err := parser.ParseFile(fset, name, src, parser.ParseComments|parser.SkipObjectResolution)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if !IsInjectorFile(f, "") {
+				continue
+			}
+			infos = append(infos, listFileInjectors(fset, pkg.PkgPath, f)...)
+		}
+	}
+	return infos, errs
+}
+
+// listFileInjectors returns the injectors declared in f, recognized by
+// their syntax alone.
+func listFileInjectors(fset *token.FileSet, pkgPath string, f *ast.File) []InjectorInfo {
+	wireName := wireImportName(f)
+	if wireName == "" {
+		return nil
+	}
+	var infos []InjectorInfo
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || fn.Body == nil || !callsWireBuild(fn.Body, wireName) {
+			continue
+		}
+		info := InjectorInfo{
+			PkgPath: pkgPath,
+			Name:    fn.Name.Name,
+			Pos:     fset.Position(fn.Name.Pos()),
+		}
+		for _, field := range fn.Type.Params.List {
+			typ := types.ExprString(field.Type)
+			if len(field.Names) == 0 {
+				info.Params = append(info.Params, InjectorParam{Type: typ})
+			}
+			for _, name := range field.Names {
+				info.Params = append(info.Params, InjectorParam{Name: name.Name, Type: typ})
+			}
+		}
+		if fn.Type.Results != nil {
+			for _, field := range fn.Type.Results.List {
+				n := len(field.Names)
+				if n == 0 {
+					n = 1
+				}
+				for i := 0; i < n; i++ {
+					info.Results = append(info.Results, types.ExprString(field.Type))
+				}
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// wireImportName returns the name that f imports the wire package as, "."
+// for a dot import, or the empty string if f does not import it or imports
+// it as _.
+func wireImportName(f *ast.File) string {
+	wireName := ""
+	for _, impt := range f.Imports {
+		if path, err := strconv.Unquote(impt.Path.Value); err != nil || !isWireImport(path) {
+			continue
+		}
+		wireName = "wire"
+		if impt.Name != nil {
+			wireName = impt.Name.Name
+		}
+	}
+	if wireName == "_" {
+		return ""
+	}
+	return wireName
+}
+
+// callsWireBuild reports whether a statement of body calls wire.Build,
+// where wireName is the name that the file imports the wire package as,
+// as returned by wireImportName, in any of the forms that an injector may:
+// on its own, as the argument of panic or assigned to the blank identifier.
+func callsWireBuild(body *ast.BlockStmt, wireName string) bool {
+	return findWireBuild(body, wireName) != nil
+}
+
+// findWireBuild returns the call to wire.Build in body that callsWireBuild
+// looks for, or nil if there is none.
+func findWireBuild(body *ast.BlockStmt, wireName string) *ast.CallExpr {
+	build := func(expr ast.Expr) *ast.CallExpr {
+		call, ok := expr.(*ast.CallExpr)
+		if !ok {
+			return nil
+		}
+		if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "panic" && len(call.Args) == 1 {
+			if call, ok = call.Args[0].(*ast.CallExpr); !ok {
+				return nil
+			}
+		}
+		if wireName == "." {
+			if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "Build" {
+				return call
+			}
+			return nil
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != "Build" {
+			return nil
+		}
+		if x, ok := sel.X.(*ast.Ident); !ok || x.Name != wireName {
+			return nil
+		}
+		return call
+	}
+	for _, stmt := range body.List {
+		switch stmt := stmt.(type) {
+		case *ast.ExprStmt:
+			if call := build(stmt.X); call != nil {
+				return call
+			}
+		case *ast.AssignStmt:
+			if len(stmt.Rhs) == 1 {
+				if call := build(stmt.Rhs[0]); call != nil {
+					return call
+				}
+			}
+		}
+	}
+	return nil
+}
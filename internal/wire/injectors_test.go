@@ -0,0 +1,253 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIsInjectorFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		tags   string
+		want   bool
+	}{
+		{name: "NoConstraint", header: "", want: false},
+		{name: "GoBuild", header: "//go:build wireinject\n", want: true},
+		{name: "PlusBuild", header: "// +build wireinject\n", want: true},
+		{name: "PlusBuildNoSpace", header: "//+build wireinject\n", want: true},
+		{name: "Negated", header: "//go:build !wireinject\n", want: false},
+		{name: "OtherTag", header: "//go:build linux\n", want: false},
+		{name: "AndOtherTagUnset", header: "//go:build wireinject && linux\n", want: false},
+		{name: "AndOtherTagSet", header: "//go:build wireinject && linux\n", tags: "linux", want: true},
+		{name: "AndNotTagSet", header: "//go:build wireinject && !race\n", tags: "race", want: false},
+		{name: "AndNotTagUnset", header: "//go:build wireinject && !race\n", want: true},
+		{name: "OrOtherTagUnset", header: "//go:build wireinject || dev\n", want: true},
+		{name: "OrOtherTagSet", header: "//go:build wireinject || dev\n", tags: "dev", want: false},
+		{name: "CommaSeparatedTags", header: "//go:build wireinject && a && b\n", tags: "a,b", want: true},
+		{name: "PlusBuildLines", header: "// +build wireinject\n// +build linux\n", tags: "linux", want: true},
+		{name: "GoBuildWins", header: "//go:build wireinject\n// +build !wireinject\n", want: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src := test.header + "\npackage foo\n"
+			f, err := parser.ParseFile(token.NewFileSet(), "foo.go", src, parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := IsInjectorFile(f, test.tags); got != test.want {
+				t.Errorf("IsInjectorFile(%q, %q) = %t; want %t", test.header, test.tags, got, test.want)
+			}
+		})
+	}
+}
+
+func TestIsInjectorFileWithTag(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{header: "//go:build di_spec\n", want: true},
+		{header: "//go:build di_spec && !wireinject\n", want: true},
+		{header: "//go:build wireinject\n", want: false},
+		{header: "//go:build !di_spec\n", want: false},
+	}
+	for _, test := range tests {
+		src := test.header + "\npackage foo\n"
+		f, err := parser.ParseFile(token.NewFileSet(), "foo.go", src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := IsInjectorFileWithTag(f, "di_spec", ""); got != test.want {
+			t.Errorf("IsInjectorFileWithTag(%q, \"di_spec\", \"\") = %t; want %t", test.header, got, test.want)
+		}
+	}
+}
+
+func TestOutputConstraint(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []string
+		want    string
+	}{
+		{name: "Tag", headers: []string{"//go:build wireinject\n"}, want: "!wireinject"},
+		{name: "PlusBuild", headers: []string{"// +build wireinject\n"}, want: "!wireinject"},
+		{name: "And", headers: []string{"//go:build wireinject && !prod\n"}, want: "!wireinject && !prod"},
+		{name: "BothSyntaxes", headers: []string{"//go:build wireinject && !prod\n// +build wireinject,!prod\n"}, want: "!wireinject && !prod"},
+		{name: "PlusBuildLines", headers: []string{"// +build wireinject\n// +build linux\n"}, want: "!wireinject && linux"},
+		{name: "AfterGenerate", headers: []string{"//go:generate echo\n//go:build wireinject && !prod\n"}, want: "!wireinject && !prod"},
+		{name: "SameTwice", headers: []string{"//go:build wireinject && !prod\n", "//go:build wireinject && !prod\n"}, want: "!wireinject && !prod"},
+		{name: "Different", headers: []string{"//go:build wireinject && linux\n", "//go:build wireinject && darwin\n"}, want: "(!wireinject && linux) || (!wireinject && darwin)"},
+		{name: "OneWithoutOtherTerms", headers: []string{"//go:build wireinject && !prod\n", "//go:build wireinject\n"}, want: "!wireinject"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var files []*ast.File
+			for _, header := range test.headers {
+				f, err := parser.ParseFile(token.NewFileSet(), "foo.go", header+"\npackage foo\n", parser.ParseComments)
+				if err != nil {
+					t.Fatal(err)
+				}
+				files = append(files, f)
+			}
+			if got := outputConstraint(files, defaultBuildTag).String(); got != test.want {
+				t.Errorf("outputConstraint(%q) = %q; want %q", test.headers, got, test.want)
+			}
+		})
+	}
+}
+
+func TestFindInjectors(t *testing.T) {
+	pkg := loadSource(t, `package fuzz
+
+import "github.com/google/wire"
+
+type Foo int
+
+func provideFoo() Foo { return 1 }
+
+func injectFoo() Foo {
+	wire.Build(provideFoo)
+	return 0
+}
+
+func notAnInjector() Foo { return provideFoo() }
+
+func injectBlank() Foo {
+	_ = wire.Build(provideFoo)
+	return 0
+}
+
+func injectPanic() Foo {
+	panic(wire.Build(provideFoo))
+}
+
+func injectInvalid() Foo {
+	foo := Foo(2)
+	wire.Build(provideFoo)
+	return foo
+}
+`)
+	if pkg == nil {
+		t.Fatal("source does not type-check")
+	}
+	decls, errs := FindInjectors(pkg)
+	var names []string
+	for _, d := range decls {
+		names = append(names, d.Name())
+		if d.File != pkg.Syntax[0] {
+			t.Errorf("%s: File is not the declaring file", d.Name())
+		}
+		if d.Build == nil {
+			t.Errorf("%s: Build is nil", d.Name())
+		}
+	}
+	if got, want := strings.Join(names, " "), "injectFoo injectBlank injectPanic"; got != want {
+		t.Errorf("injectors = %q; want %q", got, want)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "injectors must consist of only the wire.Build call") {
+		t.Errorf("errors = %v; want one invalid injector error", errs)
+	}
+}
+
+func TestListInjectors(t *testing.T) {
+	wd, env := materialize(t, map[string]string{
+		"example.com/foo/foo.go": `package foo
+
+import "context"
+
+type Foo int
+
+func NewFoo(ctx context.Context) (Foo, func(), error) { return 0, func() {}, nil }
+`,
+		"example.com/foo/wire.go": `//go:build wireinject
+
+package foo
+
+import (
+	"context"
+
+	w "github.com/google/wire"
+)
+
+func InitFoo(ctx context.Context, _ int, a, b string) (Foo, func(), error) {
+	panic(w.Build(NewFoo))
+}
+
+func initBlank() (f Foo) {
+	_ = w.Build(NewFoo)
+	return
+}
+
+func notInjector() {}
+`,
+		"example.com/bar/bar.go": `package bar
+
+func Bar() {}
+`,
+		"example.com/bar/wire.go": `//go:build wireinject
+
+package bar
+
+import . "github.com/google/wire"
+
+func initBar() int {
+	panic(Build(NewSet()))
+}
+`,
+	})
+
+	infos, errs := ListInjectors(context.Background(), wd, env, []string{"./..."})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	for i := range infos {
+		infos[i].Pos.Filename = filepath.Base(infos[i].Pos.Filename)
+		infos[i].Pos.Offset = 0
+	}
+	want := []InjectorInfo{
+		{
+			PkgPath: "example.com/bar",
+			Name:    "initBar",
+			Results: []string{"int"},
+			Pos:     token.Position{Filename: "wire.go", Line: 7, Column: 6},
+		},
+		{
+			PkgPath: "example.com/foo",
+			Name:    "InitFoo",
+			Params:  []InjectorParam{{"ctx", "context.Context"}, {"_", "int"}, {"a", "string"}, {"b", "string"}},
+			Results: []string{"Foo", "func()", "error"},
+			Pos:     token.Position{Filename: "wire.go", Line: 11, Column: 6},
+		},
+		{
+			PkgPath: "example.com/foo",
+			Name:    "initBlank",
+			Results: []string{"Foo"},
+			Pos:     token.Position{Filename: "wire.go", Line: 15, Column: 6},
+		},
+	}
+	if diff := cmp.Diff(want, infos); diff != "" {
+		t.Errorf("ListInjectors (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// packageLoader loads the packages matching patterns. packages.Load is the
+// real implementation; tests substitute their own to observe how often the go
+// command would run.
+type packageLoader func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error)
+
+const (
+	// batchWindow is how long a batchLoader waits for more requests before
+	// loading the packages requested so far.
+	batchWindow = 10 * time.Millisecond
+
+	// maxConcurrentLoads is the number of go list processes that batch
+	// loaders run at once, across all object caches.
+	maxConcurrentLoads = 4
+)
+
+// loadSlots limits the number of batches being loaded at once.
+var loadSlots = make(chan struct{}, maxConcurrentLoads)
+
+// A batchLoader loads packages on demand for lazy loading. Requests that
+// arrive within batchWindow of each other are loaded by a single call to the
+// package loader, which runs go list once for all of them.
+type batchLoader struct {
+	load packageLoader
+	ctx  context.Context
+	// imports type-checks the loaded packages. Object caches that share
+	// it share the types of the packages they load.
+	imports *importCache
+
+	mu sync.Mutex
+	// pending maps the import paths of the batch being collected to the
+	// requests waiting for them. It is nil when no batch is being collected.
+	pending map[string][]chan<- loadResult
+	// flushes counts the batches that are scheduled or being loaded.
+	flushes sync.WaitGroup
+}
+
+type loadResult struct {
+	pkg *packages.Package
+	err error
+}
+
+// newBatchLoader returns a batchLoader that loads and type-checks packages
+// with imports.
+func newBatchLoader(imports *importCache) *batchLoader {
+	return &batchLoader{
+		load:    imports.loadPackages,
+		ctx:     imports.ctx,
+		imports: imports,
+	}
+}
+
+// get loads the packages with the given import paths. Paths that fail to load
+// do not affect the others; their errors are returned in the same position.
+func (bl *batchLoader) get(pkgPaths ...string) ([]*packages.Package, []error) {
+	chans := make([]chan loadResult, len(pkgPaths))
+	bl.mu.Lock()
+	if bl.pending == nil {
+		bl.pending = make(map[string][]chan<- loadResult)
+		bl.flushes.Add(1)
+		time.AfterFunc(batchWindow, bl.flush)
+	}
+	for i, path := range pkgPaths {
+		chans[i] = make(chan loadResult, 1)
+		bl.pending[path] = append(bl.pending[path], chans[i])
+	}
+	bl.mu.Unlock()
+
+	pkgs := make([]*packages.Package, len(pkgPaths))
+	errs := make([]error, len(pkgPaths))
+	for i, ch := range chans {
+		r := <-ch
+		pkgs[i], errs[i] = r.pkg, r.err
+	}
+	return pkgs, errs
+}
+
+// wait returns once every batch scheduled so far has been loaded and handed
+// out. A request returns as soon as its own packages are loaded, so the
+// goroutine loading the batch may outlive it briefly; callers wait before
+// returning so that no goroutine outlives their run.
+func (bl *batchLoader) wait() {
+	bl.flushes.Wait()
+}
+
+// flush loads the batch collected so far and hands each result to the
+// requests waiting for it.
+func (bl *batchLoader) flush() {
+	defer bl.flushes.Done()
+	bl.mu.Lock()
+	batch := bl.pending
+	bl.pending = nil
+	bl.mu.Unlock()
+
+	paths := make([]string, 0, len(batch))
+	for path := range batch {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	results := bl.loadBatch(paths)
+	if len(paths) > 1 && results == nil {
+		// The go command failed as a whole, perhaps because of one bad
+		// path. Load each path by itself so that the others still succeed.
+		results = make(map[string]loadResult, len(paths))
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		for _, path := range paths {
+			wg.Add(1)
+			go func(path string) {
+				defer wg.Done()
+				r := bl.loadBatch([]string{path})
+				mu.Lock()
+				results[path] = r[path]
+				mu.Unlock()
+			}(path)
+		}
+		wg.Wait()
+	}
+	for path, waiters := range batch {
+		for _, ch := range waiters {
+			ch <- results[path]
+		}
+	}
+}
+
+// loadBatch runs the package loader once for paths. It returns nil if the
+// loader failed as a whole and there is more than one path to blame.
+func (bl *batchLoader) loadBatch(paths []string) map[string]loadResult {
+	loadSlots <- struct{}{}
+	defer func() { <-loadSlots }()
+
+	results := make(map[string]loadResult, len(paths))
+	fail := func(err error) map[string]loadResult {
+		for _, path := range paths {
+			results[path] = loadResult{err: fmt.Errorf("failed to lazy load package %s: %w", path, err)}
+		}
+		return results
+	}
+	// On-demand loads happen late in a run, so don't start one if the time
+	// is already up.
+	progress := newLoadProgress(bl.ctx)
+	if err := progress.interrupted(); err != nil {
+		return fail(err)
+	}
+	cfg := bl.imports.config(metadataMode)
+	start := time.Now()
+	pkgs, err := bl.load(cfg, paths...)
+	bl.imports.stats.loaded(true, paths, pkgs, time.Since(start))
+	if err := progress.interrupted(); err != nil {
+		return fail(err)
+	}
+	if err != nil {
+		if len(paths) > 1 {
+			return nil
+		}
+		return fail(err)
+	}
+	if err := bl.imports.check(pkgs, progress); err != nil {
+		return fail(err)
+	}
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			err := explainLoadError(pkg, pkg.Errors[0])[0]
+			results[pkg.PkgPath] = loadResult{err: fmt.Errorf("errors loading package %s: %w", pkg.PkgPath, err)}
+			continue
+		}
+		results[pkg.PkgPath] = loadResult{pkg: pkg}
+	}
+	for _, path := range paths {
+		if _, ok := results[path]; !ok {
+			results[path] = loadResult{err: fmt.Errorf("package %s not found", path)}
+		}
+	}
+	return results
+}
+
+// cacheEnvVars are the environment variables that the go command uses to
+// locate its build cache.
+var cacheEnvVars = []string{"HOME", "USERPROFILE", "home", "XDG_CACHE_HOME", "LocalAppData", "GOCACHE"}
+
+// passEnvVars are the environment variables that are copied from the
+// current process to an env that lacks them, besides cacheEnvVars.
+// GOPACKAGESDRIVER makes go/packages ask a build system such as Bazel
+// instead of the go command, which only works under that build system.
+var passEnvVars = []string{"GOPACKAGESDRIVER"}
+
+// completeEnv returns env with the variables that locate the go command's
+// build cache copied from the current process where env lacks them. A
+// minimal env would otherwise make the go command fail with "failed to
+// initialize build cache". If the process has none of them either, GOCACHE
+// is set to a directory under os.TempDir. The variables in passEnvVars are
+// copied the same way, so that overriding env does not switch away from a
+// driver. A nil env is returned unchanged, since go/packages runs the go
+// command in the process environment then.
+func completeEnv(env []string) []string {
+	if env == nil {
+		return nil
+	}
+	have := make(map[string]bool)
+	for _, kv := range env {
+		if k, _, ok := strings.Cut(kv, "="); ok {
+			have[k] = true
+		}
+	}
+	var added []string
+	for _, k := range cacheEnvVars {
+		if have[k] {
+			continue
+		}
+		if v, ok := os.LookupEnv(k); ok {
+			added = append(added, k+"="+v)
+			have[k] = true
+		}
+	}
+	found := false
+	for _, k := range cacheEnvVars {
+		found = found || have[k]
+	}
+	if !found {
+		added = append(added, "GOCACHE="+filepath.Join(os.TempDir(), "wire-gocache"))
+	}
+	for _, k := range passEnvVars {
+		if v, ok := os.LookupEnv(k); ok && !have[k] {
+			added = append(added, k+"="+v)
+		}
+	}
+	if len(added) == 0 {
+		return env
+	}
+	return append(append([]string(nil), env...), added...)
+}
@@ -0,0 +1,641 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+// fakeLoader is a packageLoader that makes up packages instead of running
+// the go command. Paths containing "bad" load with errors, and a batch
+// containing a path with "broken" fails as a whole.
+type fakeLoader struct {
+	mu      sync.Mutex
+	batches [][]string
+	running int
+	maxRun  int
+	// release, if not nil, blocks each load until it is closed.
+	release chan struct{}
+}
+
+func (fl *fakeLoader) load(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+	fl.mu.Lock()
+	fl.batches = append(fl.batches, patterns)
+	fl.running++
+	if fl.running > fl.maxRun {
+		fl.maxRun = fl.running
+	}
+	fl.mu.Unlock()
+	defer func() {
+		fl.mu.Lock()
+		fl.running--
+		fl.mu.Unlock()
+	}()
+	if fl.release != nil {
+		<-fl.release
+	}
+
+	var pkgs []*packages.Package
+	for _, path := range patterns {
+		if strings.Contains(path, "broken") {
+			return nil, fmt.Errorf("go list: cannot load %s", path)
+		}
+		pkg := &packages.Package{ID: path, PkgPath: path, Name: "p"}
+		if strings.Contains(path, "bad") {
+			pkg.Errors = []packages.Error{{Msg: "no Go files"}}
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, nil
+}
+
+func newFakeLazyCache(fl *fakeLoader) *objectCache {
+	root := &packages.Package{PkgPath: "example.com/root"}
+	oc := newObjectCacheWithLazyLoad([]*packages.Package{root}, newImportCache(context.Background(), "", nil, defaultBuildTag, "", nil))
+	oc.loader.load = fl.load
+	return oc
+}
+
+func TestLazyLoadBatching(t *testing.T) {
+	fl := new(fakeLoader)
+	oc := newFakeLazyCache(fl)
+	var paths []string
+	for i := 0; i < 10; i++ {
+		paths = append(paths, fmt.Sprintf("example.com/p%d", i))
+	}
+	paths = append(paths, "example.com/bad")
+	errs := oc.PreloadPackages(paths)
+	if len(fl.batches) != 1 {
+		t.Errorf("loader ran %d times; want 1", len(fl.batches))
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "example.com/bad") {
+		t.Errorf("errors = %v; want one for example.com/bad", errs)
+	}
+	for _, path := range paths[:10] {
+		pkg, err := oc.getPackage(path)
+		if err != nil {
+			t.Errorf("getPackage(%q): %v", path, err)
+			continue
+		}
+		if pkg.PkgPath != path {
+			t.Errorf("getPackage(%q) = package %q", path, pkg.PkgPath)
+		}
+	}
+	if len(fl.batches) != 1 {
+		t.Errorf("loader ran %d times after getting preloaded packages; want 1", len(fl.batches))
+	}
+}
+
+func TestLazyLoadConcurrentMisses(t *testing.T) {
+	fl := new(fakeLoader)
+	oc := newFakeLazyCache(fl)
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			path := fmt.Sprintf("example.com/p%d", i)
+			pkg, err := oc.lazyLoadPackage(path)
+			if err == nil && pkg.PkgPath != path {
+				err = fmt.Errorf("got package %q", pkg.PkgPath)
+			}
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("package %d: %v", i, err)
+		}
+	}
+	if len(fl.batches) >= n {
+		t.Errorf("loader ran %d times for %d concurrent misses; want them batched", len(fl.batches), n)
+	}
+}
+
+func TestLazyLoadBrokenBatch(t *testing.T) {
+	fl := new(fakeLoader)
+	oc := newFakeLazyCache(fl)
+	paths := []string{"example.com/a", "example.com/broken", "example.com/b"}
+	pkgs, errs := oc.lazyLoadPackages(paths)
+	for _, i := range []int{0, 2} {
+		if errs[i] != nil {
+			t.Errorf("%s: %v", paths[i], errs[i])
+		} else if pkgs[i].PkgPath != paths[i] {
+			t.Errorf("%s: got package %q", paths[i], pkgs[i].PkgPath)
+		}
+	}
+	if errs[1] == nil || !strings.Contains(errs[1].Error(), "cannot load example.com/broken") {
+		t.Errorf("example.com/broken: error = %v; want load failure", errs[1])
+	}
+}
+
+func TestLazyLoadConcurrencyLimit(t *testing.T) {
+	fl := &fakeLoader{release: make(chan struct{})}
+	var wg sync.WaitGroup
+	for i := 0; i < maxConcurrentLoads*3; i++ {
+		// Each cache batches separately, so every cache runs the loader.
+		oc := newFakeLazyCache(fl)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := oc.lazyLoadPackage(fmt.Sprintf("example.com/p%d", i)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	// Let the loads start, then let them finish.
+	for {
+		fl.mu.Lock()
+		running := fl.running
+		fl.mu.Unlock()
+		if running == maxConcurrentLoads {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(fl.release)
+	wg.Wait()
+	if fl.maxRun > maxConcurrentLoads {
+		t.Errorf("loader ran %d times at once; want at most %d", fl.maxRun, maxConcurrentLoads)
+	}
+}
+
+func TestLazyLoadSharedImports(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping because -short was passed")
+	}
+	// Two caches, as used by two workers of one run, share an importCache.
+	imports := newImportCache(context.Background(), "", nil, defaultBuildTag, "", nil)
+	var ocs [2]*objectCache
+	for i := range ocs {
+		ocs[i] = newObjectCacheWithLazyLoad([]*packages.Package{{PkgPath: "example.com/root"}}, imports)
+	}
+	var pkgs [2]*packages.Package
+	var wg sync.WaitGroup
+	for i := range ocs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pkg, err := ocs[i].lazyLoadPackage("text/tabwriter")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			pkgs[i] = pkg
+		}(i)
+	}
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+	if pkgs[0].Types != pkgs[1].Types {
+		t.Error("workers type-checked text/tabwriter separately")
+	}
+	if len(pkgs[0].Syntax) == 0 || pkgs[0].TypesInfo == nil {
+		t.Error("text/tabwriter was not type-checked from source")
+	}
+	// The types of its imports come from export data.
+	io := pkgs[0].Imports["io"]
+	if io == nil || io.Types == nil || !io.Types.Complete() {
+		t.Fatal("io was not imported")
+	}
+	if io.Syntax != nil {
+		t.Error("io was type-checked from source; want export data")
+	}
+}
+
+func TestLazyLoadErrorPosition(t *testing.T) {
+	wd, env := materialize(t, map[string]string{
+		"example.com/foo/foo.go": `package foo
+
+type Foo int
+`,
+		"example.com/bar/bar.go": `package bar
+
+import "github.com/google/wire"
+
+type Bar int
+
+func provideBar() Bar { return 1 }
+
+var Set = wire.NewSet(provideBar())
+`,
+	})
+	imports := newImportCache(context.Background(), wd, env, defaultBuildTag, "", nil)
+	pkgs, errs := imports.load([]string{"example.com/foo"})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	// foo does not import bar, so bar is loaded on demand.
+	oc := newObjectCacheWithLazyLoad(pkgs, imports)
+	defer oc.loader.wait()
+	bar, err := oc.lazyLoadPackage("example.com/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, errs = oc.get(bar.Types.Scope().Lookup("Set"))
+	if len(errs) != 1 {
+		t.Fatalf("got errors %v; want 1 error", errs)
+	}
+	want := filepath.Join(imports.wd, "bar", "bar.go") + ":9:23: "
+	if got := errs[0].Error(); !strings.HasPrefix(got, want) {
+		t.Errorf("error = %q; want it to start with %q", got, want)
+	}
+}
+
+func TestSkipFuncBodies(t *testing.T) {
+	wd, env := materialize(t, map[string]string{
+		"example.com/foo/foo.go": `package foo
+
+import "example.com/bar"
+
+func useBar() bar.Bar {
+	b := bar.ProvideBar()
+	return b
+}
+`,
+		"example.com/bar/bar.go": `package bar
+
+import "github.com/google/wire"
+
+type Bar int
+
+func ProvideBar() Bar {
+	b := Bar(1)
+	return b
+}
+
+var Set = wire.NewSet(ProvideBar)
+`,
+	})
+	imports := newImportCache(context.Background(), wd, env, defaultBuildTag, "", nil)
+	imports.skipBodies = true
+	pkgs, errs := imports.load([]string{"example.com/foo"})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	foo := pkgs[0]
+	bar := foo.Imports["example.com/bar"]
+
+	// The body of the root is checked, that of its import is not.
+	bodyUses := func(pkg *packages.Package) int {
+		n := 0
+		for id := range pkg.TypesInfo.Uses {
+			if id.Name == "b" {
+				n++
+			}
+		}
+		return n
+	}
+	if n := bodyUses(foo); n != 1 {
+		t.Errorf("foo has %d uses of b; want 1", n)
+	}
+	if n := bodyUses(bar); n != 0 {
+		t.Errorf("bar has %d uses of b; want 0, as its bodies are not checked", n)
+	}
+
+	oc := newObjectCache(pkgs)
+	item, errs := oc.get(bar.Types.Scope().Lookup("Set"))
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	set := item.(*ProviderSet)
+	if len(set.Providers) != 1 {
+		t.Fatalf("got %d providers; want 1", len(set.Providers))
+	}
+	got := oc.fset.Position(set.Providers[0].Pos)
+	want := filepath.Join(imports.wd, "bar", "bar.go") + ":7:6"
+	if got.String() != want {
+		t.Errorf("ProvideBar is at %s; want %s", got, want)
+	}
+}
+
+func TestLoadMinimalEnv(t *testing.T) {
+	wd, _ := materialize(t, map[string]string{
+		"example.com/foo/foo.go": `package foo
+
+type Foo int
+
+func provideFoo() Foo { return 42 }
+`,
+		"example.com/foo/wire.go": `//go:build wireinject
+
+package foo
+
+import "github.com/google/wire"
+
+func injectFoo() Foo {
+	panic(wire.Build(provideFoo))
+}
+`,
+	})
+	// The env lacks HOME and GOCACHE, which the go command needs to find
+	// its build cache.
+	env := []string{"GOPATH=" + gopathOf(wd)}
+	opts := []*GenerateOptions{{}, {GoCache: t.TempDir()}}
+
+	var wg sync.WaitGroup
+	errs := make([][]error, len(opts))
+	for i := range opts {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			gens, err := Generate(context.Background(), wd, env, []string{"example.com/foo"}, opts[i])
+			errs[i] = err
+			for _, gen := range gens {
+				errs[i] = append(errs[i], gen.Errs...)
+				if len(gen.Content) == 0 {
+					errs[i] = append(errs[i], fmt.Errorf("no output for %s", gen.PkgPath))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if len(err) > 0 {
+			t.Errorf("Generate with GoCache %q: %v", opts[i].GoCache, err)
+		}
+	}
+}
+
+func TestCompleteEnv(t *testing.T) {
+	t.Setenv("HOME", "/home/gopher")
+	t.Setenv("GOCACHE", "/cache")
+	t.Setenv("GOPACKAGESDRIVER", "/bin/driver")
+	if got := completeEnv(nil); got != nil {
+		t.Errorf("completeEnv(nil) = %q; want nil", got)
+	}
+	got := completeEnv([]string{"GOPATH=/go", "GOCACHE=/mine"})
+	env := make(map[string]string)
+	for _, kv := range got {
+		k, v, _ := strings.Cut(kv, "=")
+		if _, dup := env[k]; dup {
+			t.Errorf("completeEnv(...) sets %s more than once", k)
+		}
+		env[k] = v
+	}
+	for k, want := range map[string]string{"GOPATH": "/go", "HOME": "/home/gopher", "GOCACHE": "/mine", "GOPACKAGESDRIVER": "/bin/driver"} {
+		if env[k] != want {
+			t.Errorf("completeEnv(...) sets %s=%q; want %q", k, env[k], want)
+		}
+	}
+}
+
+func TestGenerateDriverPackages(t *testing.T) {
+	ctx := context.Background()
+	wd := filepath.Join("testdata", "Chain", "foo")
+	want, errs := Generate(ctx, wd, nil, []string{"."}, &GenerateOptions{})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	// Drivers named by GOPACKAGESDRIVER may leave CompiledGoFiles empty
+	// and report packages without any Go files.
+	driver := func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		pkgs, err := packages.Load(cfg, patterns...)
+		if err != nil {
+			return nil, err
+		}
+		packages.Visit(pkgs, nil, func(p *packages.Package) {
+			p.CompiledGoFiles = nil
+		})
+		if cfg.Mode == metadataMode {
+			pkgs = append(pkgs, &packages.Package{ID: "example.com/empty", Name: "empty", PkgPath: "example.com/empty"})
+		}
+		return pkgs, nil
+	}
+	got, errs := Generate(ctx, wd, nil, []string{"."}, &GenerateOptions{loader: driver})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Generate returned %d results; want 2", len(got))
+	}
+	if len(got[0].Errs) > 0 {
+		t.Fatal(got[0].Errs)
+	}
+	if diff := cmp.Diff(string(want[0].Content), string(got[0].Content)); diff != "" {
+		t.Errorf("output with driver packages differs (-go list +driver):\n%s", diff)
+	}
+	if empty := got[1]; len(empty.Errs) > 0 || len(empty.Content) > 0 {
+		t.Errorf("package without files: Errs = %v, Content = %q; want neither", empty.Errs, empty.Content)
+	}
+}
+
+func TestGenerateFilePatterns(t *testing.T) {
+	injector := func(pkg string) string {
+		return `//go:build wireinject
+
+package ` + pkg + `
+
+import "github.com/google/wire"
+
+func injectFoo() Foo {
+	panic(wire.Build(provideFoo))
+}
+`
+	}
+	provider := func(pkg string) string {
+		return `package ` + pkg + `
+
+type Foo int
+
+func provideFoo() Foo { return 42 }
+`
+	}
+	wd, env := materialize(t, map[string]string{
+		"example.com/foo/foo.go":     provider("foo"),
+		"example.com/foo/wire.go":    injector("foo"),
+		"example.com/foo/ignored.go": "//go:build ignore\n\npackage foo\n",
+		"example.com/bar/bar.go":     provider("bar"),
+		"example.com/bar/wire.go":    injector("bar"),
+	})
+	ctx := context.Background()
+
+	patterns := []string{"./foo/wire.go", "foo/foo.go", "example.com/bar", filepath.Join(wd, "bar", "wire.go")}
+	gens, errs := Generate(ctx, wd, env, patterns, &GenerateOptions{})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	var got []string
+	for _, gen := range gens {
+		if len(gen.Errs) > 0 || len(gen.Content) == 0 {
+			t.Errorf("%s: Errs = %v, %d bytes of content; want output", gen.PkgPath, gen.Errs, len(gen.Content))
+		}
+		got = append(got, gen.PkgPath)
+	}
+	if want := []string{"example.com/foo", "example.com/bar"}; !cmp.Equal(got, want) {
+		t.Errorf("Generate(%q) generated %q; want %q", patterns, got, want)
+	}
+
+	for _, file := range []string{"./foo/ignored.go", "./foo/missing.go"} {
+		_, errs = Generate(ctx, wd, env, []string{"./bar", file}, &GenerateOptions{})
+		if len(errs) != 1 || !strings.Contains(errs[0].Error(), file+" is not part of any package built with -tags=wireinject") {
+			t.Errorf("Generate with %s: errs = %v; want an error that it is not part of any package", file, errs)
+		}
+	}
+}
+
+func TestImportAllowed(t *testing.T) {
+	tests := []struct {
+		from, path string
+		want       bool
+	}{
+		{"example.com/foo", "example.com/foo/internal/bar", true},
+		{"example.com/foo/baz", "example.com/foo/internal/bar", true},
+		{"example.com/foo/internal/baz", "example.com/foo/internal", true},
+		{"example.com/foobar", "example.com/foo/internal/bar", false},
+		{"example.com/other", "example.com/foo/internal", false},
+		{"example.com/foo", "example.com/foo/internal/bar/internal/baz", false},
+		{"example.com/foo", "internal/poll", true},
+		{"example.com/foo", "example.com/internalfoo", true},
+	}
+	for _, test := range tests {
+		if got := importAllowed(test.from, test.path); got != test.want {
+			t.Errorf("importAllowed(%q, %q) = %t; want %t", test.from, test.path, got, test.want)
+		}
+	}
+}
+
+func TestLazyLoadInternalProviderSet(t *testing.T) {
+	wireGo, err := ioutil.ReadFile(filepath.Join("..", "..", "wire.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The lazy loader reports the same errors as the test cases record for
+	// Generate.
+	for _, name := range []string{"InternalProviderSet", "InternalProviderSetIndirect"} {
+		t.Run(name, func(t *testing.T) {
+			test, err := loadTestCase(filepath.Join("testdata", name), wireGo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gopath := t.TempDir()
+			if err := test.materialize(gopath); err != nil {
+				t.Fatal(err)
+			}
+			wd := filepath.Join(gopath, "src", "example.com")
+			gens, errs := GenerateWithLazyLoad(context.Background(), wd, append(os.Environ(), "GOPATH="+gopath), []string{test.pkg}, nil)
+			for _, gen := range gens {
+				errs = append(errs, gen.Errs...)
+			}
+			got := make([]string, len(errs))
+			for i, e := range errs {
+				got[i] = scrubError(gopath, e.Error())
+			}
+			if diff := cmp.Diff(test.wantWireErrorStrings, got); diff != "" {
+				t.Errorf("errors differ from wire_errs.txt (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadStats(t *testing.T) {
+	ctx := context.Background()
+	wd := filepath.Join("testdata", "Chain", "foo")
+	for _, lazy := range []bool{false, true} {
+		var got []LoadStats
+		opts := &GenerateOptions{LazyLoad: lazy, LoadStats: func(s LoadStats) { got = append(got, s) }}
+		if _, errs := Generate(ctx, wd, nil, []string{"."}, opts); len(errs) > 0 {
+			t.Fatalf("LazyLoad=%t: %v", lazy, errs)
+		}
+		if len(got) != 1 {
+			t.Fatalf("LazyLoad=%t: LoadStats called %d times; want 1", lazy, len(got))
+		}
+		s := got[0]
+		// Chain refers only to its own package and wire, which the initial
+		// load lists, so nothing is left to load on demand.
+		if s.PackagesLoadedEager == 0 || s.PackagesLoadedLazy != 0 || s.PackagesRequested == 0 {
+			t.Errorf("LazyLoad=%t: stats = %+v; want packages loaded up front and requested, none lazily", lazy, s)
+		}
+		if len(s.Loads) != 1 || s.Loads[0].Lazy || s.Loads[0].Packages != s.PackagesLoadedEager || s.Loads[0].Duration <= 0 {
+			t.Errorf("LazyLoad=%t: Loads = %+v; want the initial load of %d packages", lazy, s.Loads, s.PackagesLoadedEager)
+		}
+	}
+
+	// An object cache that misses loads the package lazily.
+	fl := new(fakeLoader)
+	imports := newImportCache(ctx, "", nil, defaultBuildTag, "", nil)
+	imports.stats = newLoadStats()
+	oc := newObjectCacheWithLazyLoad([]*packages.Package{{PkgPath: "example.com/root"}}, imports)
+	oc.loader.load = fl.load
+	for _, path := range []string{"example.com/root", "example.com/p0", "example.com/p0"} {
+		if _, err := oc.getPackage(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var s LoadStats
+	imports.stats.report(func(got LoadStats) { s = got })
+	if s.PackagesLoadedEager != 0 || s.PackagesLoadedLazy != 1 || s.PackagesRequested != 2 {
+		t.Errorf("stats = %+v; want 1 package loaded lazily and 2 requested", s)
+	}
+	if len(s.Loads) != 1 || !s.Loads[0].Lazy || !cmp.Equal(s.Loads[0].Patterns, []string{"example.com/p0"}) {
+		t.Errorf("Loads = %+v; want one lazy load of example.com/p0", s.Loads)
+	}
+}
+
+func TestLoadSnapshotReplay(t *testing.T) {
+	ctx := context.Background()
+	wd := filepath.Join("testdata", "Chain", "foo")
+	snap := new(loadSnapshot)
+	want, errs := Generate(ctx, wd, nil, []string{"."}, &GenerateOptions{loader: snap.record(packages.Load)})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	data, err := snap.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := decodeLoadSnapshot(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The replay does not need the go command.
+	env := []string{"PATH=" + t.TempDir(), "GOCACHE=" + t.TempDir()}
+	opts := &GenerateOptions{loader: decoded.replay(t.TempDir())}
+	for _, lazy := range []bool{false, true} {
+		opts.LazyLoad = lazy
+		got, errs := Generate(ctx, wd, env, []string{"."}, opts)
+		if len(errs) > 0 {
+			t.Fatalf("LazyLoad=%t: %v", lazy, errs)
+		}
+		if len(got) != 1 || len(got[0].Errs) > 0 {
+			t.Fatalf("LazyLoad=%t: Generate = %+v; want one result without errors", lazy, got)
+		}
+		if diff := cmp.Diff(string(want[0].Content), string(got[0].Content)); diff != "" {
+			t.Errorf("LazyLoad=%t: replayed output differs (-recorded +replayed):\n%s", lazy, diff)
+		}
+	}
+
+	if _, errs := Generate(ctx, wd, env, []string{"./other"}, opts); len(errs) == 0 || !strings.Contains(errs[0].Error(), "load snapshot has no") {
+		t.Errorf("Generate of a load that was not recorded = %v; want a load snapshot error", errs)
+	}
+}
@@ -0,0 +1,326 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// A loadSnapshot records the results of the package loads of one or more
+// runs of Wire, so that later runs can replay them without the go command.
+// Benchmarks use it to measure parsing, solving and code generation without
+// the variance of go list, and tests to drive the loading logic without
+// subprocesses. A loadSnapshot can be encoded as JSON; it includes the
+// export data of the standard library packages that the runs imported, but
+// not the source files of the other packages, which must still be on disk.
+type loadSnapshot struct {
+	mu    sync.Mutex
+	Loads []snapshotLoad
+}
+
+// A snapshotLoad is one call to the package loader.
+type snapshotLoad struct {
+	Mode     packages.LoadMode
+	Patterns []string
+	// Roots are the IDs of the packages that the call returned, and
+	// Packages are those packages and their dependencies.
+	Roots    []string
+	Packages []snapshotPackage
+	// Err is the error of the call as a whole, if any.
+	Err string
+}
+
+// A snapshotPackage is the metadata of a packages.Package that go list
+// reports. Syntax and type information are left out, since Wire fills them
+// in itself.
+type snapshotPackage struct {
+	ID              string
+	Name            string
+	PkgPath         string
+	GoFiles         []string
+	CompiledGoFiles []string
+	OtherFiles      []string
+	IgnoredFiles    []string
+	ExportData      []byte
+	// Imports maps import paths to package IDs.
+	Imports  map[string]string
+	Errors   []packages.Error
+	Module   *packages.Module
+	WordSize int64
+	MaxAlign int64
+}
+
+// decodeLoadSnapshot decodes a loadSnapshot encoded as JSON.
+func decodeLoadSnapshot(data []byte) (*loadSnapshot, error) {
+	s := new(loadSnapshot)
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("decoding load snapshot: %v", err)
+	}
+	return s, nil
+}
+
+// encode encodes s as JSON.
+func (s *loadSnapshot) encode() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return json.Marshal(s)
+}
+
+// record returns a package loader that loads packages with load and adds
+// the results to s.
+func (s *loadSnapshot) record(load packageLoader) packageLoader {
+	return func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		pkgs, err := load(cfg, patterns...)
+		l := snapshotLoad{
+			Mode:     cfg.Mode,
+			Patterns: append([]string(nil), patterns...),
+		}
+		if err != nil {
+			l.Err = err.Error()
+		}
+		for _, p := range pkgs {
+			l.Roots = append(l.Roots, p.ID)
+		}
+		var rerr error
+		packages.Visit(pkgs, nil, func(p *packages.Package) {
+			sp, err := snapshotOf(p)
+			if err != nil && rerr == nil {
+				rerr = err
+			}
+			l.Packages = append(l.Packages, sp)
+		})
+		if rerr != nil {
+			return nil, rerr
+		}
+		s.mu.Lock()
+		s.Loads = append(s.Loads, l)
+		s.mu.Unlock()
+		return pkgs, err
+	}
+}
+
+// snapshotOf returns the metadata of p, reading its export data file if it
+// has one.
+func snapshotOf(p *packages.Package) (snapshotPackage, error) {
+	sp := snapshotPackage{
+		ID:              p.ID,
+		Name:            p.Name,
+		PkgPath:         p.PkgPath,
+		GoFiles:         p.GoFiles,
+		CompiledGoFiles: p.CompiledGoFiles,
+		OtherFiles:      p.OtherFiles,
+		IgnoredFiles:    p.IgnoredFiles,
+		Errors:          p.Errors,
+		Module:          p.Module,
+	}
+	if len(p.Imports) > 0 {
+		sp.Imports = make(map[string]string, len(p.Imports))
+		for path, imp := range p.Imports {
+			sp.Imports[path] = imp.ID
+		}
+	}
+	if p.TypesSizes != nil {
+		sp.WordSize = p.TypesSizes.Sizeof(types.Typ[types.Uintptr])
+		sp.MaxAlign = p.TypesSizes.Alignof(types.Typ[types.Complex128])
+	}
+	if p.ExportFile != "" {
+		data, err := os.ReadFile(p.ExportFile)
+		if err != nil {
+			return sp, fmt.Errorf("recording export data of %s: %v", p.PkgPath, err)
+		}
+		sp.ExportData = data
+	}
+	return sp, nil
+}
+
+// replay returns a package loader that answers from s. A call is answered
+// by a recorded call with the same mode and patterns, in any order. Failing
+// that, if each pattern is an import path that a recorded call with the
+// same mode returned, as with the batches of lazy loading, the call is
+// answered with those packages. Otherwise it fails. Export data is written
+// to files in dir the first time it is needed.
+func (s *loadSnapshot) replay(dir string) packageLoader {
+	r := &snapshotReplay{s: s, dir: dir, exportFiles: make(map[string]string)}
+	return r.load
+}
+
+type snapshotReplay struct {
+	s   *loadSnapshot
+	dir string
+
+	mu sync.Mutex
+	// exportFiles maps package IDs to the files their export data was
+	// written to.
+	exportFiles map[string]string
+}
+
+func (r *snapshotReplay) load(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	want := sortedCopy(patterns)
+	for i := range r.s.Loads {
+		l := &r.s.Loads[i]
+		if l.Mode != cfg.Mode || strings.Join(sortedCopy(l.Patterns), "\x00") != strings.Join(want, "\x00") {
+			continue
+		}
+		if l.Err != "" {
+			return nil, errors.New(l.Err)
+		}
+		return r.build(l.Roots, r.index(cfg.Mode))
+	}
+
+	// Answer a batch of import paths from the packages of several calls.
+	index := r.index(cfg.Mode)
+	roots := make([]string, 0, len(patterns))
+	for _, path := range patterns {
+		id, ok := r.rootWithPath(cfg.Mode, path)
+		if !ok {
+			return nil, fmt.Errorf("load snapshot has no %v load of %s", cfg.Mode, strings.Join(patterns, " "))
+		}
+		roots = append(roots, id)
+	}
+	return r.build(roots, index)
+}
+
+// index returns the recorded packages of the calls with mode, by ID.
+func (r *snapshotReplay) index(mode packages.LoadMode) map[string]*snapshotPackage {
+	index := make(map[string]*snapshotPackage)
+	for i := range r.s.Loads {
+		l := &r.s.Loads[i]
+		if l.Mode != mode {
+			continue
+		}
+		for j := range l.Packages {
+			index[l.Packages[j].ID] = &l.Packages[j]
+		}
+	}
+	return index
+}
+
+// rootWithPath returns the ID of a package with import path path that a
+// recorded call with mode returned.
+func (r *snapshotReplay) rootWithPath(mode packages.LoadMode, path string) (string, bool) {
+	for i := range r.s.Loads {
+		l := &r.s.Loads[i]
+		if l.Mode != mode {
+			continue
+		}
+		for _, p := range l.Packages {
+			if p.PkgPath == path && containsID(l.Roots, p.ID) {
+				return p.ID, true
+			}
+		}
+	}
+	return "", false
+}
+
+// build returns new packages for roots and their dependencies, so that
+// each call's packages can be filled in independently.
+func (r *snapshotReplay) build(roots []string, index map[string]*snapshotPackage) ([]*packages.Package, error) {
+	built := make(map[string]*packages.Package)
+	var get func(id string) (*packages.Package, error)
+	get = func(id string) (*packages.Package, error) {
+		if p := built[id]; p != nil {
+			return p, nil
+		}
+		sp := index[id]
+		if sp == nil {
+			return nil, fmt.Errorf("load snapshot has no package %s", id)
+		}
+		p := &packages.Package{
+			ID:              sp.ID,
+			Name:            sp.Name,
+			PkgPath:         sp.PkgPath,
+			GoFiles:         sp.GoFiles,
+			CompiledGoFiles: sp.CompiledGoFiles,
+			OtherFiles:      sp.OtherFiles,
+			IgnoredFiles:    sp.IgnoredFiles,
+			Errors:          append([]packages.Error(nil), sp.Errors...),
+			Module:          sp.Module,
+		}
+		if sp.WordSize != 0 {
+			p.TypesSizes = &types.StdSizes{WordSize: sp.WordSize, MaxAlign: sp.MaxAlign}
+		}
+		if sp.ExportData != nil {
+			file, err := r.exportFile(sp)
+			if err != nil {
+				return nil, err
+			}
+			p.ExportFile = file
+		}
+		built[id] = p
+		if len(sp.Imports) > 0 {
+			p.Imports = make(map[string]*packages.Package, len(sp.Imports))
+			for path, impID := range sp.Imports {
+				imp, err := get(impID)
+				if err != nil {
+					return nil, err
+				}
+				p.Imports[path] = imp
+			}
+		}
+		return p, nil
+	}
+	pkgs := make([]*packages.Package, 0, len(roots))
+	for _, id := range roots {
+		p, err := get(id)
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, p)
+	}
+	return pkgs, nil
+}
+
+// exportFile returns the file that holds the export data of sp, writing
+// it if needed.
+func (r *snapshotReplay) exportFile(sp *snapshotPackage) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if file, ok := r.exportFiles[sp.ID]; ok {
+		return file, nil
+	}
+	file := filepath.Join(r.dir, fmt.Sprintf("export%d.a", len(r.exportFiles)))
+	if err := os.WriteFile(file, sp.ExportData, 0666); err != nil {
+		return "", fmt.Errorf("replaying export data of %s: %v", sp.PkgPath, err)
+	}
+	r.exportFiles[sp.ID] = file
+	return file, nil
+}
+
+func sortedCopy(s []string) []string {
+	s = append([]string(nil), s...)
+	sort.Strings(s)
+	return s
+}
+
+func containsID(ids []string, id string) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
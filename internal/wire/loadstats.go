@@ -0,0 +1,125 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// LoadStats counts the packages that one run of Generate loads, such as to
+// compare loading everything up front with GenerateOptions.LazyLoad. It is
+// passed to GenerateOptions.LoadStats.
+type LoadStats struct {
+	// PackagesLoadedEager is the number of packages that the initial load
+	// lists: the packages that match the patterns and their dependencies.
+	PackagesLoadedEager int
+	// PackagesLoadedLazy is the number of packages that object caches
+	// list on demand after the initial load, with LazyLoad, including the
+	// dependencies of the packages they ask for.
+	PackagesLoadedLazy int
+	// PackagesRequested is the number of distinct packages that object
+	// caches look up while they parse provider sets, whether they were
+	// loaded up front or not.
+	PackagesRequested int
+	// Loads lists the runs of the package loader, in the order they
+	// finished.
+	Loads []LoadTiming
+}
+
+// A LoadTiming describes one run of the package loader.
+type LoadTiming struct {
+	// Lazy reports whether the load is an on-demand load of an object
+	// cache rather than the initial load.
+	Lazy bool
+	// Patterns are the patterns or import paths that the load asked for.
+	Patterns []string
+	// Packages is the number of packages that the load listed, including
+	// dependencies.
+	Packages int
+	// Duration is how long the loader ran. It does not include
+	// type-checking.
+	Duration time.Duration
+}
+
+// loadStats collects LoadStats for one run. A nil *loadStats collects
+// nothing.
+type loadStats struct {
+	mu        sync.Mutex
+	stats     LoadStats
+	eager     map[string]bool
+	lazy      map[string]bool
+	requested map[string]bool
+}
+
+func newLoadStats() *loadStats {
+	return &loadStats{
+		eager:     make(map[string]bool),
+		lazy:      make(map[string]bool),
+		requested: make(map[string]bool),
+	}
+}
+
+// loaded records a run of the package loader for patterns that listed pkgs.
+func (s *loadStats) loaded(lazy bool, patterns []string, pkgs []*packages.Package, d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := s.eager
+	if lazy {
+		seen = s.lazy
+	}
+	n := 0
+	packages.Visit(pkgs, func(p *packages.Package) bool {
+		n++
+		seen[p.PkgPath] = true
+		return true
+	}, nil)
+	s.stats.Loads = append(s.stats.Loads, LoadTiming{
+		Lazy:     lazy,
+		Patterns: append([]string(nil), patterns...),
+		Packages: n,
+		Duration: d,
+	})
+}
+
+// request records that an object cache looked up the package pkgPath.
+func (s *loadStats) request(pkgPath string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requested[pkgPath] = true
+}
+
+// report calls fn with the stats collected so far, if both are non-nil.
+func (s *loadStats) report(fn func(LoadStats)) {
+	if s == nil || fn == nil {
+		return
+	}
+	s.mu.Lock()
+	stats := s.stats
+	stats.PackagesLoadedEager = len(s.eager)
+	stats.PackagesLoadedLazy = len(s.lazy)
+	stats.PackagesRequested = len(s.requested)
+	stats.Loads = append([]LoadTiming(nil), s.stats.Loads...)
+	s.mu.Unlock()
+	fn(stats)
+}
@@ -0,0 +1,139 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+)
+
+// normalizeSource applies the simplifications of gofmt -s to src, the
+// gofmt-formatted source of a generated file, and prints it again the way
+// gofmt does. The generated code is then unchanged by gofmt and gofmt -s
+// alike, and does not depend on whether whoever copied it into the injector
+// file wrote composite literals with or without their element types, so
+// regenerating it with another Go toolchain or editor setup does not churn
+// the file. The imports need no such care: Wire writes them itself, sorted,
+// in a single group.
+func normalizeSource(src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	ast.Inspect(f, simplifyNode)
+	var buf bytes.Buffer
+	cfg := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	if err := cfg.Fprint(&buf, fset, f); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// simplifyNode applies the rewrites of gofmt -s to n, for ast.Inspect:
+//
+//	[]T{T{}, T{}}       -> []T{{}, {}}
+//	[]*T{&T{}, &T{}}    -> []*T{{}, {}}
+//	s[a:len(s)]         -> s[a:]
+//	for x, _ = range v  -> for x = range v
+//	for _ = range v     -> for range v
+func simplifyNode(n ast.Node) bool {
+	switch n := n.(type) {
+	case *ast.CompositeLit:
+		var keyType, elemType ast.Expr
+		switch typ := n.Type.(type) {
+		case *ast.ArrayType:
+			elemType = typ.Elt
+		case *ast.MapType:
+			keyType, elemType = typ.Key, typ.Value
+		default:
+			return true
+		}
+		for i, e := range n.Elts {
+			if kv, ok := e.(*ast.KeyValueExpr); ok {
+				if keyType != nil {
+					kv.Key = simplifyElem(kv.Key, keyType)
+				}
+				kv.Value = simplifyElem(kv.Value, elemType)
+				continue
+			}
+			n.Elts[i] = simplifyElem(e, elemType)
+		}
+		// simplifyElem has simplified the elements already.
+		return false
+	case *ast.SliceExpr:
+		s, ok := n.X.(*ast.Ident)
+		if !ok || n.Max != nil {
+			return true
+		}
+		call, ok := n.High.(*ast.CallExpr)
+		if !ok || len(call.Args) != 1 || call.Ellipsis.IsValid() {
+			return true
+		}
+		fn, ok := call.Fun.(*ast.Ident)
+		arg, ok2 := call.Args[0].(*ast.Ident)
+		if ok && ok2 && fn.Name == "len" && arg.Name == s.Name {
+			n.High = nil
+		}
+	case *ast.RangeStmt:
+		if isBlank(n.Value) {
+			n.Value = nil
+		}
+		if isBlank(n.Key) && n.Value == nil {
+			n.Key = nil
+		}
+	}
+	return true
+}
+
+// simplifyElem simplifies e, an element of a composite literal whose
+// elements have type typ, and returns it without its redundant type:
+// T{...} becomes {...}, and &T{...} becomes {...} if typ is *T.
+func simplifyElem(e, typ ast.Expr) ast.Expr {
+	// Simplify e first: once its type is dropped, so is the element type
+	// that its own elements are compared with.
+	ast.Inspect(e, simplifyNode)
+	if lit, ok := e.(*ast.CompositeLit); ok && lit.Type != nil && sameExpr(lit.Type, typ) {
+		lit.Type = nil
+		return lit
+	}
+	ptr, ok := typ.(*ast.StarExpr)
+	if !ok {
+		return e
+	}
+	if u, ok := e.(*ast.UnaryExpr); ok && u.Op == token.AND {
+		if lit, ok := u.X.(*ast.CompositeLit); ok && lit.Type != nil && sameExpr(lit.Type, ptr.X) {
+			lit.Type = nil
+			return lit
+		}
+	}
+	return e
+}
+
+// sameExpr reports whether the type expressions a and b are written the
+// same way.
+func sameExpr(a, b ast.Expr) bool {
+	return types.ExprString(a) == types.ExprString(b)
+}
+
+// isBlank reports whether e is the blank identifier.
+func isBlank(e ast.Expr) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == "_"
+}
@@ -0,0 +1,100 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"go/format"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNormalizeSource(t *testing.T) {
+	// want is what gofmt -s makes of each input, and what normalizeSource
+	// must make of both the input and want itself.
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "SliceElements",
+			src:  "var x = []T{T{1}, T{2}}\n",
+			want: "var x = []T{{1}, {2}}\n",
+		},
+		{
+			name: "PointerElements",
+			src:  "var x = []*T{&T{1}, &T{2}}\n",
+			want: "var x = []*T{{1}, {2}}\n",
+		},
+		{
+			name: "MapKeysAndValues",
+			src:  "var x = map[K]*T{K{1}: &T{1}, K{2}: nil}\n",
+			want: "var x = map[K]*T{{1}: {1}, {2}: nil}\n",
+		},
+		{
+			name: "NestedLiterals",
+			src:  "var x = [][]T{[]T{T{1}}, []T{}}\n",
+			want: "var x = [][]T{{{1}}, {}}\n",
+		},
+		{
+			name: "QualifiedElementType",
+			src:  "var x = []foo.T{foo.T{}, bar.T{}}\n",
+			want: "var x = []foo.T{{}, bar.T{}}\n",
+		},
+		{
+			name: "StructLiteralsKeepTheirTypes",
+			src:  "var x = S{T: T{1}}\n",
+			want: "var x = S{T: T{1}}\n",
+		},
+		{
+			name: "SliceToLen",
+			src:  "func f(s []int) []int {\n\treturn s[1:len(s)]\n}\n",
+			want: "func f(s []int) []int {\n\treturn s[1:]\n}\n",
+		},
+		{
+			name: "RangeBlanks",
+			src:  "func f(s []int) {\n\tfor i, _ := range s {\n\t\t_ = i\n\t}\n\tfor _ = range s {\n\t}\n}\n",
+			want: "func f(s []int) {\n\tfor i := range s {\n\t\t_ = i\n\t}\n\tfor range s {\n\t}\n}\n",
+		},
+		{
+			name: "Comments",
+			src:  "var x = []T{\n\tT{1}, // one\n\tT{2}, // two\n}\n",
+			want: "var x = []T{\n\t{1}, // one\n\t{2}, // two\n}\n",
+		},
+	}
+	const pkg = "package foo\n\n"
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			want := pkg + test.want
+			for _, src := range []string{test.src, test.want} {
+				formatted, err := format.Source([]byte(pkg + src))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := normalizeSource(formatted)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(want, string(got)); diff != "" {
+					t.Errorf("normalizeSource(%q) diff (-want +got):\n%s", src, diff)
+				}
+				if again, err := format.Source(got); err != nil || string(again) != string(got) {
+					t.Errorf("gofmt changes the output of normalizeSource(%q) to %q (%v)", src, again, err)
+				}
+			}
+		})
+	}
+}
@@ -21,10 +21,14 @@ import (
     "errors"
     "fmt"
     "go/ast"
+    "go/constant"
+    "go/parser"
     "go/token"
     "go/types"
     "os"
+    "path/filepath"
     "reflect"
+    "sort"
     "strconv"
     "strings"
     "sync"
@@ -44,6 +48,9 @@ type providerSetSrc struct {
     Import      *ProviderSet
     InjectorArg *InjectorArg
     Field       *Field
+    Slice       *Slice
+    Keyed       *KeyedProvider
+    Map         *Map
 }
 
 // description returns a string describing the source of p, including line numbers.
@@ -61,6 +68,8 @@ func (p *providerSetSrc) description(fset *token.FileSet, typ types.Type) string
             kind = "struct provider"
         }
         return fmt.Sprintf("%s %s(%s)", kind, quoted(p.Provider.Name), fset.Position(p.Provider.Pos))
+    case p.Binding != nil && p.Binding.Synthesized:
+        return fmt.Sprintf("wire.Bind synthesized by %s (%s)", autoBindDirective, fset.Position(p.Binding.Pos))
     case p.Binding != nil:
         return fmt.Sprintf("wire.Bind (%s)", fset.Position(p.Binding.Pos))
     case p.Value != nil:
@@ -71,7 +80,73 @@ func (p *providerSetSrc) description(fset *token.FileSet, typ types.Type) string
         args := p.InjectorArg.Args
         return fmt.Sprintf("argument %s to injector function %s (%s)", args.Tuple.At(p.InjectorArg.Index).Name(), args.Name, fset.Position(args.Pos))
     case p.Field != nil:
-        return fmt.Sprintf("wire.FieldsOf (%s)", fset.Position(p.Field.Pos))
+        return fmt.Sprintf("field %q of %s (%s)", p.Field.Name, types.TypeString(p.Field.Parent, nil), fset.Position(p.Field.Pos))
+    case p.Slice != nil:
+        return fmt.Sprintf("wire.Slice (%s)", fset.Position(p.Slice.Pos))
+    case p.Keyed != nil:
+        return fmt.Sprintf("provider %sfor key %q (%s)", quoted(p.Keyed.Provider.Name), p.Keyed.Key, fset.Position(p.Keyed.Pos))
+    case p.Map != nil:
+        return fmt.Sprintf("wire.Map (%s)", fset.Position(p.Map.Pos))
+    }
+    panic("providerSetSrc with no fields set")
+}
+
+// origin returns the source of typ that p leads to, following provider
+// sets to the provider, binding, value, argument or field in them.
+func (p *providerSetSrc) origin(typ types.Type) *providerSetSrc {
+    for p.Import != nil {
+        parent := p.Import.srcMap.At(typ)
+        if parent == nil {
+            break
+        }
+        p = parent.(*providerSetSrc)
+    }
+    return p
+}
+
+// depth returns the number of provider sets between p and the source of typ
+// that p leads to: zero if p is the source itself, one if p is a set that
+// declares the source, and so on.
+func (p *providerSetSrc) depth(typ types.Type) int {
+    d := 0
+    for p.Import != nil {
+        d++
+        parent := p.Import.srcMap.At(typ)
+        if parent == nil {
+            break
+        }
+        p = parent.(*providerSetSrc)
+    }
+    return d
+}
+
+// position returns the position of the binding, value, provider, slice, map
+// or set that p describes.
+func (p *providerSetSrc) position(fset *token.FileSet) token.Position {
+    return fset.Position(p.pos())
+}
+
+// pos is like position, but returns a token.Pos.
+func (p *providerSetSrc) pos() token.Pos {
+    switch {
+    case p.Provider != nil:
+        return p.Provider.Pos
+    case p.Binding != nil:
+        return p.Binding.Pos
+    case p.Value != nil:
+        return p.Value.Pos
+    case p.Import != nil:
+        return p.Import.Pos
+    case p.InjectorArg != nil:
+        return p.InjectorArg.Args.Pos
+    case p.Field != nil:
+        return p.Field.Pos
+    case p.Slice != nil:
+        return p.Slice.Pos
+    case p.Keyed != nil:
+        return p.Keyed.Pos
+    case p.Map != nil:
+        return p.Map.Pos
     }
     panic("providerSetSrc with no fields set")
 }
@@ -102,13 +177,28 @@ type ProviderSet struct {
     // variable.
     VarName string
 
+    // Deprecated is the text of the "Deprecated:" paragraph in the doc
+    // comment of the set's variable, or empty if the set is not deprecated.
+    Deprecated string
+
+    // TestOnly reports whether the doc comment of the set's variable has a
+    // //wire:testonly directive. Only injectors in _test.go files may
+    // include a test-only set.
+    TestOnly bool
+
     Providers []*Provider
     Bindings  []*IfaceBinding
     Values    []*Value
     Fields    []*Field
+    Slices    []*Slice
+    Keyed     []*KeyedProvider
+    Maps      []*Map
     Imports   []*ProviderSet
     // InjectorArgs is only filled in for wire.Build.
     InjectorArgs *InjectorArgs
+    // CleanupRegistry is only filled in for a wire.Build call that has a
+    // wire.CleanupInto argument.
+    CleanupRegistry *CleanupRegistry
 
     // providerMap maps from provided type to a *ProvidedType.
     // It includes all of the imported types.
@@ -117,6 +207,73 @@ type ProviderSet struct {
     // srcMap maps from provided type to a *providerSetSrc capturing the
     // Provider, Binding, Value, or Import that provided the type.
     srcMap *typeutil.Map
+
+    // keyed lists the keyed providers of the set and its imports, in the
+    // order that wire.Map entries are collected.
+    keyed []keyedSrc
+
+    // pendingBindings are the bindings in the set or its imports whose
+    // concrete type the set does not provide. They are resolved against the
+    // arguments of the injector that uses the set.
+    pendingBindings []pendingBinding
+
+    // nearestWins enables nearest-wins precedence for a set built by
+    // wire.Build; see GenerateOptions.NearestWins. overrides lists the
+    // sources that the precedence left out of the set.
+    nearestWins bool
+    overrides   []override
+
+    // fset is the FileSet of the load that analyzed the set, which the
+    // positions in it and in the sets it includes belong to.
+    fset *token.FileSet
+}
+
+// An override is a source of a type in a set built by wire.Build that a
+// source the call includes through fewer provider sets took the place of.
+type override struct {
+    typ types.Type
+    src *providerSetSrc
+}
+
+// overridden returns the sources of t that nearest-wins precedence left
+// out of the set.
+func (set *ProviderSet) overridden(t types.Type) []*providerSetSrc {
+    var srcs []*providerSetSrc
+    for _, o := range set.overrides {
+        if types.Identical(o.typ, t) {
+            srcs = append(srcs, o.src)
+        }
+    }
+    return srcs
+}
+
+// A keyedSrc is a keyed provider of a provider set, and where the set gets
+// it from.
+type keyedSrc struct {
+    keyed *KeyedProvider
+    src   *providerSetSrc
+}
+
+// A pendingBinding is an interface binding whose concrete type is not
+// provided by the set that declares it.
+type pendingBinding struct {
+    binding *IfaceBinding
+    // setName names the set that declares the binding, for errors.
+    setName string
+}
+
+// Position returns the file, line and column of pos, a position in set or
+// in one of the sets it includes, such as the Pos of one of its providers.
+// The positions in a set belong to the FileSet of the load that analyzed
+// it, so a set that a ProviderSetCache returns, which may have been
+// analyzed by an earlier load, must be asked for its own positions rather
+// than have them looked up in the FileSet of the current one. Position
+// returns the zero Position for a set that Wire did not analyze.
+func (set *ProviderSet) Position(pos token.Pos) token.Position {
+    if set.fset == nil {
+        return token.Position{}
+    }
+    return set.fset.Position(pos)
 }
 
 // Outputs returns a new slice containing the set of possible types the
@@ -134,6 +291,17 @@ func (set *ProviderSet) For(t types.Type) ProvidedType {
     return *pt.(*ProvidedType)
 }
 
+// keyedOf returns the keyed providers of t in the set.
+func (set *ProviderSet) keyedOf(t types.Type) []*KeyedProvider {
+    var keyed []*KeyedProvider
+    for _, ks := range set.keyed {
+        if types.Identical(ks.keyed.Provider.Out[0], t) {
+            keyed = append(keyed, ks.keyed)
+        }
+    }
+    return keyed
+}
+
 // An IfaceBinding declares that a type should be used to satisfy inputs
 // of the given interface type.
 type IfaceBinding struct {
@@ -145,6 +313,11 @@ type IfaceBinding struct {
 
     // Pos is the position where the binding was declared.
     Pos token.Pos
+
+    // Synthesized is true if the binding was not declared with wire.Bind
+    // but added for an injector with a //wire:autobind directive. Pos is
+    // then the position of the injector.
+    Synthesized bool
 }
 
 // Provider records the signature of a provider. A provider is a
@@ -156,6 +329,11 @@ type Provider struct {
     // Name is the name of the Go object.
     Name string
 
+    // TypeArgs is the list of type arguments that a generic function or
+    // struct type is instantiated with. It is empty for non-generic
+    // providers.
+    TypeArgs []types.Type
+
     // Pos is the source position of the func keyword or type spec
     // defining this provider.
     Pos token.Pos
@@ -171,7 +349,8 @@ type Provider struct {
     IsStruct bool
 
     // Out is the set of types this provider produces. It will always
-    // contain at least one type.
+    // contain at least one type. For a function, Out lists its results in
+    // order, without the cleanup function and error.
     Out []types.Type
 
     // HasCleanup reports whether the provider function returns a cleanup
@@ -181,6 +360,30 @@ type Provider struct {
     // HasErr reports whether the provider function can return an error.
     // (Always false for structs.)
     HasErr bool
+
+    // Deprecated is the text of the "Deprecated:" paragraph in the doc
+    // comment of the provider function, or empty if it is not deprecated.
+    // (Always empty for structs.)
+    Deprecated string
+
+    // CleanupRecovers lists the positions of the recover calls in the
+    // cleanup functions that the provider function returns, except for
+    // those allowed by a //wire:allow-recover directive. (Always empty for
+    // structs.)
+    CleanupRecovers []token.Pos
+
+    // GlobalReads lists the reads of package-level variables of other
+    // packages in the body of the provider function, except for those
+    // allowed by a //wire:allow-global-read directive. (Always empty for
+    // structs.)
+    GlobalReads []GlobalRead
+
+    // ConvertsFrom is the type of the parameter that the provider function
+    // only converts to its result, if its body is just that conversion and
+    // the two are distinct named types with the same underlying type, unless
+    // a //wire:allow-conversion directive allows it. Otherwise it is nil.
+    // (Always nil for structs.)
+    ConvertsFrom types.Type
 }
 
 // ProviderInput describes an incoming edge in the provider graph.
@@ -224,6 +427,16 @@ type InjectorArgs struct {
     Pos token.Pos
 }
 
+// CleanupRegistry describes a wire.CleanupInto call: the injector hands the
+// cleanup functions of its providers to a value of type Type by calling its
+// Append method.
+type CleanupRegistry struct {
+    // Pos is the position of the call to wire.CleanupInto.
+    Pos token.Pos
+    // Type is the registry type.
+    Type types.Type
+}
+
 // Field describes a specific field selected from a struct.
 type Field struct {
     // Parent is the struct or pointer to the struct that the field belongs to.
@@ -241,6 +454,107 @@ type Field struct {
     Out []types.Type
 }
 
+// Slice describes a wire.Slice call: the slice type Out is provided by a
+// slice literal with one element for each provider of its element type.
+type Slice struct {
+    // Pos is the position of the call to wire.Slice.
+    Pos token.Pos
+    // Out is the slice type.
+    Out types.Type
+
+    // Elems lists what provides each element, in element order: the
+    // provider, value, field or injector argument of the element type, or
+    // the provider of the concrete type that a binding binds the element
+    // type to. It is only filled in for the slices that ProviderSet.For
+    // returns, which collect the elements of the whole set.
+    Elems []ProvidedType
+
+    // srcs is where the set that the slice belongs to gets the slice and
+    // each of Elems from.
+    srcs []*providerSetSrc
+}
+
+// Elem returns the element type of the slice.
+func (s *Slice) Elem() types.Type {
+    return s.Out.Underlying().(*types.Slice).Elem()
+}
+
+// Inputs returns the types that the elements of s are made from: the
+// arguments of the providers and the parents of the fields that provide the
+// element type, and the concrete types of the elements bound to it.
+func (s *Slice) Inputs() []types.Type {
+    var deps []types.Type
+    for _, e := range s.Elems {
+        switch {
+        case !types.Identical(e.Type(), s.Elem()):
+            deps = append(deps, e.Type())
+        case e.IsProvider():
+            for _, a := range e.Provider().Args {
+                deps = append(deps, a.Type)
+            }
+        case e.IsField():
+            deps = append(deps, e.Field().Parent)
+        }
+    }
+    return deps
+}
+
+// KeyedProvider describes a wire.Key call: a provider whose result is also
+// an entry of the maps declared with wire.Map.
+type KeyedProvider struct {
+    // Pos is the position of the call to wire.Key.
+    Pos token.Pos
+    // Key is the key of the entry.
+    Key string
+    // Provider provides the value of the entry.
+    Provider *Provider
+}
+
+// Map describes a wire.Map call: the map type Out is provided by a map
+// literal with one entry for each keyed provider of its value type.
+type Map struct {
+    // Pos is the position of the call to wire.Map.
+    Pos token.Pos
+    // Out is the map type.
+    Out types.Type
+
+    // Entries lists the keyed providers of the entries, sorted by key. It
+    // is only filled in for the maps that ProviderSet.For returns, which
+    // collect the entries of the whole set.
+    Entries []*KeyedProvider
+
+    // provides reports for each of Entries whether its provider also
+    // provides the value type to the set, so that the map can use that
+    // value instead of calling the provider again.
+    provides []bool
+
+    // srcs is where the set that the map belongs to gets the map and each
+    // of Entries from.
+    srcs []*providerSetSrc
+}
+
+// Elem returns the value type of the map.
+func (m *Map) Elem() types.Type {
+    return m.Out.Underlying().(*types.Map).Elem()
+}
+
+// Inputs returns the types that the entries of m are made from: the value
+// type if the set provides it with the entry's provider, or else the
+// arguments of the provider.
+func (m *Map) Inputs() []types.Type {
+    var deps []types.Type
+    for i, e := range m.Entries {
+        if m.provides[i] {
+            deps = append(deps, m.Elem())
+            continue
+        }
+        for _, a := range e.Provider.Args {
+            deps = append(deps, a.Type)
+        }
+    }
+    return deps
+}
+
 // Load finds all the provider sets in the packages that match the given
 // patterns, as well as the provider sets' transitive dependencies. It
 // may return both errors and Info. The patterns are defined by the
@@ -253,7 +567,13 @@ type Field struct {
 // In case of duplicate environment variables, the last one in the list
 // takes precedence.
 func Load(ctx context.Context, wd string, env []string, tags string, patterns []string) (*Info, []error) {
-    pkgs, errs := load(ctx, wd, env, tags, patterns)
+    return LoadWithBuildTag(ctx, wd, env, defaultBuildTag, tags, patterns)
+}
+
+// LoadWithBuildTag is like Load, but for injector files marked with
+// buildTag instead of wireinject, as with GenerateOptions.BuildTag.
+func LoadWithBuildTag(ctx context.Context, wd string, env []string, buildTag, tags string, patterns []string) (*Info, []error) {
+    pkgs, errs := load(ctx, wd, env, buildTag, tags, nil, patterns)
     if len(errs) > 0 {
         return nil, errs
     }
@@ -272,6 +592,9 @@ func Load(ctx context.Context, wd string, env []string, tags string, patterns []
             // The marker function package confuses analysis.
             continue
         }
+        // Generate reports any errors in the package options.
+        opts, _ := packageOptions(pkg, &GenerateOptions{BuildTag: buildTag})
+        oc.nearestWins = opts != nil && opts.NearestWins
         scope := pkg.Types.Scope()
         for _, name := range scope.Names() {
             obj := scope.Lookup(name)
@@ -289,7 +612,7 @@ func Load(ctx context.Context, wd string, env []string, tags string, patterns []
             id := ProviderSetID{ImportPath: pset.PkgPath, VarName: name}
             info.Sets[id] = pset
         }
-        for _, f := range pkg.Syntax {
+        for _, f := range sourceFiles(pkg) {
             for _, decl := range f.Decls {
                 fn, ok := decl.(*ast.FuncDecl)
                 if !ok {
@@ -297,46 +620,18 @@ func Load(ctx context.Context, wd string, env []string, tags string, patterns []
                 }
                 buildCall, err := findInjectorBuild(pkg.TypesInfo, fn)
                 if err != nil {
-                    ec.add(notePosition(fset.Position(fn.Pos()), fmt.Errorf("inject %s: %v", fn.Name.Name, err)))
+                    ec.add(injectorError(fset.Position(fn.Pos()), fn.Name.Name, err))
                     continue
                 }
                 if buildCall == nil {
                     continue
                 }
-                sig := pkg.TypesInfo.ObjectOf(fn.Name).Type().(*types.Signature)
-                ins, out, err := injectorFuncSignature(sig)
-                if err != nil {
-                    if w, ok := err.(*wireErr); ok {
-                        ec.add(notePosition(w.position, fmt.Errorf("inject %s: %v", fn.Name.Name, w.error)))
-                    } else {
-                        ec.add(notePosition(fset.Position(fn.Pos()), fmt.Errorf("inject %s: %v", fn.Name.Name, err)))
-                    }
-                    continue
-                }
-                injectorArgs := &InjectorArgs{
-                    Name:  fn.Name.Name,
-                    Tuple: ins,
-                    Pos:   fn.Pos(),
-                }
-                set, errs := oc.processNewSet(pkg.TypesInfo, pkg.PkgPath, buildCall, injectorArgs, "")
-                if len(errs) > 0 {
-                    ec.add(notePositionAll(fset.Position(fn.Pos()), errs)...)
-                    continue
-                }
-                _, errs = solve(fset, out.out, ins, set)
+                in, errs := oc.checkInjector(pkg, fn, buildCall)
                 if len(errs) > 0 {
-                    ec.add(mapErrors(errs, func(e error) error {
-                        if w, ok := e.(*wireErr); ok {
-                            return notePosition(w.position, fmt.Errorf("inject %s: %v", fn.Name.Name, w.error))
-                        }
-                        return notePosition(fset.Position(fn.Pos()), fmt.Errorf("inject %s: %v", fn.Name.Name, e))
-                    })...)
+                    ec.add(errs...)
                     continue
                 }
-                info.Injectors = append(info.Injectors, &Injector{
-                    ImportPath: pkg.PkgPath,
-                    FuncName:   fn.Name.Name,
-                })
+                info.Injectors = append(info.Injectors, in)
             }
         }
     }
@@ -344,83 +639,276 @@ func Load(ctx context.Context, wd string, env []string, tags string, patterns []
 }
 
 // load typechecks the packages that match the given patterns and
-// includes source for all transitive dependencies. The patterns are
+// includes source for all transitive dependencies outside of the standard
+// library, whose types come from export data. The patterns are
 // defined by the underlying build system. For the go tool, this is
 // described at https://golang.org/cmd/go/#hdr-Package_lists_and_patterns
 //
 // wd is the working directory and env is the set of environment
 // variables to use when loading the packages specified by patterns. If
-// env is nil or empty, it is interpreted as an empty set of variables.
-// In case of duplicate environment variables, the last one in the list
-// takes precedence.
-func load(ctx context.Context, wd string, env []string, tags string, patterns []string) ([]*packages.Package, []error) {
-    cfg := &packages.Config{
-        Context: ctx,
-        // Performance optimization: Use explicit mode flags instead of LoadAllSyntax.
-        // This allows the go/packages loader to be more efficient by not loading
-        // unnecessary information for indirect dependencies.
-        // LoadAllSyntax = NeedName | NeedFiles | NeedCompiledGoFiles | NeedImports |
-        //                 NeedTypes | NeedTypesSizes | NeedSyntax | NeedTypesInfo | NeedDeps
-        Mode: packages.NeedName |
-            packages.NeedFiles |
-            packages.NeedCompiledGoFiles |
-            packages.NeedImports |
-            packages.NeedTypes |
-            packages.NeedTypesSizes |
-            packages.NeedSyntax |
-            packages.NeedTypesInfo |
-            packages.NeedDeps,
-        Dir: wd,
-        Env: env,
-        // Use -mod=readonly to skip unnecessary go.mod updates
-        BuildFlags: []string{"-tags=wireinject", "-mod=readonly"},
-        // TODO(light): Use ParseFile to skip function bodies and comments in indirect packages.
-    }
-    if len(tags) > 0 {
-        cfg.BuildFlags[0] += " " + tags
-    }
-    escaped := make([]string, len(patterns))
-    for i := range patterns {
-        escaped[i] = "pattern=" + patterns[i]
-    }
-    pkgs, err := packages.Load(cfg, escaped...)
+// env is nil, the current process's environment is used. Variables that
+// locate the go command's build cache, such as HOME and GOCACHE, are taken
+// from the current process if env lacks them. In case of duplicate
+// environment variables, the last one in the list takes precedence.
+// overlay, if not nil, replaces the contents of files as with
+// GenerateOptions.Overlay.
+func load(ctx context.Context, wd string, env []string, buildTag, tags string, overlay map[string][]byte, patterns []string) ([]*packages.Package, []error) {
+    return newImportCache(ctx, wd, env, buildTag, tags, overlay).load(patterns)
+}
+
+// checkInjector solves the injector fn, whose body calls buildCall, and
+// describes it. A panic while analyzing the injector is reported as an
+// internal error at the injector's position.
+func (oc *objectCache) checkInjector(pkg *packages.Package, fn *ast.FuncDecl, buildCall *ast.CallExpr) (_ *Injector, errs []error) {
+    fset := oc.fset
+    defer func() {
+        if r := recover(); r != nil {
+            errs = []error{internalError(fset.Position(fn.Pos()), fn.Name.Name, r)}
+        }
+    }()
+    sig := pkg.TypesInfo.ObjectOf(fn.Name).Type().(*types.Signature)
+    ins, out, err := injectorFuncSignature(sig)
     if err != nil {
-        return nil, []error{err}
+        return nil, []error{injectorError(fset.Position(fn.Pos()), fn.Name.Name, err)}
     }
-    var errs []error
-    for _, p := range pkgs {
-        for _, e := range p.Errors {
-            errs = append(errs, e)
+    injectorArgs := &InjectorArgs{
+        Name:  fn.Name.Name,
+        Tuple: ins,
+        Pos:   fn.Pos(),
+    }
+    set, errs := oc.processNewSet(pkg.TypesInfo, pkg.PkgPath, buildCall, injectorArgs, "")
+    if len(errs) > 0 {
+        return nil, notePositionAll(fset.Position(fn.Pos()), errs)
+    }
+    if hasDirective(fn.Doc, autoBindDirective) {
+        if err := autoBind(oc.hasher, set, out.out, fn.Pos()); err != nil {
+            return nil, []error{injectorError(fset.Position(fn.Pos()), fn.Name.Name, err)}
         }
     }
+    if err := checkTestOnly(fset, fn.Pos(), set); err != nil {
+        return nil, []error{injectorError(fset.Position(fn.Pos()), fn.Name.Name, err)}
+    }
+    calls, errs := solveInjector(fset, out.out, ins, set)
     if len(errs) > 0 {
-        return nil, errs
+        suggestFixes(pkg, fn, buildCall, set, errs)
+        return nil, mapErrors(errs, func(e error) error {
+            return injectorError(fset.Position(fn.Pos()), fn.Name.Name, e)
+        })
     }
-    return pkgs, nil
+    return &Injector{
+        ImportPath: pkg.PkgPath,
+        FuncName:   fn.Name.Name,
+        Sig:        sig,
+        Steps:      injectorSteps(set, out.out, calls),
+    }, nil
+}
+
+// loadProgress tracks how far a call to packages.Load has gotten, so that a
+// load that is cut short by its context can say so. Its parseFile method is
+// used as packages.Config.ParseFile.
+type loadProgress struct {
+    ctx context.Context
+
+    mu   sync.Mutex
+    dirs map[string]bool
+}
+
+func newLoadProgress(ctx context.Context) *loadProgress {
+    return &loadProgress{ctx: ctx, dirs: make(map[string]bool)}
+}
+
+// parseFile parses a file like the go/packages default, but stops parsing
+// as soon as the context is done. go/packages already kills the go command
+// when the context is done, but would otherwise keep parsing and
+// type-checking the packages that it listed.
+func (lp *loadProgress) parseFile(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+    if err := lp.ctx.Err(); err != nil {
+        return nil, err
+    }
+    lp.mu.Lock()
+    lp.dirs[filepath.Dir(filename)] = true
+    lp.mu.Unlock()
+    const mode = parser.AllErrors | parser.ParseComments
+    return parser.ParseFile(fset, filename, src, mode)
+}
+
+// interrupted returns a *LoadInterruptedError if the context is done, or nil
+// otherwise.
+func (lp *loadProgress) interrupted() error {
+    err := lp.ctx.Err()
+    if err == nil {
+        return nil
+    }
+    lp.mu.Lock()
+    defer lp.mu.Unlock()
+    return &LoadInterruptedError{Err: err, Parsed: len(lp.dirs)}
 }
 
 // ProviderSetCache provides incremental compilation support by caching
 // analyzed provider sets. This significantly improves performance for
 // repeated builds where only a subset of files have changed.
+//
+// The cache is split into shards by key, so that concurrent lookups of
+// different sets rarely wait for each other, and files are checked without
+// holding any lock.
 type ProviderSetCache struct {
-    mu          sync.RWMutex
-    sets        map[string]*cachedProviderSet // key: pkgPath + ":" + varName
-    fileModTime map[string]time.Time          // file path -> mod time (fast check)
-    fileHash    map[string]string             // file path -> content hash (fallback)
+    shards [providerSetCacheShards]providerSetCacheShard
 }
 
+// providerSetCacheShards is the number of shards of a ProviderSetCache.
+const providerSetCacheShards = 32
+
+type providerSetCacheShard struct {
+    mu   sync.RWMutex
+    sets map[ProviderSetKey]*cachedProviderSet
+}
+
+// A ProviderSetKey identifies a provider set in a ProviderSetCache.
+//
+// The package path and variable name alone are not enough: a package may
+// declare the same variable in several files guarded by different build
+// tags, so the key also includes the position of the declaration.
+type ProviderSetKey struct {
+    PkgPath string
+    VarName string
+
+    // Filename and Offset locate the declaration of the variable.
+    Filename string
+    Offset   int
+}
+
+// ProviderSetKeyOf returns the key for the provider set declared by obj,
+// a package-level variable. Symbolic links in the file name are resolved,
+// so that a set loaded through a symlinked directory has the same key.
+func ProviderSetKeyOf(fset *token.FileSet, obj types.Object) ProviderSetKey {
+    pos := fset.Position(obj.Pos())
+    return ProviderSetKey{
+        PkgPath:  obj.Pkg().Path(),
+        VarName:  obj.Name(),
+        Filename: canonicalPath(pos.Filename),
+        Offset:   pos.Offset,
+    }
+}
+
+// ProviderSetFiles returns the sorted names of the files that set was
+// analyzed from: those declaring the set, its providers, bindings, values
+// and fields, and those of every set it imports, wherever they are. In a
+// go.work workspace the imported sets may come from other modules, whose
+// files must be passed to the cache too, or editing them would not
+// invalidate set. File names are resolved as in ProviderSetKeyOf.
+func ProviderSetFiles(fset *token.FileSet, set *ProviderSet) []string {
+    files := make(map[string]bool)
+    add := func(pos token.Pos) {
+        if pos.IsValid() {
+            files[canonicalPath(fset.Position(pos).Filename)] = true
+        }
+    }
+    seen := make(map[*ProviderSet]bool)
+    var visit func(*ProviderSet)
+    visit = func(set *ProviderSet) {
+        if seen[set] {
+            return
+        }
+        seen[set] = true
+        add(set.Pos)
+        for _, p := range set.Providers {
+            add(p.Pos)
+        }
+        for _, b := range set.Bindings {
+            add(b.Pos)
+        }
+        for _, v := range set.Values {
+            add(v.Pos)
+        }
+        for _, f := range set.Fields {
+            add(f.Pos)
+        }
+        for _, k := range set.Keyed {
+            add(k.Provider.Pos)
+        }
+        for _, imp := range set.Imports {
+            visit(imp)
+        }
+    }
+    visit(set)
+    names := make([]string, 0, len(files))
+    for f := range files {
+        names = append(names, f)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// shard returns the shard of c that holds key.
+func (c *ProviderSetCache) shard(key ProviderSetKey) *providerSetCacheShard {
+    // FNV-1a, inlined so that lookups do not allocate.
+    h := uint32(2166136261)
+    for _, f := range [...]string{key.PkgPath, key.VarName, key.Filename} {
+        for i := 0; i < len(f); i++ {
+            h = (h ^ uint32(f[i])) * 16777619
+        }
+        h *= 16777619 // separates the fields
+    }
+    h = (h ^ uint32(key.Offset)) * 16777619
+    return &c.shards[h%providerSetCacheShards]
+}
+
+// A cachedProviderSet is never modified once it is in the cache, so a
+// lookup that races a CacheSet for the same key sees either the old entry
+// or the new one, never a mix.
 type cachedProviderSet struct {
     set       *ProviderSet
     timestamp time.Time
+    // files records the state of the files that set was analyzed from
+    // when it was cached.
+    files map[string]cachedFile
+    // info describes set if it was cached by ParseProviderSet.
+    info *ProviderSetInfo
+}
+
+// valid reports whether files, as overlaid by overlay, are as they were
+// when the set was cached.
+func (cached *cachedProviderSet) valid(files []string, overlay map[string][]byte) bool {
+    for _, f := range files {
+        cf, ok := cached.files[f]
+        if content, overlaid := overlay[f]; overlaid {
+            // An overlay has no mod time, so always compare hashes.
+            if !ok || cf.hash == "" || cf.hash != hashContent(content) {
+                return false
+            }
+            continue
+        }
+        // Fast path: check file modification times
+        info, err := os.Stat(f)
+        if err != nil {
+            return false
+        }
+        if !ok || !cf.modTime.Equal(info.ModTime()) {
+            // Mod time changed, need to verify with hash
+            hash, err := computeFileHash(f)
+            if err != nil {
+                return false
+            }
+            if !ok || cf.hash == "" || cf.hash != hash {
+                return false
+            }
+        }
+    }
+    return true
+}
+
+type cachedFile struct {
+    modTime time.Time
+    hash    string
 }
 
 // NewProviderSetCache creates a new cache for provider sets.
 func NewProviderSetCache() *ProviderSetCache {
-    return &ProviderSetCache{
-        sets:        make(map[string]*cachedProviderSet),
-        fileModTime: make(map[string]time.Time),
-        fileHash:    make(map[string]string),
+    c := new(ProviderSetCache)
+    for i := range c.shards {
+        c.shards[i].sets = make(map[ProviderSetKey]*cachedProviderSet)
     }
+    return c
 }
 
 // globalCache is a package-level cache for provider sets.
@@ -432,49 +920,57 @@ func GetGlobalCache() *ProviderSetCache {
     return globalCache
 }
 
+// lookup returns the entry for key, if any.
+func (c *ProviderSetCache) lookup(key ProviderSetKey) *cachedProviderSet {
+    sh := c.shard(key)
+    sh.mu.RLock()
+    defer sh.mu.RUnlock()
+    return sh.sets[key]
+}
+
 // GetCachedSet retrieves a cached provider set if it's still valid.
 // It uses a two-level check: first mod time (fast), then content hash (accurate).
-func (c *ProviderSetCache) GetCachedSet(pkgPath, varName string, files []string) (*ProviderSet, bool) {
-    c.mu.RLock()
-    defer c.mu.RUnlock()
-
-    key := pkgPath + ":" + varName
-    cached, ok := c.sets[key]
-    if !ok {
+// The positions in the set are those of the load that cached it; use
+// ProviderSet.Position to find their file, line and column.
+func (c *ProviderSetCache) GetCachedSet(key ProviderSetKey, files []string) (*ProviderSet, bool) {
+    return c.GetCachedSetWithOverlay(key, files, nil)
+}
+
+// GetCachedSetWithOverlay is like GetCachedSet, but the files in overlay,
+// as with GenerateOptions.Overlay, are validated against the overlaid
+// contents instead of the files on disk.
+func (c *ProviderSetCache) GetCachedSetWithOverlay(key ProviderSetKey, files []string, overlay map[string][]byte) (*ProviderSet, bool) {
+    cached := c.lookup(key)
+    if cached == nil || !cached.valid(files, overlay) {
         return nil, false
     }
+    return cached.set, true
+}
 
-    // Fast path: check file modification times
-    for _, f := range files {
-        info, err := os.Stat(f)
-        if err != nil {
-            return nil, false
-        }
-        cachedModTime, ok := c.fileModTime[f]
-        if !ok || !cachedModTime.Equal(info.ModTime()) {
-            // Mod time changed, need to verify with hash
-            hash, err := computeFileHash(f)
-            if err != nil {
-                return nil, false
-            }
-            if cachedHash, ok := c.fileHash[f]; !ok || cachedHash != hash {
-                return nil, false
-            }
-        }
+// cachedInfo returns the description of the set cached for key by
+// ParseProviderSet, if none of the files it was analyzed from changed
+// since.
+func (c *ProviderSetCache) cachedInfo(key ProviderSetKey) (*ProviderSetInfo, bool) {
+    cached := c.lookup(key)
+    if cached == nil || cached.info == nil {
+        return nil, false
     }
-
-    return cached.set, true
+    files := make([]string, 0, len(cached.files))
+    for f := range cached.files {
+        files = append(files, f)
+    }
+    if !cached.valid(files, nil) {
+        return nil, false
+    }
+    return cached.info, true
 }
 
 // GetCachedSetFast retrieves a cached provider set using only mod time check.
 // This is faster but may have false negatives if file was touched without changes.
-func (c *ProviderSetCache) GetCachedSetFast(pkgPath, varName string, files []string) (*ProviderSet, bool) {
-    c.mu.RLock()
-    defer c.mu.RUnlock()
-
-    key := pkgPath + ":" + varName
-    cached, ok := c.sets[key]
-    if !ok {
+// It never validates a set cached with an overlay.
+func (c *ProviderSetCache) GetCachedSetFast(key ProviderSetKey, files []string) (*ProviderSet, bool) {
+    cached := c.lookup(key)
+    if cached == nil {
         return nil, false
     }
 
@@ -484,8 +980,8 @@ func (c *ProviderSetCache) GetCachedSetFast(pkgPath, varName string, files []str
         if err != nil {
             return nil, false
         }
-        cachedModTime, ok := c.fileModTime[f]
-        if !ok || !cachedModTime.Equal(info.ModTime()) {
+        cf, ok := cached.files[f]
+        if !ok || !cf.modTime.Equal(info.ModTime()) {
             return nil, false
         }
     }
@@ -494,60 +990,100 @@ func (c *ProviderSetCache) GetCachedSetFast(pkgPath, varName string, files []str
 }
 
 // CacheSet stores a provider set in the cache.
-func (c *ProviderSetCache) CacheSet(pkgPath, varName string, set *ProviderSet, files []string) {
-    c.mu.Lock()
-    defer c.mu.Unlock()
+func (c *ProviderSetCache) CacheSet(key ProviderSetKey, set *ProviderSet, files []string) {
+    c.CacheSetWithOverlay(key, set, files, nil)
+}
 
-    key := pkgPath + ":" + varName
+// CacheSetWithOverlay is like CacheSet, but records the overlaid contents of
+// the files in overlay instead of the files on disk.
+func (c *ProviderSetCache) CacheSetWithOverlay(key ProviderSetKey, set *ProviderSet, files []string, overlay map[string][]byte) {
+    c.store(key, newCachedProviderSet(set, files, overlay))
+}
 
-    // Update file mod times and hashes
+// cacheInfo stores set in the cache along with info, its description by
+// ParseProviderSet.
+func (c *ProviderSetCache) cacheInfo(key ProviderSetKey, set *ProviderSet, info *ProviderSetInfo, files []string) {
+    entry := newCachedProviderSet(set, files, nil)
+    entry.info = info
+    c.store(key, entry)
+}
+
+// newCachedProviderSet returns a cache entry for set that records the state
+// of files. It is built before taking any lock, since it reads the files.
+func newCachedProviderSet(set *ProviderSet, files []string, overlay map[string][]byte) *cachedProviderSet {
+    entry := &cachedProviderSet{
+        set:       set,
+        timestamp: time.Now(),
+        files:     make(map[string]cachedFile, len(files)),
+    }
     for _, f := range files {
-        info, err := os.Stat(f)
-        if err != nil {
+        if content, ok := overlay[f]; ok {
+            entry.files[f] = cachedFile{hash: hashContent(content)}
             continue
         }
-        c.fileModTime[f] = info.ModTime()
-
-        hash, err := computeFileHash(f)
+        info, err := os.Stat(f)
         if err != nil {
             continue
         }
-        c.fileHash[f] = hash
+        cf := cachedFile{modTime: info.ModTime()}
+        if hash, err := computeFileHash(f); err == nil {
+            cf.hash = hash
+        }
+        entry.files[f] = cf
     }
+    return entry
+}
 
-    c.sets[key] = &cachedProviderSet{
-        set:       set,
-        timestamp: time.Now(),
-    }
+// store puts entry in the cache under key, replacing any previous entry.
+func (c *ProviderSetCache) store(key ProviderSetKey, entry *cachedProviderSet) {
+    sh := c.shard(key)
+    sh.mu.Lock()
+    defer sh.mu.Unlock()
+    sh.sets[key] = entry
 }
 
 // InvalidatePackage removes all cached sets for a given package.
 func (c *ProviderSetCache) InvalidatePackage(pkgPath string) {
-    c.mu.Lock()
-    defer c.mu.Unlock()
-
-    prefix := pkgPath + ":"
-    for key := range c.sets {
-        if len(key) >= len(prefix) && key[:len(prefix)] == prefix {
-            delete(c.sets, key)
+    for i := range c.shards {
+        sh := &c.shards[i]
+        sh.mu.Lock()
+        for key := range sh.sets {
+            if key.PkgPath == pkgPath {
+                delete(sh.sets, key)
+            }
         }
+        sh.mu.Unlock()
     }
 }
 
 // Clear removes all cached entries.
 func (c *ProviderSetCache) Clear() {
-    c.mu.Lock()
-    defer c.mu.Unlock()
-
-    c.sets = make(map[string]*cachedProviderSet)
-    c.fileHash = make(map[string]string)
+    for i := range c.shards {
+        sh := &c.shards[i]
+        sh.mu.Lock()
+        sh.sets = make(map[ProviderSetKey]*cachedProviderSet)
+        sh.mu.Unlock()
+    }
 }
 
-// Stats returns cache statistics for monitoring.
+// Stats returns cache statistics for monitoring: the number of cached sets
+// and of distinct files they were analyzed from.
 func (c *ProviderSetCache) Stats() (setCount int, fileCount int) {
-    c.mu.RLock()
-    defer c.mu.RUnlock()
-    return len(c.sets), len(c.fileHash)
+    files := make(map[string]bool)
+    for i := range c.shards {
+        sh := &c.shards[i]
+        sh.mu.RLock()
+        setCount += len(sh.sets)
+        for _, cached := range sh.sets {
+            for f, cf := range cached.files {
+                if cf.hash != "" {
+                    files[f] = true
+                }
+            }
+        }
+        sh.mu.RUnlock()
+    }
+    return setCount, len(files)
 }
 
 // computeFileHash computes a SHA256 hash of a file's contents.
@@ -556,8 +1092,13 @@ func computeFileHash(path string) (string, error) {
     if err != nil {
         return "", err
     }
+    return hashContent(content), nil
+}
+
+// hashContent returns the SHA256 hash of content as computeFileHash does.
+func hashContent(content []byte) string {
     hash := sha256.Sum256(content)
-    return hex.EncodeToString(hash[:]), nil
+    return hex.EncodeToString(hash[:])
 }
 
 // Info holds the result of Load.
@@ -587,6 +1128,79 @@ func (id ProviderSetID) String() string {
 type Injector struct {
     ImportPath string
     FuncName   string
+
+    // Sig is the signature of the injector function.
+    Sig *types.Signature
+    // Steps are the types that the injector provides, in the order that
+    // it provides them, each with its source.
+    Steps []InjectorStep
+}
+
+// An InjectorStep is a type that an injector provides and the provider,
+// value, field or interface binding that provides it.
+type InjectorStep struct {
+    Type types.Type
+    // Source describes the source by kind and fully qualified name, as in
+    // "example.com/db.NewPool", "wire.Struct(example.com/db.Config)",
+    // "wire.Value(db.DefaultSize)", "wire.FieldsOf(Addr)" or
+    // "wire.Bind(*example.com/db.Pool)". Sources that are the same in two
+    // loads of a package have the same description.
+    Source string
+    // Pos is the position of the source.
+    Pos token.Pos
+}
+
+// injectorSteps returns the steps of an injector with output out, whose
+// provider set set gives calls.
+func injectorSteps(set *ProviderSet, out types.Type, calls []call) []InjectorStep {
+    var steps []InjectorStep
+    needed := []types.Type{out}
+    for _, c := range calls {
+        needed = append(needed, c.ins...)
+        var src string
+        switch c.kind {
+        case funcProviderCall:
+            src = c.pkg.Path() + "." + c.name
+        case structProvider:
+            src = "wire.Struct(" + c.pkg.Path() + "." + c.name + ")"
+        case valueExpr:
+            src = "wire.Value(" + types.ExprString(c.valueExpr) + ")"
+        case selectorExpr:
+            src = "wire.FieldsOf(" + c.name + ")"
+        case sliceLit:
+            src = "wire.Slice"
+        case mapLit:
+            src = "wire.Map"
+        }
+        outs := c.outs
+        if outs == nil {
+            outs = []types.Type{c.out}
+        }
+        for _, t := range outs {
+            steps = append(steps, InjectorStep{Type: t, Source: src, Pos: c.pos})
+        }
+    }
+    seen := new(typeutil.Map)
+    for _, t := range needed {
+        if seen.At(t) != nil {
+            continue
+        }
+        seen.Set(t, true)
+        pt := set.For(t)
+        if pt.IsNil() || types.Identical(pt.Type(), t) {
+            continue
+        }
+        var pos token.Pos
+        if src, ok := set.srcMap.At(t).(*providerSetSrc); ok {
+            pos = src.pos()
+        }
+        steps = append(steps, InjectorStep{
+            Type:   t,
+            Source: "wire.Bind(" + types.TypeString(pt.Type(), nil) + ")",
+            Pos:    pos,
+        })
+    }
+    return steps
 }
 
 // String returns the injector name as ""path/to/pkg".Foo".
@@ -601,13 +1215,18 @@ type objectCache struct {
     objects  map[objRef]objCacheEntry
     hasher   typeutil.Hasher
 
+    // nearestWins is GenerateOptions.NearestWins for the injectors whose
+    // wire.Build calls are processed next.
+    nearestWins bool
+
     // Lazy loading support
     mu              sync.RWMutex
     lazyLoadEnabled bool
-    loadCtx         context.Context
-    loadWd          string
-    loadEnv         []string
+    loader          *batchLoader
     pendingPkgs     map[string]bool // packages that need to be loaded
+
+    // stats, if not nil, counts the packages that getPackage looks up.
+    stats *loadStats
 }
 
 type objRef struct {
@@ -653,95 +1272,92 @@ func newObjectCache(pkgs []*packages.Package) *objectCache {
 // newObjectCacheWithLazyLoad creates an object cache with lazy loading enabled.
 // This allows packages to be loaded on-demand rather than all at once,
 // which can significantly improve performance for large projects.
-func newObjectCacheWithLazyLoad(pkgs []*packages.Package, ctx context.Context, wd string, env []string) *objectCache {
+// imports must be the importCache that loaded pkgs, so that the packages
+// loaded on demand share their token.FileSet and types.
+func newObjectCacheWithLazyLoad(pkgs []*packages.Package, imports *importCache) *objectCache {
     oc := newObjectCache(pkgs)
-    oc.lazyLoadEnabled = true
-    oc.loadCtx = ctx
-    oc.loadWd = wd
-    oc.loadEnv = env
+    oc.EnableLazyLoad(imports)
     return oc
 }
 
-// EnableLazyLoad enables lazy loading for the object cache.
-func (oc *objectCache) EnableLazyLoad(ctx context.Context, wd string, env []string) {
+// EnableLazyLoad enables lazy loading for the object cache, with packages
+// loaded by imports, as for newObjectCacheWithLazyLoad.
+func (oc *objectCache) EnableLazyLoad(imports *importCache) {
     oc.mu.Lock()
     defer oc.mu.Unlock()
     oc.lazyLoadEnabled = true
-    oc.loadCtx = ctx
-    oc.loadWd = wd
-    oc.loadEnv = env
+    oc.loader = newBatchLoader(imports)
+    oc.stats = imports.stats
 }
 
 // lazyLoadPackage loads a package on-demand if it's not already loaded.
 // This is useful for loading indirect dependencies only when needed.
 func (oc *objectCache) lazyLoadPackage(pkgPath string) (*packages.Package, error) {
+    pkgs, errs := oc.lazyLoadPackages([]string{pkgPath})
+    return pkgs[0], errs[0]
+}
+
+// lazyLoadPackages loads the packages that are not already loaded, in a
+// single batch where possible, and returns the package or error for each
+// path. The cache is not locked while packages load, so concurrent callers
+// share batches.
+func (oc *objectCache) lazyLoadPackages(pkgPaths []string) ([]*packages.Package, []error) {
+    pkgs := make([]*packages.Package, len(pkgPaths))
+    errs := make([]error, len(pkgPaths))
+    var missing []string
+    var missingIdx []int
+
     // Fast path: check if already loaded
     oc.mu.RLock()
-    if pkg, ok := oc.packages[pkgPath]; ok {
-        oc.mu.RUnlock()
-        return pkg, nil
-    }
-    if !oc.lazyLoadEnabled {
-        oc.mu.RUnlock()
-        return nil, fmt.Errorf("package %s not found and lazy loading is disabled", pkgPath)
+    for i, path := range pkgPaths {
+        if pkg, ok := oc.packages[path]; ok {
+            pkgs[i] = pkg
+            continue
+        }
+        if !oc.lazyLoadEnabled {
+            errs[i] = fmt.Errorf("package %s not found and lazy loading is disabled", path)
+            continue
+        }
+        missing = append(missing, path)
+        missingIdx = append(missingIdx, i)
     }
+    loader := oc.loader
     oc.mu.RUnlock()
-
-    // Slow path: load the package
-    oc.mu.Lock()
-    defer oc.mu.Unlock()
-
-    // Double-check after acquiring write lock
-    if pkg, ok := oc.packages[pkgPath]; ok {
-        return pkg, nil
+    if len(missing) == 0 {
+        return pkgs, errs
     }
 
-    // Load the package with minimal mode for better performance
-    cfg := &packages.Config{
-        Context: oc.loadCtx,
-        Mode: packages.NeedName |
-            packages.NeedFiles |
-            packages.NeedCompiledGoFiles |
-            packages.NeedImports |
-            packages.NeedTypes |
-            packages.NeedSyntax |
-            packages.NeedTypesInfo,
-        Dir:        oc.loadWd,
-        Env:        oc.loadEnv,
-        BuildFlags: []string{"-tags=wireinject", "-mod=readonly"},
-    }
+    // Slow path: load the packages
+    loaded, loadErrs := loader.get(missing...)
 
-    pkgs, err := packages.Load(cfg, pkgPath)
-    if err != nil {
-        return nil, fmt.Errorf("failed to lazy load package %s: %w", pkgPath, err)
-    }
-
-    if len(pkgs) == 0 {
-        return nil, fmt.Errorf("package %s not found", pkgPath)
-    }
-
-    // Check for package errors
-    for _, p := range pkgs {
-        if len(p.Errors) > 0 {
-            return nil, fmt.Errorf("errors loading package %s: %v", pkgPath, p.Errors[0])
+    oc.mu.Lock()
+    defer oc.mu.Unlock()
+    for j, i := range missingIdx {
+        if loadErrs[j] != nil {
+            errs[i] = loadErrs[j]
+            continue
         }
-    }
-
-    pkg := pkgs[0]
-    oc.packages[pkgPath] = pkg
+        // Another caller may have stored the package in the meantime; keep
+        // the first so that type identities stay consistent.
+        pkg, ok := oc.packages[missing[j]]
+        if !ok {
+            pkg = loaded[j]
+            oc.packages[missing[j]] = pkg
+        }
+        pkgs[i] = pkg
 
-    // Also cache any imports that were loaded
-    for _, imp := range pkg.Imports {
-        if _, exists := oc.packages[imp.PkgPath]; !exists {
-            oc.packages[imp.PkgPath] = imp
+        // Also cache any imports that were loaded
+        for _, imp := range pkg.Imports {
+            if _, exists := oc.packages[imp.PkgPath]; !exists {
+                oc.packages[imp.PkgPath] = imp
+            }
         }
     }
-
-    return pkg, nil
+    return pkgs, errs
 }
 
-// getPackage returns a package, loading it lazily if necessary.
 func (oc *objectCache) getPackage(pkgPath string) (*packages.Package, error) {
+    oc.stats.request(pkgPath)
     oc.mu.RLock()
     pkg, ok := oc.packages[pkgPath]
     oc.mu.RUnlock()
@@ -757,57 +1373,31 @@ func (oc *objectCache) getPackage(pkgPath string) (*packages.Package, error) {
     return nil, fmt.Errorf("package %s not found", pkgPath)
 }
 
-// PreloadPackages preloads a list of packages in parallel for better performance.
+// PreloadPackages preloads a list of packages for better performance.
 // This is useful when you know which packages will be needed ahead of time.
+// Packages that are not loaded yet are loaded together, with one run of the
+// go command where possible.
 func (oc *objectCache) PreloadPackages(pkgPaths []string) []error {
     if !oc.lazyLoadEnabled {
         return nil
     }
-
-    var (
-        wg     sync.WaitGroup
-        mu     sync.Mutex
-        errors []error
-    )
-
-    // Filter out already loaded packages
-    var toLoad []string
-    oc.mu.RLock()
-    for _, path := range pkgPaths {
-        if _, ok := oc.packages[path]; !ok {
-            toLoad = append(toLoad, path)
+    var errors []error
+    _, errs := oc.lazyLoadPackages(pkgPaths)
+    for _, err := range errs {
+        if err != nil {
+            errors = append(errors, err)
         }
     }
-    oc.mu.RUnlock()
-
-    if len(toLoad) == 0 {
-        return nil
-    }
-
-    // Load packages in parallel with limited concurrency
-    sem := make(chan struct{}, 4) // limit concurrent loads
-    for _, pkgPath := range toLoad {
-        wg.Add(1)
-        go func(path string) {
-            defer wg.Done()
-            sem <- struct{}{}
-            defer func() { <-sem }()
-
-            if _, err := oc.lazyLoadPackage(path); err != nil {
-                mu.Lock()
-                errors = append(errors, err)
-                mu.Unlock()
-            }
-        }(pkgPath)
-    }
-
-    wg.Wait()
     return errors
 }
 
 // get converts a Go object into a Wire structure. It may return a *Provider, an
 // *IfaceBinding, a *ProviderSet, a *Value, or a []*Field.
 func (oc *objectCache) get(obj types.Object) (val interface{}, errs []error) {
+    if obj.Pkg() == nil {
+        // Predeclared identifiers like nil or true belong to no package.
+        return nil, []error{fmt.Errorf("%s is not a provider or a provider set", obj.Name())}
+    }
     ref := objRef{
         importPath: obj.Pkg().Path(),
         name:       obj.Name(),
@@ -841,9 +1431,25 @@ func (oc *objectCache) get(obj types.Object) (val interface{}, errs []error) {
         if err != nil {
             return nil, []error{err}
         }
-        return oc.processExpr(pkg.TypesInfo, pkgPath, spec.Values[i], obj.Name())
+        if pkg == nil {
+            return nil, []error{fmt.Errorf("package %s not found", pkgPath)}
+        }
+        item, errs := oc.processExpr(pkg.TypesInfo, pkgPath, spec.Values[i], obj.Name())
+        if pset, ok := item.(*ProviderSet); ok && pset != nil && pset.PkgPath == pkgPath && pset.VarName == obj.Name() {
+            doc := oc.declDoc(obj)
+            pset.Deprecated = deprecation(doc)
+            pset.TestOnly = hasDirective(doc, testOnlyDirective)
+        }
+        return item, errs
     case *types.Func:
-        return processFuncProvider(oc.fset, obj)
+        p, errs := processFuncProvider(oc.fset, obj)
+        if p != nil {
+            p.Deprecated = deprecation(oc.declDoc(obj))
+            p.CleanupRecovers = oc.cleanupRecovers(obj, p)
+            p.GlobalReads = oc.globalReads(obj)
+            p.ConvertsFrom = oc.conversionFrom(obj)
+        }
+        return p, errs
     default:
         return nil, []error{fmt.Errorf("%v is not a provider or a provider set", obj)}
     }
@@ -872,24 +1478,136 @@ func (oc *objectCache) varDeclWithLazyLoad(obj *types.Var) (*ast.ValueSpec, erro
     pos := obj.Pos()
     for _, f := range pkg.Syntax {
         tokenFile := oc.fset.File(f.Pos())
+        if tokenFile == nil {
+            continue
+        }
+        if base := tokenFile.Base(); base <= int(pos) && int(pos) < base+tokenFile.Size() {
+            path, _ := astutil.PathEnclosingInterval(f, pos, pos)
+            for _, node := range path {
+                if spec, ok := node.(*ast.ValueSpec); ok {
+                    return spec, nil
+                }
+            }
+        }
+    }
+    return nil, nil
+}
+
+// declDoc returns the doc comment of the declaration of the given package
+// level function or variable, or nil if it has none.
+func (oc *objectCache) declDoc(obj types.Object) *ast.CommentGroup {
+    _, _, path := oc.declPath(obj)
+    for _, node := range path {
+        switch node := node.(type) {
+        case *ast.FuncDecl:
+            return node.Doc
+        case *ast.ValueSpec:
+            if node.Doc != nil {
+                return node.Doc
+            }
+        case *ast.GenDecl:
+            // A doc comment on a parenthesized group documents the
+            // group, not the individual variables.
+            if !node.Lparen.IsValid() {
+                return node.Doc
+            }
+            return nil
+        }
+    }
+    return nil
+}
+
+// declPath returns the package and file that declare the given package
+// level object, and the nodes of the file that enclose its position,
+// innermost first. It returns nils if the declaration is not found.
+func (oc *objectCache) declPath(obj types.Object) (*packages.Package, *ast.File, []ast.Node) {
+    pkg, err := oc.getPackage(obj.Pkg().Path())
+    if err != nil || pkg == nil {
+        return nil, nil, nil
+    }
+    pos := obj.Pos()
+    for _, f := range pkg.Syntax {
+        tokenFile := oc.fset.File(f.Pos())
+        if tokenFile == nil {
+            continue
+        }
         if base := tokenFile.Base(); base <= int(pos) && int(pos) < base+tokenFile.Size() {
             path, _ := astutil.PathEnclosingInterval(f, pos, pos)
-            for _, node := range path {
-                if spec, ok := node.(*ast.ValueSpec); ok {
-                    return spec, nil
-                }
-            }
+            return pkg, f, path
         }
     }
-    return nil, nil
+    return nil, nil, nil
+}
+
+// deprecation returns the text of the "Deprecated:" paragraph in doc, or the
+// empty string if there is none.
+func deprecation(doc *ast.CommentGroup) string {
+    if doc == nil {
+        return ""
+    }
+    for _, para := range strings.Split(doc.Text(), "\n\n") {
+        if text := strings.TrimPrefix(para, "Deprecated: "); text != para {
+            return strings.Join(strings.Fields(text), " ")
+        }
+    }
+    return ""
+}
+
+// testOnlyDirective marks a provider set variable as test-only.
+const testOnlyDirective = "//wire:testonly"
+
+// hasDirective reports whether doc has a line consisting of directive.
+// Directives are not part of doc.Text, so the raw comments are searched.
+func hasDirective(doc *ast.CommentGroup, directive string) bool {
+    if doc == nil {
+        return false
+    }
+    for _, c := range doc.List {
+        if strings.TrimSpace(c.Text) == directive {
+            return true
+        }
+    }
+    return false
+}
+
+// directiveArg returns the argument of the line of doc that consists of
+// directive, a space and the argument. It reports false if there is no such
+// line.
+func directiveArg(doc *ast.CommentGroup, directive string) (string, bool) {
+    if doc == nil {
+        return "", false
+    }
+    for _, c := range doc.List {
+        text := strings.TrimSpace(c.Text)
+        if text == directive || strings.HasPrefix(text, directive+" ") {
+            return strings.TrimSpace(text[len(directive):]), true
+        }
+    }
+    return "", false
 }
 
 // processExpr converts an expression into a Wire structure. It may return a
-// *Provider, an *IfaceBinding, a *ProviderSet, a *Value or a []*Field.
+// *Provider, an *IfaceBinding, a *ProviderSet, a *Value, a []*Field or a
+// *CleanupRegistry.
 func (oc *objectCache) processExpr(info *types.Info, pkgPath string, expr ast.Expr, varName string) (interface{}, []error) {
     exprPos := oc.fset.Position(expr.Pos())
     expr = astutil.Unparen(expr)
+    if fn, inst, ok := funcInstance(info, expr); ok {
+        // Instantiations are not cached by object, since the same generic
+        // function may be instantiated with different type arguments.
+        p, errs := processFuncInstanceProvider(oc.fset, fn, inst)
+        if p != nil {
+            p.Deprecated = deprecation(oc.declDoc(fn))
+            p.CleanupRecovers = oc.cleanupRecovers(fn, p)
+            p.GlobalReads = oc.globalReads(fn)
+            p.ConvertsFrom = oc.conversionFrom(fn)
+        }
+        return p, notePositionAll(exprPos, errs)
+    }
     if obj := qualifiedIdentObject(info, expr); obj != nil {
+        if obj.Pkg() != nil && isProviderSetType(obj.Type()) && !importAllowed(pkgPath, obj.Pkg().Path()) {
+            return nil, []error{notePosition(exprPos, internalSetError(obj, pkgPath))}
+        }
         item, errs := oc.get(obj)
         return item, mapErrors(errs, func(err error) error {
             return notePosition(exprPos, err)
@@ -941,6 +1659,33 @@ func (oc *objectCache) processExpr(info *types.Info, pkgPath string, expr ast.Ex
                 return nil, []error{notePosition(exprPos, err)}
             }
             return v, nil
+        case "AllFieldsOf":
+            v, err := processAllFieldsOf(oc.fset, info, call)
+            if err != nil {
+                return nil, []error{notePosition(exprPos, err)}
+            }
+            return v, nil
+        case "CleanupInto":
+            r, err := processCleanupInto(oc.fset, info, call)
+            if err != nil {
+                return nil, []error{notePosition(exprPos, err)}
+            }
+            return r, nil
+        case "Slice":
+            s, err := processSlice(oc.fset, info, call)
+            if err != nil {
+                return nil, []error{notePosition(exprPos, err)}
+            }
+            return s, nil
+        case "Map":
+            m, err := processMap(oc.fset, info, call)
+            if err != nil {
+                return nil, []error{notePosition(exprPos, err)}
+            }
+            return m, nil
+        case "Key":
+            k, errs := oc.processKey(info, pkgPath, call)
+            return k, notePositionAll(exprPos, errs)
         default:
             return nil, []error{notePosition(exprPos, errors.New("unknown pattern"))}
         }
@@ -963,9 +1708,19 @@ func (oc *objectCache) processNewSet(info *types.Info, pkgPath string, call *ast
         InjectorArgs: args,
         PkgPath:      pkgPath,
         VarName:      varName,
+        nearestWins:  args != nil && oc.nearestWins,
+        fset:         oc.fset,
     }
     ec := new(errorCollector)
-    for _, arg := range call.Args {
+    setFunc := "wire.NewSet"
+    if args != nil {
+        setFunc = "wire.Build"
+    }
+    for i, arg := range call.Args {
+        if err := unsupportedSetArg(info, arg); err != nil {
+            ec.add(notePosition(oc.fset.Position(arg.Pos()), fmt.Errorf("argument %d of %s: %v", i+1, setFunc, err)))
+            continue
+        }
         item, errs := oc.processExpr(info, pkgPath, arg, "")
         if len(errs) > 0 {
             ec.add(errs...)
@@ -982,6 +1737,21 @@ func (oc *objectCache) processNewSet(info *types.Info, pkgPath string, call *ast
             pset.Values = append(pset.Values, item)
         case []*Field:
             pset.Fields = append(pset.Fields, item...)
+        case *Slice:
+            pset.Slices = append(pset.Slices, item)
+        case *Map:
+            pset.Maps = append(pset.Maps, item)
+        case *KeyedProvider:
+            pset.Keyed = append(pset.Keyed, item)
+        case *CleanupRegistry:
+            switch {
+            case args == nil:
+                ec.add(notePosition(oc.fset.Position(item.Pos), errors.New("wire.CleanupInto may only be used in wire.Build")))
+            case pset.CleanupRegistry != nil:
+                ec.add(notePosition(oc.fset.Position(item.Pos), errors.New("wire.Build may have only one wire.CleanupInto")))
+            default:
+                pset.CleanupRegistry = item
+            }
         default:
             panic("unknown item type")
         }
@@ -990,7 +1760,8 @@ func (oc *objectCache) processNewSet(info *types.Info, pkgPath string, call *ast
         return nil, ec.errors
     }
     var errs []error
-    pset.providerMap, pset.srcMap, errs = buildProviderMap(oc.fset, oc.hasher, pset)
+    pset.keyed = keyedProviders(pset)
+    pset.providerMap, pset.srcMap, pset.pendingBindings, errs = buildProviderMap(oc.fset, oc.hasher, pset)
     if len(errs) > 0 {
         return nil, errs
     }
@@ -1000,6 +1771,56 @@ func (oc *objectCache) processNewSet(info *types.Info, pkgPath string, call *ast
     return pset, nil
 }
 
+// unsupportedSetArg returns an error that explains why expr, an argument of
+// wire.NewSet or wire.Build, cannot be used there, if it is one of the
+// common mistakes, or nil otherwise. Other unsupported arguments are left to
+// processExpr.
+func unsupportedSetArg(info *types.Info, expr ast.Expr) error {
+    expr = astutil.Unparen(expr)
+    if _, _, ok := funcInstance(info, expr); ok {
+        return nil
+    }
+    if qualifiedIdentObject(info, expr) != nil {
+        return nil
+    }
+    text := types.ExprString(expr)
+    switch expr := expr.(type) {
+    case *ast.SelectorExpr:
+        sel := info.Selections[expr]
+        if sel == nil {
+            return nil
+        }
+        switch sel.Kind() {
+        case types.MethodVal:
+            return fmt.Errorf("%s is a method value, which binds its receiver when the set is declared; pass the constructor of %s instead, or a function that takes it as an argument", text, types.TypeString(sel.Recv(), nil))
+        case types.MethodExpr:
+            return fmt.Errorf("%s is a method expression; pass a function that takes %s as an argument instead", text, types.TypeString(sel.Recv(), nil))
+        }
+    case *ast.CallExpr:
+        fun := astutil.Unparen(expr.Fun)
+        if obj := qualifiedIdentObject(info, fun); obj != nil && obj.Pkg() != nil && isWireImport(obj.Pkg().Path()) {
+            return nil
+        }
+        if tv, ok := info.Types[fun]; ok && tv.IsType() {
+            return fmt.Errorf("%s is a conversion, not a provider; wrap it in wire.Value to provide the value", text)
+        }
+        if t := info.TypeOf(expr); t != nil && isProviderSetType(t) {
+            return fmt.Errorf("%s calls a function that returns a provider set, which Wire cannot run; assign the set to a package variable and pass the variable instead", text)
+        }
+        return fmt.Errorf("%s calls %s instead of passing it; pass the function itself, as %s, and Wire will call it", text, types.ExprString(fun), types.ExprString(fun))
+    case *ast.CompositeLit:
+        if structArgType(info, expr) != nil {
+            return nil
+        }
+        return fmt.Errorf("%s is a composite literal, not a provider; wrap it in wire.Value to provide the value", text)
+    case *ast.FuncLit:
+        return errors.New("function literals are not providers; declare the function at package level and pass its name instead")
+    case *ast.IndexExpr, *ast.IndexListExpr:
+        return fmt.Errorf("%s is an index expression, not a provider; pass a function, or a generic function with explicit type arguments, instead", text)
+    }
+    return nil
+}
+
 // structArgType attempts to interpret an expression as a simple struct type.
 // It assumes any parentheses have been stripped.
 func structArgType(info *types.Info, expr ast.Expr) *types.TypeName {
@@ -1040,8 +1861,29 @@ func qualifiedIdentObject(info *types.Info, expr ast.Expr) types.Object {
 // processFuncProvider creates a provider for a function declaration.
 func processFuncProvider(fset *token.FileSet, fn *types.Func) (*Provider, []error) {
     sig := fn.Type().(*types.Signature)
+    if sig.TypeParams().Len() > 0 {
+        return nil, []error{notePosition(fset.Position(fn.Pos()), fmt.Errorf("provider %s is generic; it must be instantiated with explicit type arguments", fn.Name()))}
+    }
+    return funcProvider(fset, fn, sig, nil)
+}
+
+// processFuncInstanceProvider creates a provider for an explicit
+// instantiation of a generic function, such as F[int].
+func processFuncInstanceProvider(fset *token.FileSet, fn *types.Func, inst types.Instance) (*Provider, []error) {
+    typeArgs := make([]types.Type, inst.TypeArgs.Len())
+    for i := range typeArgs {
+        typeArgs[i] = inst.TypeArgs.At(i)
+    }
+    // Use the instantiated signature, not the generic one, so that the
+    // provider's inputs and outputs are the instantiated types.
+    return funcProvider(fset, fn, inst.Type.(*types.Signature), typeArgs)
+}
+
+// funcProvider creates a provider for fn with the given signature and type
+// arguments.
+func funcProvider(fset *token.FileSet, fn *types.Func, sig *types.Signature, typeArgs []types.Type) (*Provider, []error) {
     fpos := fn.Pos()
-    providerSig, err := funcOutput(sig)
+    out, cleanup, hasErr, err := providerOutput(sig)
     if err != nil {
         return nil, []error{notePosition(fset.Position(fpos), fmt.Errorf("wrong signature for provider %s: %v", fn.Name(), err))}
     }
@@ -1049,12 +1891,13 @@ func processFuncProvider(fset *token.FileSet, fn *types.Func) (*Provider, []erro
     provider := &Provider{
         Pkg:        fn.Pkg(),
         Name:       fn.Name(),
+        TypeArgs:   typeArgs,
         Pos:        fn.Pos(),
         Args:       make([]ProviderInput, params.Len()),
         Varargs:    sig.Variadic(),
-        Out:        []types.Type{providerSig.out},
-        HasCleanup: providerSig.cleanup,
-        HasErr:     providerSig.err,
+        Out:        out,
+        HasCleanup: cleanup,
+        HasErr:     hasErr,
     }
     for i := 0; i < params.Len(); i++ {
         provider.Args[i] = ProviderInput{
@@ -1069,7 +1912,41 @@ func processFuncProvider(fset *token.FileSet, fn *types.Func) (*Provider, []erro
     return provider, nil
 }
 
+// funcInstance returns the generic function and its instantiation for an
+// explicit instantiation expression such as F[int] or pkg.F[int, string].
+// It assumes any parentheses have been stripped.
+func funcInstance(info *types.Info, expr ast.Expr) (*types.Func, types.Instance, bool) {
+    var x ast.Expr
+    switch expr := expr.(type) {
+    case *ast.IndexExpr:
+        x = expr.X
+    case *ast.IndexListExpr:
+        x = expr.X
+    default:
+        return nil, types.Instance{}, false
+    }
+    fn, ok := qualifiedIdentObject(info, x).(*types.Func)
+    if !ok {
+        return nil, types.Instance{}, false
+    }
+    var id *ast.Ident
+    switch x := x.(type) {
+    case *ast.Ident:
+        id = x
+    case *ast.SelectorExpr:
+        id = x.Sel
+    }
+    inst, ok := info.Instances[id]
+    return fn, inst, ok
+}
+
 func injectorFuncSignature(sig *types.Signature) (*types.Tuple, outputSignature, error) {
+    if sig.TypeParams().Len() > 0 {
+        // The provider set would be solved once for all instantiations,
+        // and the generated code would refer to the type parameters
+        // without declaring them.
+        return nil, outputSignature{}, errors.New("injector has type parameters; declare an injector for each instantiation instead")
+    }
     out, err := funcOutput(sig)
     if err != nil {
         return nil, outputSignature{}, err
@@ -1081,6 +1958,9 @@ type outputSignature struct {
     out     types.Type
     cleanup bool
     err     bool
+    // namedCleanup reports that the cleanup is a *wire.Cleanups instead of
+    // a func(), which only injectors may return.
+    namedCleanup bool
 }
 
 // funcOutput validates an injector or provider function's return signature.
@@ -1098,26 +1978,68 @@ func funcOutput(sig *types.Signature) (outputSignature, error) {
             return outputSignature{out: out, err: true}, nil
         case types.Identical(t, cleanupType):
             return outputSignature{out: out, cleanup: true}, nil
+        case isCleanupsType(t):
+            return outputSignature{out: out, cleanup: true, namedCleanup: true}, nil
         default:
             return outputSignature{}, fmt.Errorf("second return type is %s; must be error or func()", types.TypeString(t, nil))
         }
     case 3:
-        if t := results.At(1).Type(); !types.Identical(t, cleanupType) {
+        named := isCleanupsType(results.At(1).Type())
+        if t := results.At(1).Type(); !types.Identical(t, cleanupType) && !named {
             return outputSignature{}, fmt.Errorf("second return type is %s; must be func()", types.TypeString(t, nil))
         }
         if t := results.At(2).Type(); !types.Identical(t, errorType) {
             return outputSignature{}, fmt.Errorf("third return type is %s; must be error", types.TypeString(t, nil))
         }
         return outputSignature{
-            out:     results.At(0).Type(),
-            cleanup: true,
-            err:     true,
+            out:          results.At(0).Type(),
+            cleanup:      true,
+            err:          true,
+            namedCleanup: named,
         }, nil
     default:
         return outputSignature{}, errors.New("too many return values")
     }
 }
 
+// providerOutput validates a provider function's return signature. Unlike an
+// injector, a provider may return several values, each of a different type,
+// followed by an optional cleanup function and an optional error.
+func providerOutput(sig *types.Signature) (out []types.Type, cleanup, hasErr bool, _ error) {
+    results := sig.Results()
+    n := results.Len()
+    if n > 0 && types.Identical(results.At(n-1).Type(), errorType) {
+        hasErr = true
+        n--
+    }
+    if n > 0 && types.Identical(results.At(n-1).Type(), cleanupType) {
+        cleanup = true
+        n--
+    }
+    if n == 0 {
+        if results.Len() == 0 {
+            return nil, false, false, errors.New("no return values")
+        }
+        return nil, false, false, errors.New("no return values besides the cleanup function and error")
+    }
+    for i := 0; i < n; i++ {
+        t := results.At(i).Type()
+        switch {
+        case types.Identical(t, errorType):
+            return nil, false, false, fmt.Errorf("return value %d is an error; an error may only be the last return value", i+1)
+        case types.Identical(t, cleanupType):
+            return nil, false, false, fmt.Errorf("return value %d is func(); a cleanup function must follow all of the provided values", i+1)
+        }
+        for _, prev := range out {
+            if types.Identical(t, prev) {
+                return nil, false, false, fmt.Errorf("multiple return values of type %s", types.TypeString(t, nil))
+            }
+        }
+        out = append(out, t)
+    }
+    return out, cleanup, hasErr, nil
+}
+
 // processStructLiteralProvider creates a provider for a named struct type.
 // It produces pointer and non-pointer variants via two values in Out.
 //
@@ -1183,14 +2105,39 @@ func processStructProvider(fset *token.FileSet, info *types.Info, call *ast.Call
             fmt.Errorf(firstArgReqFormat, types.TypeString(structPtr, nil)))
     }
 
-    stExpr := call.Args[0].(*ast.CallExpr)
-    typeName := qualifiedIdentObject(info, stExpr.Args[0]) // should be either an identifier or selector
+    if !isNewCall(info, call.Args[0]) {
+        return nil, notePosition(fset.Position(call.Pos()),
+            fmt.Errorf("first argument to Struct must be a new(T) expression; found %s", types.ExprString(call.Args[0])))
+    }
+    stExpr := astutil.Unparen(call.Args[0]).(*ast.CallExpr)
+    typeExpr := astutil.Unparen(stExpr.Args[0])
+    // The type arguments are taken as the source spells them, rather than
+    // from the instantiated type: an alias such as Pair = Box[int] names the
+    // instance without any, and an alias argument keeps its name.
+    var typeArgs []types.Type
+    switch e := typeExpr.(type) {
+    case *ast.IndexExpr:
+        typeExpr = e.X
+        typeArgs = []types.Type{info.TypeOf(e.Index)}
+    case *ast.IndexListExpr:
+        typeExpr = e.X
+        for _, index := range e.Indices {
+            typeArgs = append(typeArgs, info.TypeOf(index))
+        }
+    }
+    // The type should be named by an identifier or a qualified identifier.
+    typeName, ok := qualifiedIdentObject(info, typeExpr).(*types.TypeName)
+    if !ok {
+        return nil, notePosition(fset.Position(call.Pos()),
+            fmt.Errorf(firstArgReqFormat, types.TypeString(structPtr, nil)))
+    }
     provider := &Provider{
         Pkg:      typeName.Pkg(),
         Name:     typeName.Name(),
         Pos:      typeName.Pos(),
         IsStruct: true,
         Out:      []types.Type{structPtr.Elem(), structPtr},
+        TypeArgs: typeArgs,
     }
     if allFields(call) {
         for i := 0; i < st.NumFields(); i++ {
@@ -1281,8 +2228,7 @@ func processBind(fset *token.FileSet, info *types.Info, call *ast.CallExpr) (*If
             errors.New("cannot bind interface to itself"))
     }
     if !types.Implements(provided, methodSet) {
-        return nil, notePosition(fset.Position(call.Pos()),
-            fmt.Errorf("%s does not implement %s", types.TypeString(provided, nil), types.TypeString(iface, nil)))
+        return nil, notePosition(fset.Position(call.Pos()), notImplementedError(provided, iface))
     }
     return &IfaceBinding{
         Pos:      call.Pos(),
@@ -1291,6 +2237,143 @@ func processBind(fset *token.FileSet, info *types.Info, call *ast.CallExpr) (*If
     }, nil
 }
 
+// notImplementedError reports that t does not implement the interface type
+// iface, with the method at fault if the type checker can name it. Both
+// types are as the set declares them, so a generic type is reported with
+// the type arguments that do not work, such as *Impl[Key].
+func notImplementedError(t, iface types.Type) error {
+    msg := fmt.Sprintf("%s does not implement %s", types.TypeString(t, nil), types.TypeString(iface, nil))
+    if reason := missingMethodReason(t, iface.Underlying().(*types.Interface)); reason != "" {
+        msg += ": " + reason
+    }
+    return errors.New(msg)
+}
+
+// missingMethodReason explains why t does not implement iface, such as
+// "missing method Close", or returns "" if t implements iface.
+func missingMethodReason(t types.Type, iface *types.Interface) string {
+    m, _ := types.MissingMethod(t, iface, true)
+    if m == nil {
+        return ""
+    }
+    if obj, _, _ := types.LookupFieldOrMethod(t, false, m.Pkg(), m.Name()); obj != nil {
+        if have, ok := obj.(*types.Func); ok {
+            return fmt.Sprintf("method %s has type %s, want %s", m.Name(),
+                types.TypeString(have.Type(), nil), types.TypeString(m.Type(), nil))
+        }
+    }
+    if _, isPtr := t.Underlying().(*types.Pointer); !isPtr {
+        if obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(t), false, m.Pkg(), m.Name()); obj != nil {
+            return fmt.Sprintf("method %s has a pointer receiver", m.Name())
+        }
+    }
+    return "missing method " + m.Name()
+}
+
+// processCleanupInto creates a cleanup registry from a wire.CleanupInto call.
+func processCleanupInto(fset *token.FileSet, info *types.Info, call *ast.CallExpr) (*CleanupRegistry, error) {
+    // Assumes that call.Fun is wire.CleanupInto.
+
+    if len(call.Args) != 1 {
+        return nil, notePosition(fset.Position(call.Pos()), errors.New("call to CleanupInto takes exactly one argument"))
+    }
+    argType := info.TypeOf(call.Args[0])
+    ptr, ok := argType.(*types.Pointer)
+    if !ok {
+        return nil, notePosition(fset.Position(call.Pos()),
+            fmt.Errorf("argument to CleanupInto must be a pointer to the registry type; found %s", types.TypeString(argType, nil)))
+    }
+    reg := ptr.Elem()
+    obj, _, _ := types.LookupFieldOrMethod(reg, true, nil, "Append")
+    fn, ok := obj.(*types.Func)
+    if !ok || !isAppendCleanup(fn.Type().(*types.Signature)) {
+        return nil, notePosition(fset.Position(call.Pos()),
+            fmt.Errorf("%s has no method Append(func()) for CleanupInto to call", types.TypeString(reg, nil)))
+    }
+    return &CleanupRegistry{
+        Pos:  call.Pos(),
+        Type: reg,
+    }, nil
+}
+
+// isAppendCleanup reports whether sig is the signature of the Append method
+// of a cleanup registry: func(func()), with no results.
+func isAppendCleanup(sig *types.Signature) bool {
+    if sig.Params().Len() != 1 || sig.Results().Len() != 0 || sig.Variadic() {
+        return false
+    }
+    cleanup, ok := sig.Params().At(0).Type().Underlying().(*types.Signature)
+    return ok && cleanup.Params().Len() == 0 && cleanup.Results().Len() == 0
+}
+
+// processSlice creates a slice from a wire.Slice call.
+func processSlice(fset *token.FileSet, info *types.Info, call *ast.CallExpr) (*Slice, error) {
+    // Assumes that call.Fun is wire.Slice.
+
+    if len(call.Args) != 1 {
+        return nil, notePosition(fset.Position(call.Pos()), errors.New("call to Slice takes exactly one argument"))
+    }
+    argType := info.TypeOf(call.Args[0])
+    if ptr, ok := argType.(*types.Pointer); ok {
+        if _, ok := ptr.Elem().Underlying().(*types.Slice); ok {
+            return &Slice{
+                Pos: call.Pos(),
+                Out: ptr.Elem(),
+            }, nil
+        }
+    }
+    return nil, notePosition(fset.Position(call.Pos()),
+        fmt.Errorf("argument to Slice must be a pointer to a slice type; found %s", types.TypeString(argType, nil)))
+}
+
+// processMap creates a map from a wire.Map call.
+func processMap(fset *token.FileSet, info *types.Info, call *ast.CallExpr) (*Map, error) {
+    // Assumes that call.Fun is wire.Map.
+
+    if len(call.Args) != 1 {
+        return nil, notePosition(fset.Position(call.Pos()), errors.New("call to Map takes exactly one argument"))
+    }
+    argType := info.TypeOf(call.Args[0])
+    if ptr, ok := argType.(*types.Pointer); ok {
+        if m, ok := ptr.Elem().Underlying().(*types.Map); ok {
+            if b, ok := m.Key().Underlying().(*types.Basic); ok && b.Info()&types.IsString != 0 {
+                return &Map{
+                    Pos: call.Pos(),
+                    Out: ptr.Elem(),
+                }, nil
+            }
+        }
+    }
+    return nil, notePosition(fset.Position(call.Pos()),
+        fmt.Errorf("argument to Map must be a pointer to a map type with string keys; found %s", types.TypeString(argType, nil)))
+}
+
+// processKey creates a keyed provider from a wire.Key call.
+func (oc *objectCache) processKey(info *types.Info, pkgPath string, call *ast.CallExpr) (*KeyedProvider, []error) {
+    // Assumes that call.Fun is wire.Key.
+
+    if len(call.Args) != 2 {
+        return nil, []error{notePosition(oc.fset.Position(call.Pos()), errors.New("call to Key takes exactly two arguments"))}
+    }
+    tv := info.Types[call.Args[0]]
+    if tv.Value == nil || tv.Value.Kind() != constant.String {
+        return nil, []error{notePosition(oc.fset.Position(call.Pos()), errors.New("first argument to Key must be a string constant"))}
+    }
+    item, errs := oc.processExpr(info, pkgPath, call.Args[1], "")
+    if len(errs) > 0 {
+        return nil, errs
+    }
+    p, ok := item.(*Provider)
+    if !ok || p.IsStruct || len(p.Out) != 1 {
+        return nil, []error{notePosition(oc.fset.Position(call.Pos()), errors.New("second argument to Key must be a provider function with one result"))}
+    }
+    return &KeyedProvider{
+        Pos:      call.Pos(),
+        Key:      constant.StringVal(tv.Value),
+        Provider: p,
+    }, nil
+}
+
 // processValue creates a value from a wire.Value call.
 func processValue(fset *token.FileSet, info *types.Info, call *ast.CallExpr) (*Value, error) {
     // Assumes that call.Fun is wire.Value.
@@ -1324,8 +2407,13 @@ func processValue(fset *token.FileSet, info *types.Info, call *ast.CallExpr) (*V
         return nil, notePosition(fset.Position(call.Pos()), errors.New("argument to Value is too complex"))
     }
     // Result type can't be an interface type; use wire.InterfaceValue for that.
+    // Named error types are the exception: their type is unambiguous, and
+    // they are ordinary graph nodes rather than part of the error plumbing.
     argType := info.TypeOf(call.Args[0])
-    if _, isInterfaceType := argType.Underlying().(*types.Interface); isInterfaceType {
+    if isUntypedNil(argType) {
+        return nil, notePosition(fset.Position(call.Pos()), errors.New("argument to Value may not be untyped nil; convert it to a type first"))
+    }
+    if _, isInterfaceType := argType.Underlying().(*types.Interface); isInterfaceType && !isNamedErrorType(argType) {
         return nil, notePosition(fset.Position(call.Pos()), fmt.Errorf("argument to Value may not be an interface value (found %s); use InterfaceValue instead", types.TypeString(argType, nil)))
     }
     return &Value{
@@ -1354,8 +2442,11 @@ func processInterfaceValue(fset *token.FileSet, info *types.Info, call *ast.Call
         return nil, notePosition(fset.Position(call.Pos()), fmt.Errorf("first argument to InterfaceValue must be a pointer to an interface type; found %s", types.TypeString(ifaceArgType, nil)))
     }
     provided := info.TypeOf(call.Args[1])
+    if isUntypedNil(provided) {
+        return nil, notePosition(fset.Position(call.Pos()), errors.New("second argument to InterfaceValue may not be untyped nil; use a typed nil or a provider instead"))
+    }
     if !types.Implements(provided, methodSet) {
-        return nil, notePosition(fset.Position(call.Pos()), fmt.Errorf("%s does not implement %s", types.TypeString(provided, nil), types.TypeString(iface, nil)))
+        return nil, notePosition(fset.Position(call.Pos()), notImplementedError(provided, iface))
     }
     return &Value{
         Pos:  call.Args[1].Pos(),
@@ -1373,56 +2464,114 @@ func processFieldsOf(fset *token.FileSet, info *types.Info, call *ast.CallExpr)
         return nil, notePosition(fset.Position(call.Pos()),
             errors.New("call to FieldsOf must specify fields to be extracted"))
     }
-    const firstArgReqFormat = "first argument to FieldsOf must be a pointer to a struct or a pointer to a pointer to a struct; found %s"
+    structPtr, struc, isPtrToStruct, err := fieldsOfStruct(fset, info, call, "FieldsOf")
+    if err != nil {
+        return nil, err
+    }
+    if struc.NumFields() < len(call.Args)-1 {
+        return nil, notePosition(fset.Position(call.Pos()),
+            fmt.Errorf("fields number exceeds the number available in the struct which has %d fields", struc.NumFields()))
+    }
+
+    fields := make([]*Field, 0, len(call.Args)-1)
+    for i := 1; i < len(call.Args); i++ {
+        v, err := checkField(call.Args[i], struc)
+        if err != nil {
+            return nil, notePosition(fset.Position(call.Pos()), err)
+        }
+        fields = append(fields, newField(structPtr, v, isPtrToStruct))
+    }
+    return fields, nil
+}
+
+// processAllFieldsOf creates a slice of fields from a wire.AllFieldsOf call.
+// It selects every exported field that is not of a basic type, except the
+// fields that the call excludes by name.
+func processAllFieldsOf(fset *token.FileSet, info *types.Info, call *ast.CallExpr) ([]*Field, error) {
+    // Assumes that call.Fun is wire.AllFieldsOf.
+
+    if len(call.Args) < 1 {
+        return nil, notePosition(fset.Position(call.Pos()),
+            errors.New("call to AllFieldsOf must specify the struct to extract fields from"))
+    }
+    structPtr, struc, isPtrToStruct, err := fieldsOfStruct(fset, info, call, "AllFieldsOf")
+    if err != nil {
+        return nil, err
+    }
+    excluded := make(map[*types.Var]bool)
+    for _, arg := range call.Args[1:] {
+        v, err := checkField(arg, struc)
+        if err != nil {
+            return nil, notePosition(fset.Position(call.Pos()), err)
+        }
+        excluded[v] = true
+    }
+
+    var fields []*Field
+    for i := 0; i < struc.NumFields(); i++ {
+        v := struc.Field(i)
+        if !v.Exported() || excluded[v] || isPrevented(struc.Tag(i)) {
+            continue
+        }
+        if _, isBasic := unalias(v.Type()).(*types.Basic); isBasic {
+            // Providing strings, ints and the like is almost always
+            // ambiguous, so they must be listed with FieldsOf.
+            continue
+        }
+        fields = append(fields, newField(structPtr, v, isPtrToStruct))
+    }
+    if len(fields) == 0 {
+        return nil, notePosition(fset.Position(call.Pos()),
+            fmt.Errorf("AllFieldsOf selects no fields of %s", types.TypeString(structPtr.Elem(), nil)))
+    }
+    return fields, nil
+}
+
+// fieldsOfStruct returns the struct type named by the first argument of a
+// call to the wire marker function fn, which must be a pointer to a struct or
+// a pointer to a pointer to a struct. isPtrToStruct reports the latter.
+func fieldsOfStruct(fset *token.FileSet, info *types.Info, call *ast.CallExpr, fn string) (structPtr *types.Pointer, struc *types.Struct, isPtrToStruct bool, _ error) {
+    firstArgReqFormat := "first argument to " + fn + " must be a pointer to a struct or a pointer to a pointer to a struct; found %s"
     structType := info.TypeOf(call.Args[0])
     structPtr, ok := structType.(*types.Pointer)
     if !ok {
-        return nil, notePosition(fset.Position(call.Pos()),
+        return nil, nil, false, notePosition(fset.Position(call.Pos()),
             fmt.Errorf(firstArgReqFormat, types.TypeString(structType, nil)))
     }
 
-    var struc *types.Struct
-    isPtrToStruct := false
     switch t := structPtr.Elem().Underlying().(type) {
     case *types.Pointer:
         struc, ok = t.Elem().Underlying().(*types.Struct)
         if !ok {
-            return nil, notePosition(fset.Position(call.Pos()),
-                fmt.Errorf(firstArgReqFormat, types.TypeString(struc, nil)))
+            return nil, nil, false, notePosition(fset.Position(call.Pos()),
+                fmt.Errorf(firstArgReqFormat, types.TypeString(t, nil)))
         }
         isPtrToStruct = true
     case *types.Struct:
         struc = t
     default:
-        return nil, notePosition(fset.Position(call.Pos()),
+        return nil, nil, false, notePosition(fset.Position(call.Pos()),
             fmt.Errorf(firstArgReqFormat, types.TypeString(t, nil)))
     }
-    if struc.NumFields() < len(call.Args)-1 {
-        return nil, notePosition(fset.Position(call.Pos()),
-            fmt.Errorf("fields number exceeds the number available in the struct which has %d fields", struc.NumFields()))
-    }
+    return structPtr, struc, isPtrToStruct, nil
+}
 
-    fields := make([]*Field, 0, len(call.Args)-1)
-    for i := 1; i < len(call.Args); i++ {
-        v, err := checkField(call.Args[i], struc)
-        if err != nil {
-            return nil, notePosition(fset.Position(call.Pos()), err)
-        }
-        out := []types.Type{v.Type()}
-        if isPtrToStruct {
-            // If the field is from a pointer to a struct, then
-            // wire.Fields also provides a pointer to the field.
-            out = append(out, types.NewPointer(v.Type()))
-        }
-        fields = append(fields, &Field{
-            Parent: structPtr.Elem(),
-            Name:   v.Name(),
-            Pkg:    v.Pkg(),
-            Pos:    v.Pos(),
-            Out:    out,
-        })
+// newField returns the Field for v, a field of the struct that structPtr
+// points to.
+func newField(structPtr *types.Pointer, v *types.Var, isPtrToStruct bool) *Field {
+    out := []types.Type{v.Type()}
+    if isPtrToStruct {
+        // If the field is from a pointer to a struct, then
+        // wire.Fields also provides a pointer to the field.
+        out = append(out, types.NewPointer(v.Type()))
+    }
+    return &Field{
+        Parent: structPtr.Elem(),
+        Name:   v.Name(),
+        Pkg:    v.Pkg(),
+        Pos:    v.Pos(),
+        Out:    out,
     }
-    return fields, nil
 }
 
 // checkField reports whether f is a field of st. f should be a string with the
@@ -1430,7 +2579,7 @@ func processFieldsOf(fset *token.FileSet, info *types.Info, call *ast.CallExpr)
 func checkField(f ast.Expr, st *types.Struct) (*types.Var, error) {
     b, ok := f.(*ast.BasicLit)
     if !ok {
-        return nil, fmt.Errorf("%v must be a string with the field name", f)
+        return nil, fmt.Errorf("%s must be a string with the field name", types.ExprString(f))
     }
     for i := 0; i < st.NumFields(); i++ {
         if strings.EqualFold(strconv.Quote(st.Field(i).Name()), b.Value) {
@@ -1445,6 +2594,9 @@ func checkField(f ast.Expr, st *types.Struct) (*types.Var, error) {
 
 // findInjectorBuild returns the wire.Build call if fn is an injector template.
 // It returns nil if the function is not an injector template.
+//
+// The wire.Build call may be written in any of the styles described by
+// injectorBodyStyles, followed by an optional return.
 func findInjectorBuild(info *types.Info, fn *ast.FuncDecl) (*ast.CallExpr, error) {
     if fn.Body == nil {
         return nil, nil
@@ -1472,11 +2624,24 @@ func findInjectorBuild(info *types.Info, fn *ast.FuncDecl) (*ast.CallExpr, error
                     continue
                 }
             }
-            buildObj := qualifiedIdentObject(info, call.Fun)
-            if buildObj == nil || buildObj.Pkg() == nil || !isWireImport(buildObj.Pkg().Path()) || buildObj.Name() != "Build" {
+            if isWireBuild(info, call) {
+                wireBuildCall = call
+            }
+        case *ast.AssignStmt:
+            // Allow the result of wire.Build to be assigned to the blank
+            // identifier, for code bases that forbid panic.
+            numStatements++
+            if numStatements > 1 || !isBlankAssign(stmt) {
+                invalid = true
+            }
+            if len(stmt.Rhs) != 1 {
                 continue
             }
-            wireBuildCall = call
+            if call, ok := stmt.Rhs[0].(*ast.CallExpr); ok && isWireBuild(info, call) {
+                wireBuildCall = call
+            } else {
+                invalid = true
+            }
         case *ast.EmptyStmt:
             // Do nothing.
         case *ast.ReturnStmt:
@@ -1493,11 +2658,58 @@ func findInjectorBuild(info *types.Info, fn *ast.FuncDecl) (*ast.CallExpr, error
         return nil, nil
     }
     if invalid {
-        return nil, errors.New("a call to wire.Build indicates that this function is an injector, but injectors must consist of only the wire.Build call and an optional return")
+        return nil, errors.New("a call to wire.Build indicates that this function is an injector, but injectors must consist of only the wire.Build call and an optional return; " + injectorBodyStyles)
     }
     return wireBuildCall, nil
 }
 
+// injectorBodyStyles describes the supported ways to write the body of an
+// injector template.
+const injectorBodyStyles = "write the call as panic(wire.Build(...)), _ = wire.Build(...) or wire.Build(...), each optionally followed by a return of zero values"
+
+// isWireBuild reports whether call is a call to wire.Build.
+func isWireBuild(info *types.Info, call *ast.CallExpr) bool {
+    buildObj := qualifiedIdentObject(info, call.Fun)
+    return buildObj != nil && buildObj.Pkg() != nil && isWireImport(buildObj.Pkg().Path()) && buildObj.Name() == "Build"
+}
+
+// isBlankAssign reports whether stmt assigns a single value to the blank
+// identifier, as in "_ = x".
+func isBlankAssign(stmt *ast.AssignStmt) bool {
+    if stmt.Tok != token.ASSIGN || len(stmt.Lhs) != 1 || len(stmt.Rhs) != 1 {
+        return false
+    }
+    id, ok := stmt.Lhs[0].(*ast.Ident)
+    return ok && id.Name == "_"
+}
+
+// isNamedErrorType reports whether t is a named type whose underlying type
+// is the error interface, like "type ShutdownError error". The predeclared
+// error type itself (and aliases of it) are not named error types.
+func isNamedErrorType(t types.Type) bool {
+    if _, ok := unalias(t).(*types.Named); !ok {
+        return false
+    }
+    if types.Identical(t, errorType) {
+        return false
+    }
+    return types.Identical(t.Underlying(), errorType.Underlying())
+}
+
+// isCleanupsType reports whether t is *wire.Cleanups.
+func isCleanupsType(t types.Type) bool {
+    ptr, ok := unalias(t).(*types.Pointer)
+    if !ok {
+        return false
+    }
+    named, ok := unalias(ptr.Elem()).(*types.Named)
+    if !ok {
+        return false
+    }
+    obj := named.Obj()
+    return obj.Name() == "Cleanups" && obj.Pkg() != nil && isWireImport(obj.Pkg().Path())
+}
+
 func isWireImport(path string) bool {
     // TODO(light): This is depending on details of the current loader.
     const vendorPart = "vendor/"
@@ -1508,7 +2720,7 @@ func isWireImport(path string) bool {
 }
 
 func isProviderSetType(t types.Type) bool {
-    n, ok := t.(*types.Named)
+    n, ok := unalias(t).(*types.Named)
     if !ok {
         return false
     }
@@ -1517,8 +2729,10 @@ func isProviderSetType(t types.Type) bool {
 }
 
 // ProvidedType represents a type provided from a source. The source
-// can be a *Provider (a provider function), a *Value (wire.Value), or an
-// *InjectorArgs (arguments to the injector function). The zero value has
+// can be a *Provider (a provider function), a *Value (wire.Value), a *Slice
+// (wire.Slice), a *Map (wire.Map), or an *InjectorArgs (arguments to the
+// injector function).
+// The zero value has
 // none of the above, and returns true for IsNil.
 type ProvidedType struct {
     // t is the provided concrete type.
@@ -1527,11 +2741,13 @@ type ProvidedType struct {
     v *Value
     a *InjectorArg
     f *Field
+    s *Slice
+    m *Map
 }
 
 // IsNil reports whether pt is the zero value.
 func (pt ProvidedType) IsNil() bool {
-    return pt.p == nil && pt.v == nil && pt.a == nil && pt.f == nil
+    return pt.p == nil && pt.v == nil && pt.a == nil && pt.f == nil && pt.s == nil && pt.m == nil
 }
 
 // Type returns the output type.
@@ -1541,6 +2757,8 @@ func (pt ProvidedType) IsNil() bool {
 //     whose element type is the struct type.
 //   - For a value, this is the type of the expression.
 //   - For an argument, this is the type of the argument.
+//   - For a slice, this is the slice type.
+//   - For a map, this is the map type.
 func (pt ProvidedType) Type() types.Type {
     return pt.t
 }
@@ -1565,6 +2783,16 @@ func (pt ProvidedType) IsField() bool {
     return pt.f != nil
 }
 
+// IsSlice reports whether pt points to a Slice.
+func (pt ProvidedType) IsSlice() bool {
+    return pt.s != nil
+}
+
+// IsMap reports whether pt points to a Map.
+func (pt ProvidedType) IsMap() bool {
+    return pt.m != nil
+}
+
 // Provider returns pt as a Provider pointer. It panics if pt does not point
 // to a Provider.
 func (pt ProvidedType) Provider() *Provider {
@@ -1601,12 +2829,40 @@ func (pt ProvidedType) Field() *Field {
     return pt.f
 }
 
+// Slice returns pt as a Slice pointer. It panics if pt does not point to a
+// Slice.
+func (pt ProvidedType) Slice() *Slice {
+    if pt.s == nil {
+        panic("ProvidedType does not hold a Slice")
+    }
+    return pt.s
+}
+
+// Map returns pt as a Map pointer. It panics if pt does not point to a Map.
+func (pt ProvidedType) Map() *Map {
+    if pt.m == nil {
+        panic("ProvidedType does not hold a Map")
+    }
+    return pt.m
+}
+
+// src returns the source of the provider, value, argument, field, slice or
+// map that pt points to.
+func (pt ProvidedType) src() *providerSetSrc {
+    return &providerSetSrc{Provider: pt.p, Value: pt.v, InjectorArg: pt.a, Field: pt.f, Slice: pt.s, Map: pt.m}
+}
+
 // bindShouldUsePointer loads the wire package the user is importing from their
 // injector. The call is a wire marker function call.
 func bindShouldUsePointer(info *types.Info, call *ast.CallExpr) bool {
-    // These type assertions should not fail, otherwise panic.
-    fun := call.Fun.(*ast.SelectorExpr)                 // wire.Bind
-    pkgName := fun.X.(*ast.Ident)                       // wire
-    wireName := info.ObjectOf(pkgName).(*types.PkgName) // wire package
-    return wireName.Imported().Scope().Lookup("bindToUsePointer") != nil
+    // The caller has already resolved call.Fun to wire.Bind, though the wire
+    // package may have been dot-imported.
+    bind := qualifiedIdentObject(info, call.Fun)
+    return bind.Pkg().Scope().Lookup("bindToUsePointer") != nil
+}
+
+// isUntypedNil reports whether t is the type of the predeclared nil.
+func isUntypedNil(t types.Type) bool {
+    b, ok := t.(*types.Basic)
+    return ok && b.Kind() == types.UntypedNil
 }
@@ -0,0 +1,184 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"fmt"
+	"go/ast"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// packageOptionsDirective, in an injector file, overrides some of the
+// GenerateOptions for the file's package, as space-separated key=value
+// pairs. Values with spaces are written as Go string literals:
+//
+//	//wire:options output=di_gen.go wrap_errors="initializing {{.Type}}"
+//
+// Generated files use the same directive to record the options fingerprint,
+// which is never a key=value pair.
+const packageOptionsDirective = "//wire:options "
+
+// defaultWrapErrors is the WrapErrors template that wrap_errors=true selects.
+const defaultWrapErrors = "initializing {{.Type}}"
+
+// packageOptions returns opts with the overrides of the //wire:options
+// directives in the injector files of pkg applied. opts itself is not
+// changed. Each key may only be set once in a package.
+func packageOptions(pkg *packages.Package, opts *GenerateOptions) (*GenerateOptions, []error) {
+	merged := *opts
+	seen := make(map[string]bool)
+	var errs []error
+	for _, f := range sourceFiles(pkg) {
+		if !requiresTag(fileConstraint(f), opts.buildTag()) {
+			continue
+		}
+		dir := filepath.Dir(pkg.Fset.Position(f.Pos()).Filename)
+		for _, c := range packageOptionsComments(f) {
+			pos := pkg.Fset.Position(c.Pos())
+			pairs, err := splitPackageOptions(strings.TrimPrefix(c.Text, packageOptionsDirective))
+			if err != nil {
+				errs = append(errs, notePosition(pos, err))
+				continue
+			}
+			for _, kv := range pairs {
+				if seen[kv[0]] {
+					errs = append(errs, notePosition(pos, fmt.Errorf("%s: %s is set more than once in the package", strings.TrimSpace(packageOptionsDirective), kv[0])))
+					continue
+				}
+				seen[kv[0]] = true
+				if err := setPackageOption(&merged, dir, kv[0], kv[1]); err != nil {
+					errs = append(errs, notePosition(pos, fmt.Errorf("%s: %v", strings.TrimSpace(packageOptionsDirective), err)))
+				}
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return &merged, nil
+}
+
+// packageOptionsComments returns the //wire:options directives in f.
+func packageOptionsComments(f *ast.File) []*ast.Comment {
+	var dirs []*ast.Comment
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			if strings.HasPrefix(c.Text, packageOptionsDirective) {
+				dirs = append(dirs, c)
+			}
+		}
+	}
+	return dirs
+}
+
+// splitPackageOptions splits the text of a //wire:options directive into
+// key and value pairs, unquoting values written as Go string literals.
+func splitPackageOptions(s string) ([][2]string, error) {
+	var pairs [][2]string
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return pairs, nil
+		}
+		eq := strings.IndexAny(s, "= \t")
+		if eq <= 0 || s[eq] != '=' {
+			field := s
+			if i := strings.IndexAny(s, " \t"); i >= 0 {
+				field = s[:i]
+			}
+			return nil, fmt.Errorf("%s: %q is not a key=value pair", strings.TrimSpace(packageOptionsDirective), field)
+		}
+		key := s[:eq]
+		s = s[eq+1:]
+		var value string
+		if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "`") {
+			quoted, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s: value of %s is not a valid string literal", strings.TrimSpace(packageOptionsDirective), key)
+			}
+			value, _ = strconv.Unquote(quoted)
+			s = s[len(quoted):]
+		} else {
+			end := strings.IndexAny(s, " \t")
+			if end < 0 {
+				end = len(s)
+			}
+			value, s = s[:end], s[end:]
+		}
+		pairs = append(pairs, [2]string{key, value})
+	}
+}
+
+// setPackageOption sets the option named key in opts to value. dir is the
+// directory of the injector file, which relative paths are resolved against.
+func setPackageOption(opts *GenerateOptions, dir, key, value string) error {
+	switch key {
+	case "output":
+		if value != filepath.Base(value) || filepath.Ext(value) != ".go" || strings.HasSuffix(value, "_test.go") {
+			return fmt.Errorf("output %q is not the name of a non-test Go file", value)
+		}
+		opts.outputFile = value
+	case "header_file":
+		if !filepath.IsAbs(value) {
+			value = filepath.Join(dir, value)
+		}
+		header, err := ioutil.ReadFile(value)
+		if err != nil {
+			return fmt.Errorf("header_file: %v", err)
+		}
+		opts.Header = header
+	case "identifier_prefix":
+		if value != "" && !isIdentifierPrefix(value) {
+			return fmt.Errorf("identifier prefix %q is not a valid start of a Go identifier", value)
+		}
+		opts.IdentifierPrefix = value
+	case "wrap_errors":
+		switch value {
+		case "true":
+			value = defaultWrapErrors
+		case "false":
+			value = ""
+		}
+		if _, err := parseWrapErrors(value); err != nil {
+			return err
+		}
+		opts.WrapErrors = value
+	case "annotate", "deprecated_as_error", "unused_params_as_error", "runtime_cleanup", "nearest_wins":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a boolean", key, value)
+		}
+		switch key {
+		case "annotate":
+			opts.AnnotateOutput = b
+		case "deprecated_as_error":
+			opts.DeprecatedAsError = b
+		case "unused_params_as_error":
+			opts.UnusedParamsAsError = b
+		case "nearest_wins":
+			opts.NearestWins = b
+		default:
+			opts.UseRuntimeCleanup = b
+		}
+	default:
+		return fmt.Errorf("unknown option %q; want one of output, header_file, identifier_prefix, wrap_errors, annotate, deprecated_as_error, unused_params_as_error, runtime_cleanup or nearest_wins", key)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"go/ast"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// A ProgressPhase is the stage of generation that a ProgressEvent reports.
+type ProgressPhase int
+
+const (
+	// ProgressLoading is reported once, before the packages are loaded.
+	ProgressLoading ProgressPhase = iota
+	// ProgressLoaded is reported once the packages are loaded, with the
+	// numbers of packages and injectors to generate. It is not reported if
+	// the packages fail to load.
+	ProgressLoaded
+	// ProgressInjector is reported after each injector is solved, whether
+	// or not it has errors.
+	ProgressInjector
+	// ProgressPackage is reported after each package is generated.
+	ProgressPackage
+)
+
+func (p ProgressPhase) String() string {
+	switch p {
+	case ProgressLoading:
+		return "loading"
+	case ProgressLoaded:
+		return "loaded"
+	case ProgressInjector:
+		return "injector"
+	case ProgressPackage:
+		return "package"
+	default:
+		return "unknown"
+	}
+}
+
+// A ProgressEvent is passed to GenerateOptions.Progress.
+type ProgressEvent struct {
+	Phase ProgressPhase
+
+	// PkgPath is the package of a ProgressInjector or ProgressPackage event.
+	PkgPath string
+
+	// Injector is the name of the injector declaration of a
+	// ProgressInjector event.
+	Injector string
+
+	// Packages and Injectors are the numbers of packages generated and
+	// injectors solved so far, out of TotalPackages and TotalInjectors,
+	// which are zero before ProgressLoaded. The injectors of a package that
+	// fails before they are solved, such as for an invalid //wire:options
+	// directive, are counted by its ProgressPackage event, so both counts
+	// reach their totals.
+	Packages, TotalPackages   int
+	Injectors, TotalInjectors int
+}
+
+// progress reports the progress of one run of Generate to
+// GenerateOptions.Progress, one event at a time. A nil *progress reports
+// nothing.
+type progress struct {
+	mu    sync.Mutex
+	fn    func(ProgressEvent)
+	event ProgressEvent
+	// unsolved is the number of injectors of each package that have not
+	// been reported yet.
+	unsolved map[*packages.Package]int
+}
+
+// newProgress returns the progress that reports to fn, or nil if fn is nil.
+func newProgress(fn func(ProgressEvent)) *progress {
+	if fn == nil {
+		return nil
+	}
+	return &progress{fn: fn}
+}
+
+// report sends the event with the given phase, package and injector and the
+// current counts. The caller must hold p.mu.
+func (p *progress) report(phase ProgressPhase, pkgPath, injector string) {
+	p.event.Phase = phase
+	p.event.PkgPath = pkgPath
+	p.event.Injector = injector
+	p.fn(p.event)
+}
+
+func (p *progress) loading() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.report(ProgressLoading, "", "")
+}
+
+func (p *progress) loaded(pkgs []*packages.Package) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.unsolved = make(map[*packages.Package]int, len(pkgs))
+	p.event.TotalPackages = len(pkgs)
+	for _, pkg := range pkgs {
+		n := countInjectors(pkg)
+		p.unsolved[pkg] = n
+		p.event.TotalInjectors += n
+	}
+	p.report(ProgressLoaded, "", "")
+}
+
+func (p *progress) injectorSolved(pkg *packages.Package, name string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.unsolved[pkg]--
+	p.event.Injectors++
+	p.report(ProgressInjector, pkg.PkgPath, name)
+}
+
+func (p *progress) packageGenerated(pkg *packages.Package) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.event.Injectors += p.unsolved[pkg]
+	delete(p.unsolved, pkg)
+	p.event.Packages++
+	p.report(ProgressPackage, pkg.PkgPath, "")
+}
+
+// countInjectors returns the number of injectors declared in pkg.
+func countInjectors(pkg *packages.Package) int {
+	n := 0
+	for _, f := range sourceFiles(pkg) {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			if buildCall, err := findInjectorBuild(pkg.TypesInfo, fn); err == nil && buildCall != nil {
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,169 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// allowRecoverDirective allows a cleanup function to call recover. It may
+// be put in the doc comment of the provider or of a cleanup function
+// declared on its own, or in a comment on or just above the recover call.
+const allowRecoverDirective = "//wire:allow-recover"
+
+// A funcBody is the body of a function literal or declaration, along with
+// the file and type information it comes from.
+type funcBody struct {
+	file *ast.File
+	info *types.Info
+	body *ast.BlockStmt
+}
+
+// cleanupRecovers returns the positions of the recover calls in the cleanup
+// functions that fn, the function of the provider p, returns. Only the
+// cleanup functions that can be found from the syntax of fn are examined:
+// function literals and functions returned directly, and function literals
+// assigned to the local variables or named result returned.
+func (oc *objectCache) cleanupRecovers(fn *types.Func, p *Provider) []token.Pos {
+	if !p.HasCleanup {
+		return nil
+	}
+	pkg, file, path := oc.declPath(fn)
+	decl := enclosingFuncDecl(path)
+	if decl == nil || decl.Body == nil || hasDirective(decl.Doc, allowRecoverDirective) {
+		return nil
+	}
+	results := fn.Type().(*types.Signature).Results()
+	i := len(p.Out)
+
+	var bodies []funcBody
+	vars := make(map[types.Object]bool)
+	if results.At(i).Name() != "" {
+		vars[results.At(i)] = true
+	}
+	addExpr := func(expr ast.Expr) {
+		expr = astutil.Unparen(expr)
+		if lit, ok := expr.(*ast.FuncLit); ok {
+			bodies = append(bodies, funcBody{file, pkg.TypesInfo, lit.Body})
+			return
+		}
+		switch obj := qualifiedIdentObject(pkg.TypesInfo, expr).(type) {
+		case *types.Func:
+			fpkg, ffile, fpath := oc.declPath(obj)
+			if d := enclosingFuncDecl(fpath); d != nil && d.Body != nil && !hasDirective(d.Doc, allowRecoverDirective) {
+				bodies = append(bodies, funcBody{ffile, fpkg.TypesInfo, d.Body})
+			}
+		case *types.Var:
+			if obj.Parent() != obj.Pkg().Scope() {
+				vars[obj] = true
+			}
+		}
+	}
+	// Returns in function literals belong to those functions.
+	ast.Inspect(decl.Body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			if len(node.Results) == results.Len() {
+				addExpr(node.Results[i])
+			}
+		}
+		return true
+	})
+	// Function literals assigned to the returned variables are cleanup
+	// functions too.
+	assigned := func(lhs []*ast.Ident, rhs []ast.Expr) {
+		if len(lhs) != len(rhs) {
+			return
+		}
+		for j, id := range lhs {
+			obj := pkg.TypesInfo.ObjectOf(id)
+			if lit, ok := astutil.Unparen(rhs[j]).(*ast.FuncLit); ok && obj != nil && vars[obj] {
+				bodies = append(bodies, funcBody{file, pkg.TypesInfo, lit.Body})
+			}
+		}
+	}
+	ast.Inspect(decl.Body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.AssignStmt:
+			var lhs []*ast.Ident
+			for _, e := range node.Lhs {
+				id, _ := e.(*ast.Ident)
+				lhs = append(lhs, id)
+			}
+			assigned(lhs, node.Rhs)
+		case *ast.ValueSpec:
+			assigned(node.Names, node.Values)
+		}
+		return true
+	})
+
+	var recovers []token.Pos
+	seen := make(map[token.Pos]bool)
+	for _, b := range bodies {
+		for _, pos := range oc.recoverCalls(b) {
+			if !seen[pos] {
+				seen[pos] = true
+				recovers = append(recovers, pos)
+			}
+		}
+	}
+	return recovers
+}
+
+// recoverCalls returns the positions of the calls to recover in b that
+// are not allowed by a //wire:allow-recover comment.
+func (oc *objectCache) recoverCalls(b funcBody) []token.Pos {
+	var allowed []int
+	for _, cg := range b.file.Comments {
+		for _, c := range cg.List {
+			if strings.HasPrefix(c.Text, allowRecoverDirective) {
+				allowed = append(allowed, oc.fset.Position(c.Pos()).Line)
+			}
+		}
+	}
+	var calls []token.Pos
+	ast.Inspect(b.body, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok || qualifiedIdentObject(b.info, call.Fun) != types.Universe.Lookup("recover") {
+			return true
+		}
+		line := oc.fset.Position(call.Pos()).Line
+		for _, l := range allowed {
+			if l == line || l == line-1 {
+				return true
+			}
+		}
+		calls = append(calls, call.Pos())
+		return true
+	})
+	return calls
+}
+
+// enclosingFuncDecl returns the function declaration in path, or nil.
+func enclosingFuncDecl(path []ast.Node) *ast.FuncDecl {
+	for _, node := range path {
+		if decl, ok := node.(*ast.FuncDecl); ok {
+			return decl
+		}
+	}
+	return nil
+}
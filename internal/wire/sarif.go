@@ -0,0 +1,308 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// A sarifRule is a kind of diagnostic, reported as a SARIF rule.
+type sarifRule struct {
+	ID          string
+	Description string
+}
+
+// sarifRules are the kinds of diagnostics that EncodeSARIF tells apart.
+var sarifRules = []sarifRule{
+	{"wire/missing-provider", "A type that an injector needs has no provider."},
+	{"wire/binding-conflict", "A type has more than one provider in a provider set."},
+	{"wire/cycle", "Providers depend on each other in a cycle."},
+	{"wire/unused", "A provider, value, binding, field or set is not used by the injector."},
+	{"wire/deprecated", "A deprecated provider or provider set is used."},
+	{"wire/load", "The packages could not be loaded or type-checked."},
+	{"wire/internal", "Wire failed while analyzing an injector."},
+	{"wire/other", "Any other problem that Wire reports."},
+}
+
+// diagnosticRule returns the ID of the rule that err is reported under.
+func diagnosticRule(err error) string {
+	var conflict *conflictError
+	var interrupted *LoadInterruptedError
+	var loadErr packages.Error
+	if errors.As(err, &conflict) {
+		return "wire/binding-conflict"
+	}
+	if errors.As(err, &interrupted) || errors.As(err, &loadErr) {
+		return "wire/load"
+	}
+	msg := diagnosticMessage(err)
+	var ie *InjectorError
+	if errors.As(err, &ie) {
+		msg = ie.Err.Error()
+	}
+	switch {
+	case strings.HasPrefix(msg, "no provider found for "):
+		return "wire/missing-provider"
+	case strings.HasPrefix(msg, "cycle for "):
+		return "wire/cycle"
+	case strings.HasPrefix(msg, "unused "), strings.Contains(msg, " is unused"):
+		return "wire/unused"
+	case strings.Contains(msg, " is deprecated"):
+		return "wire/deprecated"
+	case strings.HasPrefix(msg, "internal error: "):
+		return "wire/internal"
+	}
+	return "wire/other"
+}
+
+// diagnosticMessage returns the message of err without its position.
+func diagnosticMessage(err error) string {
+	if w, ok := err.(*wireErr); ok {
+		return w.error.Error()
+	}
+	return err.Error()
+}
+
+// diagnosticPosition returns the position of err, if it has one.
+func diagnosticPosition(err error) token.Position {
+	var w *wireErr
+	if errors.As(err, &w) {
+		return w.position
+	}
+	var loadErr packages.Error
+	if errors.As(err, &loadErr) {
+		return parseErrorPos(loadErr.Pos)
+	}
+	return token.Position{}
+}
+
+// parseErrorPos parses the position of a packages.Error, which is of the
+// form file:line:col, file:line or "-".
+func parseErrorPos(s string) token.Position {
+	var pos token.Position
+	parts := strings.Split(s, ":")
+	// Take numbers off the end, leaving the file name, which may itself
+	// contain colons.
+	var nums []int
+	for len(parts) > 1 && len(nums) < 2 {
+		n, err := strconv.Atoi(parts[len(parts)-1])
+		if err != nil || n <= 0 {
+			break
+		}
+		nums = append([]int{n}, nums...)
+		parts = parts[:len(parts)-1]
+	}
+	if len(nums) == 0 {
+		return pos
+	}
+	pos.Filename = strings.Join(parts, ":")
+	pos.Line = nums[0]
+	if len(nums) > 1 {
+		pos.Column = nums[1]
+	}
+	return pos
+}
+
+// The types below are the parts of the SARIF 2.1.0 format that
+// EncodeSARIF writes.
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string               `json:"name"`
+	InformationURI string               `json:"informationUri"`
+	Rules          []sarifReportingRule `json:"rules"`
+}
+
+type sarifReportingRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId"`
+	RuleIndex        int             `json:"ruleIndex"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations,omitempty"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	ID               *int                  `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// EncodeSARIF encodes errs and warnings, as returned by Load, Generate or
+// the analyzer, as a SARIF 2.1.0 log for code scanning tools. Errors have the
+// level "error" and warnings the level "warning". Each diagnostic is
+// reported under a rule for its kind, such as wire/missing-provider, at its
+// position if it has one. The injectors that an error applies to and the
+// conflicting bindings of a type are reported as related locations.
+// Absolute file names are written as file URIs, others as relative ones.
+func EncodeSARIF(errs []error, warnings []Warning) ([]byte, error) {
+	ruleIndex := make(map[string]int)
+	var results []sarifResult
+	add := func(err error, level string) {
+		rule := diagnosticRule(err)
+		if _, ok := ruleIndex[rule]; !ok {
+			ruleIndex[rule] = -1
+		}
+		r := sarifResult{
+			RuleID:  rule,
+			Level:   level,
+			Message: sarifMessage{Text: diagnosticMessage(err)},
+		}
+		if pos := diagnosticPosition(err); pos.Filename != "" {
+			r.Locations = []sarifLocation{{PhysicalLocation: sarifPhysical(pos)}}
+		}
+		related := func(pos token.Position, text string) {
+			if pos.Filename == "" {
+				return
+			}
+			id := len(r.RelatedLocations) + 1
+			r.RelatedLocations = append(r.RelatedLocations, sarifLocation{
+				ID:               &id,
+				PhysicalLocation: sarifPhysical(pos),
+				Message:          &sarifMessage{Text: text},
+			})
+		}
+		var ie *InjectorError
+		if errors.As(err, &ie) {
+			for i, name := range ie.Injectors {
+				related(ie.Positions[i], "injector "+name)
+			}
+		}
+		var conflict *conflictError
+		if errors.As(err, &conflict) {
+			related(conflict.curPos, "current: "+conflict.current)
+			related(conflict.prevPos, "previous: "+conflict.previous)
+		}
+		var missing *MissingProviderError
+		if errors.As(err, &missing) {
+			for _, sug := range missing.Suggestions {
+				related(sug.Pos, "did you mean "+types.TypeString(sug.Type, nil)+", from "+sug.Source)
+			}
+		}
+		results = append(results, r)
+	}
+	for _, err := range errs {
+		add(err, "error")
+	}
+	for _, w := range warnings {
+		add(w, "warning")
+	}
+
+	// List the rules that are used, in the order of sarifRules.
+	driver := sarifDriver{
+		Name:           "wirex",
+		InformationURI: "https://github.com/google/wire",
+		Rules:          []sarifReportingRule{},
+	}
+	for _, rule := range sarifRules {
+		if _, ok := ruleIndex[rule.ID]; ok {
+			ruleIndex[rule.ID] = len(driver.Rules)
+			driver.Rules = append(driver.Rules, sarifReportingRule{
+				ID:               rule.ID,
+				ShortDescription: sarifMessage{Text: rule.Description},
+			})
+		}
+	}
+	for i := range results {
+		results[i].RuleIndex = ruleIndex[results[i].RuleID]
+	}
+	if results == nil {
+		results = []sarifResult{}
+	}
+	log := sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: driver},
+			Results: results,
+		}},
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(log); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sarifPhysical returns the SARIF location of pos.
+func sarifPhysical(pos token.Position) sarifPhysicalLocation {
+	loc := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: sarifURI(pos.Filename)}}
+	if pos.Line > 0 {
+		loc.Region = &sarifRegion{StartLine: pos.Line, StartColumn: pos.Column}
+	}
+	return loc
+}
+
+// sarifURI returns the URI of the file filename.
+func sarifURI(filename string) string {
+	uri := filepath.ToSlash(filename)
+	if !filepath.IsAbs(filename) {
+		return uri
+	}
+	if !strings.HasPrefix(uri, "/") {
+		// A Windows path such as C:/foo.
+		uri = "/" + uri
+	}
+	return "file://" + uri
+}
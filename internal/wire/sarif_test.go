@@ -0,0 +1,169 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestEncodeSARIF(t *testing.T) {
+	pos := func(line, col int) token.Position {
+		return token.Position{Filename: "/src/example.com/foo/wire.go", Line: line, Column: col}
+	}
+	missing := injectorError(pos(10, 6), "injectFoo", errors.New("no provider found for example.com/foo.Bar, output of injector"))
+	shared := groupErrors([]error{
+		injectorError(pos(20, 6), "injectBaz", notePosition(pos(5, 2), errors.New("cycle for example.com/foo.Baz:\nexample.com/foo.Baz (example.com/foo.provideBaz) ->\nexample.com/foo.Baz"))),
+		injectorError(pos(25, 6), "injectOtherBaz", notePosition(pos(5, 2), errors.New("cycle for example.com/foo.Baz:\nexample.com/foo.Baz (example.com/foo.provideBaz) ->\nexample.com/foo.Baz"))),
+	})
+	conflict := notePosition(pos(30, 12), &conflictError{
+		msg:      "Set has multiple bindings for example.com/foo.Foo\ncurrent:\n<- provider \"provideFoo\" (/src/example.com/foo/foo.go:8:6)\nprevious:\n<- wire.Value (/src/example.com/foo/wire.go:31:3)",
+		current:  "provider \"provideFoo\" (/src/example.com/foo/foo.go:8:6)",
+		previous: "wire.Value (/src/example.com/foo/wire.go:31:3)",
+		curPos:   token.Position{Filename: "/src/example.com/foo/foo.go", Line: 8, Column: 6},
+		prevPos:  pos(31, 3),
+	})
+	errs := []error{
+		missing,
+		shared[0],
+		conflict,
+		packages.Error{Pos: "/src/example.com/bar/bar.go:3:8", Msg: "could not import example.com/missing", Kind: packages.TypeError},
+		&LoadInterruptedError{Err: context.DeadlineExceeded, Parsed: 3},
+	}
+	warnings := []Warning{
+		injectorError(pos(40, 6), "injectQux", notePosition(pos(41, 19), fmt.Errorf("provider %q (%s) is deprecated: use NewQux", "provideQux", pos(3, 6)))),
+		injectorError(pos(40, 6), "injectQux", errors.New("result example.com/foo.Extra of provider \"main.provideQux\" is unused")),
+		notePosition(token.Position{Filename: "foo/wire.go", Line: 2}, errors.New("something else")),
+	}
+	got, err := EncodeSARIF(errs, warnings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v interface{}
+	if err := json.Unmarshal(got, &v); err != nil {
+		t.Fatalf("EncodeSARIF produced invalid JSON: %v", err)
+	}
+
+	golden := filepath.Join("testdata", "_sarif", "want.sarif")
+	if *record {
+		if err := ioutil.WriteFile(golden, got, 0666); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(want), string(got)); diff != "" {
+		t.Errorf("EncodeSARIF differs from %s (-want +got):\n%s", golden, diff)
+	}
+}
+
+func TestEncodeSARIFConflict(t *testing.T) {
+	wd, env := materialize(t, map[string]string{
+		"example.com/foo/foo.go": "package main\n\ntype Foo int\n\nfunc provideFoo() Foo { return 42 }\n\nfunc main() {}\n",
+		"example.com/foo/wire.go": `//go:build wireinject
+
+package main
+
+import "github.com/google/wire"
+
+func injectFoo() Foo {
+	panic(wire.Build(provideFoo, wire.Value(Foo(1))))
+}
+`,
+	})
+	gens, errs := Generate(context.Background(), wd, env, []string{"./foo"}, nil)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if len(gens) != 1 || len(gens[0].Errs) != 1 {
+		t.Fatalf("Generate = %+v; want one result with one error", gens)
+	}
+	got, err := EncodeSARIF(gens[0].Errs, gens[0].Warnings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Runs []struct {
+			Results []struct {
+				RuleID           string `json:"ruleId"`
+				RelatedLocations []struct {
+					Message struct {
+						Text string `json:"text"`
+					} `json:"message"`
+				} `json:"relatedLocations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(got, &log); err != nil {
+		t.Fatal(err)
+	}
+	r := log.Runs[0].Results[0]
+	var related []string
+	for _, l := range r.RelatedLocations {
+		related = append(related, l.Message.Text)
+	}
+	if r.RuleID != "wire/binding-conflict" || len(related) != 2 || !strings.HasPrefix(related[0], "current: wire.Value") || !strings.HasPrefix(related[1], "previous: provider \"provideFoo\"") {
+		t.Errorf("EncodeSARIF reported rule %s with related locations %q; want wire/binding-conflict with both bindings", r.RuleID, related)
+	}
+}
+
+func TestEncodeSARIFEmpty(t *testing.T) {
+	got, err := EncodeSARIF(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Runs []struct {
+			Results []interface{} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(got, &log); err != nil {
+		t.Fatal(err)
+	}
+	if len(log.Runs) != 1 || log.Runs[0].Results == nil || len(log.Runs[0].Results) != 0 {
+		t.Errorf("EncodeSARIF(nil, nil) = %s; want one run with an empty results array", got)
+	}
+}
+
+func TestParseErrorPos(t *testing.T) {
+	tests := []struct {
+		in   string
+		want token.Position
+	}{
+		{"/a/b.go:3:8", token.Position{Filename: "/a/b.go", Line: 3, Column: 8}},
+		{"/a/b.go:3", token.Position{Filename: "/a/b.go", Line: 3}},
+		{`C:\a\b.go:3:8`, token.Position{Filename: `C:\a\b.go`, Line: 3, Column: 8}},
+		{"-", token.Position{}},
+		{"", token.Position{}},
+	}
+	for _, test := range tests {
+		if got := parseErrorPos(test.in); got != test.want {
+			t.Errorf("parseErrorPos(%q) = %+v; want %+v", test.in, got, test.want)
+		}
+	}
+}
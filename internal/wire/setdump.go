@@ -0,0 +1,251 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/types"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// String renders the contents of set for debugging: a line naming the set,
+// then a line for each of its providers, then for each of its bindings,
+// values, fields, slices, keyed providers and maps, and then the sets it
+// includes, each rendered the same way one tab further in. A set
+// that was already rendered is only named again, with "(shown above)", so
+// that a set included through several others is rendered once. Types are
+// qualified by import path, so the rendering does not depend on where it is
+// used.
+func (set *ProviderSet) String() string {
+	sb := new(strings.Builder)
+	writeProviderSet(sb, set, 0, make(map[*ProviderSet]bool))
+	return sb.String()
+}
+
+// setName returns the name of set in a rendering by ProviderSet.String.
+func setName(set *ProviderSet) string {
+	switch {
+	case set.VarName != "":
+		return ProviderSetID{ImportPath: set.PkgPath, VarName: set.VarName}.String()
+	case set.InjectorArgs != nil:
+		return fmt.Sprintf("wire.Build in %s", (&Injector{ImportPath: set.PkgPath, FuncName: set.InjectorArgs.Name}).String())
+	default:
+		return fmt.Sprintf("wire.NewSet in %q", set.PkgPath)
+	}
+}
+
+// writeProviderSet writes the rendering of set to sb, indented by depth
+// tabs. seen holds the sets rendered so far.
+func writeProviderSet(sb *strings.Builder, set *ProviderSet, depth int, seen map[*ProviderSet]bool) {
+	indent := strings.Repeat("\t", depth)
+	if seen[set] {
+		fmt.Fprintf(sb, "%s%s (shown above)\n", indent, setName(set))
+		return
+	}
+	seen[set] = true
+	fmt.Fprintf(sb, "%s%s\n", indent, setName(set))
+	line := func(format string, args ...interface{}) {
+		fmt.Fprintf(sb, "%s\t%s\n", indent, fmt.Sprintf(format, args...))
+	}
+	if args := set.InjectorArgs; args != nil && args.Tuple.Len() > 0 {
+		var ts []string
+		for i := 0; i < args.Tuple.Len(); i++ {
+			ts = append(ts, types.TypeString(args.Tuple.At(i).Type(), nil))
+		}
+		line("args %s", strings.Join(ts, ", "))
+	}
+	for _, p := range set.Providers {
+		line("%s", providerString(p))
+	}
+	for _, b := range set.Bindings {
+		s := fmt.Sprintf("bind %s to %s", types.TypeString(b.Iface, nil), types.TypeString(b.Provided, nil))
+		if b.Synthesized {
+			s += " (autobind)"
+		}
+		line("%s", s)
+	}
+	for _, v := range set.Values {
+		line("value %s", types.TypeString(v.Out, nil))
+	}
+	for _, f := range set.Fields {
+		line("field %s of %s: %s", f.Name, types.TypeString(f.Parent, nil), typeList(f.Out))
+	}
+	for _, s := range set.Slices {
+		line("slice %s", types.TypeString(s.Out, nil))
+	}
+	for _, k := range set.Keyed {
+		line("key %q: %s", k.Key, providerString(k.Provider))
+	}
+	for _, m := range set.Maps {
+		line("map %s", types.TypeString(m.Out, nil))
+	}
+	if reg := set.CleanupRegistry; reg != nil {
+		line("cleanup into %s", types.TypeString(reg.Type, nil))
+	}
+	for _, imp := range set.Imports {
+		writeProviderSet(sb, imp, depth+1, seen)
+	}
+}
+
+// providerString renders p as in ProviderSet.String: a function provider
+// as its signature, without parameter names, and a struct provider as its
+// type with the fields it sets, followed by the types it provides.
+func providerString(p *Provider) string {
+	name := p.Name
+	if p.Pkg != nil {
+		name = p.Pkg.Path() + "." + name
+	}
+	if len(p.TypeArgs) > 0 {
+		name += "[" + typeList(p.TypeArgs) + "]"
+	}
+	if p.IsStruct {
+		var fields []string
+		for _, a := range p.Args {
+			fields = append(fields, a.FieldName)
+		}
+		return fmt.Sprintf("struct %s{%s}: %s", name, strings.Join(fields, ", "), typeList(p.Out))
+	}
+	var ins []string
+	for i, a := range p.Args {
+		in := types.TypeString(a.Type, nil)
+		if p.Varargs && i == len(p.Args)-1 {
+			in = "..." + types.TypeString(a.Type.(*types.Slice).Elem(), nil)
+		}
+		ins = append(ins, in)
+	}
+	results := typeList(p.Out)
+	if p.HasCleanup {
+		results += ", func()"
+	}
+	if p.HasErr {
+		results += ", error"
+	}
+	if len(p.Out) > 1 || p.HasCleanup || p.HasErr {
+		results = "(" + results + ")"
+	}
+	return fmt.Sprintf("provider %s(%s) %s", name, strings.Join(ins, ", "), results)
+}
+
+// typeList renders ts separated by commas.
+func typeList(ts []types.Type) string {
+	s := make([]string, len(ts))
+	for i, t := range ts {
+		s[i] = types.TypeString(t, nil)
+	}
+	return strings.Join(s, ", ")
+}
+
+// Dump writes a summary of each entry of c to w, for debugging the cache:
+// the key of the set, the number of files that the set was analyzed from
+// with a digest of their contents when it was cached, the number of
+// providers in the set and the sets it includes, and how long ago it was
+// cached. Entries are sorted by key. Files that could not be hashed are
+// counted separately, since the entry is only valid while their
+// modification times are unchanged.
+func (c *ProviderSetCache) Dump(w io.Writer) error {
+	return c.dump(w, time.Now())
+}
+
+// dump is Dump with the ages of the entries measured up to now.
+func (c *ProviderSetCache) dump(w io.Writer, now time.Time) error {
+	type entry struct {
+		key    ProviderSetKey
+		cached *cachedProviderSet
+	}
+	var entries []entry
+	for i := range c.shards {
+		sh := &c.shards[i]
+		sh.mu.RLock()
+		for key, cached := range sh.sets {
+			entries = append(entries, entry{key, cached})
+		}
+		sh.mu.RUnlock()
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i].key, entries[j].key
+		switch {
+		case a.PkgPath != b.PkgPath:
+			return a.PkgPath < b.PkgPath
+		case a.VarName != b.VarName:
+			return a.VarName < b.VarName
+		case a.Filename != b.Filename:
+			return a.Filename < b.Filename
+		default:
+			return a.Offset < b.Offset
+		}
+	})
+	for _, e := range entries {
+		id := ProviderSetID{ImportPath: e.key.PkgPath, VarName: e.key.VarName}
+		_, err := fmt.Fprintf(w, "%v at %s:#%d\n\tfiles: %s\n\tproviders: %d\n\tage: %v\n",
+			id, e.key.Filename, e.key.Offset, e.cached.filesSummary(), countProviders(e.cached.set), now.Sub(e.cached.timestamp).Round(time.Second))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filesSummary describes the files of cached for ProviderSetCache.Dump.
+func (cached *cachedProviderSet) filesSummary() string {
+	names := make([]string, 0, len(cached.files))
+	for f := range cached.files {
+		names = append(names, f)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	unhashed := 0
+	for _, f := range names {
+		if cached.files[f].hash == "" {
+			unhashed++
+			continue
+		}
+		fmt.Fprintf(h, "%s\n", cached.files[f].hash)
+	}
+	s := fmt.Sprintf("%d, digest %s", len(names), hex.EncodeToString(h.Sum(nil))[:12])
+	if unhashed > 0 {
+		s += fmt.Sprintf(", %d unhashed", unhashed)
+	}
+	return s
+}
+
+// countProviders returns the number of distinct providers, keyed providers
+// included, in set and the sets it includes.
+func countProviders(set *ProviderSet) int {
+	providers := make(map[*Provider]bool)
+	seen := make(map[*ProviderSet]bool)
+	var visit func(*ProviderSet)
+	visit = func(set *ProviderSet) {
+		if set == nil || seen[set] {
+			return
+		}
+		seen[set] = true
+		for _, p := range set.Providers {
+			providers[p] = true
+		}
+		for _, k := range set.Keyed {
+			providers[k.Provider] = true
+		}
+		for _, imp := range set.Imports {
+			visit(imp)
+		}
+	}
+	visit(set)
+	return len(providers)
+}
@@ -0,0 +1,206 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// ProviderSetInfo describes a provider set, as returned by ParseProviderSet,
+// for tools such as documentation generators. It holds types and positions
+// as strings, so that it can be marshaled to JSON.
+type ProviderSetInfo struct {
+	// PkgPath and VarName name the set's variable. If the variable is an
+	// alias of another provider set, they name that set instead.
+	PkgPath string `json:"pkgPath"`
+	VarName string `json:"varName"`
+	// Pos is the position of the call to wire.NewSet, as "file:line:col".
+	Pos string `json:"pos"`
+	// Doc is the text of the doc comment of the set's variable.
+	Doc string `json:"doc,omitempty"`
+	// Deprecated is the text of the "Deprecated:" paragraph in Doc.
+	Deprecated string `json:"deprecated,omitempty"`
+
+	Providers []ProviderInfo   `json:"providers,omitempty"`
+	Bindings  []BindingInfo    `json:"bindings,omitempty"`
+	Values    []ValueInfo      `json:"values,omitempty"`
+	Sets      []ProviderSetRef `json:"sets,omitempty"`
+}
+
+// ProviderInfo describes a provider of a set in a ProviderSetInfo. Types
+// and names from packages other than the set's are qualified by package
+// name, as in "db.NewPool" and "*db.Pool".
+type ProviderInfo struct {
+	// Name is the name of the provider function or struct type.
+	Name string `json:"name"`
+	// Type is the type that the provider provides. A function with several
+	// results provides them all, which are listed as "(A, B)".
+	Type       string `json:"type"`
+	Pos        string `json:"pos"`
+	Doc        string `json:"doc,omitempty"`
+	Deprecated string `json:"deprecated,omitempty"`
+}
+
+// BindingInfo describes a wire.Bind of a set in a ProviderSetInfo.
+type BindingInfo struct {
+	Iface    string `json:"iface"`
+	Provided string `json:"provided"`
+	Pos      string `json:"pos"`
+}
+
+// ValueInfo describes a wire.Value or wire.InterfaceValue of a set in a
+// ProviderSetInfo.
+type ValueInfo struct {
+	Type string `json:"type"`
+	// Expr is the source of the value's expression.
+	Expr string `json:"expr"`
+	Pos  string `json:"pos"`
+}
+
+// ProviderSetRef refers to a provider set that a set in a ProviderSetInfo
+// includes. VarName is empty for a set that is not a package variable,
+// such as a call to wire.NewSet in the arguments of another.
+type ProviderSetRef struct {
+	PkgPath string `json:"pkgPath"`
+	VarName string `json:"varName,omitempty"`
+	Pos     string `json:"pos"`
+}
+
+// ParseProviderSet describes the provider set declared by the package
+// variable varName in the package matching pkgPattern, without requiring
+// any injector to use it. It loads only that package and its
+// dependencies, and the packages that the set refers to are loaded on
+// demand if they are not among those, as with GenerateOptions.LazyLoad.
+//
+// The description is cached in the global ProviderSetCache, so that
+// another call for the same set skips the analysis until one of the files
+// that the set was analyzed from changes.
+//
+// wd and env are as for Generate.
+func ParseProviderSet(ctx context.Context, wd string, env []string, pkgPattern, varName string) (*ProviderSetInfo, []error) {
+	imports := newImportCache(ctx, wd, env, defaultBuildTag, "", nil)
+	pkgs, errs := imports.load([]string{pkgPattern})
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if len(pkgs) != 1 {
+		return nil, []error{fmt.Errorf("pattern %q matches %d packages; want 1", pkgPattern, len(pkgs))}
+	}
+	pkg := pkgs[0]
+	obj, ok := pkg.Types.Scope().Lookup(varName).(*types.Var)
+	if !ok || !isProviderSetType(obj.Type()) {
+		return nil, []error{fmt.Errorf("package %s has no provider set named %s", pkg.PkgPath, varName)}
+	}
+	return cacheProviderSet(imports, pkg, obj)
+}
+
+// cacheProviderSet returns the description of the provider set declared by
+// obj, a package variable of pkg, which imports loaded, from the global
+// ProviderSetCache, analyzing the set and caching its description first if
+// it is not there.
+func cacheProviderSet(imports *importCache, pkg *packages.Package, obj *types.Var) (*ProviderSetInfo, []error) {
+	key := ProviderSetKeyOf(pkg.Fset, obj)
+	if info, ok := globalCache.cachedInfo(key); ok {
+		return info, nil
+	}
+
+	oc := newObjectCacheWithLazyLoad([]*packages.Package{pkg}, imports)
+	defer oc.loader.wait()
+	item, errs := oc.get(obj)
+	if len(errs) > 0 {
+		return nil, notePositionAll(pkg.Fset.Position(obj.Pos()), errs)
+	}
+	set := item.(*ProviderSet)
+	info := describeProviderSet(oc, set, oc.declDoc(obj).Text())
+	globalCache.cacheInfo(key, set, info, ProviderSetFiles(oc.fset, set))
+	return info, nil
+}
+
+// describeProviderSet returns the ProviderSetInfo of set, whose variable
+// has the doc comment doc.
+func describeProviderSet(oc *objectCache, set *ProviderSet, doc string) *ProviderSetInfo {
+	pos := func(p token.Pos) string {
+		return oc.fset.Position(p).String()
+	}
+	qualifier := func(p *types.Package) string {
+		if p.Path() == set.PkgPath {
+			return ""
+		}
+		return p.Name()
+	}
+	typeString := func(t types.Type) string {
+		return types.TypeString(t, qualifier)
+	}
+	info := &ProviderSetInfo{
+		PkgPath:    set.PkgPath,
+		VarName:    set.VarName,
+		Pos:        pos(set.Pos),
+		Doc:        doc,
+		Deprecated: set.Deprecated,
+	}
+	for _, p := range set.Providers {
+		name := p.Name
+		if q := qualifier(p.Pkg); q != "" {
+			name = q + "." + name
+		}
+		outs := make([]string, len(p.Out))
+		for i, t := range p.Out {
+			outs[i] = typeString(t)
+		}
+		typ := outs[0]
+		if len(outs) > 1 {
+			typ = "(" + strings.Join(outs, ", ") + ")"
+		}
+		var doc string
+		if obj := p.Pkg.Scope().Lookup(p.Name); obj != nil {
+			doc = oc.declDoc(obj).Text()
+		}
+		info.Providers = append(info.Providers, ProviderInfo{
+			Name:       name,
+			Type:       typ,
+			Pos:        pos(p.Pos),
+			Doc:        doc,
+			Deprecated: p.Deprecated,
+		})
+	}
+	for _, b := range set.Bindings {
+		info.Bindings = append(info.Bindings, BindingInfo{
+			Iface:    typeString(b.Iface),
+			Provided: typeString(b.Provided),
+			Pos:      pos(b.Pos),
+		})
+	}
+	for _, v := range set.Values {
+		info.Values = append(info.Values, ValueInfo{
+			Type: typeString(v.Out),
+			Expr: types.ExprString(v.expr),
+			Pos:  pos(v.Pos),
+		})
+	}
+	for _, imp := range set.Imports {
+		info.Sets = append(info.Sets, ProviderSetRef{
+			PkgPath: imp.PkgPath,
+			VarName: imp.VarName,
+			Pos:     pos(imp.Pos),
+		})
+	}
+	return info
+}
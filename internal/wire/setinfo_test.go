@@ -0,0 +1,134 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"context"
+	"encoding/json"
+	"go/token"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseProviderSet(t *testing.T) {
+	wd, env := materialize(t, map[string]string{
+		"example.com/db/db.go": `package db
+
+import "github.com/google/wire"
+
+type Pool struct{}
+
+// NewPool opens a pool.
+func NewPool() *Pool { return new(Pool) }
+
+var Set = wire.NewSet(NewPool)
+`,
+		"example.com/foo/foo.go": `package foo
+
+import (
+	"example.com/db"
+	"github.com/google/wire"
+)
+
+type Store interface{ Get() string }
+
+type store struct{}
+
+func (store) Get() string { return "" }
+
+// NewStore returns a store backed by pool.
+//
+// Deprecated: Use a Repo.
+func NewStore(pool *db.Pool) store { return store{} }
+
+type Name string
+
+// Set provides a Store.
+var Set = wire.NewSet(
+	db.Set,
+	NewStore,
+	wire.Bind(new(Store), new(store)),
+	wire.Value(Name("foo")),
+)
+`,
+	})
+	foo := filepath.Join(wd, "foo", "foo.go")
+	dbFile := filepath.Join(wd, "db", "db.go")
+
+	info, errs := ParseProviderSet(context.Background(), wd, env, "example.com/foo", "Set")
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	want := &ProviderSetInfo{
+		PkgPath: "example.com/foo",
+		VarName: "Set",
+		Pos:     foo + ":22:11",
+		Doc:     "Set provides a Store.\n",
+		Providers: []ProviderInfo{{
+			Name:       "NewStore",
+			Type:       "store",
+			Pos:        foo + ":17:6",
+			Doc:        "NewStore returns a store backed by pool.\n\nDeprecated: Use a Repo.\n",
+			Deprecated: "Use a Repo.",
+		}},
+		Bindings: []BindingInfo{{Iface: "Store", Provided: "store", Pos: foo + ":25:2"}},
+		Values:   []ValueInfo{{Type: "Name", Expr: `Name("foo")`, Pos: foo + ":26:13"}},
+		Sets:     []ProviderSetRef{{PkgPath: "example.com/db", VarName: "Set", Pos: dbFile + ":10:11"}},
+	}
+	if diff := cmp.Diff(want, info); diff != "" {
+		t.Errorf("ParseProviderSet (-want +got):\n%s", diff)
+	}
+	if _, err := json.Marshal(info); err != nil {
+		t.Errorf("json.Marshal: %v", err)
+	}
+
+	again, errs := ParseProviderSet(context.Background(), wd, env, "example.com/foo", "Set")
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if again != info {
+		t.Error("second ParseProviderSet did not use the cached description")
+	}
+
+	// A later load has a FileSet of its own, but the cached set still
+	// places its providers, and those of the sets it includes.
+	pkgs, errs := load(context.Background(), wd, env, defaultBuildTag, "", nil, []string{"example.com/foo"})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	key := ProviderSetKeyOf(pkgs[0].Fset, pkgs[0].Types.Scope().Lookup("Set"))
+	set, ok := GetGlobalCache().GetCachedSet(key, []string{foo, dbFile})
+	if !ok {
+		t.Fatalf("GetCachedSet(%+v) missed", key)
+	}
+	for _, p := range []struct {
+		pos  token.Pos
+		want string
+	}{
+		{set.Pos, foo + ":22:11"},
+		{set.Providers[0].Pos, foo + ":17:6"},
+		{set.Imports[0].Providers[0].Pos, dbFile + ":8:6"},
+	} {
+		if got := set.Position(p.pos).String(); got != p.want {
+			t.Errorf("Position(%d) = %s; want %s", p.pos, got, p.want)
+		}
+	}
+
+	if _, errs := ParseProviderSet(context.Background(), wd, env, "example.com/foo", "Name"); len(errs) != 1 {
+		t.Errorf("ParseProviderSet of a type returned errors %v; want one", errs)
+	}
+}
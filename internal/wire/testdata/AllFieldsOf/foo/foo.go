@@ -0,0 +1,72 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/google/wire"
+)
+
+type DBConfig struct {
+	DSN string
+}
+
+type CacheConfig struct {
+	Size int
+}
+
+type DebugConfig struct {
+	Verbose bool
+}
+
+type Port int
+
+type Config struct {
+	Name  string
+	Port  Port
+	DB    DBConfig
+	Cache *CacheConfig
+	Debug DebugConfig
+	Tags  []string `wire:"-"`
+
+	secret DBConfig
+}
+
+func provideConfig() *Config {
+	return &Config{
+		Name:  "app",
+		Port:  8080,
+		DB:    DBConfig{DSN: "postgres://db"},
+		Cache: &CacheConfig{Size: 64},
+	}
+}
+
+type App struct {
+	Port  Port
+	DB    DBConfig
+	Cache *CacheConfig
+}
+
+var Set = wire.NewSet(
+	provideConfig,
+	wire.AllFieldsOf(new(*Config), "Debug"),
+	wire.Struct(new(App), "*"),
+)
+
+func main() {
+	app := injectApp()
+	fmt.Println(app.Port, app.DB.DSN, app.Cache.Size)
+}
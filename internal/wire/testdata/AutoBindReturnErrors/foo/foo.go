@@ -0,0 +1,47 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/google/wire"
+)
+
+func main() {
+	fmt.Println(injectStore().Get())
+}
+
+type Store interface {
+	Get() string
+}
+
+type Cache interface {
+	Put(string)
+}
+
+type MemStore struct{}
+
+func (*MemStore) Get() string { return "mem" }
+
+type FileStore struct{}
+
+func (*FileStore) Get() string { return "file" }
+
+func NewMemStore() *MemStore { return &MemStore{} }
+
+func NewFileStore() *FileStore { return &FileStore{} }
+
+var Set = wire.NewSet(NewMemStore, NewFileStore)
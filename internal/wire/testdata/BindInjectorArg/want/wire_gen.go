@@ -1,5 +1,8 @@
 // Code generated by Wire. DO NOT EDIT.
 
+//wire:version (devel)
+//wire:options 31d965d7a96e6a35c79402054943587fb0c5182de1236dc2adee545917edbb3d
+//wire:checksum 2187c48ceb02d65a298b1413ded671ab4fe62d8599ce8383ba7460324bf5029a
 //go:generate go run -mod=mod github.com/google/wire/cmd/wire
 //go:build !wireinject
 // +build !wireinject
@@ -1,5 +1,8 @@
 // Code generated by Wire. DO NOT EDIT.
 
+//wire:version (devel)
+//wire:options 31d965d7a96e6a35c79402054943587fb0c5182de1236dc2adee545917edbb3d
+//wire:checksum d0df08f062bb688374c96b39a90de54e7499cbd38fa4c53741cccf81883bab4a
 //go:generate go run -mod=mod github.com/google/wire/cmd/wire
 //go:build !wireinject
 // +build !wireinject
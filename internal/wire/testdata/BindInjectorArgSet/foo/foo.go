@@ -0,0 +1,50 @@
+// Copyright 2019 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/google/wire"
+)
+
+func main() {
+	fmt.Println(inject(&Foo{"hello"}).Name)
+	fmt.Println(injectNested(&Foo{"world"}).Name)
+}
+
+type Fooer interface {
+	Foo() string
+}
+
+type Foo struct {
+	f string
+}
+
+func (f *Foo) Foo() string {
+	return f.f
+}
+
+type Bar struct {
+	Name string
+}
+
+func NewBar(fooer Fooer) *Bar {
+	return &Bar{Name: fooer.Foo()}
+}
+
+// Set binds Fooer to *Foo without providing *Foo: injectors that use Set
+// must take a *Foo argument.
+var Set = wire.NewSet(NewBar, wire.Bind(new(Fooer), new(*Foo)))
@@ -1,5 +1,8 @@
 // Code generated by Wire. DO NOT EDIT.
 
+//wire:version (devel)
+//wire:options 31d965d7a96e6a35c79402054943587fb0c5182de1236dc2adee545917edbb3d
+//wire:checksum d8239aa68ea96a311d4dd27ef1f307c510af9a87d4a1cc19895f041654233986
 //go:generate go run -mod=mod github.com/google/wire/cmd/wire
 //go:build !wireinject
 // +build !wireinject
@@ -1,5 +1,8 @@
 // Code generated by Wire. DO NOT EDIT.
 
+//wire:version (devel)
+//wire:options 31d965d7a96e6a35c79402054943587fb0c5182de1236dc2adee545917edbb3d
+//wire:checksum acca8f2709597401e023b6870e7f443468bf72b405d828181c106b6c899093c1
 //go:generate go run -mod=mod github.com/google/wire/cmd/wire
 //go:build !wireinject
 // +build !wireinject
@@ -1,5 +1,8 @@
 // Code generated by Wire. DO NOT EDIT.
 
+//wire:version (devel)
+//wire:options 31d965d7a96e6a35c79402054943587fb0c5182de1236dc2adee545917edbb3d
+//wire:checksum 522ee57cc9accc74f79a39b17bc2b4e0a1b7a419f45e0199b9b5eb771a550b7a
 //go:generate go run -mod=mod github.com/google/wire/cmd/wire
 //go:build !wireinject
 // +build !wireinject
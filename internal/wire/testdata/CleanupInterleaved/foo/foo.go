@@ -0,0 +1,103 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// cleaned lists the cleanup functions that ran, in order.
+var cleaned []string
+
+func main() {
+	// Fail 0 succeeds; fail n makes the nth provider that can fail fail.
+	for fail := Fail(0); fail <= 4; fail++ {
+		cleaned = nil
+		_, cleanup, err := injectApp(fail)
+		fmt.Printf("fail %d: err %v, cleaned [%s]\n", fail, err, strings.Join(cleaned, " "))
+		if err != nil {
+			continue
+		}
+		cleanup()
+		fmt.Printf("fail %d: after cleanup, cleaned [%s]\n", fail, strings.Join(cleaned, " "))
+	}
+}
+
+type (
+	Fail int
+	A    int
+	B    int
+	C    int
+	D    int
+	E    int
+	F    int
+	G    int
+)
+
+type App struct {
+	A A
+	B B
+	C C
+	D D
+	E E
+	F F
+	G G
+}
+
+func cleanupFor(name string) func() {
+	return func() { cleaned = append(cleaned, name) }
+}
+
+func provideA() (A, func()) {
+	return 1, cleanupFor("a")
+}
+
+func provideB(a A, fail Fail) (B, error) {
+	if fail == 1 {
+		return 0, errors.New("b failed")
+	}
+	return 2, nil
+}
+
+func provideC(b B) (C, func()) {
+	return 3, cleanupFor("c")
+}
+
+func provideD(c C, fail Fail) (D, error) {
+	if fail == 2 {
+		return 0, errors.New("d failed")
+	}
+	return 4, nil
+}
+
+func provideE(d D, fail Fail) (E, func(), error) {
+	if fail == 3 {
+		return 0, nil, errors.New("e failed")
+	}
+	return 5, cleanupFor("e"), nil
+}
+
+func provideF(e E) (F, func()) {
+	return 6, cleanupFor("f")
+}
+
+func provideG(f F, fail Fail) (G, error) {
+	if fail == 4 {
+		return 0, errors.New("g failed")
+	}
+	return 7, nil
+}
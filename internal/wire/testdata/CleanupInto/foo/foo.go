@@ -0,0 +1,83 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"example.com/lifecycle"
+)
+
+func main() {
+	reg := lifecycle.New("arg")
+	baz, err := injectBaz(reg, false)
+	fmt.Println(baz, err)
+	reg.Close()
+
+	reg = lifecycle.New("failed")
+	_, err = injectBaz(reg, true)
+	fmt.Println(err)
+	reg.Close()
+
+	app := injectApp()
+	fmt.Println(*app.foo)
+	app.reg.Close()
+}
+
+type (
+	Foo  int
+	Bar  int
+	Baz  int
+	Fail bool
+	Name string
+)
+
+type App struct {
+	reg *lifecycle.Registry
+	foo *Foo
+}
+
+func provideFoo() (*Foo, func()) {
+	foo := new(Foo)
+	*foo = 1
+	return foo, func() { fmt.Println("cleanup foo") }
+}
+
+func provideBar(foo *Foo, fail Fail) (*Bar, func(), error) {
+	if fail {
+		return nil, nil, errors.New("bar failed")
+	}
+	bar := new(Bar)
+	*bar = Bar(*foo) + 1
+	return bar, func() { fmt.Println("cleanup bar") }, nil
+}
+
+func provideBaz(bar *Bar) (Baz, error) {
+	return Baz(*bar) + 1, nil
+}
+
+// provideName returns a cleanup function before the registry exists.
+func provideName() (Name, func()) {
+	return "provided", func() { fmt.Println("cleanup name") }
+}
+
+func provideRegistry(name Name) *lifecycle.Registry {
+	return lifecycle.New(string(name))
+}
+
+func provideApp(reg *lifecycle.Registry, foo *Foo) App {
+	return App{reg: reg, foo: foo}
+}
@@ -0,0 +1,46 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//go:build wireinject
+// +build wireinject
+
+package main
+
+import (
+	"net/http"
+
+	"github.com/google/wire"
+)
+
+// InitializeServer builds the HTTP server for the application.
+//
+// The server listens on port 8080. Start it like this:
+//
+//	srv := InitializeServer()
+//	log.Fatal(srv.ListenAndServe())
+//
+// See [http.Server] for the other settings.
+func InitializeServer() *http.Server {
+	panic(wire.Build(Set))
+}
+
+func newServer() *http.Server {
+	panic(wire.Build(Set))
+}
+
+// InitializeHandler returns the handler of the application.
+//
+//wire:autobind
+func InitializeHandler() http.Handler {
+	panic(wire.Build(Set))
+}
@@ -41,8 +41,8 @@ func newBazService(*baz.Config) *baz.Service {
 
 func main() {
 	cfg := &baz.Config{
-		Foo: &foo.Config{1},
-		Bar: &bar.Config{2},
+		Foo: &foo.Config{V: 1},
+		Bar: &bar.Config{V: 2},
 	}
 	svc := newBazService(cfg)
 	fmt.Println(svc.String())
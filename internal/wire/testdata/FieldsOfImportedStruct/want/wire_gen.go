@@ -1,5 +1,8 @@
 // Code generated by Wire. DO NOT EDIT.
 
+//wire:version (devel)
+//wire:options 31d965d7a96e6a35c79402054943587fb0c5182de1236dc2adee545917edbb3d
+//wire:checksum 7815cd5b3d813d797151fe4602cfb759ed619318e1e01a1a1e03a57a3c6129b1
 //go:generate go run -mod=mod github.com/google/wire/cmd/wire
 //go:build !wireinject
 // +build !wireinject
@@ -17,12 +20,12 @@ import (
 
 func newBazService(config *baz.Config) *baz.Service {
 	fooConfig := config.Foo
-	service := foo.New(fooConfig)
+	fooService := foo.New(fooConfig)
 	barConfig := config.Bar
-	barService := bar.New(barConfig, service)
+	service := bar.New(barConfig, fooService)
 	bazService := &baz.Service{
-		Foo: service,
-		Bar: barService,
+		Foo: fooService,
+		Bar: service,
 	}
 	return bazService
 }
@@ -31,8 +34,8 @@ func newBazService(config *baz.Config) *baz.Service {
 
 func main() {
 	cfg := &baz.Config{
-		Foo: &foo.Config{1},
-		Bar: &bar.Config{2},
+		Foo: &foo.Config{V: 1},
+		Bar: &bar.Config{V: 2},
 	}
 	svc := newBazService(cfg)
 	fmt.Println(svc.String())
@@ -0,0 +1,41 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "fmt"
+
+func main() {
+	cfg := injectConfig()
+	app := injectApp()
+	fmt.Println(cfg.DB.Name, app.DB.Name)
+}
+
+type DB struct{ Name string }
+
+type Config struct {
+	DB *DB
+}
+
+type App struct {
+	DB *DB
+}
+
+func provideConfig() *Config {
+	return &Config{DB: &DB{Name: "primary"}}
+}
+
+func provideApp(db *DB) *App {
+	return &App{DB: db}
+}
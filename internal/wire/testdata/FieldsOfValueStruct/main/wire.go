@@ -29,8 +29,8 @@ func newBazService() *baz.Service {
 	wire.Build(
 		wire.Struct(new(baz.Service), "*"),
 		wire.Value(&baz.Config{
-			Foo: &foo.Config{1},
-			Bar: &bar.Config{2},
+			Foo: &foo.Config{V: 1},
+			Bar: &bar.Config{V: 2},
 		}),
 		wire.FieldsOf(
 			new(*baz.Config),
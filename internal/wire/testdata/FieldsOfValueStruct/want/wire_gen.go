@@ -1,5 +1,8 @@
 // Code generated by Wire. DO NOT EDIT.
 
+//wire:version (devel)
+//wire:options 31d965d7a96e6a35c79402054943587fb0c5182de1236dc2adee545917edbb3d
+//wire:checksum e356cc41db8949864178e48863ef25bbbe736b351e0f8631a4f7e108421554cf
 //go:generate go run -mod=mod github.com/google/wire/cmd/wire
 //go:build !wireinject
 // +build !wireinject
@@ -16,22 +19,22 @@ import (
 // Injectors from wire.go:
 
 func newBazService() *baz.Service {
-	config := _wireConfigValue
-	fooConfig := config.Foo
-	service := foo.New(fooConfig)
-	barConfig := config.Bar
-	barService := bar.New(barConfig, service)
+	bazConfig := _wireConfigValue
+	fooConfig := bazConfig.Foo
+	fooService := foo.New(fooConfig)
+	config := bazConfig.Bar
+	service := bar.New(config, fooService)
 	bazService := &baz.Service{
-		Foo: service,
-		Bar: barService,
+		Foo: fooService,
+		Bar: service,
 	}
 	return bazService
 }
 
 var (
 	_wireConfigValue = &baz.Config{
-		Foo: &foo.Config{1},
-		Bar: &bar.Config{2},
+		Foo: &foo.Config{V: 1},
+		Bar: &bar.Config{V: 2},
 	}
 )
 
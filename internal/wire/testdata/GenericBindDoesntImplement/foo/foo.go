@@ -0,0 +1,51 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/google/wire"
+)
+
+func main() {
+	s := injectSorters()
+	fmt.Println(s.Names.Less("a", "b"), s.IDs.Less(1, 2))
+}
+
+type Sorter[T any] interface {
+	Less(a, b T) bool
+}
+
+// ByKey is generic, but its method does not use the type parameter, so
+// only some instantiations of it are Sorters.
+type ByKey[T any] struct{}
+
+func (*ByKey[T]) Less(a, b string) bool { return a < b }
+
+func NewByKey[T any]() *ByKey[T] { return new(ByKey[T]) }
+
+type Sorters struct {
+	Names Sorter[string]
+	IDs   Sorter[int]
+}
+
+var Set = wire.NewSet(
+	NewByKey[string],
+	wire.Bind(new(Sorter[string]), new(*ByKey[string])),
+	NewByKey[int],
+	wire.Bind(new(Sorter[int]), new(*ByKey[int])),
+	wire.Struct(new(Sorters), "*"),
+)
@@ -0,0 +1,93 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/google/wire"
+)
+
+func main() {
+	app := injectApp()
+	fmt.Println(app.Strings.Cache.name)
+	fmt.Println(app.Ints.Store.(*Cache[int]).name)
+	fmt.Println(app.Pair.Key, app.Pair.Val)
+}
+
+type Cache[T any] struct {
+	name string
+}
+
+func NewCache[T any]() *Cache[T] {
+	var zero T
+	return &Cache[T]{name: fmt.Sprintf("cache of %T", zero)}
+}
+
+func (c *Cache[T]) Put(T) {}
+
+type Store[T any] interface {
+	Put(T)
+}
+
+type StringUser struct {
+	Cache *Cache[string]
+}
+
+func NewStringUser(c *Cache[string]) StringUser {
+	return StringUser{Cache: c}
+}
+
+type IntUser struct {
+	Store Store[int]
+}
+
+func NewIntUser(s Store[int]) IntUser {
+	return IntUser{Store: s}
+}
+
+type Pair[K comparable, V any] struct {
+	Key K
+	Val V
+}
+
+type Key string
+
+func provideKey() Key { return "answer" }
+
+func provideVal() int { return 42 }
+
+type App struct {
+	Strings StringUser
+	Ints    IntUser
+	Pair    Pair[Key, int]
+}
+
+var (
+	newStringCache = NewCache[string]
+	newIntCache    = NewCache[int]
+)
+
+var Set = wire.NewSet(
+	newStringCache,
+	newIntCache,
+	wire.Bind(new(Store[int]), new(*Cache[int])),
+	NewStringUser,
+	NewIntUser,
+	provideKey,
+	provideVal,
+	wire.Struct(new(Pair[Key, int]), "*"),
+	wire.Struct(new(App), "*"),
+)
@@ -0,0 +1,39 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/google/wire"
+)
+
+func main() {}
+
+type Cache[T any] struct{}
+
+func NewCache[T any]() *Cache[T] {
+	return &Cache[T]{}
+}
+
+type App struct {
+	Strings *Cache[string]
+	Ints    *Cache[int]
+}
+
+func NewApp(s *Cache[string], i *Cache[int]) App {
+	return App{Strings: s, Ints: i}
+}
+
+// Set only provides the string instantiation of NewCache.
+var Set = wire.NewSet(NewCache[string], NewApp)
@@ -2,6 +2,9 @@
 //
 // Code generated by Wire. DO NOT EDIT.
 
+//wire:version (devel)
+//wire:options 50709dc6ffbfc9cc6d924dedfc1bc02437933f80f12e30f72458ce806247a953
+//wire:checksum e5cc4db4c0b36d6668c481ce3c38d73bcb55656214a1409b91fa869af2f9abdb
 //go:generate go run -mod=mod github.com/google/wire/cmd/wire
 //go:build !wireinject
 // +build !wireinject
@@ -0,0 +1,44 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+func main() {
+	for _, inject := range []func() (*Bar, error){injectPanic, injectBlank, injectBare} {
+		bar, err := inject()
+		fmt.Println(bar.Foo, err)
+	}
+}
+
+type Foo int
+
+type Bar struct {
+	Foo Foo
+}
+
+func provideFoo() Foo {
+	return 42
+}
+
+func provideBar(foo Foo) (*Bar, error) {
+	if foo == 0 {
+		return nil, errors.New("no foo")
+	}
+	return &Bar{Foo: foo}, nil
+}
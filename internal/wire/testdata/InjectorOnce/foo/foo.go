@@ -0,0 +1,68 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	fooBuilt, barBuilt int32
+	fooCleaned         int
+)
+
+func main() {
+	// Concurrent first calls wait for the one that runs the providers.
+	var wg sync.WaitGroup
+	foos := make([]*Foo, 10)
+	cleanups := make([]func(), 10)
+	for i := range foos {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			foos[i], cleanups[i] = injectFoo()
+		}(i)
+	}
+	wg.Wait()
+	same := true
+	for i := range foos {
+		same = same && foos[i] == foos[0] && fmt.Sprint(cleanups[i]) == fmt.Sprint(cleanups[0])
+	}
+	fmt.Println(*foos[0], fooBuilt, same)
+	cleanups[0]()
+	fmt.Println(fooCleaned)
+
+	// The error is memoized too.
+	_, err1 := injectBar()
+	_, err2 := injectBar()
+	fmt.Println(err1, err1 == err2, barBuilt)
+}
+
+type Foo int
+type Bar int
+
+func provideFoo() (*Foo, func()) {
+	atomic.AddInt32(&fooBuilt, 1)
+	foo := Foo(42)
+	return &foo, func() { fooCleaned++ }
+}
+
+func provideBar() (Bar, error) {
+	atomic.AddInt32(&barBuilt, 1)
+	return 0, errors.New("bar failed")
+}
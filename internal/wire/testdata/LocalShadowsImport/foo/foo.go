@@ -0,0 +1,56 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+func main() {
+	s, err := injectServer()
+	if err != nil {
+		fmt.Println("ERROR:", err)
+		return
+	}
+	fmt.Println(s.HTTP.Client == http.DefaultClient, s.Mux != nil, s.Error)
+}
+
+// HTTP and Error are named so that their local variables would be http and
+// error, the names of an import and a predeclared type that the injector
+// uses after declaring them.
+type HTTP struct {
+	Client *http.Client
+}
+
+type Error string
+
+type Server struct {
+	HTTP  *HTTP
+	Mux   *http.ServeMux
+	Error Error
+}
+
+func provideHTTP(client *http.Client) *HTTP {
+	return &HTTP{Client: client}
+}
+
+func provideError() Error {
+	return "none"
+}
+
+func provideServer(h *HTTP, e Error, mux *http.ServeMux) (*Server, error) {
+	return &Server{HTTP: h, Mux: mux, Error: e}, nil
+}
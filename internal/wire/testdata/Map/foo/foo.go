@@ -0,0 +1,93 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/wire"
+)
+
+func main() {
+	r, err := injectRegistry("v1")
+	if err != nil {
+		fmt.Println("error:", err)
+		return
+	}
+	fmt.Println(r)
+	fmt.Println(injectReport())
+}
+
+type Codec interface {
+	Name() string
+}
+
+type codec string
+
+func (c codec) Name() string { return string(c) }
+
+type Prefix string
+
+type Registry struct {
+	codecs map[string]Codec
+}
+
+func (r *Registry) String() string {
+	var names []string
+	for k, c := range r.codecs {
+		names = append(names, k+"="+c.Name())
+	}
+	sort.Strings(names)
+	return strings.Join(names, " ")
+}
+
+func newJSONCodec() Codec {
+	return codec("json")
+}
+
+func newXMLCodec() (Codec, error) {
+	return codec("xml"), nil
+}
+
+func newGobCodec(p Prefix) Codec {
+	return codec(string(p) + "/gob")
+}
+
+func newRegistry(codecs map[string]Codec) *Registry {
+	return &Registry{codecs: codecs}
+}
+
+var XMLSet = wire.NewSet(wire.Key("xml", newXMLCodec))
+
+var CodecSet = wire.NewSet(
+	wire.Key("json", newJSONCodec),
+	XMLSet,
+	wire.Map(new(map[string]Codec)))
+
+type Format string
+
+type Formats map[string]Format
+
+type Report string
+
+func provideFormat() Format {
+	return "text"
+}
+
+func provideReport(f Format, fs Formats) Report {
+	return Report(fmt.Sprintf("%s of %d", f, len(fs)))
+}
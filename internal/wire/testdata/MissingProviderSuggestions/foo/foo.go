@@ -0,0 +1,42 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"example.com/db"
+	"github.com/google/wire"
+)
+
+func main() {}
+
+type Logger struct{}
+
+type App struct{}
+
+func NewApp(conn *db.Connection, logger *Logger) App { return App{} }
+
+type (
+	Handle      struct{}
+	Handled     struct{}
+	Handler     struct{}
+	HandlerFunc struct{}
+	Handlers    struct{}
+)
+
+func provideHandlers() (Handle, Handled, HandlerFunc, Handlers) {
+	return Handle{}, Handled{}, HandlerFunc{}, Handlers{}
+}
+
+var Set = wire.NewSet(db.Set, NewApp, provideHandlers)
@@ -0,0 +1,66 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+)
+
+func main() {
+	app, cleanup, err := injectApp(Config{Name: "primary"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(app.Client.Name, app.Cache.Size)
+	cleanup()
+
+	cache, cleanup, err := injectCache(Config{Name: "cache only"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(cache.Size)
+	cleanup()
+}
+
+type Config struct {
+	Name string
+}
+
+type Client struct {
+	Name string
+}
+
+type Cache struct {
+	Size int
+}
+
+type App struct {
+	Client *Client
+	Cache  *Cache
+}
+
+// NewClientAndCache constructs a client and the cache that it shares.
+func NewClientAndCache(cfg Config) (*Client, *Cache, func(), error) {
+	client := &Client{Name: cfg.Name}
+	return client, &Cache{Size: len(cfg.Name)}, func() {
+		fmt.Println("closing", client.Name)
+	}, nil
+}
+
+func NewApp(client *Client, cache *Cache) *App {
+	return &App{Client: client, Cache: cache}
+}
@@ -59,9 +59,9 @@ func newMainService(MainConfig) *MainService {
 
 func main() {
 	cfg := MainConfig{
-		Foo: &foo.Config{1},
-		Bar: &bar.Config{2},
-		baz: &baz.Config{3},
+		Foo: &foo.Config{V: 1},
+		Bar: &bar.Config{V: 2},
+		baz: &baz.Config{V: 3},
 	}
 	svc := newMainService(cfg)
 	fmt.Println(svc.String())
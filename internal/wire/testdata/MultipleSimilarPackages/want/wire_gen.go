@@ -1,5 +1,8 @@
 // Code generated by Wire. DO NOT EDIT.
 
+//wire:version (devel)
+//wire:options 31d965d7a96e6a35c79402054943587fb0c5182de1236dc2adee545917edbb3d
+//wire:checksum b60142198bf73a58d66ced66031ff5b05ec916e26ab0a024d4b185d77c18ab6d
 //go:generate go run -mod=mod github.com/google/wire/cmd/wire
 //go:build !wireinject
 // +build !wireinject
@@ -16,15 +19,15 @@ import (
 // Injectors from wire.go:
 
 func newMainService(mainConfig MainConfig) *MainService {
-	config := mainConfig.Foo
-	service := foo.New(config)
-	barConfig := mainConfig.Bar
-	barService := bar.New(barConfig, service)
+	fooConfig := mainConfig.Foo
+	fooService := foo.New(fooConfig)
+	config := mainConfig.Bar
+	service := bar.New(config, fooService)
 	bazConfig := mainConfig.baz
-	bazService := baz.New(bazConfig, barService)
+	bazService := baz.New(bazConfig, service)
 	mainService := &MainService{
-		Foo: service,
-		Bar: barService,
+		Foo: fooService,
+		Bar: service,
 		baz: bazService,
 	}
 	return mainService
@@ -50,9 +53,9 @@ func (m *MainService) String() string {
 
 func main() {
 	cfg := MainConfig{
-		Foo: &foo.Config{1},
-		Bar: &bar.Config{2},
-		baz: &baz.Config{3},
+		Foo: &foo.Config{V: 1},
+		Bar: &bar.Config{V: 2},
+		baz: &baz.Config{V: 3},
 	}
 	svc := newMainService(cfg)
 	fmt.Println(svc.String())
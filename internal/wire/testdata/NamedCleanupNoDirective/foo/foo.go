@@ -0,0 +1,65 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+func main() {
+	baz, cleanups, err := injectBaz(false)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(baz, cleanups.Names())
+	cleanups.Close()
+	cleanups.Close()
+
+	_, cleanups, _ = injectBaz(false)
+	fmt.Println(cleanups.Skip("*main.Foo"))
+	cleanups.Func("*main.Bar")()
+	cleanups.Close()
+
+	_, cleanups, err = injectBaz(true)
+	fmt.Println(err, cleanups == nil)
+}
+
+type (
+	Foo  int
+	Bar  int
+	Baz  int
+	Fail bool
+)
+
+func provideFoo() (*Foo, func()) {
+	foo := new(Foo)
+	*foo = 1
+	return foo, func() { fmt.Println("cleanup foo") }
+}
+
+func provideBar(foo *Foo, fail Fail) (*Bar, func(), error) {
+	if fail {
+		return nil, nil, errors.New("bar failed")
+	}
+	bar := new(Bar)
+	*bar = Bar(*foo) + 1
+	return bar, func() { fmt.Println("cleanup bar") }, nil
+}
+
+func provideBaz(bar *Bar) (Baz, error) {
+	return Baz(*bar) + 1, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/wire"
+)
+
+func main() {
+	r, err := injectReport()
+	if err != nil {
+		fmt.Println("error:", err)
+		return
+	}
+	fmt.Println(r)
+	var verr *ValidationError
+	fmt.Println(errors.As(provideLastError(&ValidationError{Field: "port"}), &verr), verr.Field)
+}
+
+// ValidationError is a named error type that is an ordinary dependency,
+// not part of the injector's error plumbing.
+type ValidationError struct {
+	Field string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid " + e.Field
+}
+
+// ConfigError is a named error type with a non-interface underlying type.
+type ConfigError string
+
+func (e ConfigError) Error() string {
+	return string(e)
+}
+
+// Err is an alias for the predeclared error type.
+type Err = error
+
+// ErrNotConfigured is a sentinel provided through wire.Value.
+const ErrNotConfigured = ConfigError("not configured")
+
+type Report string
+
+// LastError is a named interface type whose method set matches error.
+type LastError error
+
+// ShutdownError is a named interface type provided through wire.Value.
+type ShutdownError error
+
+// ErrShutdown is a sentinel whose declared type is a named error type.
+var ErrShutdown ShutdownError = errors.New("shutting down")
+
+var Set = wire.NewSet(
+	provideValidationError,
+	wire.Value(ErrNotConfigured),
+	wire.Value(ErrShutdown),
+	provideLastError,
+	provideReport)
+
+func provideValidationError() *ValidationError {
+	return &ValidationError{Field: "name"}
+}
+
+func provideLastError(v *ValidationError) LastError {
+	return v
+}
+
+func provideReport(v *ValidationError, c ConfigError, last LastError, shutdown ShutdownError) (Report, Err) {
+	return Report(fmt.Sprintf("%v; %v; %v; %v", v, c, last, shutdown)), nil
+}
@@ -1,5 +1,8 @@
 // Code generated by Wire. DO NOT EDIT.
 
+//wire:version (devel)
+//wire:options 31d965d7a96e6a35c79402054943587fb0c5182de1236dc2adee545917edbb3d
+//wire:checksum fbf23c9bfc671713c3eb9f094cd2d360f76b079748cd3ed4bc6d0e68cfa306d9
 //go:generate go run -mod=mod github.com/google/wire/cmd/wire
 //go:build !wireinject
 // +build !wireinject
@@ -7,13 +10,13 @@
 package main
 
 import (
-	context2 "context"
+	stdcontext "context"
 )
 
 // Injectors from wire.go:
 
-func inject(context3 context2.Context, err2 struct{}) (context, error) {
-	mainContext, err := provide(context3)
+func inject(context2 stdcontext.Context, err2 struct{}) (context, error) {
+	mainContext, err := provide(context2)
 	if err != nil {
 		return context{}, err
 	}
@@ -1,5 +1,8 @@
 // Code generated by Wire. DO NOT EDIT.
 
+//wire:version (devel)
+//wire:options 31d965d7a96e6a35c79402054943587fb0c5182de1236dc2adee545917edbb3d
+//wire:checksum ff28fe131ed2d012aa94a24baa23a87f356c3b1f0f376530ac0bb998dc4b652a
 //go:generate go run -mod=mod github.com/google/wire/cmd/wire
 //go:build !wireinject
 // +build !wireinject
@@ -7,7 +10,7 @@
 package main
 
 import (
-	context2 "context"
+	stdcontext "context"
 	"fmt"
 	"os"
 	"reflect"
@@ -15,8 +18,8 @@ import (
 
 // Injectors from foo.go:
 
-func inject(context3 context2.Context, err2 struct{}) (context, error) {
-	mainContext, err := Provide(context3)
+func inject(context2 stdcontext.Context, err2 struct{}) (context, error) {
+	mainContext, err := Provide(context2)
 	if err != nil {
 		return context{}, err
 	}
@@ -32,7 +35,7 @@ func main() {
 		fmt.Println("ERROR: context.Provide renamed")
 		os.Exit(1)
 	}
-	c, err := inject(context2.Background(), struct{}{})
+	c, err := inject(stdcontext.Background(), struct{}{})
 	if err != nil {
 		fmt.Println("ERROR:", err)
 		os.Exit(1)
@@ -40,9 +43,9 @@ func main() {
 	fmt.Println(c)
 }
 
-func Provide(context2_2 context2.Context) (context, error) {
-	var context3 = context2.Background()
-	_ = context2_2
+func Provide(context2 stdcontext.Context) (context, error) {
+	var context3 = stdcontext.Background()
+	_ = context2
 	_ = context3
 	return context{}, nil
 }
@@ -0,0 +1,53 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/google/wire"
+)
+
+func main() {
+	fmt.Println(injectMessage())
+}
+
+type Greeting string
+
+type Name string
+
+type Message string
+
+func provideGreeting() Greeting {
+	return "hello"
+}
+
+func provideTestGreeting() Greeting {
+	return "hi"
+}
+
+func provideName() Name {
+	return "world"
+}
+
+func provideMessage(g Greeting, n Name) Message {
+	return Message(string(g) + ", " + string(n))
+}
+
+var BaseSet = wire.NewSet(provideGreeting, provideName, provideMessage)
+
+var AppSet = wire.NewSet(BaseSet)
+
+var GopherSet = wire.NewSet(wire.Value(Name("gopher")))
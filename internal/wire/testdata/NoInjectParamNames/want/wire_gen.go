@@ -1,5 +1,8 @@
 // Code generated by Wire. DO NOT EDIT.
 
+//wire:version (devel)
+//wire:options 31d965d7a96e6a35c79402054943587fb0c5182de1236dc2adee545917edbb3d
+//wire:checksum 7c4a0ded32ca9f1dadfe2bd05f4cd28dae01a1f5666e0cb8e4c59615dc5fdbf6
 //go:generate go run -mod=mod github.com/google/wire/cmd/wire
 //go:build !wireinject
 // +build !wireinject
@@ -7,12 +10,12 @@
 package main
 
 import (
-	context2 "context"
+	stdcontext "context"
 )
 
 // Injectors from wire.go:
 
-func inject(contextContext context2.Context, arg struct{}) (context, error) {
+func inject(contextContext stdcontext.Context, arg struct{}) (context, error) {
 	mainContext, err := provide(contextContext)
 	if err != nil {
 		return context{}, err
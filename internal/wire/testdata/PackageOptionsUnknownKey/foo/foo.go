@@ -0,0 +1,41 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errBork = errors.New("bork!")
+
+func main() {
+	_, err := injectBaz()
+	fmt.Println(err)
+	fmt.Println(errors.Is(err, errBork))
+}
+
+type (
+	Bar int
+	Baz int
+)
+
+func provideBar() (Bar, error) {
+	return 1, nil
+}
+
+func provideBaz(bar Bar) (Baz, error) {
+	return 0, errBork
+}
@@ -0,0 +1,68 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	cleaned  []string
+	panicBar bool
+)
+
+func main() {
+	panicBar = true
+	app, cleanup, err := injectApp(false)
+	fmt.Println(app, err)
+	func() {
+		defer func() { fmt.Println("recovered:", recover()) }()
+		cleanup()
+	}()
+	fmt.Println(strings.Join(cleaned, " "))
+
+	panicBar = false
+	cleaned = nil
+	_, cleanup, err = injectApp(true)
+	fmt.Println(cleanup == nil, err)
+	fmt.Println(strings.Join(cleaned, " "))
+}
+
+type Fail bool
+type Foo int
+type Bar int
+type App int
+
+func provideFoo() (Foo, func()) {
+	return 1, func() { cleaned = append(cleaned, "foo") }
+}
+
+func provideBar(foo Foo) (Bar, func()) {
+	return 2, func() {
+		cleaned = append(cleaned, "bar")
+		if panicBar {
+			panic("bar cleanup failed")
+		}
+	}
+}
+
+func provideApp(bar Bar, fail Fail) (App, func(), error) {
+	if fail {
+		return 0, nil, errors.New("app failed")
+	}
+	return 3, func() { cleaned = append(cleaned, "app") }, nil
+}
@@ -0,0 +1,29 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build wireinject
+
+package main
+
+import (
+	"example.com/alpha"
+	"example.com/zeta"
+	"github.com/google/wire"
+)
+
+func injectApp() *App {
+	// zeta.NewClient is called first, but the local of alpha.Client is
+	// named client, since its package path sorts first.
+	panic(wire.Build(alpha.NewClient, zeta.NewClient, newApp))
+}
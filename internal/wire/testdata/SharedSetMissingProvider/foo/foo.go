@@ -0,0 +1,40 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/google/wire"
+)
+
+func main() {
+	fmt.Println(injectBar())
+}
+
+type Foo int
+type Bar int
+type Baz int
+
+// Set is missing a provider for Foo.
+var Set = wire.NewSet(provideBar, provideBaz)
+
+func provideBar(foo Foo) Bar {
+	return Bar(foo)
+}
+
+func provideBaz(bar Bar) Baz {
+	return Baz(bar)
+}
@@ -0,0 +1,74 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/wire"
+)
+
+func main() {
+	h, err := injectHandler("api")
+	if err != nil {
+		fmt.Println("error:", err)
+		return
+	}
+	fmt.Println(h)
+}
+
+type Middleware interface {
+	Name() string
+}
+
+type named string
+
+func (n named) Name() string { return string(n) }
+
+type Service string
+
+type Tracing struct {
+	Service Service
+}
+
+func (t *Tracing) Name() string { return "tracing:" + string(t.Service) }
+
+type Handler string
+
+func provideLogging() Middleware {
+	return named("logging")
+}
+
+func provideMetrics() (Middleware, error) {
+	return named("metrics"), nil
+}
+
+func provideTracing(s Service) *Tracing {
+	return &Tracing{Service: s}
+}
+
+func provideHandler(ms []Middleware) Handler {
+	names := make([]string, len(ms))
+	for i, m := range ms {
+		names[i] = m.Name()
+	}
+	return Handler(strings.Join(names, " -> "))
+}
+
+var TracingSet = wire.NewSet(provideTracing, wire.Bind(new(Middleware), new(*Tracing)))
+
+// MiddlewareSet collects the middleware of TracingSet, followed by its own.
+var MiddlewareSet = wire.NewSet(provideLogging, TracingSet, wire.Slice(new([]Middleware)))
@@ -20,12 +20,12 @@ import (
 	"github.com/google/wire"
 )
 
-func injectFooBar() FooBar {
+func injectFooBar() *FooBar {
 	wire.Build(Set)
-	return FooBar{}
+	return &FooBar{}
 }
 
-func injectPartFooBar() FooBar {
+func injectPartFooBar() *FooBar {
 	wire.Build(PartSet)
-	return FooBar{}
+	return &FooBar{}
 }
@@ -1,5 +1,8 @@
 // Code generated by Wire. DO NOT EDIT.
 
+//wire:version (devel)
+//wire:options 31d965d7a96e6a35c79402054943587fb0c5182de1236dc2adee545917edbb3d
+//wire:checksum 6c2c21932aa1e91de528628e83c1ccfb6f6ef3bd4e7f152372815a9efb93e2f3
 //go:generate go run -mod=mod github.com/google/wire/cmd/wire
 //go:build !wireinject
 // +build !wireinject
@@ -8,19 +11,19 @@ package main
 
 // Injectors from wire.go:
 
-func injectFooBar() FooBar {
+func injectFooBar() *FooBar {
 	foo := provideFoo()
 	bar := provideBar()
-	fooBar := FooBar{
+	fooBar := &FooBar{
 		Foo: foo,
 		Bar: bar,
 	}
 	return fooBar
 }
 
-func injectPartFooBar() FooBar {
+func injectPartFooBar() *FooBar {
 	foo := provideFoo()
-	fooBar := FooBar{
+	fooBar := &FooBar{
 		Foo: foo,
 	}
 	return fooBar
@@ -0,0 +1,47 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "fmt"
+
+func main() {
+	p := injectPair()
+	fmt.Println(p.First, p.Second)
+	b := injectBox()
+	fmt.Println(b.First, b.Second)
+}
+
+type Box[T any] struct {
+	First  T
+	Second Label
+}
+
+type (
+	Label = string
+	Pair  = Box[int]
+	Boxes = Box[float64]
+)
+
+func provideInt() int {
+	return 42
+}
+
+func provideFloat() float64 {
+	return 0.5
+}
+
+func provideLabel() Label {
+	return "label"
+}
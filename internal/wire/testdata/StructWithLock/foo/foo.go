@@ -0,0 +1,47 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/wire"
+)
+
+func main() {
+	c := injectCounter()
+	fmt.Println(c.N)
+}
+
+type N int
+
+type Counter struct {
+	mu state
+	N  N
+}
+
+type state struct {
+	sync.Mutex
+}
+
+func provideN() N {
+	return 1
+}
+
+var Set = wire.NewSet(
+	wire.Struct(new(Counter), "N"),
+	provideN,
+)
@@ -0,0 +1,53 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"example.com/pgx"
+)
+
+func main() {
+	app := injectApp()
+	fmt.Println(app.Conn.Addr, app.Namer.Name(), app.Timeout, app.Label)
+}
+
+type (
+	Conn     = pgx.Conn
+	Settings = pgx.Config
+	Timeout  = pgx.Timeout
+	Label    = string
+	AppRef   = *App
+)
+
+type Namer interface {
+	Name() string
+}
+
+type App struct {
+	Conn    *pgx.Conn
+	Namer   Namer
+	Timeout Timeout
+	Label   Label
+}
+
+func provideSettings() *Settings {
+	return &Settings{Addr: "db:5432", Timeout: 30}
+}
+
+func NewConn(cfg *pgx.Config) *Conn {
+	return &Conn{Addr: cfg.Addr}
+}
@@ -0,0 +1,42 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"example.com/bar"
+	"github.com/google/wire"
+)
+
+func main() {
+	fmt.Println(injectMessage())
+}
+
+type config struct {
+	Bar bar.Bar
+}
+
+func newFoo() bar.Foo {
+	return 41
+}
+
+func newMessage(c *config) string {
+	return fmt.Sprintf("the answer is %d", c.Bar)
+}
+
+// set uses unexported providers of this package, which injectors in this
+// package can call.
+var set = wire.NewSet(newFoo, bar.NewBar, wire.Struct(new(config), "*"), newMessage)
@@ -0,0 +1,35 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+type Foo int
+
+type Bar struct{ F Foo }
+
+func NewBar(f Foo) Bar { return Bar{F: f} }
+
+var printMu sync.Mutex
+
+func main() {
+	printMu.Lock()
+	defer printMu.Unlock()
+	v, _ := fooValue()
+	fmt.Println(injectA(), injectB(), injectC(), v)
+}
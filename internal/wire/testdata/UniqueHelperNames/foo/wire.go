@@ -0,0 +1,44 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build wireinject
+// +build wireinject
+
+package main
+
+import "github.com/google/wire"
+
+var shared = wire.NewSet(wire.Value(Foo(3)))
+
+// injectA and injectB both name the variable of their wire.Value after Foo.
+func injectA() Foo {
+	panic(wire.Build(wire.Value(Foo(1))))
+}
+
+func injectB() Foo {
+	panic(wire.Build(wire.Value(Foo(2))))
+}
+
+// injectC and fooValue share the variable of the same wire.Value.
+func injectC() Bar {
+	panic(wire.Build(shared, NewBar))
+}
+
+// fooValue's helpers are named after it, as _wireFooValue and
+// _wireFooValueOnce, unless those names are taken.
+//
+//wire:once
+func fooValue() (Bar, error) {
+	panic(wire.Build(shared, NewBar))
+}
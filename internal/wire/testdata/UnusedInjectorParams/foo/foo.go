@@ -0,0 +1,43 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/google/wire"
+)
+
+func main() {
+	fmt.Println(injectDirect(Config{}, "Ada"))
+	fmt.Println(injectTransitive(Config{}, "Ada"))
+	fmt.Println(injectBlank(Config{}, "Ada"))
+}
+
+type Config struct {
+	Port int
+}
+
+type Name string
+
+type Greeting string
+
+// Set provides a Greeting and the fields of Config. The Port field is
+// never needed, so a Config parameter used only through it is unused.
+var Set = wire.NewSet(provideGreeting, wire.FieldsOf(new(Config), "Port"))
+
+func provideGreeting(name Name) Greeting {
+	return Greeting("Hello, " + name)
+}
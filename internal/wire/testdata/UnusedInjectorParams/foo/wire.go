@@ -0,0 +1,38 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build wireinject
+
+//wire:options unused_params_as_error=true
+
+package main
+
+import (
+	"github.com/google/wire"
+)
+
+func injectDirect(cfg Config, name Name) Greeting {
+	wire.Build(provideGreeting)
+	return ""
+}
+
+func injectTransitive(conf Config, name Name) Greeting {
+	wire.Build(Set)
+	return ""
+}
+
+func injectBlank(_ Config, name Name) Greeting {
+	wire.Build(provideGreeting)
+	return ""
+}
@@ -0,0 +1,42 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "fmt"
+
+func main() {
+	// Each call gets its own copy of the value, so changing one does not
+	// change what later calls return.
+	first := injectConfig()
+	first.Name = "changed"
+	first.Tags[0] = "shared"
+	second := injectConfig()
+	fmt.Println(second.Name, second.Tags[0])
+
+	// A pointer value is shared by every call.
+	p := injectPointer()
+	p.Name = "changed"
+	fmt.Println(injectPointer().Name)
+}
+
+type Config struct {
+	Name string
+	// Tags is a slice, so the copies share its elements.
+	Tags []string
+}
+
+type Pointer struct {
+	Name string
+}
@@ -0,0 +1,32 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import "errors"
+
+// ErrDown is returned by NewPool when the database is down.
+var ErrDown = errors.New("database is down")
+
+type Pool struct {
+	Closed bool
+}
+
+func NewPool(down bool) (*Pool, func(), error) {
+	if down {
+		return nil, nil, ErrDown
+	}
+	p := new(Pool)
+	return p, func() { p.Closed = true }, nil
+}
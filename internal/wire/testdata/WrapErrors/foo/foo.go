@@ -0,0 +1,80 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"example.com/db"
+)
+
+var (
+	errFull    = errors.New("cache is full")
+	errNoPort  = errors.New("no port")
+	cachePools []*db.Pool
+)
+
+func main() {
+	_, _, err := injectServer(true, false, 80)
+	fmt.Println(err)
+	fmt.Println(errors.Is(err, db.ErrDown))
+
+	_, _, err = injectServer(false, true, 80)
+	fmt.Println(err)
+	fmt.Println(errors.Is(err, errFull), cachePools[0].Closed)
+
+	_, _, err = injectServer(false, false, 0)
+	fmt.Println(err)
+	fmt.Println(errors.Is(err, errNoPort), cachePools[1].Closed)
+
+	s, cleanup, err := injectServer(false, false, 80)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(s.Port, s.Cache.Pool.Closed)
+	cleanup()
+	fmt.Println(s.Cache.Pool.Closed)
+}
+
+type (
+	Full bool
+	Port int
+)
+
+type Cache struct {
+	Pool *db.Pool
+}
+
+func provideCache(pool *db.Pool, full Full) (*Cache, error) {
+	cachePools = append(cachePools, pool)
+	if full {
+		return nil, errFull
+	}
+	return &Cache{Pool: pool}, nil
+}
+
+type Server struct {
+	Cache *Cache
+	Port  Port
+}
+
+func provideServer(c *Cache, port Port) (Server, error) {
+	if port == 0 {
+		return Server{}, errNoPort
+	}
+	return Server{Cache: c, Port: port}, nil
+}
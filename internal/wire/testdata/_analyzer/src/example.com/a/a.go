@@ -0,0 +1,62 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+package a
+
+import (
+	"github.com/google/wire"
+)
+
+type Foo struct {
+	X int
+}
+
+type Fooer interface {
+	Foo()
+}
+
+func (*Foo) Foo() {}
+
+func (*Foo) Append(func()) {}
+
+func NewFoo() *Foo {
+	return &Foo{}
+}
+
+var fooPtr = new(*Foo)
+
+var Good = wire.NewSet(NewFoo, wire.Bind(new(Fooer), new(*Foo)))
+
+var BadBind = wire.NewSet(NewFoo, wire.Bind(new(Fooer), fooPtr)) // want `argument to wire.Bind must be a new\(T\) expression`
+
+var BadStruct = wire.NewSet(wire.Struct(new(Foo), "Y")) // want `"Y" is not a field of`
+
+var BadStructArg = wire.NewSet(wire.Struct(fooPtr, "X")) // want `first argument to wire.Struct must be a new\(T\) expression`
+
+var foosPtr = new([]*Foo)
+
+var BadSlice = wire.NewSet(NewFoo, wire.Slice(foosPtr)) // want `argument to wire.Slice must be a new\(T\) expression`
+
+var fooMapPtr = new(map[string]*Foo)
+
+var BadMap = wire.NewSet(NewFoo, wire.Map(fooMapPtr)) // want `argument to wire.Map must be a new\(T\) expression`
+
+func Runtime() {
+	_ = wire.NewSet(NewFoo) // want `wire.NewSet called at run time in Runtime`
+}
+
+func injectFoo() *Foo { // want `injector injectFoo is declared in a file that is not constrained to wireinject builds`
+	panic(wire.Build(Good))
+}
@@ -0,0 +1,36 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+//go:build wireinject
+
+package a
+
+import (
+	"github.com/google/wire"
+)
+
+func injectOK() *Foo {
+	panic(wire.Build(NewFoo))
+}
+
+func injectBody() *Foo { // want `injector injectBody: a call to wire.Build indicates that this function is an injector`
+	foo := NewFoo()
+	_ = foo
+	panic(wire.Build(NewFoo))
+}
+
+func injectBadCleanupInto() *Foo {
+	panic(wire.Build(NewFoo, wire.CleanupInto(fooPtr))) // want `argument to wire.CleanupInto must be a new\(T\) expression`
+}
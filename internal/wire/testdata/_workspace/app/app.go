@@ -0,0 +1,35 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"example.com/greet"
+	"github.com/google/wire"
+)
+
+// Set builds a Message from the greet module's set.
+var Set = wire.NewSet(greet.Set, NewMessage)
+
+type Message string
+
+func NewMessage(g greet.Greeting) Message {
+	return Message(g + ", world")
+}
+
+func main() {
+	fmt.Println(injectMessage())
+}
@@ -0,0 +1,8 @@
+module example.com/app
+
+go 1.19
+
+require (
+	example.com/greet v0.1.0
+	github.com/google/wire v0.1.0
+)
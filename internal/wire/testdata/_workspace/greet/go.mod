@@ -0,0 +1,5 @@
+module example.com/greet
+
+go 1.19
+
+require github.com/google/wire v0.1.0
@@ -0,0 +1,35 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.22
+// +build go1.22
+
+package wire
+
+import "go/types"
+
+// unalias returns the type that t denotes if t is an alias, or t itself.
+// From Go 1.22 on, the type checker can represent aliases as types of their
+// own, which type switches must see through.
+func unalias(t types.Type) types.Type {
+	return types.Unalias(t)
+}
+
+// aliasObj returns the declaration of t if t is an alias, or nil.
+func aliasObj(t types.Type) *types.TypeName {
+	if a, ok := t.(*types.Alias); ok {
+		return a.Obj()
+	}
+	return nil
+}
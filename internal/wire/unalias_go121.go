@@ -0,0 +1,31 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !go1.22
+// +build !go1.22
+
+package wire
+
+import "go/types"
+
+// unalias returns t. Before Go 1.22, the type checker always replaces
+// aliases with the types they denote.
+func unalias(t types.Type) types.Type {
+	return t
+}
+
+// aliasObj returns nil, since there are no alias types before Go 1.22.
+func aliasObj(t types.Type) *types.TypeName {
+	return nil
+}
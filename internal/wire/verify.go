@@ -0,0 +1,111 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/types"
+	"io/ioutil"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// verifyOutput type-checks content, the generated file for pkg, together
+// with the package's files that are not injector templates, including those
+// that only buildTag, the tag of injector files, leaves out. It uses the
+// already loaded dependencies of pkg, so nothing is written to disk and the
+// go command is not run.
+func verifyOutput(pkg *packages.Package, buildTag, filename string, content []byte) []error {
+	if pkg == nil {
+		return []error{errors.New("generated code cannot be verified without its loaded package")}
+	}
+	genFile, err := parser.ParseFile(pkg.Fset, filename, content, parser.ParseComments)
+	if err != nil {
+		return []error{fmt.Errorf("generated code does not parse: %v", err)}
+	}
+	files := []*ast.File{genFile}
+	for _, f := range pkg.Syntax {
+		// Injector templates are replaced by the generated file.
+		if !requiresTag(fileConstraint(f), buildTag) {
+			files = append(files, f)
+		}
+	}
+	for _, name := range pkg.IgnoredFiles {
+		// Files that are left out of the injector build, such as wrappers
+		// around injectors renamed with //wire:name, take part in the real
+		// one. Generated files, including earlier output, are replaced.
+		src, err := ioutil.ReadFile(name)
+		if err != nil || name == filename || IsGeneratedFile(src) {
+			continue
+		}
+		f, err := parser.ParseFile(pkg.Fset, name, src, parser.ParseComments)
+		if err != nil {
+			return []error{err}
+		}
+		if excludesTag(fileConstraint(f), buildTag) {
+			files = append(files, f)
+		}
+	}
+
+	imports := make(map[string]*types.Package)
+	seen := make(map[*packages.Package]bool)
+	stk := []*packages.Package{pkg}
+	for len(stk) > 0 {
+		p := stk[len(stk)-1]
+		stk = stk[:len(stk)-1]
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		for path, imp := range p.Imports {
+			if imp.Types != nil {
+				imports[path] = imp.Types
+				imports[imp.PkgPath] = imp.Types
+			}
+			stk = append(stk, imp)
+		}
+	}
+
+	var errs []error
+	conf := &types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			if path == "unsafe" {
+				return types.Unsafe, nil
+			}
+			if p := imports[path]; p != nil {
+				return p, nil
+			}
+			return nil, fmt.Errorf("package %s is not a dependency of %s", path, pkg.PkgPath)
+		}),
+		Sizes: pkg.TypesSizes,
+		Error: func(err error) {
+			errs = append(errs, err)
+		},
+	}
+	conf.Check(pkg.PkgPath, pkg.Fset, files, nil)
+	return mapErrors(errs, func(err error) error {
+		return fmt.Errorf("generated code does not type-check: %v", err)
+	})
+}
+
+// importerFunc implements types.Importer with a function.
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) {
+	return f(path)
+}
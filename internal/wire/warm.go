@@ -0,0 +1,243 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// WarmStats reports what WarmCache did.
+type WarmStats struct {
+	// Packages are the import paths of the packages whose provider sets
+	// were cached, in the order they were warmed.
+	Packages []string
+	// Sets is the number of provider sets that are now in the global
+	// ProviderSetCache, whether or not they were there already.
+	Sets int
+	// Skipped is the number of packages that the budget left no time for.
+	Skipped int
+	// Elapsed is how long WarmCache took.
+	Elapsed time.Duration
+}
+
+// WarmCache prepares the caches that a later run of Generate with the same
+// options draws on, without generating anything, for a CI stage that runs
+// ahead of the real work. It loads the packages matching patterns, which
+// fills the go command's build cache (GenerateOptions.GoCache, if set) with
+// the export data of the standard library packages they import, and then
+// type-checks the packages one by one and puts their exported provider sets
+// in the global ProviderSetCache, where ParseProviderSet finds them.
+//
+// Packages go in order of how many injectors refer to them, as found by
+// scanning the syntax of the injector files of the matching packages, so
+// that the sets most in use are warmed first. Only the matching packages
+// and the packages that injectors refer to are warmed. WarmCache stops
+// when budget runs out, if it is positive, and reports the packages it had
+// no time for in Skipped; running out of budget is not an error. Provider
+// sets that fail to analyze are left out, since Generate reports their
+// errors.
+//
+// wd and env are as for Generate.
+func WarmCache(ctx context.Context, wd string, env []string, patterns []string, opts *GenerateOptions, budget time.Duration) (*WarmStats, []error) {
+	start := time.Now()
+	if opts == nil {
+		opts = &GenerateOptions{}
+	}
+	if errs := opts.validate(); len(errs) > 0 {
+		return nil, errs
+	}
+	warmCtx := ctx
+	if budget > 0 {
+		var cancel context.CancelFunc
+		warmCtx, cancel = context.WithTimeout(ctx, budget)
+		defer cancel()
+	}
+	stats := new(WarmStats)
+	// expired reports whether the budget ran out, and returns the error of
+	// ctx itself if it is done.
+	expired := func() (bool, []error) {
+		if err := ctx.Err(); err != nil {
+			return true, []error{err}
+		}
+		return warmCtx.Err() != nil, nil
+	}
+
+	imports := opts.importCache(warmCtx, wd, env)
+	progress := newLoadProgress(warmCtx)
+	roots, errs := imports.list(patterns, progress)
+	if len(errs) > 0 {
+		if done, errs := expired(); done {
+			stats.Elapsed = time.Since(start)
+			return stats, errs
+		}
+		return nil, errs
+	}
+	if roots, _, errs = imports.filterIgnored(roots); len(errs) > 0 {
+		return nil, errs
+	}
+	if err := imports.findExports(roots); err != nil {
+		if done, errs := expired(); done {
+			stats.Elapsed = time.Since(start)
+			return stats, errs
+		}
+		return nil, []error{err}
+	}
+
+	order := warmOrder(imports, roots, opts)
+	for i, pkg := range order {
+		if done, errs := expired(); done {
+			stats.Skipped = len(order) - i
+			stats.Elapsed = time.Since(start)
+			return stats, errs
+		}
+		if err := imports.check([]*packages.Package{pkg}, progress); err != nil {
+			stats.Skipped = len(order) - i
+			break
+		}
+		if pkg.Types == nil || len(pkg.Errors) > 0 {
+			continue
+		}
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			obj, ok := scope.Lookup(name).(*types.Var)
+			if !ok || !obj.Exported() || !isProviderSetType(obj.Type()) {
+				continue
+			}
+			if _, errs := cacheProviderSet(imports, pkg, obj); len(errs) == 0 {
+				stats.Sets++
+			}
+		}
+		stats.Packages = append(stats.Packages, pkg.PkgPath)
+	}
+	_, errs = expired()
+	stats.Elapsed = time.Since(start)
+	return stats, errs
+}
+
+// warmOrder returns the packages that WarmCache warms: roots and the
+// packages that the injectors of roots refer to in their calls to
+// wire.Build, those referred to by the most injectors first and otherwise
+// in import path order.
+func warmOrder(imports *importCache, roots []*packages.Package, opts *GenerateOptions) []*packages.Package {
+	byPath := make(map[string]*packages.Package)
+	packages.Visit(roots, nil, func(p *packages.Package) {
+		byPath[p.PkgPath] = p
+	})
+	refs := make(map[string]int)
+	fset := token.NewFileSet()
+	for _, pkg := range roots {
+		files := append([]string(nil), pkg.GoFiles...)
+		sort.Strings(files)
+		for _, name := range files {
+			src, err := imports.readFile(name)
+			if err != nil {
+				continue
+			}
+			f, err := parser.ParseFile(fset, name, src, parser.ParseComments|parser.SkipObjectResolution)
+			if err != nil || !IsInjectorFileWithTag(f, opts.buildTag(), opts.Tags) {
+				continue
+			}
+			for path, n := range injectorRefs(pkg, f) {
+				refs[path] += n
+			}
+		}
+	}
+	var order []*packages.Package
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if p := byPath[path]; p != nil && !seen[path] && !isStandard(p) {
+			seen[path] = true
+			order = append(order, p)
+		}
+	}
+	for _, pkg := range roots {
+		add(pkg.PkgPath)
+	}
+	for path := range refs {
+		add(path)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		if ri, rj := refs[order[i].PkgPath], refs[order[j].PkgPath]; ri != rj {
+			return ri > rj
+		}
+		return order[i].PkgPath < order[j].PkgPath
+	})
+	return order
+}
+
+// injectorRefs returns the number of injectors declared in f, a file of
+// pkg, that refer to each package in their calls to wire.Build, by import
+// path, as far as the syntax of f tells: an identifier refers to pkg, and
+// a selector on the name of an import to the imported package.
+func injectorRefs(pkg *packages.Package, f *ast.File) map[string]int {
+	wireName := wireImportName(f)
+	if wireName == "" {
+		return nil
+	}
+	names := make(map[string]string) // import name to path
+	for _, impt := range f.Imports {
+		path, err := strconv.Unquote(impt.Path.Value)
+		if err != nil {
+			continue
+		}
+		switch {
+		case impt.Name != nil:
+			names[impt.Name.Name] = path
+		case pkg.Imports[path] != nil:
+			names[pkg.Imports[path].Name] = path
+		}
+	}
+	// Calls such as wire.NewSet do not refer to a package of provider sets.
+	delete(names, wireName)
+	refs := make(map[string]int)
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || fn.Body == nil {
+			continue
+		}
+		call := findWireBuild(fn.Body, wireName)
+		if call == nil {
+			continue
+		}
+		paths := make(map[string]bool)
+		for _, arg := range call.Args {
+			ast.Inspect(arg, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.SelectorExpr:
+					if x, ok := n.X.(*ast.Ident); ok && names[x.Name] != "" {
+						paths[names[x.Name]] = true
+						return false
+					}
+				case *ast.Ident:
+					paths[pkg.PkgPath] = true
+				}
+				return true
+			})
+		}
+		for path := range paths {
+			refs[path]++
+		}
+	}
+	return refs
+}
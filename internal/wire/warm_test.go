@@ -0,0 +1,121 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWarmCache(t *testing.T) {
+	wd, env := materialize(t, map[string]string{
+		"example.com/config/config.go": `package config
+
+import "github.com/google/wire"
+
+type Config struct{}
+
+func Load() Config { return Config{} }
+
+var Set = wire.NewSet(Load)
+`,
+		"example.com/store/store.go": `package store
+
+import (
+	"example.com/config"
+	"github.com/google/wire"
+)
+
+type Store struct{}
+
+func Open(config.Config) *Store { return new(Store) }
+
+var Set = wire.NewSet(Open)
+
+var unexported = wire.NewSet(Open)
+`,
+		"example.com/foo/foo.go": `package foo
+
+import "example.com/store"
+
+type App struct{}
+
+func NewApp(*store.Store) App { return App{} }
+`,
+		"example.com/foo/wire.go": `//go:build wireinject
+
+package foo
+
+import (
+	"example.com/config"
+	st "example.com/store"
+	"github.com/google/wire"
+)
+
+func injectApp() App {
+	panic(wire.Build(config.Set, st.Set, NewApp))
+}
+
+func injectStore() *st.Store {
+	panic(wire.Build(wire.NewSet(config.Set, st.Set)))
+}
+
+func injectOtherStore() *st.Store {
+	panic(wire.Build(st.Set, config.Load))
+}
+`,
+	})
+	ctx := context.Background()
+
+	t.Run("Budget", func(t *testing.T) {
+		stats, errs := WarmCache(ctx, wd, env, []string{"example.com/foo"}, nil, time.Nanosecond)
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		if len(stats.Packages) > 0 || stats.Sets > 0 {
+			t.Errorf("WarmCache with no budget warmed %v and %d sets; want nothing", stats.Packages, stats.Sets)
+		}
+	})
+	t.Run("Warm", func(t *testing.T) {
+		before, _ := globalCache.Stats()
+		stats, errs := WarmCache(ctx, wd, env, []string{"example.com/foo"}, nil, 0)
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		// All three injectors refer to config and store, whose tie is
+		// broken by path, and only one to foo.
+		want := []string{"example.com/config", "example.com/store", "example.com/foo"}
+		if diff := cmp.Diff(want, stats.Packages); diff != "" {
+			t.Errorf("WarmCache packages (-want +got):\n%s", diff)
+		}
+		if stats.Sets != 2 || stats.Skipped != 0 {
+			t.Errorf("WarmCache warmed %d sets and skipped %d packages; want 2 and 0", stats.Sets, stats.Skipped)
+		}
+		if after, _ := globalCache.Stats(); after != before+2 {
+			t.Errorf("global cache has %d sets after WarmCache; want %d", after, before+2)
+		}
+
+		// The warmed sets are not cached again.
+		if _, errs := ParseProviderSet(ctx, wd, env, "example.com/store", "Set"); len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		if after, _ := globalCache.Stats(); after != before+2 {
+			t.Errorf("global cache has %d sets after ParseProviderSet; want %d", after, before+2)
+		}
+	})
+}
@@ -19,20 +19,27 @@ package wire
 import (
     "bytes"
     "context"
+    "crypto/sha256"
+    "encoding/hex"
     "errors"
     "fmt"
     "go/ast"
+    "go/build/constraint"
     "go/format"
+    "go/parser"
     "go/printer"
     "go/token"
     "go/types"
     "io/ioutil"
+    "os"
+    "path"
     "path/filepath"
     "runtime"
     "sort"
     "strconv"
     "strings"
     "sync"
+    "text/template"
     "unicode"
     "unicode/utf8"
 
@@ -40,6 +47,11 @@ import (
     "golang.org/x/tools/go/packages"
 )
 
+// A Warning is a problem that Wire reports without failing, such as a use of
+// a deprecated provider. It carries a position and the injectors it applies
+// to the same way an error does.
+type Warning = error
+
 // GenerateResult stores the result for a package from a call to Generate.
 type GenerateResult struct {
     // PkgPath is the package's PkgPath.
@@ -52,14 +64,239 @@ type GenerateResult struct {
     Content []byte
     // Errs is a slice of errors identified during generation.
     Errs []error
+    // Warnings is a slice of problems identified during generation that did
+    // not prevent the output from being generated, such as uses of deprecated
+    // providers.
+    Warnings []Warning
+    // Injectors is the number of injector functions found in the package.
+    // A package without any has nothing for Wire to generate, so its Content
+    // is empty even though there are no errors.
+    Injectors int
+    // ContentHash is the hex-encoded SHA-256 hash of Content with any
+    // annotation comments and the Wire version and options fingerprint
+    // removed, so it only changes when the code does.
+    // It is empty if Content is.
+    ContentHash string
+    // OptionsFingerprint is the GenerateOptions.OptionsFingerprint of the
+    // options the package was generated with, after its //wire:options
+    // directives are applied. It is what the header of Content records, so
+    // a generated file is stale if its FileInfo.OptionsFingerprint differs.
+    // It is empty if the package's options could not be determined.
+    OptionsFingerprint string
+    // Ignored reports that the package matched the patterns but was skipped
+    // because of a //wire:ignore directive or a .wireignore file, so it was
+    // not scanned for injectors. Only PkgPath is set.
+    Ignored bool
+    // Benchmarks, if not nil, is the result for the file of benchmarks that
+    // GenerateOptions.EmitBenchmarks asks for, which goes next to the
+    // generated file. Commit and CommitAll write it along with Content.
+    Benchmarks *GenerateResult
+
+    // pkg is the loaded package that Content was generated for.
+    pkg *packages.Package
+    // buildTag is the build tag of the injector files of pkg.
+    buildTag string
+    // force lets Commit overwrite a generated file that was edited by hand.
+    force bool
+}
+
+// ErrNoInjectors is returned by Summary.Err when none of the packages that
+// Wire scanned contain an injector. Wire does not treat this as an error by
+// itself, but it usually means that the patterns name the wrong packages.
+var ErrNoInjectors = errors.New("no injectors found")
+
+// A Summary describes which of the packages scanned by Wire have injectors.
+type Summary struct {
+    // Scanned lists the import paths of the packages scanned for injectors.
+    Scanned []string
+    // WithInjectors lists the import paths of the scanned packages that have
+    // at least one injector.
+    WithInjectors []string
+    // Ignored lists the import paths of the packages that matched the
+    // patterns but were skipped, as reported by GenerateResult.Ignored.
+    Ignored []string
+}
+
+// Summarize returns the Summary of the results of Generate or any of its
+// variants. A package that appears in several results, as with
+// GenerateForPlatforms, is listed once.
+func Summarize(results []GenerateResult) Summary {
+    var s Summary
+    scanned := make(map[string]bool)
+    withInjectors := make(map[string]bool)
+    ignored := make(map[string]bool)
+    for _, r := range results {
+        if r.Ignored {
+            if !ignored[r.PkgPath] {
+                ignored[r.PkgPath] = true
+                s.Ignored = append(s.Ignored, r.PkgPath)
+            }
+            continue
+        }
+        if !scanned[r.PkgPath] {
+            scanned[r.PkgPath] = true
+            s.Scanned = append(s.Scanned, r.PkgPath)
+        }
+        if r.Injectors > 0 && !withInjectors[r.PkgPath] {
+            withInjectors[r.PkgPath] = true
+            s.WithInjectors = append(s.WithInjectors, r.PkgPath)
+        }
+    }
+    return s
+}
+
+// Err returns ErrNoInjectors if no scanned package has an injector, and nil
+// otherwise. Errors from generation are reported in GenerateResult.Errs,
+// not here.
+func (s Summary) Err() error {
+    if len(s.WithInjectors) == 0 {
+        return ErrNoInjectors
+    }
+    return nil
 }
 
-// Commit writes the generated file to disk.
+// Commit writes the generated file to disk, followed by the file of
+// Benchmarks, if any. It does not overwrite an existing file that was not
+// generated by Wire, or, unless the package was generated with
+// GenerateOptions.Force, one that was edited by hand since Wire wrote it. A
+// file that already has the generated content is left alone.
 func (gen GenerateResult) Commit() error {
+    if err := gen.commit(); err != nil {
+        return err
+    }
+    if gen.Benchmarks != nil {
+        return gen.Benchmarks.commit()
+    }
+    return nil
+}
+
+// commit writes the generated file, but not the file of benchmarks.
+func (gen GenerateResult) commit() error {
+    write, _, err := gen.prepareCommit()
+    if !write {
+        return err
+    }
+    return ioutil.WriteFile(gen.OutputPath, gen.Content, 0666)
+}
+
+// prepareCommit reports whether Commit should write the generated file,
+// or else the error, if any, that keeps it from doing so. If the file
+// exists, cur is its current content.
+func (gen GenerateResult) prepareCommit() (write bool, cur []byte, err error) {
+    if len(gen.Content) == 0 {
+        return false, nil, nil
+    }
+    cur, err = ioutil.ReadFile(gen.OutputPath)
+    if err != nil {
+        return true, nil, nil
+    }
+    switch {
+    case !IsGeneratedFile(cur):
+        return false, nil, fmt.Errorf("%s was not generated by Wire; not overwriting it", gen.OutputPath)
+    case bytes.Equal(cur, gen.Content):
+        return false, nil, nil
+    case !gen.force && editedByHand(cur):
+        return false, nil, fmt.Errorf("%s was edited by hand since Wire generated it; not overwriting it. Move the edits into a provider, or generate with Force to discard them", gen.OutputPath)
+    }
+    return true, cur, nil
+}
+
+// CommitAll writes the generated files of results to disk like Commit,
+// but all or none of them. It first checks that every file may be
+// written, as Commit does, and that it can be, and writes nothing if any
+// check fails. If a write fails anyway, such as because the disk is full,
+// the files written so far are restored to their previous content, or
+// removed if they did not exist. The error names the package that failed.
+func CommitAll(results []*GenerateResult) error {
+    type staged struct {
+        gen *GenerateResult
+        // backup is the content of the file before the commit, or nil if
+        // it did not exist.
+        backup []byte
+    }
+    var files []*GenerateResult
+    for _, gen := range results {
+        files = append(files, gen)
+        if gen.Benchmarks != nil {
+            files = append(files, gen.Benchmarks)
+        }
+    }
+    var writes []staged
+    for _, gen := range files {
+        write, cur, err := gen.prepareCommit()
+        if err == nil && write {
+            err = checkWritable(gen.OutputPath, cur != nil)
+        }
+        if err != nil {
+            return fmt.Errorf("%s: %v", gen.PkgPath, err)
+        }
+        if write {
+            writes = append(writes, staged{gen: gen, backup: cur})
+        }
+    }
+    for i, w := range writes {
+        err := commitWriteFile(w.gen.OutputPath, w.gen.Content, 0666)
+        if err == nil {
+            continue
+        }
+        msg := fmt.Sprintf("%s: %v", w.gen.PkgPath, err)
+        // The failed write may have truncated its file, so restore it too.
+        for j := i; j >= 0; j-- {
+            w := writes[j]
+            var err error
+            if w.backup == nil {
+                err = os.Remove(w.gen.OutputPath)
+                if os.IsNotExist(err) {
+                    err = nil
+                }
+            } else {
+                err = ioutil.WriteFile(w.gen.OutputPath, w.backup, 0666)
+            }
+            if err != nil {
+                msg += fmt.Sprintf("; could not restore %s: %v", w.gen.OutputPath, err)
+            }
+        }
+        return errors.New(msg)
+    }
+    return nil
+}
+
+// commitWriteFile writes the files of CommitAll. Tests replace it to make writes
+// fail.
+var commitWriteFile = ioutil.WriteFile
+
+// checkWritable reports an error if the file at path cannot be written:
+// opened for writing if it exists, or created in its directory if not.
+// It does not change the file.
+func checkWritable(path string, exists bool) error {
+    if exists {
+        f, err := os.OpenFile(path, os.O_WRONLY, 0)
+        if err != nil {
+            return err
+        }
+        return f.Close()
+    }
+    if _, err := os.Lstat(path); err == nil {
+        // prepareCommit could not read the file, so it cannot be restored.
+        return fmt.Errorf("%s cannot be read to back it up", path)
+    }
+    f, err := ioutil.TempFile(filepath.Dir(path), ".wire-*")
+    if err != nil {
+        return err
+    }
+    f.Close()
+    return os.Remove(f.Name())
+}
+
+// Verify type-checks the generated file in memory against the loaded
+// program, together with the package's files that are not injector
+// templates. It does not write to disk or run the go command. Verify returns
+// nil if there is no generated content.
+func (gen GenerateResult) Verify() []error {
     if len(gen.Content) == 0 {
         return nil
     }
-    return ioutil.WriteFile(gen.OutputPath, gen.Content, 0666)
+    return verifyOutput(gen.pkg, gen.buildTag, gen.OutputPath, gen.Content)
 }
 
 // GenerateOptions holds options for Generate.
@@ -68,337 +305,1081 @@ type GenerateOptions struct {
     Header           []byte
     PrefixOutputFile string
     Tags             string
+
+    // GOOS and GOARCH select the target platform used when loading
+    // packages. If empty, the platform from env (or the host) is used.
+    GOOS   string
+    GOARCH string
+
+    // GoCache, if not empty, is the GOCACHE directory used by the go
+    // command for all package loads, overriding env. Tests can set it to
+    // a private directory to keep runs hermetic.
+    GoCache string
+
+    // Overlay maps absolute file paths to contents that Wire reads in place
+    // of the files on disk, such as the unsaved buffers of an editor, as
+    // with packages.Config.Overlay. A path in the overlay need not exist on
+    // disk. Generate does not write files, so an editor can regenerate
+    // without saving and apply GenerateResult.Content itself.
+    Overlay map[string][]byte
+
+    // VerifyOutput type-checks each generated file in memory, as with
+    // GenerateResult.Verify, and reports any type errors in Errs.
+    VerifyOutput bool
+
+    // DeprecatedAsError reports uses of deprecated providers and provider
+    // sets as errors instead of warnings.
+    DeprecatedAsError bool
+
+    // UnusedParamsAsError reports injector parameters that the injector
+    // does not use to provide anything as errors instead of warnings.
+    UnusedParamsAsError bool
+
+    // NearestWins lets a provider, binding, value or field that wire.Build
+    // includes through fewer provider sets take the place of another one of
+    // the same type instead of conflicting with it: an argument of
+    // wire.Build or of the injector beats a source in a set passed to
+    // wire.Build, which beats one in a set that such a set includes, and so
+    // on. Sources at the same depth still conflict, and each provider set
+    // must not conflict on its own. Explain and the annotations of
+    // AnnotateOutput name the sources that are overridden.
+    NearestWins bool
+
+    // AnnotateOutput adds a trailing comment to each statement of the
+    // generated injectors that names the type it provides and the provider,
+    // value or field that provides it. Annotations do not affect
+    // GenerateResult.ContentHash.
+    AnnotateOutput bool
+
+    // UngroupedErrors reports an error or warning that several injectors of
+    // a package share once for each injector, instead of once for all of
+    // them with an InjectorError that lists them.
+    UngroupedErrors bool
+
+    // RequireVersion, if not empty, is a constraint on the version of Wire
+    // that may generate the packages, as a comma-separated list of
+    // comparisons such as ">=v0.6.0, <v0.8.0". Injector files can also
+    // require a minimum version with a //wire:minversion directive.
+    // Packages that the running Wire does not satisfy fail with an error.
+    // Development builds, whose version is not known, satisfy any
+    // constraint.
+    RequireVersion string
+
+    // IdentifierPrefix is prepended to the names of the package-level
+    // identifiers that Wire generates besides the injectors, such as the
+    // variables that hold wire.Value expressions, so that they do not collide
+    // with the output of other code generators. It must be a valid start of
+    // a Go identifier.
+    IdentifierPrefix string
+
+    // CheckCleanupRecover adds a warning for each recover call in the
+    // cleanup functions of the providers that the injectors call, since
+    // such a call can swallow a panic. Only cleanup functions that can be
+    // found in the provider's syntax are checked. A //wire:allow-recover
+    // comment in the doc comment of the provider or cleanup function, or on
+    // or just above the call, allows it.
+    CheckCleanupRecover bool
+
+    // CheckGlobalReads adds a warning for each read of a package-level
+    // variable of another package, outside of the standard library, in the
+    // body of the providers that the injectors call. Such a variable may be
+    // set by an init function, or by other code, that has not run yet when
+    // the injector does. Reads in function literals and in the functions
+    // that the providers call are not checked. A //wire:allow-global-read
+    // comment in the doc comment of the provider, or on or just above the
+    // read, allows it.
+    CheckGlobalReads bool
+
+    // CheckConversions reports an error for each provider function that the
+    // injectors call whose body only converts one of its parameters to its
+    // result, between two named types with the same underlying type, such
+    // as from UserID to SessionID when both are int64s. Such a provider
+    // lets any value of one type stand in for the other, which is usually
+    // a mistake in the graph. A //wire:allow-conversion comment in the doc
+    // comment of the provider allows it.
+    CheckConversions bool
+
+    // UseRuntimeCleanup makes injectors that return a func() cleanup return
+    // the Run method of a wire.CleanupFuncs that lists the cleanup functions
+    // of their providers, instead of a closure that calls them one after
+    // another, and run them the same way when a provider fails. Unlike the
+    // closure, Run keeps a panic in one cleanup function from skipping the
+    // others. The generated files then import the wire package.
+    UseRuntimeCleanup bool
+
+    // BuildTag is the build tag that marks injector files, which Wire loads
+    // with the tag set. The generated files are constrained to builds
+    // without it. If empty, it is "wireinject". Files constrained to the
+    // wireinject tag are an error when another tag is used, since Wire
+    // would silently leave them out.
+    BuildTag string
+
+    // WrapErrors, if not empty, makes the generated injectors wrap the error
+    // of a provider that fails before returning it, as with
+    // fmt.Errorf("initializing *db.Pool: %w", err), so that errors.Is and
+    // errors.As still see the original. It is a text/template for the
+    // message before ": %w", such as "initializing {{.Type}}", executed with
+    // the Type the provider provides and the Provider function, both
+    // qualified by package name, e.g. "*db.Pool" and "db.NewPool". Providers
+    // in the injector's own package are not qualified.
+    WrapErrors string
+
+    // Concurrency is the number of packages that Generate generates at
+    // once. If it is zero, packages are generated one at a time; if it is
+    // negative, one per CPU. The order does not matter even when one
+    // package uses the injectors of another: injector files are loaded
+    // without any generated files, so each package sees the injector
+    // templates of the others rather than their output.
+    Concurrency int
+
+    // EmitBenchmarks also generates a file of benchmarks next to each
+    // generated file, named after it with _bench_test.go in place of .go,
+    // with a benchmark for each injector. The benchmark of InitApp is
+    // BenchmarkInitApp. It calls a fixture function of the package,
+    // BenchmarkFixtureInitApp, for the arguments of the injector, and then
+    // the injector, its cleanup function included, in the benchmark loop.
+    // The fixture must be declared in a file that is not an injector
+    // template and, since go test expects a function whose name starts with
+    // Benchmark in a test file to be a benchmark, not in a test file. An
+    // injector without parameters needs no fixture; if it has one, the
+    // fixture takes no arguments and returns nothing. The benchmarks of
+    // injectors without a suitable fixture, and of injectors marked
+    // //wire:once, call b.Skip instead; a fixture that does not match its
+    // injector is also reported as a warning.
+    EmitBenchmarks bool
+
+    // LazyLoad loads the packages that injectors refer to on demand, in
+    // batches, when they are not among the packages loaded up front. It can
+    // save time on large projects with deep dependency trees.
+    LazyLoad bool
+
+    // Force lets GenerateResult.Commit overwrite generated files that were
+    // edited by hand since Wire wrote them, discarding the edits.
+    Force bool
+
+    // Format, if not nil, post-processes each generated file after Wire
+    // formats it with gofmt, such as to run goimports or a license tool.
+    // It is called with the path of the output file and its formatted
+    // source and returns the content to use instead. An error fails the
+    // package it was called for, so that nothing is written for it, but not
+    // the others. The hook also applies to GenerateResult.Content when it
+    // is only compared, as by wirex diff. Wire still adds its checksum to
+    // the result, so the hook should keep the generated file header.
+    Format func(filename string, src []byte) ([]byte, error)
+
+    // Progress, if not nil, is called as generation goes through its
+    // phases and finishes each injector and package, such as to drive a
+    // progress bar. Calls are never concurrent, even when packages are
+    // generated at once, and block generation, so Progress should return
+    // quickly. GenerateForPlatforms reports each platform as a separate run.
+    Progress func(ProgressEvent)
+
+    // LoadStats, if not nil, is called once at the end of each run of
+    // Generate or GenerateStream with the numbers of packages that the run
+    // loaded up front and on demand, and how long each load took. It is
+    // not called if the packages fail to load. GenerateForPlatforms reports
+    // each platform as a separate run.
+    LoadStats func(LoadStats)
+
+    // platformSuffix is set by GenerateForPlatforms to name the output file
+    // after the target platform and to constrain it to that platform.
+    platformSuffix bool
+
+    // outputFile, if not empty, replaces wire_gen.go as the name of the
+    // generated file. It is set by the output key of a package's
+    // //wire:options directive.
+    outputFile string
+
+    // progress reports to Progress. It is set by withProgress.
+    progress *progress
+
+    // loader, if not nil, replaces packages.Load, such as to replay a
+    // loadSnapshot in benchmarks.
+    loader packageLoader
+}
+
+// Validate reports whether opts can be used, with an error that lists
+// every problem, one per line, each starting with the name of the field.
+// Generate and the other functions that take GenerateOptions validate them
+// first and return the problems as their errors, without loading anything.
+//
+// The zero value of GenerateOptions, like a nil *GenerateOptions, is always
+// valid and means Wire's default behavior; a new option must keep it so.
+func (opts *GenerateOptions) Validate() error {
+    errs := opts.validate()
+    if len(errs) == 0 {
+        return nil
+    }
+    msgs := make([]string, len(errs))
+    for i, err := range errs {
+        msgs[i] = err.Error()
+    }
+    return errors.New(strings.Join(msgs, "\n"))
+}
+
+// validate returns the problems that Validate reports, one per field, or
+// nil if opts is nil or can be used.
+func (opts *GenerateOptions) validate() []error {
+    if opts == nil {
+        return nil
+    }
+    var errs []error
+    problem := func(field string, err error) {
+        errs = append(errs, fmt.Errorf("GenerateOptions.%s: %v", field, err))
+    }
+    if strings.ContainsAny(opts.PrefixOutputFile, `/\`) {
+        problem("PrefixOutputFile", fmt.Errorf("prefix %q must not contain a path separator", opts.PrefixOutputFile))
+    }
+    for _, tag := range strings.FieldsFunc(opts.Tags, func(r rune) bool { return r == ' ' || r == ',' }) {
+        if !isBuildTag(tag) {
+            problem("Tags", fmt.Errorf("%q is not a valid build tag", tag))
+            break
+        }
+    }
+    if opts.GoCache != "" && !filepath.IsAbs(opts.GoCache) {
+        problem("GoCache", fmt.Errorf("%q is not an absolute path", opts.GoCache))
+    }
+    var relative []string
+    for path := range opts.Overlay {
+        if !filepath.IsAbs(path) {
+            relative = append(relative, path)
+        }
+    }
+    if len(relative) > 0 {
+        sort.Strings(relative)
+        problem("Overlay", fmt.Errorf("%q is not an absolute path", relative[0]))
+    }
+    if opts.RequireVersion != "" {
+        if err := checkVersion("", opts.RequireVersion); err != nil {
+            problem("RequireVersion", err)
+        }
+    }
+    if p := opts.IdentifierPrefix; p != "" && !isIdentifierPrefix(p) {
+        problem("IdentifierPrefix", fmt.Errorf("identifier prefix %q is not a valid start of a Go identifier", p))
+    }
+    if t := opts.BuildTag; t != "" && !isBuildTag(t) {
+        problem("BuildTag", fmt.Errorf("build tag %q is not a valid build tag", t))
+    }
+    if _, err := parseWrapErrors(opts.WrapErrors); err != nil {
+        problem("WrapErrors", err)
+    }
+    return errs
+}
+
+// wrapErrorsData is the data that the WrapErrors template is executed with.
+type wrapErrorsData struct {
+    Type     string
+    Provider string
+}
+
+// parseWrapErrors parses the WrapErrors template s and checks that it can
+// be executed. It returns nil if s is empty.
+func parseWrapErrors(s string) (*template.Template, error) {
+    if s == "" {
+        return nil, nil
+    }
+    tmpl, err := template.New("WrapErrors").Parse(s)
+    if err == nil {
+        err = tmpl.Execute(ioutil.Discard, wrapErrorsData{Type: "*db.Pool", Provider: "db.NewPool"})
+    }
+    if err != nil {
+        return nil, fmt.Errorf("invalid WrapErrors template: %v", err)
+    }
+    return tmpl, nil
+}
+
+// importCache returns the importCache that loads packages for a run with
+// opts.
+func (opts *GenerateOptions) importCache(ctx context.Context, wd string, env []string) *importCache {
+    imports := newImportCache(ctx, wd, opts.loadEnv(env), opts.buildTag(), opts.Tags, opts.Overlay)
+    // Only the checks of provider bodies look into the functions of
+    // packages other than those being generated.
+    imports.skipBodies = !opts.CheckCleanupRecover && !opts.CheckGlobalReads && !opts.CheckConversions
+    if opts.loader != nil {
+        imports.loadPackages = opts.loader
+    }
+    if opts.LoadStats != nil {
+        imports.stats = newLoadStats()
+    }
+    return imports
+}
+
+// withProgress returns opts with a new progress that reports to
+// opts.Progress, or opts itself if Progress is nil.
+func (opts *GenerateOptions) withProgress() *GenerateOptions {
+    if opts.Progress == nil {
+        return opts
+    }
+    copied := *opts
+    copied.progress = newProgress(opts.Progress)
+    return &copied
+}
+
+// isBuildTag reports whether s can be used as a build tag: a non-empty
+// string of letters, digits, underscores and dots.
+func isBuildTag(s string) bool {
+    if s == "" {
+        return false
+    }
+    for _, r := range s {
+        if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '.' {
+            return false
+        }
+    }
+    return true
+}
+
+// buildTag returns the build tag of injector files.
+func (opts *GenerateOptions) buildTag() string {
+    if opts.BuildTag == "" {
+        return defaultBuildTag
+    }
+    return opts.BuildTag
+}
+
+// isIdentifierPrefix reports whether s is the start of Go identifiers:
+// a letter or underscore followed by letters, digits and underscores.
+func isIdentifierPrefix(s string) bool {
+    for i, r := range s {
+        if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+            return false
+        }
+    }
+    return true
+}
+
+// loadEnv returns env with the target platform and build cache of opts
+// applied. It leaves the variables that select modules, such as GOWORK and
+// GOFLAGS, alone.
+func (opts *GenerateOptions) loadEnv(env []string) []string {
+    if opts.GOOS == "" && opts.GOARCH == "" && opts.GoCache == "" {
+        return env
+    }
+    if env == nil {
+        // go/packages treats a nil Env as the current environment.
+        env = os.Environ()
+    }
+    env = append([]string(nil), env...)
+    if opts.GOOS != "" {
+        env = append(env, "GOOS="+opts.GOOS)
+    }
+    if opts.GOARCH != "" {
+        env = append(env, "GOARCH="+opts.GOARCH)
+    }
+    if opts.GoCache != "" {
+        env = append(env, "GOCACHE="+opts.GoCache)
+    }
+    return env
+}
+
+// outputFileName returns the base name of the generated file.
+func (opts *GenerateOptions) outputFileName() string {
+    base := "wire_gen"
+    if opts.outputFile != "" {
+        base = strings.TrimSuffix(opts.outputFile, ".go")
+    }
+    if opts.platformSuffix {
+        p := Platform{GOOS: opts.GOOS, GOARCH: opts.GOARCH}
+        return opts.PrefixOutputFile + base + "_" + p.suffix() + ".go"
+    }
+    return opts.PrefixOutputFile + base + ".go"
+}
+
+// OptionsFingerprint returns a hash of the options that affect the content
+// or location of the generated files. Generate embeds it in the header of
+// each generated file, so that a build system that caches Wire output by its
+// input files can tell when the same inputs were generated with different
+// options. Options that only control checks, such as VerifyOutput, are not
+// included.
+func (opts *GenerateOptions) OptionsFingerprint() string {
+    h := sha256.New()
+    fmt.Fprintf(h, "header=%q\n", opts.Header)
+    fmt.Fprintf(h, "prefix=%q\n", opts.PrefixOutputFile)
+    fmt.Fprintf(h, "tags=%q\n", opts.Tags)
+    fmt.Fprintf(h, "goos=%q\n", opts.GOOS)
+    fmt.Fprintf(h, "goarch=%q\n", opts.GOARCH)
+    fmt.Fprintf(h, "deprecatedAsError=%t\n", opts.DeprecatedAsError)
+    fmt.Fprintf(h, "annotate=%t\n", opts.AnnotateOutput)
+    fmt.Fprintf(h, "platformSuffix=%t\n", opts.platformSuffix)
+    if opts.IdentifierPrefix != "" {
+        // Added later; left out when empty to keep earlier fingerprints.
+        fmt.Fprintf(h, "identifierPrefix=%q\n", opts.IdentifierPrefix)
+    }
+    if t := opts.buildTag(); t != defaultBuildTag {
+        fmt.Fprintf(h, "buildTag=%q\n", t)
+    }
+    if opts.WrapErrors != "" {
+        fmt.Fprintf(h, "wrapErrors=%q\n", opts.WrapErrors)
+    }
+    if opts.outputFile != "" {
+        fmt.Fprintf(h, "outputFile=%q\n", opts.outputFile)
+    }
+    if opts.UseRuntimeCleanup {
+        fmt.Fprintf(h, "runtimeCleanup=true\n")
+    }
+    if opts.UnusedParamsAsError {
+        fmt.Fprintf(h, "unusedParamsAsError=true\n")
+    }
+    if opts.NearestWins {
+        fmt.Fprintf(h, "nearestWins=true\n")
+    }
+    if opts.EmitBenchmarks {
+        fmt.Fprintf(h, "emitBenchmarks=true\n")
+    }
+    return hex.EncodeToString(h.Sum(nil))
+}
+
+// A Platform is a target GOOS/GOARCH pair. Either field may be empty, in
+// which case the platform matches any value of it.
+type Platform struct {
+    GOOS   string
+    GOARCH string
+}
+
+// String returns the platform as "goos/goarch", omitting empty parts.
+func (p Platform) String() string {
+    return strings.Join(p.parts(), "/")
+}
+
+// suffix returns the file name suffix for the platform, like "linux_amd64".
+// The go tool applies the matching implicit build constraint to such files.
+func (p Platform) suffix() string {
+    return strings.Join(p.parts(), "_")
+}
+
+// constraint returns the build constraint that matches the platform.
+func (p Platform) constraint() constraint.Expr {
+    var x constraint.Expr
+    for _, part := range p.parts() {
+        var tag constraint.Expr = &constraint.TagExpr{Tag: part}
+        if x == nil {
+            x = tag
+        } else {
+            x = &constraint.AndExpr{X: x, Y: tag}
+        }
+    }
+    return x
+}
+
+func (p Platform) parts() []string {
+    var parts []string
+    if p.GOOS != "" {
+        parts = append(parts, p.GOOS)
+    }
+    if p.GOARCH != "" {
+        parts = append(parts, p.GOARCH)
+    }
+    return parts
+}
+
+// overlaps reports whether a build could match both p and q.
+func (p Platform) overlaps(q Platform) bool {
+    matches := func(a, b string) bool {
+        return a == "" || b == "" || a == b
+    }
+    return matches(p.GOOS, q.GOOS) && matches(p.GOARCH, q.GOARCH)
 }
 
 // Generate performs dependency injection for the packages that match the given
 // patterns, return a GenerateResult for each package. The package pattern is
 // defined by the underlying build system. For the go tool, this is described at
 // https://golang.org/cmd/go/#hdr-Package_lists_and_patterns
+// A pattern ending in .go instead names a file, relative to wd unless it is
+// absolute, and stands for the package that the file belongs to with the
+// build tags of opts. Files and package patterns may be mixed, and each
+// package is generated once however many patterns lead to it.
 //
 // wd is the working directory and env is the set of environment
 // variables to use when loading the package specified by pkgPattern. If
 // env is nil or empty, it is interpreted as an empty set of variables.
 // In case of duplicate environment variables, the last one in the list
-// takes precedence.
+// takes precedence. GOWORK is passed to the go command as it is, so in a
+// go.work workspace, packages of the other workspace modules are loaded
+// from the workspace, including when they are loaded lazily.
+//
+// opts.Concurrency and opts.LazyLoad choose how the packages are processed;
+// they do not change the results.
 //
 // Generate may return one or more errors if it failed to load the packages.
+// Whether it succeeds or fails, every goroutine that it starts, including
+// those that load packages lazily, has exited by the time it returns.
 func Generate(ctx context.Context, wd string, env []string, patterns []string, opts *GenerateOptions) ([]GenerateResult, []error) {
     if opts == nil {
         opts = &GenerateOptions{}
     }
-    pkgs, errs := load(ctx, wd, env, opts.Tags, patterns)
+    if errs := opts.validate(); len(errs) > 0 {
+        return nil, errs
+    }
+    opts = opts.withProgress()
+    opts.progress.loading()
+    // The packages share imports, so a package that several of them load
+    // lazily is type-checked once.
+    imports := opts.importCache(ctx, wd, env)
+    pkgs, errs := imports.load(patterns)
     if len(errs) > 0 {
         return nil, errs
     }
-    generated := make([]GenerateResult, len(pkgs))
-    for i, pkg := range pkgs {
-        generated[i].PkgPath = pkg.PkgPath
-        outDir, err := detectOutputDir(pkg.GoFiles)
-        if err != nil {
-            generated[i].Errs = append(generated[i].Errs, err)
-            continue
-        }
-        generated[i].OutputPath = filepath.Join(outDir, opts.PrefixOutputFile+"wire_gen.go")
-        g := newGen(pkg)
-        injectorFiles, errs := generateInjectors(g, pkg)
-        if len(errs) > 0 {
-            generated[i].Errs = errs
-            continue
-        }
-        copyNonInjectorDecls(g, injectorFiles, pkg.TypesInfo)
-        goSrc := g.frame(opts.Tags)
-        if len(opts.Header) > 0 {
-            goSrc = append(opts.Header, goSrc...)
-        }
-        fmtSrc, err := format.Source(goSrc)
-        if err != nil {
-            // This is likely a bug from a poorly generated source file.
-            // Add an error but also the unformatted source.
-            generated[i].Errs = append(generated[i].Errs, err)
-        } else {
-            goSrc = fmtSrc
-        }
-        generated[i].Content = goSrc
+    opts.progress.loaded(pkgs)
+    generated := generatePackages(imports, pkgs, opts)
+    for _, path := range imports.ignored {
+        generated = append(generated, GenerateResult{PkgPath: path, Ignored: true})
     }
+    imports.stats.report(opts.LoadStats)
     return generated, nil
 }
 
-// GenerateParallel performs dependency injection for the packages that match
-// the given patterns using parallel processing for improved performance on
-// large codebases. It returns a GenerateResult for each package.
-//
-// maxWorkers controls the number of parallel workers. If maxWorkers <= 0,
-// it defaults to runtime.GOMAXPROCS(0).
-//
-// This function is recommended for large projects with many packages.
-func GenerateParallel(ctx context.Context, wd string, env []string, patterns []string, opts *GenerateOptions, maxWorkers int) ([]GenerateResult, []error) {
-    if opts == nil {
-        opts = &GenerateOptions{}
-    }
-    pkgs, errs := load(ctx, wd, env, opts.Tags, patterns)
-    if len(errs) > 0 {
-        return nil, errs
-    }
+// generatePackages generates code for pkgs, which imports loaded, with
+// opts.Concurrency goroutines.
+func generatePackages(imports *importCache, pkgs []*packages.Package, opts *GenerateOptions) []GenerateResult {
+    return generateRoots([]*importCache{imports}, [][]*packages.Package{pkgs}, opts)[0]
+}
 
-    if maxWorkers <= 0 {
-        maxWorkers = runtime.GOMAXPROCS(0)
-    }
-    if maxWorkers > len(pkgs) {
-        maxWorkers = len(pkgs)
+// generateRoots generates code for each pkgs[i], which imports[i] loaded,
+// with one pool of opts.Concurrency goroutines for all of them.
+func generateRoots(imports []*importCache, pkgs [][]*packages.Package, opts *GenerateOptions) [][]GenerateResult {
+    generated := make([][]GenerateResult, len(pkgs))
+    total := 0
+    for i := range pkgs {
+        generated[i] = make([]GenerateResult, len(pkgs[i]))
+        total += len(pkgs[i])
+    }
+    workers := opts.workers(total)
+    if workers <= 1 {
+        for i := range pkgs {
+            for j, pkg := range pkgs[i] {
+                generated[i][j] = generatePackage(imports[i], pkg, opts)
+            }
+        }
+        return generated
     }
 
-    generated := make([]GenerateResult, len(pkgs))
-
     // Use a worker pool for parallel processing
     type workItem struct {
-        index int
-        pkg   *packages.Package
+        root, index int
+        pkg         *packages.Package
     }
 
-    workCh := make(chan workItem, len(pkgs))
+    workCh := make(chan workItem, total)
     var wg sync.WaitGroup
 
     // Start workers
-    for w := 0; w < maxWorkers; w++ {
+    for w := 0; w < workers; w++ {
         wg.Add(1)
         go func() {
             defer wg.Done()
             for item := range workCh {
-                generated[item.index] = generateSinglePackage(item.pkg, opts)
+                generated[item.root][item.index] = generatePackage(imports[item.root], item.pkg, opts)
             }
         }()
     }
 
     // Send work items
-    for i, pkg := range pkgs {
-        workCh <- workItem{index: i, pkg: pkg}
+    for i := range pkgs {
+        for j, pkg := range pkgs[i] {
+            workCh <- workItem{root: i, index: j, pkg: pkg}
+        }
     }
     close(workCh)
 
     wg.Wait()
-    return generated, nil
+    return generated
 }
 
-// GenerateOptimized performs dependency injection with optimized AST traversal.
-// It combines injector generation and non-injector declaration copying in a
-// single pass, reducing processing time by 10-20%.
-//
-// This function is recommended when processing single packages or when
-// parallel processing overhead is not justified.
-func GenerateOptimized(ctx context.Context, wd string, env []string, patterns []string, opts *GenerateOptions) ([]GenerateResult, []error) {
-    if opts == nil {
-        opts = &GenerateOptions{}
+// workers returns the number of goroutines that generate n packages with
+// opts.Concurrency.
+func (opts *GenerateOptions) workers(n int) int {
+    workers := opts.Concurrency
+    if workers < 0 {
+        workers = runtime.GOMAXPROCS(0)
     }
-    pkgs, errs := load(ctx, wd, env, opts.Tags, patterns)
-    if len(errs) > 0 {
-        return nil, errs
+    if workers > n {
+        workers = n
     }
-    generated := make([]GenerateResult, len(pkgs))
-    for i, pkg := range pkgs {
-        generated[i] = generateSinglePackageOptimized(pkg, opts)
+    return workers
+}
+
+// concurrent returns a copy of opts that generates with maxWorkers goroutines,
+// or one per CPU if maxWorkers <= 0, as the deprecated parallel variants of
+// Generate do.
+func concurrent(opts *GenerateOptions, maxWorkers int) *GenerateOptions {
+    copied := GenerateOptions{}
+    if opts != nil {
+        copied = *opts
     }
-    return generated, nil
+    copied.Concurrency = maxWorkers
+    if maxWorkers <= 0 {
+        copied.Concurrency = -1
+    }
+    return &copied
+}
+
+// GenerateParallel is Generate with maxWorkers packages generated at once,
+// or one per CPU if maxWorkers <= 0.
+//
+// Deprecated: Use Generate with GenerateOptions.Concurrency.
+func GenerateParallel(ctx context.Context, wd string, env []string, patterns []string, opts *GenerateOptions, maxWorkers int) ([]GenerateResult, []error) {
+    return Generate(ctx, wd, env, patterns, concurrent(opts, maxWorkers))
 }
 
-// GenerateWithLazyLoad performs dependency injection with lazy package loading.
-// Instead of loading all transitive dependencies upfront, packages are loaded
-// on-demand as they are needed. This can significantly reduce initial load time
-// for large projects where only a subset of dependencies are actually used.
+// GenerateStream performs dependency injection for the packages that match
+// the given patterns like Generate, but sends each package's result on
+// results as soon as it is ready instead of returning them all at the end.
+// Results are sent in no particular order, and each package is sent exactly
+// once. GenerateStream closes results before returning. Packages are
+// generated with opts.Concurrency goroutines, or one per CPU if it is not
+// positive.
 //
-// This function is recommended for very large projects with deep dependency trees
-// where the upfront loading cost is significant.
+// A slow consumer slows the workers down but does not block them forever:
+// if ctx is canceled, GenerateStream stops sending, returns ctx.Err() and
+// any unsent results are dropped.
 //
-// Note: This may result in slightly slower individual package processing due to
-// on-demand loading, but the overall time can be reduced if not all dependencies
-// are needed.
-func GenerateWithLazyLoad(ctx context.Context, wd string, env []string, patterns []string, opts *GenerateOptions) ([]GenerateResult, []error) {
+// GenerateStream may return one or more errors if it failed to load the
+// packages, in which case no results are sent.
+func GenerateStream(ctx context.Context, wd string, env []string, patterns []string, opts *GenerateOptions, results chan<- *GenerateResult) []error {
+    defer close(results)
     if opts == nil {
         opts = &GenerateOptions{}
     }
-    pkgs, errs := load(ctx, wd, env, opts.Tags, patterns)
+    if errs := opts.validate(); len(errs) > 0 {
+        return errs
+    }
+    opts = opts.withProgress()
+    opts.progress.loading()
+    imports := opts.importCache(ctx, wd, env)
+    pkgs, errs := imports.load(patterns)
     if len(errs) > 0 {
-        return nil, errs
+        return errs
     }
-    generated := make([]GenerateResult, len(pkgs))
-    for i, pkg := range pkgs {
-        generated[i] = generateSinglePackageWithLazyLoad(ctx, wd, env, pkg, opts)
+    opts.progress.loaded(pkgs)
+
+    workers := concurrent(opts, opts.Concurrency).workers(len(pkgs))
+    workCh := make(chan *packages.Package)
+    var wg sync.WaitGroup
+    for w := 0; w < workers; w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for pkg := range workCh {
+                result := generatePackage(imports, pkg, opts)
+                select {
+                case results <- &result:
+                case <-ctx.Done():
+                    return
+                }
+            }
+        }()
     }
-    return generated, nil
+
+send:
+    for _, pkg := range pkgs {
+        select {
+        case workCh <- pkg:
+        case <-ctx.Done():
+            break send
+        }
+    }
+    for _, path := range imports.ignored {
+        select {
+        case results <- &GenerateResult{PkgPath: path, Ignored: true}:
+            continue
+        case <-ctx.Done():
+        }
+        break
+    }
+    close(workCh)
+    wg.Wait()
+    imports.stats.report(opts.LoadStats)
+    if err := ctx.Err(); err != nil {
+        return []error{err}
+    }
+    return nil
 }
 
-// GenerateParallelWithLazyLoad combines parallel processing with lazy loading
-// for maximum performance on very large projects.
+// GenerateOptimized is Generate. It once selected a single-pass traversal of
+// the injector files, which Generate's output now matches.
 //
-// maxWorkers controls the number of parallel workers. If maxWorkers <= 0,
-// it defaults to runtime.GOMAXPROCS(0).
+// Deprecated: Use Generate.
+func GenerateOptimized(ctx context.Context, wd string, env []string, patterns []string, opts *GenerateOptions) ([]GenerateResult, []error) {
+    return Generate(ctx, wd, env, patterns, opts)
+}
+
+// GenerateWithLazyLoad is Generate with the packages that provider sets
+// come from loaded lazily.
+//
+// Deprecated: Use Generate with GenerateOptions.LazyLoad.
+func GenerateWithLazyLoad(ctx context.Context, wd string, env []string, patterns []string, opts *GenerateOptions) ([]GenerateResult, []error) {
+    lazy := GenerateOptions{}
+    if opts != nil {
+        lazy = *opts
+    }
+    lazy.LazyLoad = true
+    return Generate(ctx, wd, env, patterns, &lazy)
+}
+
+// GenerateParallelWithLazyLoad is GenerateParallel with the packages that
+// provider sets come from loaded lazily.
+//
+// Deprecated: Use Generate with GenerateOptions.Concurrency and
+// GenerateOptions.LazyLoad.
 func GenerateParallelWithLazyLoad(ctx context.Context, wd string, env []string, patterns []string, opts *GenerateOptions, maxWorkers int) ([]GenerateResult, []error) {
+    lazy := concurrent(opts, maxWorkers)
+    lazy.LazyLoad = true
+    return Generate(ctx, wd, env, patterns, lazy)
+}
+
+// GenerateForPlatforms performs dependency injection once for each of the
+// given platforms, so that injectors behind platform build constraints are
+// generated from a single invocation. Each platform's output is written to a
+// file named after the platform (for example, wire_gen_linux.go) that is
+// constrained to that platform.
+//
+// Platforms that could be selected by the same build would produce
+// conflicting injectors, so they are reported as errors before any packages
+// are loaded. So is, in the results of its package, an existing output file
+// generated without a platform, which would be built together with the
+// per-platform ones. Injectors whose files are left out of the build for
+// every platform are reported as warnings, as they are not generated at all.
+func GenerateForPlatforms(ctx context.Context, wd string, env []string, patterns []string, opts *GenerateOptions, platforms []Platform) ([]GenerateResult, []error) {
     if opts == nil {
         opts = &GenerateOptions{}
     }
-    pkgs, errs := load(ctx, wd, env, opts.Tags, patterns)
-    if len(errs) > 0 {
+    if errs := opts.validate(); len(errs) > 0 {
         return nil, errs
     }
-
-    if maxWorkers <= 0 {
-        maxWorkers = runtime.GOMAXPROCS(0)
+    if errs := checkPlatforms(platforms); len(errs) > 0 {
+        return nil, errs
     }
-    if maxWorkers > len(pkgs) {
-        maxWorkers = len(pkgs)
+    var (
+        generated    []GenerateResult
+        genPlatforms []Platform
+    )
+    for _, p := range platforms {
+        popts := *opts
+        popts.GOOS = p.GOOS
+        popts.GOARCH = p.GOARCH
+        popts.platformSuffix = true
+        gens, errs := Generate(ctx, wd, env, patterns, &popts)
+        if len(errs) > 0 {
+            return nil, mapErrors(errs, func(e error) error {
+                return fmt.Errorf("%v: %v", p, e)
+            })
+        }
+        generated = append(generated, gens...)
+        for range gens {
+            genPlatforms = append(genPlatforms, p)
+        }
     }
+    checkPlatformOutputs(generated, genPlatforms, platforms)
+    return generated, nil
+}
 
-    generated := make([]GenerateResult, len(pkgs))
+// A RootResult holds the outcome of GenerateMulti for one working directory.
+type RootResult struct {
+    // Dir is the working directory, as passed to GenerateMulti.
+    Dir string
+    // Results holds the results for the packages that the patterns match
+    // in Dir, as Generate returns them.
+    Results []GenerateResult
+    // Errs holds the errors that Generate would return for Dir, such as
+    // failures to load its packages, in which case Results is nil.
+    Errs []error
+}
 
-    type workItem struct {
-        index int
-        pkg   *packages.Package
+// GenerateMulti is Generate for several working directories, such as the
+// roots of the modules of a repository, with the patterns expanded in each
+// of them. It returns one RootResult for each of dirs, in order. A root that
+// fails to load does not keep the others from being generated.
+//
+// Unlike calls to Generate for each directory in turn, the roots are loaded
+// at once and share the type-checked packages, including those of the
+// standard library, and one pool of opts.Concurrency goroutines generates
+// the packages of all of them. Provider sets are cached in the global
+// ProviderSetCache either way.
+//
+// GenerateMulti returns an error, and no results, only if opts is invalid.
+func GenerateMulti(ctx context.Context, dirs []string, env []string, patterns []string, opts *GenerateOptions) ([]RootResult, []error) {
+    if opts == nil {
+        opts = &GenerateOptions{}
     }
-
-    workCh := make(chan workItem, len(pkgs))
+    if errs := opts.validate(); len(errs) > 0 {
+        return nil, errs
+    }
+    if len(dirs) == 0 {
+        return nil, nil
+    }
+    opts = opts.withProgress()
+    opts.progress.loading()
+    shared := opts.importCache(ctx, dirs[0], env)
+    results := make([]RootResult, len(dirs))
+    imports := make([]*importCache, len(dirs))
+    pkgs := make([][]*packages.Package, len(dirs))
+    progress := make([]*loadProgress, len(dirs))
+    // The roots are listed at once, but checked one after another, so that
+    // each finds the packages that the roots before it share with it, and
+    // the export data of the standard library, already checked.
     var wg sync.WaitGroup
-
-    for w := 0; w < maxWorkers; w++ {
+    for i, dir := range dirs {
+        results[i].Dir = dir
+        imports[i] = shared.forDir(ctx, dir)
+        progress[i] = newLoadProgress(ctx)
         wg.Add(1)
-        go func() {
+        go func(i int) {
             defer wg.Done()
-            for item := range workCh {
-                generated[item.index] = generateSinglePackageWithLazyLoad(ctx, wd, env, item.pkg, opts)
-            }
-        }()
-    }
-
-    for i, pkg := range pkgs {
-        workCh <- workItem{index: i, pkg: pkg}
+            pkgs[i], results[i].Errs = imports[i].listRoots(patterns, progress[i])
+        }(i)
     }
-    close(workCh)
-
     wg.Wait()
-    return generated, nil
+    // Every root is marked before any is checked, so that a root which
+    // another imports is still checked with its function bodies.
+    for i := range dirs {
+        imports[i].markRoots(pkgs[i])
+    }
+    for i := range dirs {
+        if len(results[i].Errs) == 0 {
+            pkgs[i], results[i].Errs = imports[i].checkRoots(pkgs[i], progress[i])
+        }
+    }
+    var all []*packages.Package
+    for i := range dirs {
+        all = append(all, pkgs[i]...)
+    }
+    opts.progress.loaded(all)
+    generated := generateRoots(imports, pkgs, opts)
+    for i := range dirs {
+        if len(results[i].Errs) > 0 {
+            continue
+        }
+        results[i].Results = generated[i]
+        for _, path := range imports[i].ignored {
+            results[i].Results = append(results[i].Results, GenerateResult{PkgPath: path, Ignored: true})
+        }
+    }
+    shared.stats.report(opts.LoadStats)
+    return results, nil
 }
 
-// generateSinglePackage generates code for a single package.
-// This is extracted to enable parallel processing.
-func generateSinglePackage(pkg *packages.Package, opts *GenerateOptions) GenerateResult {
-    result := GenerateResult{
-        PkgPath: pkg.PkgPath,
+// checkPlatforms reports any platforms whose generated files would conflict.
+func checkPlatforms(platforms []Platform) []error {
+    ec := new(errorCollector)
+    for i, p := range platforms {
+        if p.GOOS == "" && p.GOARCH == "" {
+            ec.add(errors.New("platform must set GOOS or GOARCH"))
+            continue
+        }
+        for _, q := range platforms[:i] {
+            if p == q {
+                ec.add(fmt.Errorf("duplicate platform %v", p))
+            } else if p.overlaps(q) {
+                ec.add(fmt.Errorf("platforms %v and %v overlap; their generated files would conflict", q, p))
+            }
+        }
     }
+    return ec.errors
+}
 
-    outDir, err := detectOutputDir(pkg.GoFiles)
-    if err != nil {
-        result.Errs = append(result.Errs, err)
-        return result
+// checkPlatformOutputs adds to generated, the results of GenerateForPlatforms
+// for the given platforms, the problems that only show across platforms.
+// resultPlatforms holds the platform that each result was generated for.
+// The first result of a package reports its injectors that no platform's
+// build includes, and an existing output file generated for every platform,
+// which is an error that keeps any of the package's files from being written.
+func checkPlatformOutputs(generated []GenerateResult, resultPlatforms []Platform, platforms []Platform) {
+    first := make(map[string]int)
+    built := make(map[string]map[string]bool)
+    ignored := make(map[string]map[string]bool)
+    var conflicts []string
+    for i := range generated {
+        out := &generated[i]
+        if out.pkg == nil {
+            continue
+        }
+        if _, ok := first[out.PkgPath]; !ok {
+            first[out.PkgPath] = i
+            built[out.PkgPath] = make(map[string]bool)
+            ignored[out.PkgPath] = make(map[string]bool)
+            suffix := "_" + resultPlatforms[i].suffix() + ".go"
+            if strings.HasSuffix(out.OutputPath, suffix) {
+                plain := strings.TrimSuffix(out.OutputPath, suffix) + ".go"
+                if src, err := ioutil.ReadFile(plain); err == nil && IsGeneratedFile(src) {
+                    out.Errs = append(out.Errs, fmt.Errorf("%s was generated for every platform and would be built together with %s; delete it", plain, filepath.Base(out.OutputPath)))
+                    conflicts = append(conflicts, out.PkgPath)
+                }
+            }
+        }
+        for _, name := range out.pkg.GoFiles {
+            built[out.PkgPath][name] = true
+        }
+        for _, name := range out.pkg.IgnoredFiles {
+            ignored[out.PkgPath][name] = true
+        }
     }
-    result.OutputPath = filepath.Join(outDir, opts.PrefixOutputFile+"wire_gen.go")
-
-    g := newGen(pkg)
-    injectorFiles, errs := generateInjectors(g, pkg)
-    if len(errs) > 0 {
-        result.Errs = errs
-        return result
+    for _, path := range conflicts {
+        for i := range generated {
+            if generated[i].PkgPath == path {
+                generated[i].Content = nil
+                generated[i].Benchmarks = nil
+            }
+        }
     }
-
-    copyNonInjectorDecls(g, injectorFiles, pkg.TypesInfo)
-    goSrc := g.frame(opts.Tags)
-
-    if len(opts.Header) > 0 {
-        goSrc = append(opts.Header, goSrc...)
+    names := make([]string, len(platforms))
+    for i, p := range platforms {
+        names[i] = p.String()
     }
+    for path, i := range first {
+        out := &generated[i]
+        var files []string
+        for name := range ignored[path] {
+            if !built[path][name] {
+                files = append(files, name)
+            }
+        }
+        sort.Strings(files)
+        for _, name := range files {
+            f, err := parser.ParseFile(out.pkg.Fset, name, nil, parser.ParseComments)
+            if err != nil || !requiresTag(fileConstraint(f), out.buildTag) {
+                continue
+            }
+            for _, fn := range syntacticInjectors(f) {
+                err := fmt.Errorf("build constraints leave it out for every platform (%s), so it is not generated", strings.Join(names, ", "))
+                out.Warnings = append(out.Warnings, injectorError(out.pkg.Fset.Position(fn.Pos()), fn.Name.Name, err))
+            }
+        }
+    }
+}
 
-    fmtSrc, err := format.Source(goSrc)
-    if err != nil {
-        result.Errs = append(result.Errs, err)
-    } else {
-        goSrc = fmtSrc
+// syntacticInjectors returns the functions of f that call wire.Build, for
+// files that are not type-checked. The call is recognized by the name that
+// f imports the wire package as.
+func syntacticInjectors(f *ast.File) []*ast.FuncDecl {
+    wireName := ""
+    for _, imp := range f.Imports {
+        path, err := strconv.Unquote(imp.Path.Value)
+        if err != nil || !isWireImport(path) {
+            continue
+        }
+        wireName = "wire"
+        if imp.Name != nil {
+            wireName = imp.Name.Name
+        }
     }
-    result.Content = goSrc
-    return result
+    if wireName == "" || wireName == "_" || wireName == "." {
+        return nil
+    }
+    var fns []*ast.FuncDecl
+    for _, decl := range f.Decls {
+        fn, ok := decl.(*ast.FuncDecl)
+        if !ok || fn.Recv != nil || fn.Body == nil {
+            continue
+        }
+        found := false
+        ast.Inspect(fn.Body, func(n ast.Node) bool {
+            if sel, ok := n.(*ast.SelectorExpr); ok && sel.Sel.Name == "Build" {
+                if x, ok := sel.X.(*ast.Ident); ok && x.Name == wireName {
+                    found = true
+                }
+            }
+            return !found
+        })
+        if found {
+            fns = append(fns, fn)
+        }
+    }
+    return fns
 }
 
-// generateSinglePackageOptimized generates code for a single package using
-// the optimized single-pass AST traversal.
-func generateSinglePackageOptimized(pkg *packages.Package, opts *GenerateOptions) GenerateResult {
+// generatePackage generates code for a single package. Packages loaded
+// lazily, with opts.LazyLoad, are type-checked by imports.
+func generatePackage(imports *importCache, pkg *packages.Package, opts *GenerateOptions) GenerateResult {
+    defer opts.progress.packageGenerated(pkg)
     result := GenerateResult{
-        PkgPath: pkg.PkgPath,
+        PkgPath:  pkg.PkgPath,
+        pkg:      pkg,
+        buildTag: opts.buildTag(),
+        force:    opts.Force,
     }
 
-    outDir, err := detectOutputDir(pkg.GoFiles)
+    if len(pkg.Syntax) == 0 {
+        // A driver named by GOPACKAGESDRIVER may report packages without
+        // any Go files, such as for generated or assembly-only targets.
+        // They cannot declare injectors, so there is nothing to generate.
+        return result
+    }
+    goFiles := pkg.GoFiles
+    if len(goFiles) == 0 {
+        goFiles = pkg.CompiledGoFiles
+    }
+    outDir, err := detectOutputDir(goFiles)
     if err != nil {
         result.Errs = append(result.Errs, err)
         return result
     }
-    result.OutputPath = filepath.Join(outDir, opts.PrefixOutputFile+"wire_gen.go")
-
-    g := newGen(pkg)
-    // Use optimized single-pass generation
-    _, errs := generateInjectorsOptimized(g, pkg)
+    // Apply the package's own //wire:options before anything depends on
+    // them, starting with the output file name.
+    opts, errs := packageOptions(pkg, opts)
     if len(errs) > 0 {
         result.Errs = errs
         return result
     }
-
-    goSrc := g.frame(opts.Tags)
-
-    if len(opts.Header) > 0 {
-        goSrc = append(opts.Header, goSrc...)
-    }
-
-    fmtSrc, err := format.Source(goSrc)
-    if err != nil {
-        result.Errs = append(result.Errs, err)
-    } else {
-        goSrc = fmtSrc
-    }
-    result.Content = goSrc
-    return result
-}
-
-// generateSinglePackageWithLazyLoad generates code for a single package using
-// lazy loading for dependencies.
-func generateSinglePackageWithLazyLoad(ctx context.Context, wd string, env []string, pkg *packages.Package, opts *GenerateOptions) GenerateResult {
-    result := GenerateResult{
-        PkgPath: pkg.PkgPath,
+    result.OutputPath = filepath.Join(outDir, opts.outputFileName())
+    result.OptionsFingerprint = opts.OptionsFingerprint()
+    if errs := checkBuildTag(pkg, opts.buildTag()); len(errs) > 0 {
+        result.Errs = errs
+        return result
     }
-
-    outDir, err := detectOutputDir(pkg.GoFiles)
-    if err != nil {
-        result.Errs = append(result.Errs, err)
+    if errs := checkRequiredVersion(pkg, opts); len(errs) > 0 {
+        result.Errs = errs
         return result
     }
-    result.OutputPath = filepath.Join(outDir, opts.PrefixOutputFile+"wire_gen.go")
 
     g := newGen(pkg)
-    // Use lazy loading for injector generation
-    injectorFiles, errs := generateInjectorsWithLazyLoad(ctx, wd, env, g, pkg)
+    g.deprecatedAsError = opts.DeprecatedAsError
+    g.unusedParamsAsError = opts.UnusedParamsAsError
+    g.nearestWins = opts.NearestWins
+    g.annotate = opts.AnnotateOutput
+    g.ungroupedErrors = opts.UngroupedErrors
+    g.identifierPrefix = opts.IdentifierPrefix
+    g.checkCleanupRecover = opts.CheckCleanupRecover
+    g.checkGlobalReads = opts.CheckGlobalReads
+    g.checkConversions = opts.CheckConversions
+    g.runtimeCleanup = opts.UseRuntimeCleanup
+    g.wrapErrors, _ = parseWrapErrors(opts.WrapErrors)
+    g.progress = opts.progress
+    g.loadStats = imports.stats
+    var injectorFiles []*ast.File
+    if opts.LazyLoad {
+        injectorFiles, errs = generateInjectorsWithLazyLoad(imports, g, pkg)
+    } else {
+        injectorFiles, errs = generateInjectors(g, pkg)
+    }
+    result.Warnings = g.group(g.warnings)
+    result.Injectors = g.injectors
     if len(errs) > 0 {
-        result.Errs = errs
+        result.Errs = g.group(errs)
         return result
     }
 
     copyNonInjectorDecls(g, injectorFiles, pkg.TypesInfo)
-    goSrc := g.frame(opts.Tags)
-
-    if len(opts.Header) > 0 {
-        goSrc = append(opts.Header, goSrc...)
-    }
-
-    fmtSrc, err := format.Source(goSrc)
-    if err != nil {
-        result.Errs = append(result.Errs, err)
-    } else {
-        goSrc = fmtSrc
+    g.constraint = outputConstraint(injectorFiles, opts.buildTag())
+    g.finish(&result, opts)
+    if opts.EmitBenchmarks && len(result.Errs) == 0 && len(result.Content) > 0 {
+        bench, warnings := g.benchmarks(&result, opts)
+        result.Benchmarks = bench
+        result.Warnings = append(result.Warnings, g.group(warnings)...)
+        result.Errs = bench.Errs
     }
-    result.Content = goSrc
     return result
 }
 
 // generateInjectorsWithLazyLoad generates injectors using lazy package loading.
-func generateInjectorsWithLazyLoad(ctx context.Context, wd string, env []string, g *gen, pkg *packages.Package) (injectorFiles []*ast.File, _ []error) {
+func generateInjectorsWithLazyLoad(imports *importCache, g *gen, pkg *packages.Package) (injectorFiles []*ast.File, _ []error) {
     // Create object cache with lazy loading enabled
-    oc := newObjectCacheWithLazyLoad([]*packages.Package{pkg}, ctx, wd, env)
+    oc := newObjectCacheWithLazyLoad([]*packages.Package{pkg}, imports)
+    oc.nearestWins = g.nearestWins
+    defer oc.loader.wait()
     injectorFiles = make([]*ast.File, 0, len(pkg.Syntax))
     ec := new(errorCollector)
 
-    for _, f := range pkg.Syntax {
+    for _, f := range sourceFiles(pkg) {
         for _, decl := range f.Decls {
             fn, ok := decl.(*ast.FuncDecl)
             if !ok {
@@ -412,39 +1393,22 @@ func generateInjectorsWithLazyLoad(ctx context.Context, wd string, env []string,
             if buildCall == nil {
                 continue
             }
+            g.injectors++
             if len(injectorFiles) == 0 || injectorFiles[len(injectorFiles)-1] != f {
-                name := filepath.Base(g.pkg.Fset.File(f.Pos()).Name())
+                name := filepath.Base(g.pkg.Fset.Position(f.Pos()).Filename)
                 g.p("// Injectors from %s:\n\n", name)
                 injectorFiles = append(injectorFiles, f)
             }
-            sig := pkg.TypesInfo.ObjectOf(fn.Name).Type().(*types.Signature)
-            ins, _, err := injectorFuncSignature(sig)
-            if err != nil {
-                if w, ok := err.(*wireErr); ok {
-                    ec.add(notePosition(w.position, fmt.Errorf("inject %s: %v", fn.Name.Name, w.error)))
-                } else {
-                    ec.add(notePosition(g.pkg.Fset.Position(fn.Pos()), fmt.Errorf("inject %s: %v", fn.Name.Name, err)))
-                }
-                continue
-            }
-            injectorArgs := &InjectorArgs{
-                Name:  fn.Name.Name,
-                Tuple: ins,
-                Pos:   fn.Pos(),
-            }
-            set, errs := oc.processNewSet(pkg.TypesInfo, pkg.PkgPath, buildCall, injectorArgs, "")
+            errs := g.injectFunc(oc, fn, buildCall)
+            g.progress.injectorSolved(pkg, fn.Name.Name)
             if len(errs) > 0 {
-                ec.add(notePositionAll(g.pkg.Fset.Position(fn.Pos()), errs)...)
-                continue
-            }
-            if errs := g.inject(fn.Pos(), fn.Name.Name, sig, set, fn.Doc); len(errs) > 0 {
                 ec.add(errs...)
                 continue
             }
         }
 
         for _, impt := range f.Imports {
-            if impt.Name != nil && impt.Name.Name == "_" {
+            if impt.Name != nil && impt.Name.Name == "_" && !cgoImport(pkg, f, impt) {
                 g.anonImports[impt.Path.Value] = true
             }
         }
@@ -471,9 +1435,11 @@ func detectOutputDir(paths []string) (string, error) {
 // generateInjectors generates the injectors for a given package.
 func generateInjectors(g *gen, pkg *packages.Package) (injectorFiles []*ast.File, _ []error) {
     oc := newObjectCache([]*packages.Package{pkg})
+    oc.nearestWins = g.nearestWins
+    oc.stats = g.loadStats
     injectorFiles = make([]*ast.File, 0, len(pkg.Syntax))
     ec := new(errorCollector)
-    for _, f := range pkg.Syntax {
+    for _, f := range sourceFiles(pkg) {
         for _, decl := range f.Decls {
             fn, ok := decl.(*ast.FuncDecl)
             if !ok {
@@ -487,41 +1453,24 @@ func generateInjectors(g *gen, pkg *packages.Package) (injectorFiles []*ast.File
             if buildCall == nil {
                 continue
             }
+            g.injectors++
             if len(injectorFiles) == 0 || injectorFiles[len(injectorFiles)-1] != f {
                 // This is the first injector generated for this file.
                 // Write a file header.
-                name := filepath.Base(g.pkg.Fset.File(f.Pos()).Name())
+                name := filepath.Base(g.pkg.Fset.Position(f.Pos()).Filename)
                 g.p("// Injectors from %s:\n\n", name)
                 injectorFiles = append(injectorFiles, f)
             }
-            sig := pkg.TypesInfo.ObjectOf(fn.Name).Type().(*types.Signature)
-            ins, _, err := injectorFuncSignature(sig)
-            if err != nil {
-                if w, ok := err.(*wireErr); ok {
-                    ec.add(notePosition(w.position, fmt.Errorf("inject %s: %v", fn.Name.Name, w.error)))
-                } else {
-                    ec.add(notePosition(g.pkg.Fset.Position(fn.Pos()), fmt.Errorf("inject %s: %v", fn.Name.Name, err)))
-                }
-                continue
-            }
-            injectorArgs := &InjectorArgs{
-                Name:  fn.Name.Name,
-                Tuple: ins,
-                Pos:   fn.Pos(),
-            }
-            set, errs := oc.processNewSet(pkg.TypesInfo, pkg.PkgPath, buildCall, injectorArgs, "")
+            errs := g.injectFunc(oc, fn, buildCall)
+            g.progress.injectorSolved(pkg, fn.Name.Name)
             if len(errs) > 0 {
-                ec.add(notePositionAll(g.pkg.Fset.Position(fn.Pos()), errs)...)
-                continue
-            }
-            if errs := g.inject(fn.Pos(), fn.Name.Name, sig, set, fn.Doc); len(errs) > 0 {
                 ec.add(errs...)
                 continue
             }
         }
 
         for _, impt := range f.Imports {
-            if impt.Name != nil && impt.Name.Name == "_" {
+            if impt.Name != nil && impt.Name.Name == "_" && !cgoImport(pkg, f, impt) {
                 g.anonImports[impt.Path.Value] = true
             }
         }
@@ -536,7 +1485,7 @@ func generateInjectors(g *gen, pkg *packages.Package) (injectorFiles []*ast.File
 // given files into the generated output.
 func copyNonInjectorDecls(g *gen, files []*ast.File, info *types.Info) {
     for _, f := range files {
-        name := filepath.Base(g.pkg.Fset.File(f.Pos()).Name())
+        name := filepath.Base(g.pkg.Fset.Position(f.Pos()).Filename)
         first := true
         for _, decl := range f.Decls {
             switch decl := decl.(type) {
@@ -564,115 +1513,6 @@ func copyNonInjectorDecls(g *gen, files []*ast.File, info *types.Info) {
     }
 }
 
-// generateInjectorsOptimized is an optimized version that combines injector
-// generation and non-injector declaration copying in a single AST traversal.
-// This reduces processing time by 10-20% compared to separate traversals.
-func generateInjectorsOptimized(g *gen, pkg *packages.Package) (injectorFiles []*ast.File, _ []error) {
-    oc := newObjectCache([]*packages.Package{pkg})
-    injectorFiles = make([]*ast.File, 0, len(pkg.Syntax))
-    ec := new(errorCollector)
-
-    // Track non-injector declarations per file for later output
-    type fileDecls struct {
-        file  *ast.File
-        decls []ast.Decl
-    }
-    nonInjectorDecls := make([]fileDecls, 0, len(pkg.Syntax))
-
-    for _, f := range pkg.Syntax {
-        hasInjector := false
-        var currentNonInjectorDecls []ast.Decl
-
-        for _, decl := range f.Decls {
-            fn, ok := decl.(*ast.FuncDecl)
-            if !ok {
-                // Collect non-function declarations (except imports)
-                if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok != token.IMPORT {
-                    currentNonInjectorDecls = append(currentNonInjectorDecls, decl)
-                }
-                continue
-            }
-
-            buildCall, err := findInjectorBuild(pkg.TypesInfo, fn)
-            if err != nil {
-                ec.add(err)
-                continue
-            }
-
-            if buildCall == nil {
-                // Non-injector function
-                currentNonInjectorDecls = append(currentNonInjectorDecls, decl)
-                continue
-            }
-
-            // This is an injector
-            if !hasInjector {
-                hasInjector = true
-                name := filepath.Base(g.pkg.Fset.File(f.Pos()).Name())
-                g.p("// Injectors from %s:\n\n", name)
-                injectorFiles = append(injectorFiles, f)
-            }
-
-            sig := pkg.TypesInfo.ObjectOf(fn.Name).Type().(*types.Signature)
-            ins, _, err := injectorFuncSignature(sig)
-            if err != nil {
-                if w, ok := err.(*wireErr); ok {
-                    ec.add(notePosition(w.position, fmt.Errorf("inject %s: %v", fn.Name.Name, w.error)))
-                } else {
-                    ec.add(notePosition(g.pkg.Fset.Position(fn.Pos()), fmt.Errorf("inject %s: %v", fn.Name.Name, err)))
-                }
-                continue
-            }
-
-            injectorArgs := &InjectorArgs{
-                Name:  fn.Name.Name,
-                Tuple: ins,
-                Pos:   fn.Pos(),
-            }
-            set, errs := oc.processNewSet(pkg.TypesInfo, pkg.PkgPath, buildCall, injectorArgs, "")
-            if len(errs) > 0 {
-                ec.add(notePositionAll(g.pkg.Fset.Position(fn.Pos()), errs)...)
-                continue
-            }
-            if errs := g.inject(fn.Pos(), fn.Name.Name, sig, set, fn.Doc); len(errs) > 0 {
-                ec.add(errs...)
-                continue
-            }
-        }
-
-        // Collect anonymous imports
-        for _, impt := range f.Imports {
-            if impt.Name != nil && impt.Name.Name == "_" {
-                g.anonImports[impt.Path.Value] = true
-            }
-        }
-
-        // Store non-injector declarations if this file has injectors
-        if hasInjector && len(currentNonInjectorDecls) > 0 {
-            nonInjectorDecls = append(nonInjectorDecls, fileDecls{
-                file:  f,
-                decls: currentNonInjectorDecls,
-            })
-        }
-    }
-
-    if len(ec.errors) > 0 {
-        return nil, ec.errors
-    }
-
-    // Output non-injector declarations
-    for _, fd := range nonInjectorDecls {
-        name := filepath.Base(g.pkg.Fset.File(fd.file.Pos()).Name())
-        g.p("// %s:\n\n", name)
-        for _, decl := range fd.decls {
-            g.writeAST(pkg.TypesInfo, decl)
-            g.p("\n\n")
-        }
-    }
-
-    return injectorFiles, nil
-}
-
 // importInfo holds info about an import.
 type importInfo struct {
     // name is the identifier that is used in the generated source.
@@ -689,29 +1529,163 @@ type gen struct {
     imports     map[string]importInfo
     anonImports map[string]bool
     values      map[ast.Expr]string
+
+    // aliases maps import paths to the names that the package's own source
+    // files import them as, when that differs from the package name.
+    aliases map[string]string
+
+    // deprecatedAsError reports uses of deprecated providers as errors
+    // instead of adding them to warnings.
+    deprecatedAsError bool
+    warnings          []error
+
+    // unusedParamsAsError reports unused injector parameters as errors
+    // instead of adding them to warnings.
+    unusedParamsAsError bool
+
+    // nearestWins applies nearest-wins precedence to the sets built by
+    // wire.Build.
+    nearestWins bool
+
+    // annotate adds a comment to each injector statement describing where
+    // its value comes from. annotations records the text of these comments
+    // so that they can be left out of the content hash.
+    annotate    bool
+    annotations map[string]bool
+
+    // injectors counts the injector functions found in the package.
+    injectors int
+    // fileNames maps the package-level identifiers that the generated file
+    // declares, other than injectors under their own names and the copies
+    // of declarations from injector files, to a description of what they
+    // name. They are injectors renamed by //wire:name and the helpers that
+    // Wire declares for injectors, such as the variables of wire.Value
+    // expressions. Each is reserved by reserveName or newName, so that the
+    // helpers of different injectors never share a name.
+    fileNames map[string]string
+
+    // ungroupedErrors reports errors that several injectors share once for
+    // each injector.
+    ungroupedErrors bool
+
+    // identifierPrefix starts the names of generated package-level
+    // identifiers other than injectors.
+    identifierPrefix string
+
+    // checkCleanupRecover warns about recover calls in the cleanup
+    // functions of providers.
+    checkCleanupRecover bool
+
+    // checkGlobalReads warns about reads of package-level variables of
+    // other packages in providers.
+    checkGlobalReads bool
+
+    // checkConversions reports providers that only convert between named
+    // types with the same underlying type as errors.
+    checkConversions bool
+
+    // runtimeCleanup makes injectors run their cleanup functions with
+    // wire.CleanupFuncs.
+    runtimeCleanup bool
+
+    // wrapErrors, if not nil, is the template for the message that
+    // injectors wrap provider errors with.
+    wrapErrors *template.Template
+
+    // progress reports each injector as it is solved.
+    progress *progress
+
+    // loadStats, if not nil, counts the packages that the object caches
+    // of the injectors look up.
+    loadStats *loadStats
+
+    // constraint, if not nil, is the build constraint of the generated
+    // file before any platform terms. It is set by generatePackage from
+    // the injector files.
+    constraint constraint.Expr
+
+    // generated lists the injectors that code was generated for, in order.
+    generated []generatedInjector
+    // companion marks a gen for a file that goes with the generated file,
+    // such as its benchmarks, which must not repeat its go:generate
+    // directive.
+    companion bool
 }
 
 func newGen(pkg *packages.Package) *gen {
     return &gen{
-        pkg:         pkg,
-        anonImports: make(map[string]bool),
-        imports:     make(map[string]importInfo),
-        values:      make(map[ast.Expr]string),
+        pkg:           pkg,
+        anonImports:   make(map[string]bool),
+        imports:       make(map[string]importInfo),
+        values:        make(map[ast.Expr]string),
+        aliases:       importAliases(sourceFiles(pkg)),
+        annotations:   make(map[string]bool),
+        fileNames:     make(map[string]string),
     }
 }
 
+// importAliases returns the explicit names that files use to import
+// packages, keyed by import path. If files disagree on the name for a path,
+// the first file wins.
+func importAliases(files []*ast.File) map[string]string {
+    aliases := make(map[string]string)
+    for _, f := range files {
+        for _, impt := range f.Imports {
+            if impt.Name == nil || impt.Name.Name == "_" || impt.Name.Name == "." {
+                continue
+            }
+            path, err := strconv.Unquote(impt.Path.Value)
+            if err != nil {
+                continue
+            }
+            if _, ok := aliases[path]; !ok {
+                aliases[path] = impt.Name.Name
+            }
+        }
+    }
+    return aliases
+}
+
+// group returns errs with the errors that several injectors share grouped
+// together, unless grouping is turned off.
+func (g *gen) group(errs []error) []error {
+    if g.ungroupedErrors {
+        return errs
+    }
+    return groupErrors(errs)
+}
+
 // frame bakes the built up source body into an unformatted Go source file.
-func (g *gen) frame(tags string) []byte {
+func (g *gen) frame(opts *GenerateOptions) []byte {
     if g.buf.Len() == 0 {
         return nil
     }
     var buf bytes.Buffer
+    tags := opts.Tags
     if len(tags) > 0 {
         tags = fmt.Sprintf(" gen -tags \"%s\"", tags)
     }
-    buf.WriteString("// Code generated by Wire. DO NOT EDIT.\n\n")
-    buf.WriteString("//go:generate go run -mod=mod github.com/google/wire/cmd/wire" + tags + "\n")
-    buf.WriteString("//+build !wireinject\n\n")
+    x := g.constraint
+    if x == nil {
+        x = notTag(opts.buildTag())
+    }
+    writeGeneratedHeader(&buf, opts)
+    if opts.platformSuffix {
+        // Running go generate on a platform-specific file would regenerate
+        // only the host platform, so omit the directive.
+        p := Platform{GOOS: opts.GOOS, GOARCH: opts.GOARCH}
+        x = &constraint.AndExpr{X: x, Y: p.constraint()}
+    } else if !g.companion {
+        buf.WriteString("//go:generate go run -mod=mod github.com/google/wire/cmd/wire" + tags + "\n")
+    }
+    buf.WriteString("//go:build " + x.String() + "\n")
+    lines, err := constraint.PlusBuildLines(x)
+    if err == nil {
+        for _, line := range lines {
+            buf.WriteString(line + "\n")
+        }
+    }
+    buf.WriteString("\n")
     buf.WriteString("package ")
     buf.WriteString(g.pkg.Name)
     buf.WriteString("\n\n")
@@ -744,29 +1718,261 @@ func (g *gen) frame(tags string) []byte {
         for _, path := range anonImps {
             fmt.Fprintf(&buf, "\t_ %s\n", path)
         }
-        buf.WriteString(")\n\n")
+        buf.WriteString(")\n\n")
+    }
+    buf.Write(g.buf.Bytes())
+    return buf.Bytes()
+}
+
+// finish fills in the content of result from the code generated by g,
+// formatting, hashing and, if opts ask for it, verifying the code. It runs
+// in the worker that generated the package, so that with Concurrency above
+// one the outputs, and any opts.Format hook, are formatted in parallel.
+func (g *gen) finish(result *GenerateResult, opts *GenerateOptions) {
+    goSrc := g.frame(opts)
+    if len(opts.Header) > 0 {
+        goSrc = append(opts.Header, goSrc...)
+    }
+    fmtSrc, err := format.Source(goSrc)
+    if err == nil && len(fmtSrc) > 0 {
+        fmtSrc, err = normalizeSource(fmtSrc)
+    }
+    if err != nil {
+        // This is likely a bug from a poorly generated source file.
+        // Add an error but also the unformatted source.
+        result.Errs = append(result.Errs, err)
+    } else {
+        goSrc = fmtSrc
+        if opts.Format != nil && len(goSrc) > 0 {
+            out, err := opts.Format(result.OutputPath, goSrc)
+            if err != nil {
+                result.Errs = append(result.Errs, fmt.Errorf("%s: %v", result.OutputPath, err))
+                return
+            }
+            goSrc = out
+        }
+    }
+    if len(goSrc) > 0 {
+        goSrc = addChecksum(goSrc)
+    }
+    result.Content = goSrc
+    if len(goSrc) > 0 {
+        sum := sha256.Sum256(stripGeneratedHeader(g.stripAnnotations(goSrc)))
+        result.ContentHash = hex.EncodeToString(sum[:])
+    }
+    if opts.VerifyOutput && len(result.Errs) == 0 {
+        result.Errs = result.Verify()
+    }
+}
+
+// stripAnnotations returns src without the annotation comments that g
+// added to it. gofmt may align trailing comments, so the whitespace before
+// each annotation is removed along with it.
+func (g *gen) stripAnnotations(src []byte) []byte {
+    if len(g.annotations) == 0 {
+        return src
+    }
+    lines := bytes.SplitAfter(src, []byte("\n"))
+    for i, line := range lines {
+        j := bytes.LastIndex(line, []byte(" // "))
+        if j < 0 || !g.annotations[strings.TrimSuffix(string(line[j+len(" // "):]), "\n")] {
+            continue
+        }
+        code := bytes.TrimRight(line[:j], " \t")
+        lines[i] = append(code[:len(code):len(code)], '\n')
+    }
+    return bytes.Join(lines, nil)
+}
+
+// injectFunc emits the code for the injector fn, whose body calls buildCall.
+//
+// A panic while analyzing the injector is reported as an internal error at
+// the injector's position, so that a bug in Wire affects only that injector
+// rather than crashing the whole run.
+func (g *gen) injectFunc(oc *objectCache, fn *ast.FuncDecl, buildCall *ast.CallExpr) (errs []error) {
+    defer func() {
+        if r := recover(); r != nil {
+            errs = []error{internalError(g.pkg.Fset.Position(fn.Pos()), fn.Name.Name, r)}
+        }
+    }()
+    pkg := g.pkg
+    sig := pkg.TypesInfo.ObjectOf(fn.Name).Type().(*types.Signature)
+    ins, out, err := injectorFuncSignature(sig)
+    if err != nil {
+        return []error{injectorError(g.pkg.Fset.Position(fn.Pos()), fn.Name.Name, err)}
+    }
+    injectorArgs := &InjectorArgs{
+        Name:  fn.Name.Name,
+        Tuple: ins,
+        Pos:   fn.Pos(),
+    }
+    set, errs := oc.processNewSet(pkg.TypesInfo, pkg.PkgPath, buildCall, injectorArgs, "")
+    if len(errs) > 0 {
+        return notePositionAll(g.pkg.Fset.Position(fn.Pos()), errs)
+    }
+    if hasDirective(fn.Doc, autoBindDirective) {
+        if err := autoBind(oc.hasher, set, out.out, fn.Pos()); err != nil {
+            return []error{injectorError(g.pkg.Fset.Position(fn.Pos()), fn.Name.Name, err)}
+        }
     }
-    buf.Write(g.buf.Bytes())
-    return buf.Bytes()
+    if err := checkTestOnly(g.pkg.Fset, fn.Pos(), set); err != nil {
+        return []error{injectorError(g.pkg.Fset.Position(fn.Pos()), fn.Name.Name, err)}
+    }
+    genName, err := g.injectorName(fn)
+    if err != nil {
+        return []error{injectorError(g.pkg.Fset.Position(fn.Pos()), fn.Name.Name, err)}
+    }
+    errs = g.inject(fn.Pos(), fn.Name.Name, genName, sig, set, fn.Doc)
+    if len(errs) > 0 {
+        suggestFixes(pkg, fn, buildCall, set, errs)
+        return errs
+    }
+    g.generated = append(g.generated, generatedInjector{
+        pos:     fn.Pos(),
+        name:    fn.Name.Name,
+        genName: genName,
+        sig:     sig,
+        once:    hasDirective(fn.Doc, onceDirective),
+    })
+    return nil
+}
+
+// nameDirective sets the name of the function generated for an injector,
+// which is otherwise the name of the injector declaration.
+const nameDirective = "//wire:name"
+
+// onceDirective makes an injector without parameters build its results
+// once per process: the first call runs the providers, and every call
+// returns the results of the first, including its error and its cleanup
+// function, which must then be called only once. Concurrent first calls
+// wait for the one that runs the providers. If that call panics, later
+// calls return zero values, as with sync.Once.
+const onceDirective = "//wire:once"
+
+// onceNames names the declarations generated for an injector marked with
+// onceDirective.
+type onceNames struct {
+    // impl is the function that runs the providers.
+    impl string
+    // results is the variable that holds the sync.Once and the results.
+    results string
+}
+
+// onceNames returns the names of the declarations for the injector
+// genName, marked with onceDirective, and reserves them.
+func (g *gen) onceNames(genName string) *onceNames {
+    what := "a helper of injector " + genName
+    impl := g.newName(g.identifierPrefix+"_wire"+export(genName), what)
+    results := g.newName(impl+"Once", what)
+    return &onceNames{impl: impl, results: results}
+}
+
+// injectorName returns the name of the function to generate for the
+// injector fn and records it. The name given by a //wire:name directive must
+// not be declared anywhere else in the package.
+func (g *gen) injectorName(fn *ast.FuncDecl) (string, error) {
+    name, ok := directiveArg(fn.Doc, nameDirective)
+    if !ok || name == fn.Name.Name {
+        return fn.Name.Name, nil
+    }
+    if !token.IsIdentifier(name) || name == "_" {
+        return "", fmt.Errorf("%s %q is not a valid function name", nameDirective, name)
+    }
+    if obj := g.pkg.Types.Scope().Lookup(name); obj != nil {
+        return "", fmt.Errorf("%s %s conflicts with the declaration at %v", nameDirective, name, g.pkg.Fset.Position(obj.Pos()))
+    }
+    if what, ok := g.fileNames[name]; ok {
+        return "", fmt.Errorf("%s %s is also the generated name of %s", nameDirective, name, what)
+    }
+    return g.reserveName(name, "another injector"), nil
 }
 
-// inject emits the code for an injector.
-func (g *gen) inject(pos token.Pos, name string, sig *types.Signature, set *ProviderSet, doc *ast.CommentGroup) []error {
+// inject emits the code for an injector. name is the name of the injector
+// declaration, which errors refer to, and genName the name of the generated
+// function.
+func (g *gen) inject(pos token.Pos, name, genName string, sig *types.Signature, set *ProviderSet, doc *ast.CommentGroup) []error {
     injectSig, err := funcOutput(sig)
     if err != nil {
-        return []error{notePosition(g.pkg.Fset.Position(pos),
-            fmt.Errorf("inject %s: %v", name, err))}
+        return []error{injectorError(g.pkg.Fset.Position(pos), name, err)}
+    }
+    if named := hasDirective(doc, namedCleanupDirective); named != injectSig.namedCleanup {
+        if named {
+            err = fmt.Errorf("%s injector must return *wire.Cleanups as its cleanup", namedCleanupDirective)
+        } else {
+            err = fmt.Errorf("injector returns *wire.Cleanups but is not marked %s", namedCleanupDirective)
+        }
+        return []error{injectorError(g.pkg.Fset.Position(pos), name, err)}
+    }
+    if set.CleanupRegistry != nil && injectSig.cleanup {
+        return []error{injectorError(g.pkg.Fset.Position(pos), name,
+            errors.New("injector that uses wire.CleanupInto must not return a cleanup function"))}
+    }
+    once := hasDirective(doc, onceDirective)
+    if once && sig.Params().Len() > 0 {
+        return []error{injectorError(g.pkg.Fset.Position(pos), name,
+            fmt.Errorf("%s injector must not have parameters, since every call after the first would ignore them", onceDirective))}
     }
     params := sig.Params()
-    calls, errs := solve(g.pkg.Fset, injectSig.out, params, set)
+    calls, errs := solveInjector(g.pkg.Fset, injectSig.out, params, set)
     if len(errs) > 0 {
         return mapErrors(errs, func(e error) error {
-            if w, ok := e.(*wireErr); ok {
-                return notePosition(w.position, fmt.Errorf("inject %s: %v", name, w.error))
-            }
-            return notePosition(g.pkg.Fset.Position(pos), fmt.Errorf("inject %s: %v", name, e))
+            return injectorError(g.pkg.Fset.Position(pos), name, e)
         })
     }
+    var deprecated []error
+    for _, d := range findDeprecated(set, calls) {
+        deprecated = append(deprecated, injectorError(g.pkg.Fset.Position(pos), name,
+            fmt.Errorf("%s is deprecated: %s", d.src.description(g.pkg.Fset, d.typ), d.text)))
+    }
+    if g.deprecatedAsError && len(deprecated) > 0 {
+        return deprecated
+    }
+    var unusedParams []error
+    for _, err := range checkParamsUsed(params, calls, set, injectSig.out) {
+        unusedParams = append(unusedParams, injectorError(g.pkg.Fset.Position(pos), name, err))
+    }
+    if g.unusedParamsAsError && len(unusedParams) > 0 {
+        return unusedParams
+    }
+    if g.checkConversions {
+        var conversions []error
+        for _, c := range calls {
+            if c.convertsFrom != nil {
+                conversions = append(conversions, injectorError(g.pkg.Fset.Position(pos), name, notePosition(g.pkg.Fset.Position(c.pos),
+                    fmt.Errorf("provider %q only converts %s to %s, which have the same underlying type %s", c.pkg.Name()+"."+c.name,
+                        types.TypeString(c.convertsFrom, nil), types.TypeString(c.out, nil), types.TypeString(c.out.Underlying(), nil)))))
+            }
+        }
+        if len(conversions) > 0 {
+            return conversions
+        }
+    }
+    g.warnings = append(g.warnings, deprecated...)
+    g.warnings = append(g.warnings, unusedParams...)
+    for _, c := range calls {
+        for i, t := range c.outs {
+            if !c.usedOuts[i] {
+                g.warnings = append(g.warnings, injectorError(g.pkg.Fset.Position(pos), name,
+                    fmt.Errorf("result %s of provider %q is unused", types.TypeString(t, nil), c.pkg.Name()+"."+c.name)))
+            }
+        }
+    }
+    if g.checkCleanupRecover {
+        for _, c := range calls {
+            for _, rpos := range c.cleanupRecovers {
+                g.warnings = append(g.warnings, injectorError(g.pkg.Fset.Position(pos), name, notePosition(g.pkg.Fset.Position(rpos),
+                    fmt.Errorf("cleanup function of provider %q calls recover, which can swallow panics", c.pkg.Name()+"."+c.name))))
+            }
+        }
+    }
+    if g.checkGlobalReads {
+        for _, c := range calls {
+            for _, r := range c.globalReads {
+                g.warnings = append(g.warnings, injectorError(g.pkg.Fset.Position(pos), name, notePosition(g.pkg.Fset.Position(r.Pos),
+                    fmt.Errorf("provider %q reads package-level variable %s.%s, which may not be set yet when the injector runs", c.pkg.Name()+"."+c.name, r.Var.Pkg().Name(), r.Var.Name()))))
+            }
+        }
+    }
     type pendingVar struct {
         name     string
         expr     ast.Expr
@@ -776,31 +1982,39 @@ func (g *gen) inject(pos token.Pos, name string, sig *types.Signature, set *Prov
     ec := new(errorCollector)
     for i := range calls {
         c := &calls[i]
-        if c.hasCleanup && !injectSig.cleanup {
+        if c.hasCleanup && !injectSig.cleanup && set.CleanupRegistry == nil {
             ts := types.TypeString(c.out, nil)
-            ec.add(notePosition(
-                g.pkg.Fset.Position(pos),
-                fmt.Errorf("inject %s: provider for %s returns cleanup but injection does not return cleanup function", name, ts)))
+            ec.add(injectorError(
+                g.pkg.Fset.Position(pos), name,
+                fmt.Errorf("provider for %s returns cleanup but injection does not return cleanup function", ts)))
         }
         if c.hasErr && !injectSig.err {
             ts := types.TypeString(c.out, nil)
-            ec.add(notePosition(
-                g.pkg.Fset.Position(pos),
-                fmt.Errorf("inject %s: provider for %s returns error but injection not allowed to fail", name, ts)))
+            ec.add(injectorError(
+                g.pkg.Fset.Position(pos), name,
+                fmt.Errorf("provider for %s returns error but injection not allowed to fail", ts)))
+        }
+        for _, t := range spelledTypes(c) {
+            if u := unnamedTypeOutside(t, g.pkg.PkgPath); u != nil {
+                ec.add(injectorError(
+                    g.pkg.Fset.Position(pos), name,
+                    notePosition(g.pkg.Fset.Position(c.pos),
+                        fmt.Errorf("%s uses the unnamed type %s, which package %s cannot refer to; define a named type for it", describeCall(c), types.TypeString(u, nil), g.pkg.PkgPath))))
+            }
         }
         if c.kind == valueExpr {
             if err := accessibleFrom(c.valueTypeInfo, c.valueExpr, g.pkg.PkgPath); err != nil {
                 // TODO(light): Display line number of value expression.
                 ts := types.TypeString(c.out, nil)
-                ec.add(notePosition(
-                    g.pkg.Fset.Position(pos),
-                    fmt.Errorf("inject %s: value %s can't be used: %v", name, ts, err)))
+                ec.add(injectorError(
+                    g.pkg.Fset.Position(pos), name,
+                    fmt.Errorf("value %s can't be used: %v", ts, err)))
             }
             if g.values[c.valueExpr] == "" {
                 t := c.valueTypeInfo.TypeOf(c.valueExpr)
 
-                name := typeVariableName(t, "", func(name string) string { return "_wire" + export(name) + "Value" }, g.nameInFileScope)
-                g.values[c.valueExpr] = name
+                name := typeVariableName(t, "", func(name string) string { return g.identifierPrefix + "_wire" + export(name) + "Value" }, g.nameInFileScope)
+                g.values[c.valueExpr] = g.reserveName(name, "the variable of a wire.Value expression")
                 pendingVars = append(pendingVars, pendingVar{
                     name:     name,
                     expr:     c.valueExpr,
@@ -813,17 +2027,32 @@ func (g *gen) inject(pos token.Pos, name string, sig *types.Signature, set *Prov
         return ec.errors
     }
 
+    var onceNames *onceNames
+    if once {
+        onceNames = g.onceNames(genName)
+    }
+
     // Perform one pass to collect all imports, followed by the real pass.
-    injectPass(name, sig, calls, set, doc, &injectorGen{
+    // The real pass names the locals after every package qualifier that the
+    // injector uses is known, so that no local shadows one of them.
+    injectPass(genName, sig, calls, set, doc, &injectorGen{
         g:       g,
+        set:     set,
         errVar:  disambiguate("err", g.nameInFileScope),
+        once:    onceNames,
         discard: true,
     })
-    injectPass(name, sig, calls, set, doc, &injectorGen{
+    numImports := len(g.imports)
+    injectPass(genName, sig, calls, set, doc, &injectorGen{
         g:       g,
+        set:     set,
         errVar:  disambiguate("err", g.nameInFileScope),
+        once:    onceNames,
         discard: false,
     })
+    if len(g.imports) != numImports {
+        panic("wire: injector imported a package that the discard pass did not")
+    }
     if len(pendingVars) > 0 {
         g.p("var (\n")
         for _, pv := range pendingVars {
@@ -969,6 +2198,31 @@ func (g *gen) qualifiedID(pkgName, pkgPath, sym string) string {
     return name + "." + sym
 }
 
+// typeArgList returns the type argument list for instantiating a generic
+// function or type with typeArgs, such as "[int, string]", or the empty
+// string if typeArgs is empty.
+func (g *gen) typeArgList(typeArgs []types.Type) string {
+    if len(typeArgs) == 0 {
+        return ""
+    }
+    args := make([]string, len(typeArgs))
+    for i, t := range typeArgs {
+        args[i] = types.TypeString(t, g.qualifyPkg)
+    }
+    return "[" + strings.Join(args, ", ") + "]"
+}
+
+// wirePackage returns the wire package that the injector files of g.pkg
+// import to call wire.Build.
+func (g *gen) wirePackage() *types.Package {
+    for _, imp := range g.pkg.Types.Imports() {
+        if isWireImport(imp.Path()) {
+            return imp
+        }
+    }
+    panic("wire: package with injectors does not import the wire package")
+}
+
 func (g *gen) qualifyImport(name, path string) string {
     if path == g.pkg.PkgPath {
         return ""
@@ -982,11 +2236,17 @@ func (g *gen) qualifyImport(name, path string) string {
     if info, ok := g.imports[unvendored]; ok {
         return info.name
     }
-    // TODO(light): Use parts of import path to disambiguate.
-    newName := disambiguate(name, func(n string) bool {
+    collides := func(n string) bool {
         // Don't let an import take the "err" name. That's annoying.
         return n == "err" || g.nameInFileScope(n)
-    })
+    }
+    // Prefer the name the package's own files use for the import, so that
+    // both files refer to it the same way.
+    newName := g.aliases[unvendored]
+    if newName == "" || collides(newName) {
+        // TODO(light): Use parts of import path to disambiguate.
+        newName = disambiguate(name, collides)
+    }
     g.imports[unvendored] = importInfo{
         name:    newName,
         differs: newName != name,
@@ -1000,31 +2260,69 @@ func (g *gen) nameInFileScope(name string) bool {
             return true
         }
     }
-    for _, other := range g.values {
-        if other == name {
-            return true
-        }
+    if _, ok := g.fileNames[name]; ok {
+        return true
     }
     _, obj := g.pkg.Types.Scope().LookupParent(name, token.NoPos)
     return obj != nil
 }
 
+// reserveName records that the generated file declares name, which
+// nameInFileScope must not report yet, as what, and returns it.
+func (g *gen) reserveName(name, what string) string {
+    g.fileNames[name] = what
+    return name
+}
+
+// newName reserves and returns name, or if name is already in the file
+// scope, the first variant of it that disambiguate finds that is not.
+func (g *gen) newName(name, what string) string {
+    return g.reserveName(disambiguate(name, g.nameInFileScope), what)
+}
+
 func (g *gen) qualifyPkg(pkg *types.Package) string {
     return g.qualifyImport(pkg.Name(), pkg.Path())
 }
 
+// describeQualifier qualifies types by package name without adding imports,
+// for text such as annotations that must not change which packages the file
+// uses.
+func (g *gen) describeQualifier(pkg *types.Package) string {
+    if pkg == g.pkg.Types {
+        return ""
+    }
+    return pkg.Name()
+}
+
 func (g *gen) p(format string, args ...interface{}) {
     fmt.Fprintf(&g.buf, format, args...)
 }
 
 // injectorGen is the per-injector pass generator state.
 type injectorGen struct {
-    g *gen
+    g   *gen
+    set *ProviderSet
 
     paramNames   []string
     localNames   []string
+    // localTypes holds the type of each of localNames.
+    localTypes   []types.Type
+    // localOwners maps the names that the locals of several types would be
+    // named first to the type whose local gets the name; see localOwners.
+    localOwners  map[string]types.Type
     cleanupNames []string
-    errVar       string
+    // cleanupTypes names the type that each of cleanupNames cleans up, for
+    // injectors that return a *wire.Cleanups.
+    cleanupTypes []string
+    // registry is the expression for the registry of wire.CleanupInto once
+    // the injector has it, and registered is the number of cleanupNames
+    // appended to it, which the injector no longer runs itself.
+    registry   string
+    registered int
+    errVar     string
+    // once, if not nil, makes the injector call a function that runs the
+    // providers once, as with onceDirective.
+    once *onceNames
 
     // discard causes ig.p and ig.writeAST to no-op. Useful to run
     // generation for side-effects like filling in g.imports.
@@ -1042,9 +2340,18 @@ func injectPass(name string, sig *types.Signature, calls []call, set *ProviderSe
     }
     if doc != nil {
         for _, c := range doc.List {
+            // Directives such as //wire:name are for the declaration only.
+            if strings.HasPrefix(c.Text, "//wire:") {
+                continue
+            }
             ig.p("%s\n", c.Text)
         }
     }
+    if ig.once != nil {
+        ig.onceWrapper(name, sig, injectSig)
+        ig.p("// %s runs the providers of %s, which calls it once.\n", ig.once.impl, name)
+        name = ig.once.impl
+    }
     ig.p("func %s(", name)
     for i := 0; i < params.Len(); i++ {
         if i > 0 {
@@ -1067,39 +2374,74 @@ func injectPass(name string, sig *types.Signature, calls []call, set *ProviderSe
         }
     }
     outTypeString := types.TypeString(injectSig.out, ig.g.qualifyPkg)
+    cleanupTypeString := "func()"
+    if injectSig.namedCleanup {
+        cleanupTypeString = types.TypeString(sig.Results().At(1).Type(), ig.g.qualifyPkg)
+    }
     switch {
     case injectSig.cleanup && injectSig.err:
-        ig.p(") (%s, func(), error) {\n", outTypeString)
+        ig.p(") (%s, %s, error) {\n", outTypeString, cleanupTypeString)
     case injectSig.cleanup:
-        ig.p(") (%s, func()) {\n", outTypeString)
+        ig.p(") (%s, %s) {\n", outTypeString, cleanupTypeString)
     case injectSig.err:
         ig.p(") (%s, error) {\n", outTypeString)
     default:
         ig.p(") %s {\n", outTypeString)
     }
+    ig.localOwners = localOwners(calls)
     for i := range calls {
         c := &calls[i]
-        lname := typeVariableName(c.out, "v", unexport, ig.nameInInjector)
+        if c.outs != nil {
+            lnames := make([]string, len(c.outs))
+            for j, t := range c.outs {
+                lnames[j] = "_"
+                if c.usedOuts[j] {
+                    lnames[j] = ig.localName(t)
+                }
+                ig.localNames = append(ig.localNames, lnames[j])
+                ig.localTypes = append(ig.localTypes, t)
+            }
+            ig.funcProviderCall(lnames, c, injectSig)
+            ig.registerCleanups(set)
+            continue
+        }
+        lname := ig.localName(c.out)
         ig.localNames = append(ig.localNames, lname)
+        ig.localTypes = append(ig.localTypes, c.out)
         switch c.kind {
         case structProvider:
             ig.structProviderCall(lname, c)
         case funcProviderCall:
-            ig.funcProviderCall(lname, c, injectSig)
+            ig.funcProviderCall([]string{lname}, c, injectSig)
         case valueExpr:
             ig.valueExpr(lname, c)
         case selectorExpr:
             ig.fieldExpr(lname, c)
+        case sliceLit:
+            ig.sliceLit(lname, c)
+        case mapLit:
+            ig.mapLit(lname, c)
         default:
             panic("unknown kind")
         }
+        ig.registerCleanups(set)
     }
-    if len(calls) == 0 {
-        ig.p("\treturn %s", ig.paramNames[set.For(injectSig.out).Arg().Index])
-    } else {
-        ig.p("\treturn %s", ig.localNames[len(calls)-1])
-    }
-    if injectSig.cleanup {
+    ig.p("\treturn %s", ig.valueOf(set, injectSig.out))
+    switch {
+    case injectSig.namedCleanup:
+        // The cleanups are listed in creation order; Close reverses it.
+        wirePkg := unalias(unalias(sig.Results().At(1).Type()).(*types.Pointer).Elem()).(*types.Named).Obj().Pkg()
+        ig.p(", %s([]string{", ig.g.qualifiedID(wirePkg.Name(), wirePkg.Path(), "NewCleanups"))
+        for i, t := range ig.cleanupTypes {
+            if i > 0 {
+                ig.p(", ")
+            }
+            ig.p("%s", strconv.Quote(t))
+        }
+        ig.p("}, []func(){%s})", strings.Join(ig.cleanupNames, ", "))
+    case injectSig.cleanup && ig.g.runtimeCleanup:
+        ig.p(", %s.Run", ig.cleanupFuncs(ig.cleanupNames))
+    case injectSig.cleanup:
         ig.p(", func() {\n")
         for i := len(ig.cleanupNames) - 1; i >= 0; i-- {
             ig.p("\t\t%s()\n", ig.cleanupNames[i])
@@ -1112,19 +2454,63 @@ func injectPass(name string, sig *types.Signature, calls []call, set *ProviderSe
     ig.p("\n}\n\n")
 }
 
-func (ig *injectorGen) funcProviderCall(lname string, c *call, injectSig outputSignature) {
-    ig.p("\t%s", lname)
+// onceWrapper writes the injector name, marked with onceDirective, which
+// returns the memoized results of ig.once.impl, and the variable that holds
+// them.
+func (ig *injectorGen) onceWrapper(name string, sig *types.Signature, injectSig outputSignature) {
+    fields := []string{"value"}
+    typeStrings := []string{types.TypeString(injectSig.out, ig.g.qualifyPkg)}
+    if injectSig.cleanup {
+        fields = append(fields, "cleanup")
+        typeStrings = append(typeStrings, types.TypeString(sig.Results().At(1).Type(), ig.g.qualifyPkg))
+    }
+    if injectSig.err {
+        fields = append(fields, "err")
+        typeStrings = append(typeStrings, "error")
+    }
+    results := make([]string, len(fields))
+    for i, f := range fields {
+        results[i] = ig.once.results + "." + f
+    }
+    list := strings.Join(results, ", ")
+
+    if len(typeStrings) == 1 {
+        ig.p("func %s() %s {\n", name, typeStrings[0])
+    } else {
+        ig.p("func %s() (%s) {\n", name, strings.Join(typeStrings, ", "))
+    }
+    ig.p("\t%s.Do(func() {\n", ig.once.results)
+    ig.p("\t\t%s = %s()\n", list, ig.once.impl)
+    ig.p("\t})\n")
+    ig.p("\treturn %s\n", list)
+    ig.p("}\n\n")
+
+    ig.p("// %s holds the results that every call to %s returns.\n", ig.once.results, name)
+    if injectSig.cleanup {
+        ig.p("// The cleanup function must be called only once.\n")
+    }
+    ig.p("var %s struct {\n", ig.once.results)
+    ig.p("\t%s\n", ig.g.qualifiedID("sync", "sync", "Once"))
+    for i, f := range fields {
+        ig.p("\t%s %s\n", f, typeStrings[i])
+    }
+    ig.p("}\n\n")
+}
+
+func (ig *injectorGen) funcProviderCall(lnames []string, c *call, injectSig outputSignature) {
+    ig.p("\t%s", strings.Join(lnames, ", "))
     prevCleanup := len(ig.cleanupNames)
     if c.hasCleanup {
         cname := disambiguate("cleanup", ig.nameInInjector)
         ig.cleanupNames = append(ig.cleanupNames, cname)
+        ig.cleanupTypes = append(ig.cleanupTypes, types.TypeString(c.out, (*types.Package).Name))
         ig.p(", %s", cname)
     }
     if c.hasErr {
         ig.p(", %s", ig.errVar)
     }
     ig.p(" := ")
-    ig.p("%s(", ig.g.qualifiedID(c.pkg.Name(), c.pkg.Path(), c.name))
+    ig.p("%s%s(", ig.g.qualifiedID(c.pkg.Name(), c.pkg.Path(), c.name), ig.g.typeArgList(c.typeArgs))
     for i, a := range c.args {
         if i > 0 {
             ig.p(", ")
@@ -1138,29 +2524,100 @@ func (ig *injectorGen) funcProviderCall(lname string, c *call, injectSig outputS
     if c.varargs {
         ig.p("...")
     }
-    ig.p(")\n")
+    ig.p(")")
+    ig.annotate(c, ig.g.qualifiedID(c.pkg.Name(), c.pkg.Path(), c.name))
+    ig.p("\n")
     if c.hasErr {
         ig.p("\tif %s != nil {\n", ig.errVar)
-        for i := prevCleanup - 1; i >= 0; i-- {
-            ig.p("\t\t%s()\n", ig.cleanupNames[i])
+        if ig.g.runtimeCleanup && injectSig.cleanup && !injectSig.namedCleanup {
+            if prevCleanup > 0 {
+                ig.p("\t\t%s.Run()\n", ig.cleanupFuncs(ig.cleanupNames[:prevCleanup]))
+            }
+        } else {
+            for i := prevCleanup - 1; i >= ig.registered; i-- {
+                ig.p("\t\t%s()\n", ig.cleanupNames[i])
+            }
         }
         ig.p("\t\treturn %s", zeroValue(injectSig.out, ig.g.qualifyPkg))
         if injectSig.cleanup {
             ig.p(", nil")
         }
-        // TODO(light): Give information about failing provider.
-        ig.p(", err\n")
+        ig.p(", %s\n", ig.wrapError(c))
         ig.p("\t}\n")
     }
 }
 
+// cleanupFuncs returns a wire.CleanupFuncs literal listing names.
+func (ig *injectorGen) cleanupFuncs(names []string) string {
+    wirePkg := ig.g.wirePackage()
+    return fmt.Sprintf("%s{%s}", ig.g.qualifiedID(wirePkg.Name(), wirePkg.Path(), "CleanupFuncs"), strings.Join(names, ", "))
+}
+
+// valueOf returns the variable that holds the value of type t, which the
+// injector takes as an argument or has already produced.
+func (ig *injectorGen) valueOf(set *ProviderSet, t types.Type) string {
+    pv := set.For(t)
+    if pv.IsArg() {
+        return ig.paramNames[pv.Arg().Index]
+    }
+    for i := len(ig.localTypes) - 1; i >= 0; i-- {
+        if types.Identical(ig.localTypes[i], pv.Type()) {
+            return ig.localNames[i]
+        }
+    }
+    return ""
+}
+
+// registerCleanups appends the cleanup functions that have not been handed
+// over yet to the registry of wire.CleanupInto, if the injector has it.
+func (ig *injectorGen) registerCleanups(set *ProviderSet) {
+    reg := set.CleanupRegistry
+    if reg == nil || ig.registered == len(ig.cleanupNames) {
+        return
+    }
+    if ig.registry == "" {
+        ig.registry = ig.valueOf(set, reg.Type)
+        if ig.registry == "" {
+            return
+        }
+    }
+    for ; ig.registered < len(ig.cleanupNames); ig.registered++ {
+        ig.p("\t%s.Append(%s)\n", ig.registry, ig.cleanupNames[ig.registered])
+    }
+}
+
+// wrapError returns the expression that the injector returns when the
+// provider that c calls fails: the error itself, or a call to fmt.Errorf
+// that wraps it if the package is generated with WrapErrors.
+func (ig *injectorGen) wrapError(c *call) string {
+    if ig.g.wrapErrors == nil {
+        return ig.errVar
+    }
+    data := wrapErrorsData{
+        Type:     types.TypeString(c.out, ig.g.describeQualifier),
+        Provider: c.name,
+    }
+    if q := ig.g.describeQualifier(c.pkg); q != "" {
+        data.Provider = q + "." + c.name
+    }
+    var msg strings.Builder
+    if err := ig.g.wrapErrors.Execute(&msg, data); err != nil {
+        // The template was checked when the options were validated.
+        panic(err)
+    }
+    format := strings.ReplaceAll(msg.String(), "%", "%%") + ": %w"
+    return fmt.Sprintf("%s(%s, %s)", ig.g.qualifiedID("fmt", "fmt", "Errorf"), strconv.Quote(format), ig.errVar)
+}
+
 func (ig *injectorGen) structProviderCall(lname string, c *call) {
     ig.p("\t%s", lname)
     ig.p(" := ")
-    if _, ok := c.out.(*types.Pointer); ok {
+    if _, ok := unalias(c.out).(*types.Pointer); ok {
         ig.p("&")
     }
-    ig.p("%s{\n", ig.g.qualifiedID(c.pkg.Name(), c.pkg.Path(), c.name))
+    ig.p("%s%s{", ig.g.qualifiedID(c.pkg.Name(), c.pkg.Path(), c.name), ig.g.typeArgList(c.typeArgs))
+    ig.annotate(c, ig.g.qualifiedID(c.pkg.Name(), c.pkg.Path(), c.name))
+    ig.p("\n")
     for i, a := range c.args {
         ig.p("\t\t%s: ", c.fieldNames[i])
         if a < len(ig.paramNames) {
@@ -1174,7 +2631,9 @@ func (ig *injectorGen) structProviderCall(lname string, c *call) {
 }
 
 func (ig *injectorGen) valueExpr(lname string, c *call) {
-    ig.p("\t%s := %s\n", lname, ig.g.values[c.valueExpr])
+    ig.p("\t%s := %s", lname, ig.g.values[c.valueExpr])
+    ig.annotate(c, "value")
+    ig.p("\n")
 }
 
 func (ig *injectorGen) fieldExpr(lname string, c *call) {
@@ -1184,14 +2643,126 @@ func (ig *injectorGen) fieldExpr(lname string, c *call) {
         ig.p("&")
     }
     if a < len(ig.paramNames) {
-        ig.p("%s.%s\n", ig.paramNames[a], c.name)
+        ig.p("%s.%s", ig.paramNames[a], c.name)
     } else {
-        ig.p("%s.%s\n", ig.localNames[a-len(ig.paramNames)], c.name)
+        ig.p("%s.%s", ig.localNames[a-len(ig.paramNames)], c.name)
+    }
+    ig.annotate(c, "field "+c.name)
+    ig.p("\n")
+}
+
+func (ig *injectorGen) sliceLit(lname string, c *call) {
+    ig.p("\t%s := %s{", lname, types.TypeString(c.out, ig.g.qualifyPkg))
+    for i, a := range c.args {
+        if i > 0 {
+            ig.p(", ")
+        }
+        if a < len(ig.paramNames) {
+            ig.p("%s", ig.paramNames[a])
+        } else {
+            ig.p("%s", ig.localNames[a-len(ig.paramNames)])
+        }
+    }
+    ig.p("}")
+    ig.annotate(c, "wire.Slice")
+    ig.p("\n")
+}
+
+func (ig *injectorGen) mapLit(lname string, c *call) {
+    ig.p("\t%s := %s{", lname, types.TypeString(c.out, ig.g.qualifyPkg))
+    ig.annotate(c, "wire.Map")
+    ig.p("\n")
+    for i, a := range c.args {
+        ig.p("\t\t%s: ", strconv.Quote(c.keys[i]))
+        if a < len(ig.paramNames) {
+            ig.p("%s", ig.paramNames[a])
+        } else {
+            ig.p("%s", ig.localNames[a-len(ig.paramNames)])
+        }
+        ig.p(",\n")
+    }
+    ig.p("\t}\n")
+}
+
+// shortPosition returns the file and line of pos for annotations, or "" if
+// pos is not valid. The file name is relative to the root of the module of
+// the package, or, for files outside of it, only keeps the last directory,
+// since it is otherwise different wherever the code is checked out.
+func (g *gen) shortPosition(pos token.Pos) string {
+    p := g.pkg.Fset.Position(pos)
+    if !p.IsValid() {
+        return ""
+    }
+    if m := g.pkg.Module; m != nil && m.Dir != "" {
+        rel, err := filepath.Rel(m.Dir, p.Filename)
+        if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+            return fmt.Sprintf("%s:%d", filepath.ToSlash(rel), p.Line)
+        }
+    }
+    dir, file := filepath.Split(p.Filename)
+    return fmt.Sprintf("%s:%d", path.Join(filepath.Base(dir), file), p.Line)
+}
+
+// overriddenSource describes src, a source of t that nearest-wins
+// precedence overrode, for annotations.
+func (g *gen) overriddenSource(src *providerSetSrc, t types.Type) string {
+    var desc string
+    switch {
+    case src.Provider != nil:
+        desc = src.Provider.Name
+        if q := g.describeQualifier(src.Provider.Pkg); q != "" {
+            desc = q + "." + desc
+        }
+    case src.Binding != nil:
+        desc = "wire.Bind"
+    case src.Value != nil:
+        desc = "value"
+    case src.Field != nil:
+        desc = "field " + src.Field.Name
+    default:
+        desc = types.TypeString(t, g.describeQualifier)
+    }
+    if pos := g.shortPosition(src.pos()); pos != "" {
+        desc += ", " + pos
+    }
+    return desc
+}
+
+// annotate writes a trailing comment for the statement that c generates, if
+// annotations are enabled. src describes the provider, value or field that
+// c uses.
+func (ig *injectorGen) annotate(c *call, src string) {
+    if !ig.g.annotate || ig.discard {
+        return
+    }
+    typ := types.TypeString(c.out, ig.g.describeQualifier)
+    if c.outs != nil {
+        ts := make([]string, len(c.outs))
+        for i, t := range c.outs {
+            ts[i] = types.TypeString(t, ig.g.describeQualifier)
+        }
+        typ = strings.Join(ts, ", ")
     }
+    if pos := ig.g.shortPosition(c.pos); pos != "" {
+        src += ", " + pos
+    }
+    text := fmt.Sprintf("provides %s (%s)", typ, src)
+    outs := c.outs
+    if outs == nil {
+        outs = []types.Type{c.out}
+    }
+    for _, t := range outs {
+        for _, o := range ig.set.overridden(t) {
+            text += fmt.Sprintf("; overrides %s", ig.g.overriddenSource(o, t))
+        }
+    }
+    ig.g.annotations[text] = true
+    ig.p(" // %s", text)
 }
 
 // nameInInjector reports whether name collides with any other identifier
-// in the current injector.
+// in the current injector, including the package qualifiers, predeclared
+// identifiers and top-level names that it may refer to.
 func (ig *injectorGen) nameInInjector(name string) bool {
     if name == ig.errVar {
         return true
@@ -1214,6 +2785,72 @@ func (ig *injectorGen) nameInInjector(name string) bool {
     return ig.g.nameInFileScope(name)
 }
 
+// localName returns the name for the local variable that holds the value
+// of type t. A name that localOwners gives to another type counts as taken.
+func (ig *injectorGen) localName(t types.Type) string {
+    return typeVariableName(t, "v", unexport, func(name string) bool {
+        if owner := ig.localOwners[name]; owner != nil && !types.Identical(owner, t) {
+            return true
+        }
+        return ig.nameInInjector(name)
+    })
+}
+
+// localOwners returns, for each name that the locals of several named types
+// in calls would be named first, such as client for both *a.Client and
+// *b.Client, the type whose local gets the name: the one whose package path
+// sorts first, and then the one whose type string does. The locals of the
+// other types take their alternate names, such as bClient, so that which
+// local gets the name does not depend on the order of the calls.
+func localOwners(calls []call) map[string]types.Type {
+    byName := make(map[string][]types.Type)
+    for i := range calls {
+        c := &calls[i]
+        outs, used := c.outs, c.usedOuts
+        if outs == nil {
+            outs, used = []types.Type{c.out}, []bool{true}
+        }
+        for j, t := range outs {
+            if !used[j] || typePkgPath(t) == "" {
+                continue
+            }
+            name := typeVariableNames(t, "v", unexport)[0]
+            byName[name] = append(byName[name], t)
+        }
+    }
+    owners := make(map[string]types.Type)
+    for name, ts := range byName {
+        if len(ts) < 2 {
+            continue
+        }
+        sort.Slice(ts, func(i, j int) bool {
+            if pi, pj := typePkgPath(ts[i]), typePkgPath(ts[j]); pi != pj {
+                return pi < pj
+            }
+            return types.TypeString(ts[i], nil) < types.TypeString(ts[j], nil)
+        })
+        owners[name] = ts[0]
+    }
+    return owners
+}
+
+// typePkgPath returns the import path of the package that declares the type
+// that typeVariableName names a variable of type t after, or "" if it is
+// not a declared type.
+func typePkgPath(t types.Type) string {
+    if p, ok := unalias(t).(*types.Pointer); ok {
+        t = p.Elem()
+    }
+    obj := aliasObj(t)
+    if n, ok := unalias(t).(*types.Named); ok && obj == nil {
+        obj = n.Obj()
+    }
+    if obj == nil || obj.Pkg() == nil {
+        return ""
+    }
+    return obj.Pkg().Path()
+}
+
 func (ig *injectorGen) p(format string, args ...interface{}) {
     if ig.discard {
         return
@@ -1254,17 +2891,25 @@ func zeroValue(t types.Type, qf types.Qualifier) string {
 // names is unambiguous, it used; otherwise, the first derived name is
 // disambiguated using disambiguate().
 func typeVariableName(t types.Type, defaultName string, transform func(string) string, collides func(string) bool) string {
-    if p, ok := t.(*types.Pointer); ok {
+    names := typeVariableNames(t, defaultName, transform)
+    // See if there's an unambiguous name; if so, use it.
+    for _, name := range names {
+        if !token.Lookup(name).IsKeyword() && !collides(name) {
+            return name
+        }
+    }
+    // Otherwise, disambiguate the first name.
+    return disambiguate(names[0], collides)
+}
+
+// typeVariableNames returns the names that typeVariableName tries for a
+// variable of type t, in order of preference.
+func typeVariableNames(t types.Type, defaultName string, transform func(string) string) []string {
+    if p, ok := unalias(t).(*types.Pointer); ok {
         t = p.Elem()
     }
     var names []string
-    switch t := t.(type) {
-    case *types.Basic:
-        if t.Name() != "" {
-            names = append(names, t.Name())
-        }
-    case *types.Named:
-        obj := t.Obj()
+    addObj := func(obj *types.TypeName) {
         if name := obj.Name(); name != "" {
             names = append(names, name)
         }
@@ -1274,6 +2919,21 @@ func typeVariableName(t types.Type, defaultName string, transform func(string) s
             names = append(names, fmt.Sprintf("%s%s", pkg.Name(), strings.Title(obj.Name())))
         }
     }
+    // An alias is named after itself, as the code that declares it refers
+    // to it, rather than after the type it denotes.
+    if obj := aliasObj(t); obj != nil {
+        addObj(obj)
+    }
+    switch t := unalias(t).(type) {
+    case *types.Basic:
+        if len(names) == 0 && t.Name() != "" {
+            names = append(names, t.Name())
+        }
+    case *types.Named:
+        if len(names) == 0 {
+            addObj(t.Obj())
+        }
+    }
 
     // If we were unable to derive a name, use defaultName.
     if len(names) == 0 {
@@ -1284,15 +2944,7 @@ func typeVariableName(t types.Type, defaultName string, transform func(string) s
     for i, name := range names {
         names[i] = transform(name)
     }
-
-    // See if there's an unambiguous name; if so, use it.
-    for _, name := range names {
-        if !token.Lookup(name).IsKeyword() && !collides(name) {
-            return name
-        }
-    }
-    // Otherwise, disambiguate the first name.
-    return disambiguate(names[0], collides)
+    return names
 }
 
 // unexport converts a name that is potentially exported to an unexported name.
@@ -1397,6 +3049,103 @@ func accessibleFrom(info *types.Info, node ast.Node, wantPkg string) error {
     return unexportError
 }
 
+// spelledTypes returns the types that the generated code writes out for c:
+// the type arguments of a generic provider and the type of a slice or map
+// literal. The types of the other locals are inferred.
+func spelledTypes(c *call) []types.Type {
+    switch c.kind {
+    case funcProviderCall, structProvider:
+        return c.typeArgs
+    case sliceLit, mapLit:
+        return []types.Type{c.out}
+    }
+    return nil
+}
+
+// describeCall names the provider that c calls, or the kind of literal it
+// builds, for error messages.
+func describeCall(c *call) string {
+    switch c.kind {
+    case funcProviderCall:
+        return fmt.Sprintf("provider %s.%s", c.pkg.Name(), c.name)
+    case structProvider:
+        return fmt.Sprintf("struct provider %s.%s", c.pkg.Name(), c.name)
+    case sliceLit:
+        return "wire.Slice"
+    case mapLit:
+        return "wire.Map"
+    }
+    return "value"
+}
+
+// unnamedTypeOutside returns the first unnamed struct or interface type
+// within t that code in pkgPath cannot write out, or nil if there is none.
+// Such a type has a field or method that another package does not export:
+// written out in pkgPath, the same type literal denotes a different type.
+func unnamedTypeOutside(t types.Type, pkgPath string) types.Type {
+    if obj := aliasObj(t); obj != nil && obj.Pkg() != nil && (obj.Exported() || obj.Pkg().Path() == pkgPath) {
+        // The type is written out by the alias's name.
+        return nil
+    }
+    switch t := unalias(t).(type) {
+    case *types.Named:
+        if args := t.TypeArgs(); args != nil {
+            for i := 0; i < args.Len(); i++ {
+                if u := unnamedTypeOutside(args.At(i), pkgPath); u != nil {
+                    return u
+                }
+            }
+        }
+    case *types.Pointer:
+        return unnamedTypeOutside(t.Elem(), pkgPath)
+    case *types.Slice:
+        return unnamedTypeOutside(t.Elem(), pkgPath)
+    case *types.Array:
+        return unnamedTypeOutside(t.Elem(), pkgPath)
+    case *types.Chan:
+        return unnamedTypeOutside(t.Elem(), pkgPath)
+    case *types.Map:
+        if u := unnamedTypeOutside(t.Key(), pkgPath); u != nil {
+            return u
+        }
+        return unnamedTypeOutside(t.Elem(), pkgPath)
+    case *types.Signature:
+        for _, tuple := range []*types.Tuple{t.Params(), t.Results()} {
+            for i := 0; i < tuple.Len(); i++ {
+                if u := unnamedTypeOutside(tuple.At(i).Type(), pkgPath); u != nil {
+                    return u
+                }
+            }
+        }
+    case *types.Struct:
+        for i := 0; i < t.NumFields(); i++ {
+            f := t.Field(i)
+            if !f.Exported() && f.Pkg().Path() != pkgPath {
+                return t
+            }
+            if u := unnamedTypeOutside(f.Type(), pkgPath); u != nil {
+                return u
+            }
+        }
+    case *types.Interface:
+        for i := 0; i < t.NumExplicitMethods(); i++ {
+            m := t.ExplicitMethod(i)
+            if !m.Exported() && m.Pkg().Path() != pkgPath {
+                return t
+            }
+            if u := unnamedTypeOutside(m.Type(), pkgPath); u != nil {
+                return u
+            }
+        }
+        for i := 0; i < t.NumEmbeddeds(); i++ {
+            if u := unnamedTypeOutside(t.EmbeddedType(i), pkgPath); u != nil {
+                return u
+            }
+        }
+    }
+    return nil
+}
+
 var (
     errorType   = types.Universe.Lookup("error").Type()
     cleanupType = types.NewSignature(nil, nil, nil, false)
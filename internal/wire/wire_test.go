@@ -17,20 +17,33 @@ package wire
 import (
 	"bytes"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"go/ast"
 	"go/build"
+	"go/format"
+	"go/parser"
+	"go/token"
 	"go/types"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 	"unicode"
 	"unicode/utf8"
 
 	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
 )
 
 var record = flag.Bool("record", false, "whether to run tests against cloud resources and record the interactions")
@@ -61,8 +74,13 @@ func TestWire(t *testing.T) {
 		tests = append(tests, test)
 	}
 
+	// The programs of the test cases are built and run when recording, and
+	// also when replaying unless the tests are short, so that a change in
+	// what the generated injectors do fails even if their source is the
+	// same as the golden file's.
+	runPrograms := *record || !testing.Short()
 	var goToolPath string
-	if *record {
+	if runPrograms {
 		goToolPath = filepath.Join(build.Default.GOROOT, "bin", "go")
 		if _, err := os.Stat(goToolPath); err != nil {
 			t.Fatal("go toolchain not available:", err)
@@ -73,6 +91,9 @@ func TestWire(t *testing.T) {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
+			if test.usesCgo && !cgoEnabled() {
+				t.Skip("cgo is not enabled")
+			}
 
 			// Materialize a temporary GOPATH directory.
 			gopath, err := ioutil.TempDir("", "wire_test")
@@ -88,7 +109,32 @@ func TestWire(t *testing.T) {
 				t.Fatal(err)
 			}
 			wd := filepath.Join(gopath, "src", "example.com")
-			gens, errs := Generate(ctx, wd, append(os.Environ(), "GOPATH="+gopath), []string{test.pkg}, &GenerateOptions{Header: test.header})
+			env := append(os.Environ(), "GOPATH="+gopath)
+			opts := &GenerateOptions{Header: test.header, IdentifierPrefix: test.identifierPrefix, WrapErrors: test.wrapErrors, GOOS: test.goos, VerifyOutput: true}
+			gens, errs := Generate(ctx, wd, env, []string{test.pkg}, opts)
+			if !*record {
+				// Every mode of Generate must give the same results. The
+				// modes only differ after loading, so the packages are
+				// loaded once for all of them.
+				want := generateOutcome(gopath, gens, errs)
+				imports := newImportCache(ctx, wd, opts.loadEnv(env), opts.buildTag(), opts.Tags, nil)
+				pkgs, loadErrs := imports.load([]string{test.pkg})
+				for _, mode := range []struct {
+					concurrency int
+					lazyLoad    bool
+				}{{2, false}, {0, true}, {2, true}} {
+					mopts := *opts
+					mopts.Concurrency, mopts.LazyLoad = mode.concurrency, mode.lazyLoad
+					var mgens []GenerateResult
+					if len(loadErrs) == 0 {
+						mgens = generatePackages(imports, pkgs, &mopts)
+					}
+					got := generateOutcome(gopath, mgens, loadErrs)
+					if diff := cmp.Diff(want, got); diff != "" {
+						t.Errorf("Generate with Concurrency %d and LazyLoad %t differs from the default (-default +got):\n%s", mode.concurrency, mode.lazyLoad, diff)
+					}
+				}
+			}
 			var gen GenerateResult
 			if len(gens) > 1 {
 				t.Fatalf("got %d generated files, want 0 or 1", len(gens))
@@ -160,402 +206,3577 @@ func TestWire(t *testing.T) {
 					diff := cmp.Diff(strings.Split(gotS, "\n"), strings.Split(wantS, "\n"))
 					t.Fatalf("wire output differs from golden file. If this change is expected, run with -record to update the wire_gen.go file.\n*** got:\n%s\n\n*** want:\n%s\n\n*** diff:\n%s", gotS, wantS, diff)
 				}
+				// Run ==> Build the program with the golden file and
+				// check its output against program_out.txt. Recording
+				// again would accept any new golden file, so this is
+				// what catches a change in how the injectors behave,
+				// such as the order of their cleanup functions.
+				if runPrograms && outPathSane {
+					if err := gen.Commit(); err != nil {
+						t.Fatalf("failed to write wire_gen.go to test GOPATH: %v", err)
+					}
+					if err := goBuildCheck(goToolPath, gopath, test); err != nil {
+						t.Fatalf("go build check failed: %v", err)
+					}
+				}
 			}
 		})
 	}
 }
 
-func goBuildCheck(goToolPath, gopath string, test *testCase) error {
-	// Run `go build`.
-	testExePath := filepath.Join(gopath, "bin", "testprog")
-	buildCmd := []string{"build", "-o", testExePath}
-	buildCmd = append(buildCmd, test.pkg)
-	cmd := exec.Command(goToolPath, buildCmd...)
-	cmd.Dir = filepath.Join(gopath, "src", "example.com")
-	cmd.Env = append(os.Environ(), "GOPATH="+gopath)
-	if buildOut, err := cmd.CombinedOutput(); err != nil {
-		if len(buildOut) > 0 {
-			return fmt.Errorf("build: %v; output:\n%s", err, buildOut)
-		}
-		return fmt.Errorf("build: %v", err)
-	}
+// greetingFiles returns a package, example.com/foo, with an injector for
+// each of linux and darwin, for tests of GenerateForPlatforms.
+func greetingFiles() map[string]string {
+	return map[string]string{
+		"example.com/foo/foo.go": `package main
 
-	// Run the resulting program and compare its output to the expected
-	// output.
-	out, err := exec.Command(testExePath).Output()
-	if err != nil {
-		return fmt.Errorf("run compiled program: %v", err)
-	}
-	if !bytes.Equal(out, test.wantProgramOutput) {
-		gotS, wantS := string(out), string(test.wantProgramOutput)
-		diff := cmp.Diff(strings.Split(gotS, "\n"), strings.Split(wantS, "\n"))
-		return fmt.Errorf("compiled program output doesn't match:\n*** got:\n%s\n\n*** want:\n%s\n\n*** diff:\n%s", gotS, wantS, diff)
+type Greeting string
+
+func main() {}
+`,
+		"example.com/foo/foo_linux.go": `package main
+
+func provideLinuxGreeting() Greeting { return "linux" }
+`,
+		"example.com/foo/foo_darwin.go": `package main
+
+func provideDarwinGreeting() Greeting { return "darwin" }
+`,
+		"example.com/foo/wire_linux.go": `//go:build wireinject
+
+package main
+
+import "github.com/google/wire"
+
+func injectGreeting() Greeting {
+	panic(wire.Build(provideLinuxGreeting))
+}
+`,
+		"example.com/foo/wire_darwin.go": `//go:build wireinject
+
+package main
+
+import "github.com/google/wire"
+
+func injectGreeting() Greeting {
+	panic(wire.Build(provideDarwinGreeting))
+}
+`,
 	}
-	return nil
 }
 
-func TestUnexport(t *testing.T) {
-	tests := []struct {
-		name string
-		want string
+func TestGenerateForPlatforms(t *testing.T) {
+	wd, env := materialize(t, greetingFiles())
+	platforms := []Platform{{GOOS: "linux"}, {GOOS: "darwin", GOARCH: "arm64"}}
+	gens, errs := GenerateForPlatforms(context.Background(), wd, env, []string{"example.com/foo"}, nil, platforms)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if len(gens) != 2 {
+		t.Fatalf("got %d results, want 2", len(gens))
+	}
+	want := []struct {
+		file, constraint, provider string
 	}{
-		{"", ""},
-		{"a", "a"},
-		{"ab", "ab"},
-		{"A", "a"},
-		{"AB", "ab"},
-		{"A_", "a_"},
-		{"ABc", "aBc"},
-		{"ABC", "abc"},
-		{"AB_", "ab_"},
-		{"foo", "foo"},
-		{"Foo", "foo"},
-		{"HTTPClient", "httpClient"},
-		{"IFace", "iFace"},
-		{"SNAKE_CASE", "snake_CASE"},
-		{"HTTP", "http"},
+		{"wire_gen_linux.go", "//go:build !wireinject && linux\n", "provideLinuxGreeting()"},
+		{"wire_gen_darwin_arm64.go", "//go:build !wireinject && darwin && arm64\n", "provideDarwinGreeting()"},
 	}
-	for _, test := range tests {
-		if got := unexport(test.name); got != test.want {
-			t.Errorf("unexport(%q) = %q; want %q", test.name, got, test.want)
+	for i, w := range want {
+		gen := gens[i]
+		if len(gen.Errs) > 0 {
+			t.Fatalf("%s: %v", w.file, gen.Errs)
+		}
+		if got := filepath.Base(gen.OutputPath); got != w.file {
+			t.Errorf("output file = %q; want %q", got, w.file)
+		}
+		content := string(gen.Content)
+		if !strings.Contains(content, w.constraint) {
+			t.Errorf("%s does not contain constraint %q:\n%s", w.file, w.constraint, content)
+		}
+		if !strings.Contains(content, w.provider) {
+			t.Errorf("%s does not call %s:\n%s", w.file, w.provider, content)
+		}
+		if strings.Contains(content, "//go:generate") {
+			t.Errorf("%s contains a go:generate directive:\n%s", w.file, content)
 		}
 	}
 }
 
-func TestExport(t *testing.T) {
-	tests := []struct {
-		name string
-		want string
-	}{
-		{"", ""},
-		{"a", "A"},
-		{"ab", "Ab"},
-		{"A", "A"},
-		{"AB", "AB"},
-		{"A_", "A_"},
-		{"ABc", "ABc"},
-		{"ABC", "ABC"},
-		{"AB_", "AB_"},
-		{"foo", "Foo"},
-		{"Foo", "Foo"},
-		{"HTTPClient", "HTTPClient"},
-		{"httpClient", "HttpClient"},
-		{"IFace", "IFace"},
-		{"iFace", "IFace"},
-		{"SNAKE_CASE", "SNAKE_CASE"},
-		{"HTTP", "HTTP"},
+func TestGenerateForPlatformsExcludedInjector(t *testing.T) {
+	files := greetingFiles()
+	files["example.com/foo/wire_windows.go"] = `//go:build wireinject
+
+package main
+
+import "github.com/google/wire"
+
+func injectWindowsGreeting() Greeting {
+	panic(wire.Build(provideWindowsGreeting))
+}
+`
+	wd, env := materialize(t, files)
+	platforms := []Platform{{GOOS: "linux"}, {GOOS: "darwin", GOARCH: "arm64"}}
+	gens, errs := GenerateForPlatforms(context.Background(), wd, env, []string{"example.com/foo"}, nil, platforms)
+	if len(errs) > 0 {
+		t.Fatal(errs)
 	}
-	for _, test := range tests {
-		if got := export(test.name); got != test.want {
-			t.Errorf("export(%q) = %q; want %q", test.name, got, test.want)
+	var warnings []string
+	for _, gen := range gens {
+		if len(gen.Errs) > 0 {
+			t.Fatal(gen.Errs)
+		}
+		for _, w := range gen.Warnings {
+			warnings = append(warnings, w.Error())
+		}
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("got warnings %q; want one for injectWindowsGreeting", warnings)
+	}
+	for _, want := range []string{"wire_windows.go", "inject injectWindowsGreeting", "(linux, darwin/arm64)"} {
+		if !strings.Contains(warnings[0], want) {
+			t.Errorf("warning %q does not contain %q", warnings[0], want)
 		}
 	}
 }
 
-func TestTypeVariableName(t *testing.T) {
-	var (
-		boolT           = types.Typ[types.Bool]
-		stringT         = types.Typ[types.String]
-		fooVarT         = types.NewNamed(types.NewTypeName(0, nil, "foo", stringT), stringT, nil)
-		nonameVarT      = types.NewNamed(types.NewTypeName(0, nil, "", stringT), stringT, nil)
-		barVarInFooPkgT = types.NewNamed(types.NewTypeName(0, types.NewPackage("my.example/foo", "foo"), "bar", stringT), stringT, nil)
-	)
-	tests := []struct {
-		description     string
-		typ             types.Type
-		defaultName     string
-		transformAppend string
-		collides        map[string]bool
-		want            string
-	}{
-		{"basic type", boolT, "", "", map[string]bool{}, "bool"},
-		{"basic type with transform", boolT, "", "suffix", map[string]bool{}, "boolsuffix"},
-		{"basic type with collision", boolT, "", "", map[string]bool{"bool": true}, "bool2"},
-		{"basic type with transform and collision", boolT, "", "suffix", map[string]bool{"boolsuffix": true}, "boolsuffix2"},
-		{"a different basic type", stringT, "", "", map[string]bool{}, "string"},
-		{"named type", fooVarT, "", "", map[string]bool{}, "foo"},
-		{"named type with transform", fooVarT, "", "suffix", map[string]bool{}, "foosuffix"},
-		{"named type with collision", fooVarT, "", "", map[string]bool{"foo": true}, "foo2"},
-		{"named type with transform and collision", fooVarT, "", "suffix", map[string]bool{"foosuffix": true}, "foosuffix2"},
-		{"noname type", nonameVarT, "bar", "", map[string]bool{}, "bar"},
-		{"noname type with transform", nonameVarT, "bar", "s", map[string]bool{}, "bars"},
-		{"noname type with transform and collision", nonameVarT, "bar", "s", map[string]bool{"bars": true}, "bars2"},
-		{"var in pkg type", barVarInFooPkgT, "", "", map[string]bool{}, "bar"},
-		{"var in pkg type with collision", barVarInFooPkgT, "", "", map[string]bool{"bar": true}, "fooBar"},
-		{"var in pkg type with double collision", barVarInFooPkgT, "", "", map[string]bool{"bar": true, "fooBar": true}, "bar2"},
+func TestGenerateForPlatformsPlainOutput(t *testing.T) {
+	wd, env := materialize(t, greetingFiles())
+	ctx := context.Background()
+	gens, errs := Generate(ctx, wd, env, []string{"example.com/foo"}, &GenerateOptions{GOOS: "linux"})
+	if len(errs) > 0 {
+		t.Fatal(errs)
 	}
-	for _, test := range tests {
-		t.Run(fmt.Sprintf("%s: typeVariableName(%v, %q, %q, %v)", test.description, test.typ, test.defaultName, test.transformAppend, test.collides), func(t *testing.T) {
-			got := typeVariableName(test.typ, test.defaultName, func(name string) string { return name + test.transformAppend }, func(name string) bool { return test.collides[name] })
-			if !isIdent(got) {
-				t.Errorf("%q is not an identifier", got)
-			}
-			if got != test.want {
-				t.Errorf("got %q want %q", got, test.want)
-			}
-			if test.collides[got] {
-				t.Errorf("%q collides", got)
-			}
-		})
+	if len(gens) != 1 || len(gens[0].Errs) > 0 {
+		t.Fatalf("Generate: %+v", gens)
+	}
+	if err := gens[0].Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	platforms := []Platform{{GOOS: "linux"}, {GOOS: "darwin", GOARCH: "arm64"}}
+	gens, errs = GenerateForPlatforms(ctx, wd, env, []string{"example.com/foo"}, nil, platforms)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if len(gens) != 2 {
+		t.Fatalf("got %d results, want 2", len(gens))
+	}
+	if len(gens[0].Errs) != 1 || !strings.Contains(gens[0].Errs[0].Error(), "wire_gen.go was generated for every platform") {
+		t.Errorf("errors = %v; want one about wire_gen.go", gens[0].Errs)
+	}
+	for _, gen := range gens {
+		if len(gen.Content) > 0 {
+			t.Errorf("%s has content; want none while wire_gen.go conflicts", filepath.Base(gen.OutputPath))
+		}
 	}
 }
 
-func TestDisambiguate(t *testing.T) {
+func TestCheckPlatforms(t *testing.T) {
 	tests := []struct {
-		name     string
-		want     string
-		collides map[string]bool
+		name      string
+		platforms []Platform
+		wantErr   bool
 	}{
-		{"foo", "foo", nil},
-		{"foo", "foo2", map[string]bool{"foo": true}},
-		{"foo", "foo3", map[string]bool{"foo": true, "foo1": true, "foo2": true}},
-		{"foo1", "foo1_2", map[string]bool{"foo": true, "foo1": true, "foo2": true}},
-		{"foo\u0661", "foo\u0661", map[string]bool{"foo": true, "foo1": true, "foo2": true}},
-		{"foo\u0661", "foo\u06612", map[string]bool{"foo": true, "foo1": true, "foo2": true, "foo\u0661": true}},
-		{"select", "select2", nil},
-		{"var", "var2", nil},
+		{"distinct", []Platform{{GOOS: "linux"}, {GOOS: "darwin"}}, false},
+		{"distinct arch", []Platform{{GOOS: "linux", GOARCH: "amd64"}, {GOOS: "linux", GOARCH: "arm64"}}, false},
+		{"duplicate", []Platform{{GOOS: "linux"}, {GOOS: "linux"}}, true},
+		{"overlap", []Platform{{GOOS: "linux"}, {GOOS: "linux", GOARCH: "amd64"}}, true},
+		{"arch only overlap", []Platform{{GOARCH: "amd64"}, {GOOS: "linux", GOARCH: "amd64"}}, true},
+		{"empty", []Platform{{}}, true},
 	}
 	for _, test := range tests {
-		t.Run(fmt.Sprintf("disambiguate(%q, %v)", test.name, test.collides), func(t *testing.T) {
-			got := disambiguate(test.name, func(name string) bool { return test.collides[name] })
-			if !isIdent(got) {
-				t.Errorf("%q is not an identifier", got)
-			}
-			if got != test.want {
-				t.Errorf("got %q want %q", got, test.want)
-			}
-			if test.collides[got] {
-				t.Errorf("%q collides", got)
+		t.Run(test.name, func(t *testing.T) {
+			errs := checkPlatforms(test.platforms)
+			if got := len(errs) > 0; got != test.wantErr {
+				t.Errorf("checkPlatforms(%v) = %v; want error = %t", test.platforms, errs, test.wantErr)
 			}
 		})
 	}
 }
 
-func isIdent(s string) bool {
-	if len(s) == 0 {
-		return false
-	}
-	r, i := utf8.DecodeRuneInString(s)
-	if !unicode.IsLetter(r) && r != '_' {
-		return false
-	}
-	for i < len(s) {
-		r, sz := utf8.DecodeRuneInString(s[i:])
-		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
-			return false
-		}
-		i += sz
-	}
-	return true
+func TestGenerateDeprecated(t *testing.T) {
+	wd, env := materialize(t, map[string]string{
+		"example.com/foo/foo.go": `package main
+
+import "github.com/google/wire"
+
+type Greeter struct {
+	Message string
+	Count   int
 }
 
-// scrubError rewrites the given string to remove occurrences of GOPATH/src,
-// rewrites OS-specific path separators to slashes, and any line/column
-// information to a fixed ":x:y". For example, if the gopath parameter is
-// "C:\GOPATH" and running on Windows, the string
-// "C:\GOPATH\src\foo\bar.go:15:4" would be rewritten to "foo/bar.go:x:y".
-func scrubError(gopath string, s string) string {
-	sb := new(strings.Builder)
-	query := gopath + string(os.PathSeparator) + "src" + string(os.PathSeparator)
-	for {
-		// Find next occurrence of source root. This indicates the next path to
-		// scrub.
-		start := strings.Index(s, query)
-		if start == -1 {
-			sb.WriteString(s)
-			break
-		}
+func main() {}
 
-		// Find end of file name (extension ".go").
-		fileStart := start + len(query)
-		fileEnd := strings.Index(s[fileStart:], ".go")
-		if fileEnd == -1 {
-			// If no ".go" occurs to end of string, further searches will fail too.
-			// Break the loop.
-			sb.WriteString(s)
-			break
-		}
-		fileEnd += fileStart + 3 // Advance to end of extension.
+// Deprecated: Use provideMessage instead.
+func provideOldMessage() string { return "hello" }
 
-		// Write out file name and advance scrub position.
-		file := s[fileStart:fileEnd]
-		if os.PathSeparator != '/' {
-			file = strings.Replace(file, string(os.PathSeparator), "/", -1)
-		}
-		sb.WriteString(s[:start])
-		sb.WriteString(file)
-		s = s[fileEnd:]
+func provideCount() int { return 1 }
 
-		// Peek past to see if there is line/column info.
-		linecol, linecolLen := scrubLineColumn(s)
-		sb.WriteString(linecol)
-		s = s[linecolLen:]
-	}
-	return sb.String()
+func provideGreeter(msg string, n int) Greeter { return Greeter{msg, n} }
+
+// LegacySet provides a message and a count.
+//
+// Deprecated: Use GreeterSet instead. LegacySet will be
+// removed in the next release.
+var LegacySet = wire.NewSet(provideOldMessage, provideCount)
+`,
+		"example.com/foo/wire.go": `//go:build wireinject
+
+package main
+
+import "github.com/google/wire"
+
+func injectGreeter() Greeter {
+	panic(wire.Build(LegacySet, provideGreeter))
 }
 
-func scrubLineColumn(s string) (replacement string, n int) {
-	if !strings.HasPrefix(s, ":") {
-		return "", 0
+func injectMessage() string {
+	panic(wire.Build(LegacySet))
+}
+`,
+	})
+	want := []string{
+		`inject injectGreeter: provider set "LegacySet" (.*) is deprecated: Use GreeterSet instead. LegacySet will be removed in the next release.$`,
+		`inject injectGreeter: provider "provideOldMessage" (.*) is deprecated: Use provideMessage instead.$`,
+		`inject injectMessage: provider set "LegacySet" (.*) is deprecated: Use GreeterSet instead. LegacySet will be removed in the next release.$`,
+		`inject injectMessage: provider "provideOldMessage" (.*) is deprecated: Use provideMessage instead.$`,
 	}
-	// Skip first colon and run of digits.
-	for n++; len(s) > n && '0' <= s[n] && s[n] <= '9'; {
-		n++
+	check := func(t *testing.T, got []error) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("got %d problems, want %d: %v", len(got), len(want), got)
+		}
+		for i := range want {
+			if !regexp.MustCompile(want[i]).MatchString(got[i].Error()) {
+				t.Errorf("problem %d = %q; want match for %q", i, got[i], want[i])
+			}
+		}
 	}
-	if n == 1 {
+
+	t.Run("Warnings", func(t *testing.T) {
+		opts := &GenerateOptions{UngroupedErrors: true}
+		gens, errs := Generate(context.Background(), wd, env, []string{"example.com/foo"}, opts)
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		if len(gens) != 1 {
+			t.Fatalf("got %d results, want 1", len(gens))
+		}
+		if len(gens[0].Errs) > 0 {
+			t.Fatal(gens[0].Errs)
+		}
+		if len(gens[0].Content) == 0 {
+			t.Error("no content generated")
+		}
+		check(t, gens[0].Warnings)
+	})
+	t.Run("DeprecatedAsError", func(t *testing.T) {
+		opts := &GenerateOptions{DeprecatedAsError: true, UngroupedErrors: true}
+		gens, errs := Generate(context.Background(), wd, env, []string{"example.com/foo"}, opts)
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		if len(gens) != 1 {
+			t.Fatalf("got %d results, want 1", len(gens))
+		}
+		if len(gens[0].Content) != 0 {
+			t.Error("content generated despite errors")
+		}
+		if len(gens[0].Warnings) > 0 {
+			t.Errorf("got warnings %v; want none", gens[0].Warnings)
+		}
+		check(t, gens[0].Errs)
+	})
+	t.Run("Grouped", func(t *testing.T) {
+		gens, errs := Generate(context.Background(), wd, env, []string{"example.com/foo"}, nil)
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		if len(gens) != 1 {
+			t.Fatalf("got %d results, want 1", len(gens))
+		}
+		want := []string{
+			`inject injectGreeter, injectMessage: provider set "LegacySet" (.*) is deprecated: Use GreeterSet instead. LegacySet will be removed in the next release.$`,
+			`inject injectGreeter, injectMessage: provider "provideOldMessage" (.*) is deprecated: Use provideMessage instead.$`,
+		}
+		got := gens[0].Warnings
+		if len(got) != len(want) {
+			t.Fatalf("got %d problems, want %d: %v", len(got), len(want), got)
+		}
+		for i := range want {
+			if !regexp.MustCompile(want[i]).MatchString(got[i].Error()) {
+				t.Errorf("problem %d = %q; want match for %q", i, got[i], want[i])
+			}
+			var ie *InjectorError
+			if !errors.As(got[i], &ie) {
+				t.Errorf("problem %d = %v; want an *InjectorError", i, got[i])
+				continue
+			}
+			if len(ie.Positions) != 2 || ie.Positions[0].Line == ie.Positions[1].Line {
+				t.Errorf("problem %d positions = %v; want the positions of both injectors", i, ie.Positions)
+			}
+		}
+	})
+}
+
+func TestGenerateStableOrder(t *testing.T) {
+	injectorFile := func(name string) string {
+		return `//go:build wireinject
+
+package main
+
+import "github.com/google/wire"
+
+type ` + name + `Helper int
+
+func inject` + name + `() Foo {
+	panic(wire.Build(provideFoo))
+}
+`
+	}
+	wd, env := materialize(t, map[string]string{
+		"example.com/foo/foo.go": `package main
+
+type Foo int
+
+func provideFoo() Foo { return 42 }
+
+func main() {}
+`,
+		"example.com/foo/a_wire.go": injectorFile("A"),
+		"example.com/foo/z_wire.go": injectorFile("Z"),
+	})
+	imports := newImportCache(context.Background(), wd, env, defaultBuildTag, "", nil)
+	pkgs, errs := imports.load([]string{"example.com/foo"})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	pkg := pkgs[0]
+	generators := map[string]func() GenerateResult{
+		"Generate": func() GenerateResult { return generatePackage(imports, pkg, &GenerateOptions{}) },
+		"LazyLoad": func() GenerateResult { return generatePackage(imports, pkg, &GenerateOptions{LazyLoad: true}) },
+	}
+	for name, generate := range generators {
+		t.Run(name, func(t *testing.T) {
+			var contents []string
+			// Generate from the files in load order and shuffled.
+			for _, perm := range [][]int{{0, 1, 2}, {2, 0, 1}, {1, 2, 0}} {
+				orig := pkg.Syntax
+				shuffled := make([]*ast.File, len(orig))
+				for i, j := range perm {
+					shuffled[i] = orig[j]
+				}
+				pkg.Syntax = shuffled
+				gen := generate()
+				pkg.Syntax = orig
+				if len(gen.Errs) > 0 {
+					t.Fatal(gen.Errs)
+				}
+				contents = append(contents, string(gen.Content))
+			}
+			for _, c := range contents[1:] {
+				if diff := cmp.Diff(contents[0], c); diff != "" {
+					t.Errorf("output depends on file order (-load order +shuffled):\n%s", diff)
+				}
+			}
+			if a, z := strings.Index(contents[0], "func injectA"), strings.Index(contents[0], "func injectZ"); a < 0 || z < a {
+				t.Errorf("injectA does not come before injectZ:\n%s", contents[0])
+			}
+		})
+	}
+}
+
+func TestGenerateCleanupRecover(t *testing.T) {
+	wd, env := materialize(t, map[string]string{
+		"example.com/foo/foo.go": `package main
+
+func main() {}
+
+type (
+	A int
+	B int
+	C int
+	D int
+	E int
+	F int
+)
+
+func provideA() (A, func()) {
+	return 1, func() { recover() } // line 15
+}
+
+func provideB() (B, func(), error) {
+	return 2, cleanupB, nil
+}
+
+func cleanupB() {
+	if r := recover(); r != nil { // line 23
+		println(r)
+	}
+}
+
+func provideC() (c C, cleanup func()) {
+	cleanup = func() {
+		recover() // line 30
+	}
+	return 3, cleanup
+}
+
+// provideD is allowed to recover.
+//
+//wire:allow-recover
+func provideD() (D, func()) {
+	return 4, func() { recover() }
+}
+
+func provideE() (E, func()) {
+	return 5, func() {
+		//wire:allow-recover
+		recover()
+	}
+}
+
+func provideF() (F, func()) {
+	return 6, func() {}
+}
+`,
+		"example.com/foo/wire.go": `//go:build wireinject
+
+package main
+
+import "github.com/google/wire"
+
+func injectAll() (All, func(), error) {
+	panic(wire.Build(provideA, provideB, provideC, provideD, provideE, provideF, wire.Struct(new(All), "*")))
+}
+
+type All struct {
+	A A
+	B B
+	C C
+	D D
+	E E
+	F F
+}
+`,
+	})
+	for _, check := range []bool{false, true} {
+		gens, errs := Generate(context.Background(), wd, env, []string{"example.com/foo"}, &GenerateOptions{CheckCleanupRecover: check})
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		if len(gens[0].Errs) > 0 {
+			t.Fatal(gens[0].Errs)
+		}
+		var got []string
+		for _, w := range gens[0].Warnings {
+			got = append(got, strings.TrimPrefix(w.Error(), filepath.Dir(wd)+string(os.PathSeparator)))
+		}
+		var want []string
+		if check {
+			want = []string{
+				`example.com/foo/foo.go:15:21: inject injectAll: cleanup function of provider "main.provideA" calls recover, which can swallow panics`,
+				`example.com/foo/foo.go:23:10: inject injectAll: cleanup function of provider "main.provideB" calls recover, which can swallow panics`,
+				`example.com/foo/foo.go:30:3: inject injectAll: cleanup function of provider "main.provideC" calls recover, which can swallow panics`,
+			}
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("CheckCleanupRecover = %t: warnings differ (-want +got):\n%s", check, diff)
+		}
+	}
+}
+
+// TestGenerateCleanupRuntime runs the programs of the test cases about
+// cleanup functions under the options that change how cleanup code is
+// generated. TestWire already runs them with the default options. The
+// programs print the cleanup functions that ran after each possible
+// failure, so their output must not depend on the options.
+func TestGenerateCleanupRuntime(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs programs")
+	}
+	goToolPath := filepath.Join(build.Default.GOROOT, "bin", "go")
+	if _, err := os.Stat(goToolPath); err != nil {
+		t.Skip("go toolchain not available:", err)
+	}
+	wireGo, err := ioutil.ReadFile(filepath.Join("..", "..", "wire.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	modes := map[string]*GenerateOptions{
+		"UseRuntimeCleanup": {UseRuntimeCleanup: true},
+		"IdentifierPrefix":  {IdentifierPrefix: "w"},
+	}
+	for _, name := range []string{"Cleanup", "CleanupInterleaved", "PartialCleanup"} {
+		test, err := loadTestCase(filepath.Join("testdata", name), wireGo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for mode, opts := range modes {
+			name, test, opts := name, test, opts
+			t.Run(name+"/"+mode, func(t *testing.T) {
+				t.Parallel()
+				gopath := t.TempDir()
+				if err := test.materialize(gopath); err != nil {
+					t.Fatal(err)
+				}
+				wd := filepath.Join(gopath, "src", "example.com")
+				env := append(os.Environ(), "GOPATH="+gopath)
+				gens, errs := Generate(context.Background(), wd, env, []string{test.pkg}, opts)
+				if len(errs) > 0 {
+					t.Fatal(errs)
+				}
+				if len(gens[0].Errs) > 0 {
+					t.Fatal(gens[0].Errs)
+				}
+				if err := gens[0].Commit(); err != nil {
+					t.Fatal(err)
+				}
+				if err := goBuildCheck(goToolPath, gopath, test); err != nil {
+					t.Errorf("%v\nwire_gen.go:\n%s", err, gens[0].Content)
+				}
+			})
+		}
+	}
+}
+
+func TestGenerateBenchmarks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	wd, env := materialize(t, map[string]string{
+		"example.com/foo/foo.go": `package foo
+
+import "errors"
+
+type (
+	Foo  struct{}
+	Bar  string
+	Baz  int
+	Bad  int
+	Once int
+)
+
+func provideFoo() (*Foo, func(), error) {
+	return new(Foo), func() {}, nil
+}
+
+func provideBar(n int, s string) Bar {
+	return Bar(s)
+}
+
+func provideBaz(n int) Baz {
+	return Baz(n)
+}
+
+func provideBad(n int) Bad {
+	return Bad(n)
+}
+
+func provideOnce() (Once, error) {
+	return 0, errors.New("unused")
+}
+
+func BenchmarkFixtureInitBar() (int, string) {
+	return 42, "bar"
+}
+
+func BenchmarkFixtureInitBad() string {
+	return "bad"
+}
+`,
+		"example.com/foo/wire.go": `//go:build wireinject
+
+package foo
+
+import "github.com/google/wire"
+
+func InitFoo() (*Foo, func(), error) {
+	panic(wire.Build(provideFoo))
+}
+
+func InitBar(n int, s string) Bar {
+	panic(wire.Build(provideBar))
+}
+
+func InitBaz(n int) Baz {
+	panic(wire.Build(provideBaz))
+}
+
+func InitBad(n int) Bad {
+	panic(wire.Build(provideBad))
+}
+
+//wire:once
+func InitOnce() (Once, error) {
+	panic(wire.Build(provideOnce))
+}
+`,
+	})
+	gopath := gopathOf(wd)
+
+	gens, errs := Generate(context.Background(), wd, env, []string{"example.com/foo"}, &GenerateOptions{})
+	if len(errs) > 0 || len(gens) != 1 || len(gens[0].Errs) > 0 {
+		t.Fatalf("Generate without EmitBenchmarks: %v %+v", errs, gens)
+	}
+	if gens[0].Benchmarks != nil {
+		t.Error("Benchmarks is set without EmitBenchmarks")
+	}
+	gens, errs = Generate(context.Background(), wd, env, []string{"example.com/foo"}, &GenerateOptions{EmitBenchmarks: true})
+	if len(errs) > 0 || len(gens) != 1 || len(gens[0].Errs) > 0 {
+		t.Fatalf("Generate with EmitBenchmarks: %v %+v", errs, gens)
+	}
+	gen := gens[0]
+	var warnings []string
+	for _, w := range gen.Warnings {
+		warnings = append(warnings, scrubError(gopath, w.Error()))
+	}
+	wantWarnings := []string{
+		"example.com/foo/foo.go:x:y: inject InitBad: benchmark fixture BenchmarkFixtureInitBad must take no arguments and return the arguments of InitBad: (int)",
+	}
+	if diff := cmp.Diff(wantWarnings, warnings); diff != "" {
+		t.Errorf("warnings differ (-want +got):\n%s", diff)
+	}
+	bench := gen.Benchmarks
+	if bench == nil {
+		t.Fatal("Benchmarks is nil")
+	}
+	if want := filepath.Join(wd, "foo", "wire_gen_bench_test.go"); bench.OutputPath != want {
+		t.Errorf("Benchmarks.OutputPath = %q; want %q", bench.OutputPath, want)
+	}
+	if !IsGeneratedFile(bench.Content) || editedByHand(bench.Content) {
+		t.Errorf("benchmarks lack the generated file header:\n%s", bench.Content)
+	}
+	if bytes.Contains(bench.Content, []byte("//go:generate")) {
+		t.Errorf("benchmarks repeat the go:generate directive:\n%s", bench.Content)
+	}
+	if err := gen.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(filepath.Join(build.Default.GOROOT, "bin", "go"), "test", "-run=^$", "-bench=.", "-benchtime=1x", "-v", "example.com/foo")
+	cmd.Dir = wd
+	cmd.Env = env
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go test: %v\n%s", err, out)
+	}
+	for _, want := range []string{
+		"BenchmarkInitFoo",
+		"BenchmarkInitBar",
+		"--- SKIP: BenchmarkInitBaz",
+		"no BenchmarkFixtureInitBaz function provides the arguments of InitBaz",
+		"--- SKIP: BenchmarkInitBad",
+		"--- SKIP: BenchmarkInitOnce",
+	} {
+		if !bytes.Contains(out, []byte(want)) {
+			t.Errorf("go test output does not contain %q:\n%s", want, out)
+		}
+	}
+	for _, name := range []string{"BenchmarkInitFoo", "BenchmarkInitBar"} {
+		if bytes.Contains(out, []byte("--- SKIP: "+name)) {
+			t.Errorf("%s skipped:\n%s", name, out)
+		}
+	}
+}
+
+func TestGenerateFromGoGenerate(t *testing.T) {
+	wd, shellEnv := materialize(t, map[string]string{
+		"example.com/foo/foo.go": `package foo
+
+type Foo int
+
+func provideFoo() Foo { return 42 }
+`,
+		"example.com/foo/wire.go": `//go:build wireinject
+
+package foo
+
+import "github.com/google/wire"
+
+//go:generate go run ./wiregen
+func injectFoo() Foo {
+	panic(wire.Build(provideFoo))
+}
+`,
+		"example.com/bar/bar.go": `package bar
+
+//go:generate go run ./wiregen
+`,
+	})
+	var env []string
+	for _, kv := range shellEnv {
+		// The shell sets PWD too, but not the other variables.
+		if !strings.HasPrefix(kv, "PWD=") {
+			env = append(env, kv)
+		}
+	}
+	ctx := context.Background()
+	goGenerate := func(dir, file, pkg string) []string {
+		return append(env[:len(env):len(env)], "PWD="+filepath.Join(wd, dir), "GOFILE="+file, "GOLINE=7", "GOPACKAGE="+pkg)
+	}
+
+	tests := []struct {
+		name    string
+		env     []string
+		wantErr string
+	}{
+		{name: "NotGoGenerate", env: env, wantErr: "GOFILE, GOLINE, GOPACKAGE, PWD not set; GenerateFromGoGenerate must be run by go generate"},
+		{name: "WrongPackage", env: goGenerate("foo", "wire.go", "bar"), wantErr: `wire.go:7: GOPACKAGE is "bar", but wire.go is in package foo`},
+		{name: "NoInjectors", env: goGenerate("bar", "bar.go", "bar"), wantErr: "bar.go:7: no injectors found in example.com/bar"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, errs := generateFromGoGenerate(ctx, test.env, nil)
+			if len(errs) != 1 || errs[0].Error() != test.wantErr {
+				t.Errorf("errors = %v; want %q", errs, test.wantErr)
+			}
+		})
+	}
+	if _, err := os.Stat(filepath.Join(wd, "foo", "wire_gen.go")); !os.IsNotExist(err) {
+		t.Fatalf("wire_gen.go was written for a failed run: %v", err)
+	}
+
+	gen, errs := generateFromGoGenerate(ctx, goGenerate("foo", "wire.go", "foo"), nil)
+	if len(errs) > 0 || len(gen.Errs) > 0 {
+		t.Fatalf("generateFromGoGenerate: %v %v", errs, gen.Errs)
+	}
+	if gen.PkgPath != "example.com/foo" {
+		t.Errorf("PkgPath = %q; want %q", gen.PkgPath, "example.com/foo")
+	}
+	written, err := ioutil.ReadFile(filepath.Join(wd, "foo", "wire_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(written, gen.Content) {
+		t.Errorf("wire_gen.go is not the generated content:\n%s", written)
+	}
+}
+
+func TestGenerateGlobalReads(t *testing.T) {
+	wd, env := materialize(t, map[string]string{
+		"example.com/config/config.go": `package config
+
+var (
+	Addr  string
+	Debug bool
+	Level int
+)
+
+func Get() string { return Addr }
+`,
+		"example.com/foo/foo.go": `package main
+
+import (
+	"os"
+
+	"example.com/config"
+)
+
+func main() {}
+
+type (
+	A string
+	B bool
+	C int
+	D string
+	E string
+	F int
+)
+
+var local = "local"
+
+func provideA() A {
+	return A(config.Addr) // line 23
+}
+
+func provideB() (B, func()) {
+	config.Debug = true
+	p := &config.Debug
+	return B(*p), func() { println(config.Level) }
+}
+
+// provideC is allowed to read globals.
+//
+//wire:allow-global-read
+func provideC() C {
+	return C(config.Level)
+}
+
+func provideD() D {
+	//wire:allow-global-read
+	return D(config.Addr)
+}
+
+func provideE() E {
+	return E(local + os.Getenv("E") + config.Get())
+}
+
+func provideF() F {
+	return F(config.Level) // line 49
+}
+`,
+		"example.com/foo/wire.go": `//go:build wireinject
+
+package main
+
+import "github.com/google/wire"
+
+func injectAll() (All, func()) {
+	panic(wire.Build(provideA, provideB, provideC, provideD, provideE, provideF, wire.Struct(new(All), "*")))
+}
+
+type All struct {
+	A A
+	B B
+	C C
+	D D
+	E E
+	F F
+}
+`,
+	})
+	for _, check := range []bool{false, true} {
+		gens, errs := Generate(context.Background(), wd, env, []string{"example.com/foo"}, &GenerateOptions{CheckGlobalReads: check})
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		if len(gens[0].Errs) > 0 {
+			t.Fatal(gens[0].Errs)
+		}
+		var got []string
+		for _, w := range gens[0].Warnings {
+			got = append(got, strings.TrimPrefix(w.Error(), filepath.Dir(wd)+string(os.PathSeparator)))
+		}
+		var want []string
+		if check {
+			want = []string{
+				`example.com/foo/foo.go:23:18: inject injectAll: provider "main.provideA" reads package-level variable config.Addr, which may not be set yet when the injector runs`,
+				`example.com/foo/foo.go:49:18: inject injectAll: provider "main.provideF" reads package-level variable config.Level, which may not be set yet when the injector runs`,
+			}
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("CheckGlobalReads = %t: warnings differ (-want +got):\n%s", check, diff)
+		}
+	}
+}
+
+func TestGenerateConversions(t *testing.T) {
+	wd, env := materialize(t, map[string]string{
+		"example.com/ids/ids.go": `package ids
+
+type (
+	UserID    int64
+	SessionID int64
+	TraceID   int64
+)
+
+func NewUserID() UserID { return 1 }
+
+func SessionFromUser(id UserID) SessionID {
+	return SessionID(id)
+}
+
+// TraceFromUser is meant to be a conversion.
+//
+//wire:allow-conversion
+func TraceFromUser(id UserID) TraceID {
+	return TraceID(id)
+}
+`,
+		"example.com/foo/foo.go": `package main
+
+import "example.com/ids"
+
+func main() {}
+
+type (
+	Count   int64
+	Name    string
+	Label   string
+	Total   int64
+	Renamed = ids.UserID
+)
+
+func provideCount(id ids.UserID) Count {
+	return (Count)((id))
+}
+
+func provideName() Name { return "name" }
+
+func provideLabel(n Name) Label {
+	l := Label(n)
+	return l
+}
+
+func provideTotal(c Count) Total {
+	return Total(c + 1)
+}
+
+func provideInt(id Renamed) int64 {
+	return int64(id)
+}
+`,
+		"example.com/foo/wire.go": `//go:build wireinject
+
+package main
+
+import (
+	"example.com/ids"
+	"github.com/google/wire"
+)
+
+func injectAll() All {
+	panic(wire.Build(ids.NewUserID, ids.SessionFromUser, ids.TraceFromUser, provideCount, provideName, provideLabel, provideTotal, provideInt, wire.Struct(new(All), "*")))
+}
+
+type All struct {
+	S ids.SessionID
+	T ids.TraceID
+	L Label
+	C Total
+	I int64
+}
+`,
+	})
+	for _, check := range []bool{false, true} {
+		gens, errs := Generate(context.Background(), wd, env, []string{"example.com/foo"}, &GenerateOptions{CheckConversions: check})
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		var got []string
+		for _, e := range gens[0].Errs {
+			got = append(got, strings.TrimPrefix(e.Error(), filepath.Dir(wd)+string(os.PathSeparator)))
+		}
+		var want []string
+		if check {
+			want = []string{
+				`example.com/ids/ids.go:11:6: inject injectAll: provider "ids.SessionFromUser" only converts example.com/ids.UserID to example.com/ids.SessionID, which have the same underlying type int64`,
+				`example.com/foo/foo.go:15:6: inject injectAll: provider "main.provideCount" only converts example.com/ids.UserID to example.com/foo.Count, which have the same underlying type int64`,
+			}
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("CheckConversions = %t: errors differ (-want +got):\n%s", check, diff)
+		}
+		if check == (len(gens[0].Content) > 0) {
+			t.Errorf("CheckConversions = %t: got %d bytes of output", check, len(gens[0].Content))
+		}
+	}
+}
+
+func TestGenerateUnusedResults(t *testing.T) {
+	wireGo, err := ioutil.ReadFile(filepath.Join("..", "..", "wire.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test, err := loadTestCase(filepath.Join("testdata", "MultipleResults"), wireGo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gopath := t.TempDir()
+	if err := test.materialize(gopath); err != nil {
+		t.Fatal(err)
+	}
+	wd := filepath.Join(gopath, "src", "example.com")
+	env := append(os.Environ(), "GOPATH="+gopath)
+	gens, errs := Generate(context.Background(), wd, env, []string{test.pkg}, &GenerateOptions{})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if len(gens) != 1 {
+		t.Fatalf("got %d results, want 1", len(gens))
+	}
+	if len(gens[0].Errs) > 0 {
+		t.Fatal(gens[0].Errs)
+	}
+	want := `inject injectCache: result \*example.com/foo.Client of provider "main.NewClientAndCache" is unused$`
+	if got := gens[0].Warnings; len(got) != 1 || !regexp.MustCompile(want).MatchString(got[0].Error()) {
+		t.Errorf("Warnings = %v; want one matching %q", got, want)
+	}
+}
+
+func TestGenerateUnusedParams(t *testing.T) {
+	wd, env := materialize(t, map[string]string{
+		"example.com/foo/foo.go": `package main
+
+import "github.com/google/wire"
+
+type Config struct{ Port int }
+
+func main() {}
+
+var Set = wire.NewSet(provideGreeting, wire.FieldsOf(new(Config), "Port"))
+
+func provideGreeting(name string) []byte { return []byte(name) }
+`,
+		"example.com/foo/wire.go": `//go:build wireinject
+
+package main
+
+import "github.com/google/wire"
+
+func injectDirect(cfg Config, name string) []byte {
+	panic(wire.Build(provideGreeting))
+}
+
+func injectTransitive(cfg Config, name string) []byte {
+	panic(wire.Build(Set))
+}
+
+func injectUsed(cfg Config) int {
+	panic(wire.Build(Set))
+}
+`,
+	})
+	want := []string{
+		`inject injectDirect: parameter cfg of type example.com/foo.Config is unused$`,
+		`inject injectTransitive: parameter cfg of type example.com/foo.Config is unused$`,
+	}
+	check := func(t *testing.T, got []error) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("got %d problems, want %d: %v", len(got), len(want), got)
+		}
+		for i := range want {
+			if !regexp.MustCompile(want[i]).MatchString(got[i].Error()) {
+				t.Errorf("problem %d = %q; want match for %q", i, got[i], want[i])
+			}
+		}
+	}
+
+	t.Run("Warnings", func(t *testing.T) {
+		opts := &GenerateOptions{UngroupedErrors: true}
+		gens, errs := Generate(context.Background(), wd, env, []string{"example.com/foo"}, opts)
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		if len(gens) != 1 {
+			t.Fatalf("got %d results, want 1", len(gens))
+		}
+		if len(gens[0].Errs) > 0 {
+			t.Fatal(gens[0].Errs)
+		}
+		if len(gens[0].Content) == 0 {
+			t.Error("no content generated")
+		}
+		check(t, gens[0].Warnings)
+	})
+	t.Run("UnusedParamsAsError", func(t *testing.T) {
+		opts := &GenerateOptions{UnusedParamsAsError: true, UngroupedErrors: true}
+		gens, errs := Generate(context.Background(), wd, env, []string{"example.com/foo"}, opts)
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		if len(gens) != 1 {
+			t.Fatalf("got %d results, want 1", len(gens))
+		}
+		if len(gens[0].Content) != 0 {
+			t.Error("content generated despite errors")
+		}
+		if len(gens[0].Warnings) > 0 {
+			t.Errorf("got warnings %v; want none", gens[0].Warnings)
+		}
+		check(t, gens[0].Errs)
+	})
+}
+
+func TestMissingProviderSuggestions(t *testing.T) {
+	wireGo, err := ioutil.ReadFile(filepath.Join("..", "..", "wire.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test, err := loadTestCase(filepath.Join("testdata", "MissingProviderSuggestions"), wireGo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gopath := t.TempDir()
+	if err := test.materialize(gopath); err != nil {
+		t.Fatal(err)
+	}
+	wd := filepath.Join(gopath, "src", "example.com")
+	env := append(os.Environ(), "GOPATH="+gopath)
+	gens, errs := Generate(context.Background(), wd, env, []string{test.pkg}, &GenerateOptions{})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if len(gens) != 1 {
+		t.Fatalf("got %d results, want 1", len(gens))
+	}
+	var got [][]string
+	for _, err := range gens[0].Errs {
+		var missing *MissingProviderError
+		if !errors.As(err, &missing) {
+			t.Errorf("%v is not a MissingProviderError", err)
+			continue
+		}
+		sugs := []string{types.TypeString(missing.Type, nil)}
+		for _, sug := range missing.Suggestions {
+			sugs = append(sugs, fmt.Sprintf("%s %s:%d", types.TypeString(sug.Type, nil), filepath.Base(sug.Pos.Filename), sug.Pos.Line))
+		}
+		got = append(got, sugs)
+	}
+	want := [][]string{
+		{"*example.com/db.Connection", "*example.com/db.Conn db.go:25"},
+		{"*example.com/foo.Logger", "*example.com/db.Logger db.go:27"},
+		{"example.com/db.Conn", "*example.com/db.Conn db.go:25"},
+		{"example.com/foo.Handler", "example.com/foo.Handle foo.go:38", "example.com/foo.Handled foo.go:38", "example.com/foo.Handlers foo.go:38"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("suggestions (-want +got):\n%s", diff)
+	}
+}
+
+func TestLocalNamesAllEntryPoints(t *testing.T) {
+	wireGo, err := ioutil.ReadFile(filepath.Join("..", "..", "wire.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The names of locals whose types share a name must not depend on how
+	// the packages are generated.
+	for _, name := range []string{"SameNameProviders", "MultipleSimilarPackages", "FieldsOfImportedStruct"} {
+		t.Run(name, func(t *testing.T) {
+			test, err := loadTestCase(filepath.Join("testdata", name), wireGo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gopath := t.TempDir()
+			if err := test.materialize(gopath); err != nil {
+				t.Fatal(err)
+			}
+			wd := filepath.Join(gopath, "src", "example.com")
+			env := append(os.Environ(), "GOPATH="+gopath)
+			ctx := context.Background()
+			patterns := []string{test.pkg}
+			generators := map[string]func() ([]GenerateResult, []error){
+				"Generate": func() ([]GenerateResult, []error) {
+					return Generate(ctx, wd, env, patterns, &GenerateOptions{})
+				},
+				"GenerateParallel": func() ([]GenerateResult, []error) {
+					return GenerateParallel(ctx, wd, env, patterns, &GenerateOptions{}, 2)
+				},
+				"GenerateWithLazyLoad": func() ([]GenerateResult, []error) {
+					return GenerateWithLazyLoad(ctx, wd, env, patterns, &GenerateOptions{})
+				},
+				"GenerateParallelWithLazyLoad": func() ([]GenerateResult, []error) {
+					return GenerateParallelWithLazyLoad(ctx, wd, env, patterns, &GenerateOptions{}, 2)
+				},
+				"GenerateStream": func() ([]GenerateResult, []error) {
+					results := make(chan *GenerateResult, 1)
+					errs := GenerateStream(ctx, wd, env, patterns, nil, results)
+					var gens []GenerateResult
+					for r := range results {
+						gens = append(gens, *r)
+					}
+					return gens, errs
+				},
+			}
+			for genName, generate := range generators {
+				gens, errs := generate()
+				if len(errs) > 0 {
+					t.Fatalf("%s: %v", genName, errs)
+				}
+				if len(gens) != 1 || len(gens[0].Errs) > 0 {
+					t.Fatalf("%s: got %d results (%v); want 1 without errors", genName, len(gens), gens)
+				}
+				if diff := cmp.Diff(string(test.wantWireOutput), string(gens[0].Content)); diff != "" {
+					t.Errorf("%s: output differs from wire_gen.go (-want +got):\n%s", genName, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	wireGo, err := ioutil.ReadFile(filepath.Join("..", "..", "wire.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test, err := loadTestCase(filepath.Join("testdata", "Chain"), wireGo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.goFiles["example.com/empty/empty.go"] = []byte("package empty\n\nfunc Empty() {}\n")
+	gopath := t.TempDir()
+	if err := test.materialize(gopath); err != nil {
+		t.Fatal(err)
+	}
+	wd := filepath.Join(gopath, "src", "example.com")
+	env := append(os.Environ(), "GOPATH="+gopath)
+	ctx := context.Background()
+	generators := map[string]func(patterns []string) ([]GenerateResult, []error){
+		"Generate": func(patterns []string) ([]GenerateResult, []error) {
+			return Generate(ctx, wd, env, patterns, &GenerateOptions{})
+		},
+		"GenerateParallel": func(patterns []string) ([]GenerateResult, []error) {
+			return GenerateParallel(ctx, wd, env, patterns, &GenerateOptions{}, 2)
+		},
+		"GenerateParallelWithLazyLoad": func(patterns []string) ([]GenerateResult, []error) {
+			return GenerateParallelWithLazyLoad(ctx, wd, env, patterns, &GenerateOptions{}, 2)
+		},
+	}
+	tests := []struct {
+		patterns          []string
+		wantWithInjectors []string
+		wantErr           error
+	}{
+		{
+			patterns: []string{"example.com/empty"},
+			wantErr:  ErrNoInjectors,
+		},
+		{
+			patterns:          []string{"example.com/foo", "example.com/empty"},
+			wantWithInjectors: []string{"example.com/foo"},
+		},
+	}
+	for name, generate := range generators {
+		for _, test := range tests {
+			gens, errs := generate(test.patterns)
+			if len(errs) > 0 {
+				t.Fatalf("%s(%q): %v", name, test.patterns, errs)
+			}
+			sum := Summarize(gens)
+			if len(sum.Scanned) != len(test.patterns) {
+				t.Errorf("%s(%q): Scanned = %q; want %d packages", name, test.patterns, sum.Scanned, len(test.patterns))
+			}
+			if diff := cmp.Diff(test.wantWithInjectors, sum.WithInjectors); diff != "" {
+				t.Errorf("%s(%q): WithInjectors (-want +got):\n%s", name, test.patterns, diff)
+			}
+			if err := sum.Err(); err != test.wantErr {
+				t.Errorf("%s(%q): Err() = %v; want %v", name, test.patterns, err, test.wantErr)
+			}
+		}
+	}
+}
+
+func TestGenerateStream(t *testing.T) {
+	const numPkgs = 10
+	files := make(map[string]string)
+	wantPkgs := make(map[string]bool)
+	for i := 0; i < numPkgs; i++ {
+		pkg := fmt.Sprintf("example.com/p%d", i)
+		wantPkgs[pkg] = true
+		files[pkg+"/foo.go"] = `package main
+
+func main() {}
+
+func provideMessage() string { return "hello" }
+`
+		files[pkg+"/wire.go"] = `//go:build wireinject
+
+package main
+
+import "github.com/google/wire"
+
+func injectMessage() string {
+	panic(wire.Build(provideMessage))
+}
+`
+	}
+	wd, env := materialize(t, files)
+
+	t.Run("SlowConsumer", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+		results := make(chan *GenerateResult)
+		errCh := make(chan []error, 1)
+		go func() {
+			errCh <- GenerateStream(context.Background(), wd, env, []string{"example.com/..."}, nil, results)
+		}()
+		got := make(map[string]int)
+		for res := range results {
+			time.Sleep(20 * time.Millisecond)
+			if len(res.Errs) > 0 {
+				t.Errorf("%s: %v", res.PkgPath, res.Errs)
+			}
+			if len(res.Content) == 0 {
+				t.Errorf("%s: no content generated", res.PkgPath)
+			}
+			got[res.PkgPath]++
+		}
+		if errs := <-errCh; len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		for pkg := range wantPkgs {
+			if got[pkg] != 1 {
+				t.Errorf("%s sent %d times; want 1", pkg, got[pkg])
+			}
+		}
+		if len(got) != len(wantPkgs) {
+			t.Errorf("got results for %d packages; want %d", len(got), len(wantPkgs))
+		}
+		checkGoroutines(t, before)
+	})
+	t.Run("Canceled", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		results := make(chan *GenerateResult)
+		errCh := make(chan []error, 1)
+		go func() {
+			errCh <- GenerateStream(ctx, wd, env, []string{"example.com/..."}, nil, results)
+		}()
+		// Take one result, then stop reading.
+		if _, ok := <-results; !ok {
+			t.Fatal("results closed before any result was sent")
+		}
+		cancel()
+		select {
+		case errs := <-errCh:
+			if len(errs) != 1 || errs[0] != context.Canceled {
+				t.Errorf("GenerateStream returned %v; want %v", errs, context.Canceled)
+			}
+		case <-time.After(30 * time.Second):
+			t.Fatal("GenerateStream did not return after the context was canceled")
+		}
+		for range results {
+			// Drain any result that was sent before cancellation was noticed.
+		}
+		checkGoroutines(t, before)
+	})
+}
+
+// checkGoroutines reports an error if the number of goroutines does not drop
+// back to at most want within a few seconds.
+func checkGoroutines(t *testing.T, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		n := runtime.NumGoroutine()
+		if n <= want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Errorf("%d goroutines running; want at most %d", n, want)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGenerateVerify(t *testing.T) {
+	wd, env := materialize(t, map[string]string{
+		"example.com/foo/foo.go": `package main
+
+type Foo int
+type Bar int
+
+func main() {}
+
+func provideFoo() Foo { return 1 }
+
+func provideBar(foo Foo, n int) Bar { return Bar(foo) + Bar(n) }
+`,
+		"example.com/foo/wire.go": `//go:build wireinject
+
+package main
+
+import "github.com/google/wire"
+
+func injectBar(n int) Bar {
+	panic(wire.Build(provideFoo, provideBar))
+}
+`,
+	})
+	gens, errs := Generate(context.Background(), wd, env, []string{"example.com/foo"}, &GenerateOptions{VerifyOutput: true})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if len(gens) != 1 {
+		t.Fatalf("got %d results, want 1", len(gens))
+	}
+	gen := gens[0]
+	if len(gen.Errs) > 0 {
+		t.Fatal(gen.Errs)
+	}
+
+	// Swap the arguments to provideBar. The result still parses, but does
+	// not type-check.
+	const call = "provideBar(foo, n)"
+	if !bytes.Contains(gen.Content, []byte(call)) {
+		t.Fatalf("generated code does not contain %q:\n%s", call, gen.Content)
+	}
+	gen.Content = bytes.Replace(gen.Content, []byte(call), []byte("provideBar(n, foo)"), 1)
+	errs = gen.Verify()
+	if len(errs) == 0 {
+		t.Fatal("Verify succeeded on code with swapped arguments")
+	}
+	for _, err := range errs {
+		if !strings.Contains(err.Error(), "does not type-check") {
+			t.Errorf("Verify error = %q; want type error", err)
+		}
+	}
+	if _, err := os.Stat(gen.OutputPath); !os.IsNotExist(err) {
+		t.Errorf("Verify wrote %s to disk", gen.OutputPath)
+	}
+}
+
+func TestGenerateAnnotate(t *testing.T) {
+	wd, env := materialize(t, map[string]string{
+		"example.com/foo/foo.go": `package main
+
+import "example.com/bar"
+
+type Config struct {
+	Name string
+}
+
+type Greeter struct {
+	Pool *bar.Pool
+	Name string
+}
+
+func main() {}
+
+func provideName(c Config) string { return c.Name }
+`,
+		"example.com/bar/bar.go": `package bar
+
+type Pool struct{}
+
+func NewPool() (*Pool, func(), error) { return &Pool{}, func() {}, nil }
+`,
+		"example.com/foo/wire.go": `//go:build wireinject
+
+package main
+
+import (
+	"example.com/bar"
+	"github.com/google/wire"
+)
+
+func injectGreeter() (*Greeter, func(), error) {
+	panic(wire.Build(
+		bar.NewPool,
+		wire.Value(Config{Name: "wire"}),
+		wire.FieldsOf(new(Config), "Name"),
+		wire.Struct(new(Greeter), "*"),
+	))
+}
+`,
+	})
+	generate := func(t *testing.T, annotate bool) GenerateResult {
+		t.Helper()
+		gens, errs := Generate(context.Background(), wd, env, []string{"example.com/foo"}, &GenerateOptions{AnnotateOutput: annotate})
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		if len(gens) != 1 {
+			t.Fatalf("got %d results, want 1", len(gens))
+		}
+		if len(gens[0].Errs) > 0 {
+			t.Fatal(gens[0].Errs)
+		}
+		return gens[0]
+	}
+
+	plain := generate(t, false)
+	if bytes.Contains(plain.Content, []byte("// provides")) {
+		t.Errorf("output without AnnotateOutput has annotations:\n%s", plain.Content)
+	}
+	annotated := generate(t, true)
+	// gofmt aligns the comments, so compare with runs of blanks collapsed.
+	got := regexp.MustCompile(`[ \t]+`).ReplaceAll(annotated.Content, []byte(" "))
+	for _, want := range []string{
+		"pool, cleanup, err := bar.NewPool() // provides *bar.Pool (bar.NewPool, bar/bar.go:5)",
+		"config := _wireConfigValue // provides Config (value, foo/wire.go:13)",
+		"string2 := config.Name // provides string (field Name, foo/foo.go:6)",
+		"greeter := &Greeter{ // provides *Greeter (Greeter, foo/foo.go:9)",
+	} {
+		if !bytes.Contains(got, []byte(want)) {
+			t.Errorf("annotated output does not contain %q:\n%s", want, annotated.Content)
+		}
+	}
+	if fmtSrc, err := format.Source(annotated.Content); err != nil {
+		t.Error(err)
+	} else if !bytes.Equal(fmtSrc, annotated.Content) {
+		t.Errorf("annotated output changes under gofmt:\n%s", annotated.Content)
+	}
+	if again := generate(t, true); !bytes.Equal(again.Content, annotated.Content) {
+		t.Errorf("annotated output is not deterministic; got:\n%s\nthen:\n%s", annotated.Content, again.Content)
+	}
+	if plain.ContentHash == "" {
+		t.Error("ContentHash is empty")
+	}
+	if plain.ContentHash != annotated.ContentHash {
+		t.Errorf("ContentHash = %s with annotations, %s without; want equal", annotated.ContentHash, plain.ContentHash)
+	}
+}
+
+func goBuildCheck(goToolPath, gopath string, test *testCase) error {
+	// Run `go build`.
+	testExePath := filepath.Join(gopath, "bin", "testprog")
+	buildCmd := []string{"build", "-o", testExePath}
+	buildCmd = append(buildCmd, test.pkg)
+	cmd := exec.Command(goToolPath, buildCmd...)
+	cmd.Dir = filepath.Join(gopath, "src", "example.com")
+	cmd.Env = append(os.Environ(), "GOPATH="+gopath)
+	if buildOut, err := cmd.CombinedOutput(); err != nil {
+		if len(buildOut) > 0 {
+			return fmt.Errorf("build: %v; output:\n%s", err, buildOut)
+		}
+		return fmt.Errorf("build: %v", err)
+	}
+
+	// Run `go vet`. Only diagnostics in the generated file count: the
+	// hand-written test case sources are not held to the same standard.
+	cmd = exec.Command(goToolPath, "vet", test.pkg)
+	cmd.Dir = filepath.Join(gopath, "src", "example.com")
+	cmd.Env = append(os.Environ(), "GOPATH="+gopath)
+	vetOut, _ := cmd.CombinedOutput()
+	var vetErrs []string
+	for _, line := range strings.Split(string(vetOut), "\n") {
+		if strings.Contains(line, "wire_gen.go") {
+			vetErrs = append(vetErrs, line)
+		}
+	}
+	if len(vetErrs) > 0 {
+		return fmt.Errorf("vet:\n%s", strings.Join(vetErrs, "\n"))
+	}
+
+	// Run the resulting program and compare its output to the expected
+	// output.
+	out, err := exec.Command(testExePath).Output()
+	if err != nil {
+		return fmt.Errorf("run compiled program: %v", err)
+	}
+	if !bytes.Equal(out, test.wantProgramOutput) {
+		gotS, wantS := string(out), string(test.wantProgramOutput)
+		diff := cmp.Diff(strings.Split(gotS, "\n"), strings.Split(wantS, "\n"))
+		return fmt.Errorf("compiled program output doesn't match:\n*** got:\n%s\n\n*** want:\n%s\n\n*** diff:\n%s", gotS, wantS, diff)
+	}
+	return nil
+}
+
+func TestUnexport(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"", ""},
+		{"a", "a"},
+		{"ab", "ab"},
+		{"A", "a"},
+		{"AB", "ab"},
+		{"A_", "a_"},
+		{"ABc", "aBc"},
+		{"ABC", "abc"},
+		{"AB_", "ab_"},
+		{"foo", "foo"},
+		{"Foo", "foo"},
+		{"HTTPClient", "httpClient"},
+		{"IFace", "iFace"},
+		{"SNAKE_CASE", "snake_CASE"},
+		{"HTTP", "http"},
+	}
+	for _, test := range tests {
+		if got := unexport(test.name); got != test.want {
+			t.Errorf("unexport(%q) = %q; want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"", ""},
+		{"a", "A"},
+		{"ab", "Ab"},
+		{"A", "A"},
+		{"AB", "AB"},
+		{"A_", "A_"},
+		{"ABc", "ABc"},
+		{"ABC", "ABC"},
+		{"AB_", "AB_"},
+		{"foo", "Foo"},
+		{"Foo", "Foo"},
+		{"HTTPClient", "HTTPClient"},
+		{"httpClient", "HttpClient"},
+		{"IFace", "IFace"},
+		{"iFace", "IFace"},
+		{"SNAKE_CASE", "SNAKE_CASE"},
+		{"HTTP", "HTTP"},
+	}
+	for _, test := range tests {
+		if got := export(test.name); got != test.want {
+			t.Errorf("export(%q) = %q; want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestTypeVariableName(t *testing.T) {
+	var (
+		boolT           = types.Typ[types.Bool]
+		stringT         = types.Typ[types.String]
+		fooVarT         = types.NewNamed(types.NewTypeName(0, nil, "foo", stringT), stringT, nil)
+		nonameVarT      = types.NewNamed(types.NewTypeName(0, nil, "", stringT), stringT, nil)
+		barVarInFooPkgT = types.NewNamed(types.NewTypeName(0, types.NewPackage("my.example/foo", "foo"), "bar", stringT), stringT, nil)
+	)
+	tests := []struct {
+		description     string
+		typ             types.Type
+		defaultName     string
+		transformAppend string
+		collides        map[string]bool
+		want            string
+	}{
+		{"basic type", boolT, "", "", map[string]bool{}, "bool"},
+		{"basic type with transform", boolT, "", "suffix", map[string]bool{}, "boolsuffix"},
+		{"basic type with collision", boolT, "", "", map[string]bool{"bool": true}, "bool2"},
+		{"basic type with transform and collision", boolT, "", "suffix", map[string]bool{"boolsuffix": true}, "boolsuffix2"},
+		{"a different basic type", stringT, "", "", map[string]bool{}, "string"},
+		{"named type", fooVarT, "", "", map[string]bool{}, "foo"},
+		{"named type with transform", fooVarT, "", "suffix", map[string]bool{}, "foosuffix"},
+		{"named type with collision", fooVarT, "", "", map[string]bool{"foo": true}, "foo2"},
+		{"named type with transform and collision", fooVarT, "", "suffix", map[string]bool{"foosuffix": true}, "foosuffix2"},
+		{"noname type", nonameVarT, "bar", "", map[string]bool{}, "bar"},
+		{"noname type with transform", nonameVarT, "bar", "s", map[string]bool{}, "bars"},
+		{"noname type with transform and collision", nonameVarT, "bar", "s", map[string]bool{"bars": true}, "bars2"},
+		{"var in pkg type", barVarInFooPkgT, "", "", map[string]bool{}, "bar"},
+		{"var in pkg type with collision", barVarInFooPkgT, "", "", map[string]bool{"bar": true}, "fooBar"},
+		{"var in pkg type with double collision", barVarInFooPkgT, "", "", map[string]bool{"bar": true, "fooBar": true}, "bar2"},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s: typeVariableName(%v, %q, %q, %v)", test.description, test.typ, test.defaultName, test.transformAppend, test.collides), func(t *testing.T) {
+			got := typeVariableName(test.typ, test.defaultName, func(name string) string { return name + test.transformAppend }, func(name string) bool { return test.collides[name] })
+			if !isIdent(got) {
+				t.Errorf("%q is not an identifier", got)
+			}
+			if got != test.want {
+				t.Errorf("got %q want %q", got, test.want)
+			}
+			if test.collides[got] {
+				t.Errorf("%q collides", got)
+			}
+		})
+	}
+}
+
+func TestDisambiguate(t *testing.T) {
+	tests := []struct {
+		name     string
+		want     string
+		collides map[string]bool
+	}{
+		{"foo", "foo", nil},
+		{"foo", "foo2", map[string]bool{"foo": true}},
+		{"foo", "foo3", map[string]bool{"foo": true, "foo1": true, "foo2": true}},
+		{"foo1", "foo1_2", map[string]bool{"foo": true, "foo1": true, "foo2": true}},
+		{"foo\u0661", "foo\u0661", map[string]bool{"foo": true, "foo1": true, "foo2": true}},
+		{"foo\u0661", "foo\u06612", map[string]bool{"foo": true, "foo1": true, "foo2": true, "foo\u0661": true}},
+		{"select", "select2", nil},
+		{"var", "var2", nil},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("disambiguate(%q, %v)", test.name, test.collides), func(t *testing.T) {
+			got := disambiguate(test.name, func(name string) bool { return test.collides[name] })
+			if !isIdent(got) {
+				t.Errorf("%q is not an identifier", got)
+			}
+			if got != test.want {
+				t.Errorf("got %q want %q", got, test.want)
+			}
+			if test.collides[got] {
+				t.Errorf("%q collides", got)
+			}
+		})
+	}
+}
+
+func isIdent(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	r, i := utf8.DecodeRuneInString(s)
+	if !unicode.IsLetter(r) && r != '_' {
+		return false
+	}
+	for i < len(s) {
+		r, sz := utf8.DecodeRuneInString(s[i:])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			return false
+		}
+		i += sz
+	}
+	return true
+}
+
+// scrubError rewrites the given string to remove occurrences of GOPATH/src,
+// rewrites OS-specific path separators to slashes, and any line/column
+// information to a fixed ":x:y". For example, if the gopath parameter is
+// "C:\GOPATH" and running on Windows, the string
+// "C:\GOPATH\src\foo\bar.go:15:4" would be rewritten to "foo/bar.go:x:y".
+// generateOutcome returns the output paths, contents and errors of a call to
+// Generate, with errors scrubbed as by scrubError, for comparing calls.
+func generateOutcome(gopath string, gens []GenerateResult, errs []error) []string {
+	var out []string
+	for _, e := range errs {
+		out = append(out, scrubError(gopath, e.Error()))
+	}
+	for _, gen := range gens {
+		out = append(out, gen.OutputPath, string(gen.Content))
+		for _, e := range gen.Errs {
+			out = append(out, scrubError(gopath, e.Error()))
+		}
+	}
+	return out
+}
+
+func scrubError(gopath string, s string) string {
+	sb := new(strings.Builder)
+	query := gopath + string(os.PathSeparator) + "src" + string(os.PathSeparator)
+	for {
+		// Find next occurrence of source root. This indicates the next path to
+		// scrub.
+		start := strings.Index(s, query)
+		if start == -1 {
+			sb.WriteString(s)
+			break
+		}
+
+		// Find end of file name (extension ".go").
+		fileStart := start + len(query)
+		fileEnd := strings.Index(s[fileStart:], ".go")
+		if fileEnd == -1 {
+			// If no ".go" occurs to end of string, further searches will fail too.
+			// Break the loop.
+			sb.WriteString(s)
+			break
+		}
+		fileEnd += fileStart + 3 // Advance to end of extension.
+
+		// Write out file name and advance scrub position.
+		file := s[fileStart:fileEnd]
+		if os.PathSeparator != '/' {
+			file = strings.Replace(file, string(os.PathSeparator), "/", -1)
+		}
+		sb.WriteString(s[:start])
+		sb.WriteString(file)
+		s = s[fileEnd:]
+
+		// Peek past to see if there is line/column info.
+		linecol, linecolLen := scrubLineColumn(s)
+		sb.WriteString(linecol)
+		s = s[linecolLen:]
+	}
+	return sb.String()
+}
+
+func scrubLineColumn(s string) (replacement string, n int) {
+	if !strings.HasPrefix(s, ":") {
+		return "", 0
+	}
+	// Skip first colon and run of digits.
+	for n++; len(s) > n && '0' <= s[n] && s[n] <= '9'; {
+		n++
+	}
+	if n == 1 {
 		// No digits followed colon.
 		return "", 0
 	}
 
-	// Start on column part.
-	if !strings.HasPrefix(s[n:], ":") {
-		return ":x", n
+	// Start on column part.
+	if !strings.HasPrefix(s[n:], ":") {
+		return ":x", n
+	}
+	lineEnd := n
+	// Skip second colon and run of digits.
+	for n++; len(s) > n && '0' <= s[n] && s[n] <= '9'; {
+		n++
+	}
+	if n == lineEnd+1 {
+		// No digits followed second colon.
+		return ":x", lineEnd
+	}
+	return ":x:y", n
+}
+
+type testCase struct {
+	name                 string
+	pkg                  string
+	header               []byte
+	identifierPrefix     string
+	wrapErrors           string
+	goos                 string
+	goFiles              map[string][]byte
+	wantProgramOutput    []byte
+	wantWireOutput       []byte
+	wantWireError        bool
+	wantWireErrorStrings []string
+	// usesCgo is true if one of the files imports "C".
+	usesCgo bool
+}
+
+// loadTestCase reads a test case from a directory.
+//
+// The directory structure is:
+//
+//	root/
+//
+//		pkg
+//			file containing the package name containing the inject function
+//			(must also be package main)
+//
+//		header
+//			optional file inserted at the start of the generated file
+//
+//		identifier_prefix
+//			optional GenerateOptions.IdentifierPrefix
+//
+//		wrap_errors
+//			optional GenerateOptions.WrapErrors
+//
+//		goos
+//			optional GenerateOptions.GOOS, for a test case whose outcome
+//			would otherwise depend on the host; since the program is not
+//			run for another platform, the case must expect errors
+//
+//		...
+//			any Go files found recursively placed under GOPATH/src/...
+//
+//		want/
+//
+//			wire_errs.txt
+//					Expected errors from the Wire Generate function,
+//					missing if no errors expected.
+//					Distinct errors are separated by a blank line,
+//					and line numbers and line positions are scrubbed
+//					(e.g. "$GOPATH/src/foo.go:52:8" --> "foo.go:x:y").
+//
+//			wire_gen.go
+//					verified output of wire from a test run with
+//					-record, missing if wire_errs.txt is present
+//
+//			program_out.txt
+//					expected output from the final compiled program,
+//					which is built with wire_gen.go and run unless
+//					the tests are run with -short; missing if
+//					wire_errs.txt is present
+func loadTestCase(root string, wireGoSrc []byte) (*testCase, error) {
+	name := filepath.Base(root)
+	pkg, err := ioutil.ReadFile(filepath.Join(root, "pkg"))
+	if err != nil {
+		return nil, fmt.Errorf("load test case %s: %v", name, err)
+	}
+	header, _ := ioutil.ReadFile(filepath.Join(root, "header"))
+	identifierPrefix, _ := ioutil.ReadFile(filepath.Join(root, "identifier_prefix"))
+	wrapErrors, _ := ioutil.ReadFile(filepath.Join(root, "wrap_errors"))
+	goos, _ := ioutil.ReadFile(filepath.Join(root, "goos"))
+	var wantProgramOutput []byte
+	var wantWireOutput []byte
+	wireErrb, err := ioutil.ReadFile(filepath.Join(root, "want", "wire_errs.txt"))
+	wantWireError := err == nil
+	var wantWireErrorStrings []string
+	if wantWireError {
+		for _, errs := range strings.Split(string(wireErrb), "\n\n") {
+			// Allow for trailing newlines, which can be hard to remove in some editors.
+			wantWireErrorStrings = append(wantWireErrorStrings, strings.TrimRight(errs, "\n\r"))
+		}
+	} else {
+		if !*record {
+			wantWireOutput, err = ioutil.ReadFile(filepath.Join(root, "want", "wire_gen.go"))
+			if err != nil {
+				return nil, fmt.Errorf("load test case %s: %v, if this is a new testcase, run with -record to generate the wire_gen.go file", name, err)
+			}
+		}
+		wantProgramOutput, err = ioutil.ReadFile(filepath.Join(root, "want", "program_out.txt"))
+		if err != nil {
+			return nil, fmt.Errorf("load test case %s: %v", name, err)
+		}
+	}
+	goFiles := map[string][]byte{
+		"github.com/google/wire/wire.go": wireGoSrc,
+	}
+	usesCgo := false
+	err = filepath.Walk(root, func(src string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, src)
+		if err != nil {
+			return err // unlikely
+		}
+		if info.Mode().IsDir() && rel == "want" {
+			// The "want" directory should not be included in goFiles.
+			return filepath.SkipDir
+		}
+		if !info.Mode().IsRegular() || filepath.Ext(src) != ".go" {
+			return nil
+		}
+		data, err := ioutil.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		goFiles["example.com/"+filepath.ToSlash(rel)] = data
+		usesCgo = usesCgo || bytes.Contains(data, []byte(`import "C"`))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load test case %s: %v", name, err)
+	}
+	return &testCase{
+		name:                 name,
+		pkg:                  string(bytes.TrimSpace(pkg)),
+		header:               header,
+		identifierPrefix:     string(bytes.TrimSpace(identifierPrefix)),
+		wrapErrors:           string(bytes.TrimSpace(wrapErrors)),
+		goos:                 string(bytes.TrimSpace(goos)),
+		goFiles:              goFiles,
+		wantWireOutput:       wantWireOutput,
+		wantProgramOutput:    wantProgramOutput,
+		wantWireError:        wantWireError,
+		wantWireErrorStrings: wantWireErrorStrings,
+		usesCgo:              usesCgo,
+	}, nil
+}
+
+var (
+	cgoEnabledOnce sync.Once
+	cgoEnabledOK   bool
+)
+
+// cgoEnabled reports whether the go command builds packages that import
+// "C". It turns cgo off by default when there is no C toolchain.
+func cgoEnabled() bool {
+	cgoEnabledOnce.Do(func() {
+		out, err := exec.Command("go", "env", "CGO_ENABLED").Output()
+		cgoEnabledOK = err == nil && strings.TrimSpace(string(out)) == "1"
+	})
+	return cgoEnabledOK
+}
+
+// materialize writes files, keyed by their slash-separated paths under
+// GOPATH/src, to a new GOPATH along with the wire marker package. It returns
+// the directory of the example.com module and the environment to generate
+// it with.
+func materialize(tb testing.TB, files map[string]string) (wd string, env []string) {
+	tb.Helper()
+	wireGo, err := ioutil.ReadFile(filepath.Join("..", "..", "wire.go"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	test := &testCase{
+		goFiles: map[string][]byte{
+			"github.com/google/wire/wire.go": wireGo,
+		},
+	}
+	for name, content := range files {
+		test.goFiles[name] = []byte(content)
+	}
+	gopath := tb.TempDir()
+	if err := test.materialize(gopath); err != nil {
+		tb.Fatal(err)
+	}
+	return filepath.Join(gopath, "src", "example.com"), append(os.Environ(), "GOPATH="+gopath)
+}
+
+// gopathOf returns the GOPATH that materialize created wd in.
+func gopathOf(wd string) string {
+	return filepath.Dir(filepath.Dir(wd))
+}
+
+// materialize creates a new GOPATH at the given directory, which may or
+// may not exist.
+func (test *testCase) materialize(gopath string) error {
+	for name, content := range test.goFiles {
+		dst := filepath.Join(gopath, "src", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+			return fmt.Errorf("materialize GOPATH: %v", err)
+		}
+		if err := ioutil.WriteFile(dst, content, 0666); err != nil {
+			return fmt.Errorf("materialize GOPATH: %v", err)
+		}
+	}
+
+	// Add go.mod files to example.com and github.com/google/wire.
+	const importPath = "example.com"
+	const depPath = "github.com/google/wire"
+	depLoc := filepath.Join(gopath, "src", filepath.FromSlash(depPath))
+	example := fmt.Sprintf("module %s\n\ngo 1.19\n\nrequire %s v0.1.0\nreplace %s => %s\n", importPath, depPath, depPath, depLoc)
+	gomod := filepath.Join(gopath, "src", filepath.FromSlash(importPath), "go.mod")
+	if err := ioutil.WriteFile(gomod, []byte(example), 0666); err != nil {
+		return fmt.Errorf("generate go.mod for %s: %v", gomod, err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(depLoc, "go.mod"), []byte("module "+depPath+"\n"), 0666); err != nil {
+		return fmt.Errorf("generate go.mod for %s: %v", depPath, err)
+	}
+	return nil
+}
+
+func TestProviderSetCacheKey(t *testing.T) {
+	// Set is declared in two files of the same package, one of which is
+	// only built with the "special" tag.
+	wd, env := materialize(t, map[string]string{
+		"example.com/foo/foo.go": `package foo
+
+type Foo string
+
+func provideDefault() Foo { return "default" }
+
+func provideSpecial() Foo { return "special" }
+`,
+		"example.com/foo/set.go": `//go:build !special
+
+package foo
+
+import "github.com/google/wire"
+
+var Set = wire.NewSet(provideDefault)
+`,
+		"example.com/foo/set_special.go": `//go:build special
+
+package foo
+
+import "github.com/google/wire"
+
+var Set = wire.NewSet(provideSpecial)
+`,
+	})
+
+	type loadedSet struct {
+		key ProviderSetKey
+		set *ProviderSet
+	}
+	loadSet := func(tags string) loadedSet {
+		t.Helper()
+		pkgs, errs := load(context.Background(), wd, env, defaultBuildTag, tags, nil, []string{"example.com/foo"})
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		obj := pkgs[0].Types.Scope().Lookup("Set")
+		item, errs := newObjectCache(pkgs).get(obj)
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		return loadedSet{key: ProviderSetKeyOf(pkgs[0].Fset, obj), set: item.(*ProviderSet)}
+	}
+	def := loadSet("")
+	special := loadSet("special")
+	if def.key.PkgPath != special.key.PkgPath || def.key.VarName != special.key.VarName {
+		t.Fatalf("keys %+v and %+v should share package path and variable name", def.key, special.key)
+	}
+	if def.key == special.key {
+		t.Fatalf("sets declared in different files have the same key %+v", def.key)
+	}
+
+	cache := NewProviderSetCache()
+	cache.CacheSet(def.key, def.set, []string{def.key.Filename})
+	cache.CacheSet(special.key, special.set, []string{special.key.Filename})
+	for _, want := range []struct {
+		loaded   loadedSet
+		provider string
+	}{
+		{def, "provideDefault"},
+		{special, "provideSpecial"},
+	} {
+		lookups := map[string]func(ProviderSetKey, []string) (*ProviderSet, bool){
+			"GetCachedSet":     cache.GetCachedSet,
+			"GetCachedSetFast": cache.GetCachedSetFast,
+		}
+		for name, lookup := range lookups {
+			got, ok := lookup(want.loaded.key, []string{want.loaded.key.Filename})
+			if !ok {
+				t.Errorf("%s(%+v) missed", name, want.loaded.key)
+				continue
+			}
+			if got != want.loaded.set || got.Providers[0].Name != want.provider {
+				t.Errorf("%s(%+v) returned the set providing %s; want %s", name, want.loaded.key, got.Providers[0].Name, want.provider)
+			}
+		}
+	}
+}
+
+func TestProviderSetCacheConcurrent(t *testing.T) {
+	// Each file backs one version of the set, so a lookup that mixed the
+	// set of one CacheSet with the file state of the other would miss.
+	dir := t.TempDir()
+	oldFile, newFile := filepath.Join(dir, "old.go"), filepath.Join(dir, "new.go")
+	for _, f := range []string{oldFile, newFile} {
+		if err := ioutil.WriteFile(f, []byte("package foo\n"), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldSet := &ProviderSet{PkgPath: "example.com/foo", VarName: "Set"}
+	newSet := &ProviderSet{PkgPath: "example.com/foo", VarName: "Set"}
+	key := ProviderSetKey{PkgPath: "example.com/foo", VarName: "Set", Filename: oldFile}
+
+	cache := NewProviderSetCache()
+	cache.CacheSet(key, oldSet, []string{oldFile})
+	stop := make(chan struct{})
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if i%2 == 0 {
+				cache.CacheSet(key, newSet, []string{oldFile, newFile})
+			} else {
+				cache.CacheSet(key, oldSet, []string{oldFile})
+			}
+		}
+	}()
+	var readers sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for i := 0; i < 1000; i++ {
+				got, ok := cache.GetCachedSetFast(key, []string{oldFile})
+				if !ok || (got != oldSet && got != newSet) {
+					t.Errorf("GetCachedSetFast = %p, %t; want the old or new set", got, ok)
+					return
+				}
+				if got, ok := cache.GetCachedSet(key, []string{oldFile, newFile}); ok && got != newSet {
+					t.Errorf("GetCachedSet with both files = %p; want the new set or a miss", got)
+					return
+				}
+			}
+		}()
+	}
+	readers.Wait()
+	close(stop)
+	<-writerDone
+}
+
+func TestProviderSetString(t *testing.T) {
+	wd, env := materialize(t, map[string]string{
+		"example.com/bar/bar.go": `package bar
+
+import "github.com/google/wire"
+
+type Bar int
+
+func NewBar() (Bar, func(), error) { return 1, func() {}, nil }
+
+var Set = wire.NewSet(NewBar)
+`,
+		"example.com/foo/foo.go": `package foo
+
+import (
+	"example.com/bar"
+	"github.com/google/wire"
+)
+
+type Fooer interface{ Foo() }
+
+type Foo struct{ B bar.Bar }
+
+func (*Foo) Foo() {}
+
+type Config struct{ Name string }
+
+func provideConfig(names ...string) *Config { return &Config{} }
+
+var Inner = wire.NewSet(bar.Set, wire.Value(3))
+
+var Set = wire.NewSet(
+	wire.Struct(new(Foo), "*"),
+	wire.Bind(new(Fooer), new(*Foo)),
+	provideConfig,
+	wire.FieldsOf(new(*Config), "Name"),
+	wire.NewSet(Inner),
+)
+`,
+	})
+	pkgs, errs := load(context.Background(), wd, env, defaultBuildTag, "", nil, []string{"example.com/foo"})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	item, errs := newObjectCache(pkgs).get(pkgs[0].Types.Scope().Lookup("Set"))
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	want := `"example.com/foo".Set
+	struct example.com/foo.Foo{B}: example.com/foo.Foo, *example.com/foo.Foo
+	provider example.com/foo.provideConfig(...string) *example.com/foo.Config
+	bind example.com/foo.Fooer to *example.com/foo.Foo
+	field Name of *example.com/foo.Config: string, *string
+	wire.NewSet in "example.com/foo"
+		"example.com/foo".Inner
+			value int
+			"example.com/bar".Set
+				provider example.com/bar.NewBar() (example.com/bar.Bar, func(), error)
+`
+	set := item.(*ProviderSet)
+	if diff := cmp.Diff(want, set.String()); diff != "" {
+		t.Errorf("String() differs (-want +got):\n%s", diff)
+	}
+
+	// Sets that Wire analyzes cannot include themselves, but a set that
+	// does is still rendered.
+	a := &ProviderSet{PkgPath: "example.com/a", VarName: "A"}
+	b := &ProviderSet{PkgPath: "example.com/b", Imports: []*ProviderSet{a}}
+	a.Imports = []*ProviderSet{b}
+	want = `"example.com/a".A
+	wire.NewSet in "example.com/b"
+		"example.com/a".A (shown above)
+`
+	if diff := cmp.Diff(want, a.String()); diff != "" {
+		t.Errorf("String() of a cycle differs (-want +got):\n%s", diff)
+	}
+
+	cache := NewProviderSetCache()
+	barFile := filepath.Join(wd, "bar", "bar.go")
+	fooFile := filepath.Join(wd, "foo", "foo.go")
+	cache.CacheSet(ProviderSetKey{PkgPath: "example.com/foo", VarName: "Set", Filename: fooFile, Offset: 10}, set, []string{fooFile, barFile, filepath.Join(wd, "missing.go")})
+	cache.CacheSet(ProviderSetKey{PkgPath: "example.com/bar", VarName: "Set", Filename: barFile, Offset: 20}, set.Imports[0].Imports[0].Imports[0], []string{barFile})
+	cachedAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range cache.shards {
+		for _, cached := range cache.shards[i].sets {
+			cached.timestamp = cachedAt
+		}
+	}
+	var dumps []string
+	for i := 0; i < 2; i++ {
+		buf := new(bytes.Buffer)
+		if err := cache.dump(buf, cachedAt.Add(90*time.Second+300*time.Millisecond)); err != nil {
+			t.Fatal(err)
+		}
+		dumps = append(dumps, strings.ReplaceAll(buf.String(), wd, "$WORK"))
+	}
+	if dumps[0] != dumps[1] {
+		t.Errorf("Dump is not deterministic:\n%s\nvs.\n%s", dumps[0], dumps[1])
+	}
+	lines := strings.Split(dumps[0], "\n")
+	for i, want := range []string{
+		`"example.com/bar".Set at $WORK/bar/bar.go:#20`,
+		"\tfiles: 1, digest ",
+		"\tproviders: 1",
+		"\tage: 1m30s",
+		`"example.com/foo".Set at $WORK/foo/foo.go:#10`,
+		"\tfiles: 2, digest ",
+		"\tproviders: 3",
+		"\tage: 1m30s",
+	} {
+		if i >= len(lines) || !strings.HasPrefix(lines[i], want) {
+			t.Fatalf("Dump line %d does not start with %q:\n%s", i+1, want, dumps[0])
+		}
+	}
+}
+
+func TestGenerateSymlinkedWorkingDir(t *testing.T) {
+	wireGo, err := ioutil.ReadFile(filepath.Join("..", "..", "wire.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test, err := loadTestCase(filepath.Join("testdata", "ExportedValueDifferentPackage"), wireGo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gopath, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := test.materialize(gopath); err != nil {
+		t.Fatal(err)
+	}
+	wd := filepath.Join(gopath, "src", "example.com")
+	link := filepath.Join(t.TempDir(), "checkout")
+	if err := os.Symlink(wd, link); err != nil {
+		t.Skipf("cannot create symlinks: %v", err)
+	}
+	env := append(os.Environ(), "GOPATH="+gopath)
+
+	generate := func(wd string) GenerateResult {
+		t.Helper()
+		gens, errs := Generate(context.Background(), wd, env, []string{"./foo"}, &GenerateOptions{})
+		if len(errs) > 0 {
+			t.Fatalf("Generate in %s: %v", wd, errs)
+		}
+		if len(gens) != 1 || len(gens[0].Errs) > 0 {
+			t.Fatalf("Generate in %s = %+v; want one result without errors", wd, gens)
+		}
+		return gens[0]
+	}
+	want := generate(wd)
+	got := generate(link)
+	if got.PkgPath != want.PkgPath {
+		t.Errorf("through the symlink, PkgPath = %q; want %q", got.PkgPath, want.PkgPath)
+	}
+	if got.OutputPath != want.OutputPath {
+		t.Errorf("through the symlink, OutputPath = %q; want %q", got.OutputPath, want.OutputPath)
+	}
+	if !bytes.Equal(got.Content, want.Content) {
+		t.Errorf("through the symlink, the generated file differs:\n%s\nwant:\n%s", got.Content, want.Content)
+	}
+}
+
+// TestGenerateReproducible checks that the output does not depend on where
+// the module is checked out, even with annotations, which name the files
+// that providers are declared in.
+func TestGenerateReproducible(t *testing.T) {
+	wireGo, err := ioutil.ReadFile(filepath.Join("..", "..", "wire.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The package is at the root of the module, so that the directory of
+	// its files is the checkout itself.
+	test := &testCase{
+		goFiles: map[string][]byte{
+			"github.com/google/wire/wire.go": wireGo,
+			"example.com/foo.go": []byte(`package main
+
+import "example.com/bar"
+
+type Greeter struct {
+	Pool *bar.Pool
+}
+
+func main() {}
+
+func NewGreeter(p *bar.Pool) *Greeter { return &Greeter{Pool: p} }
+`),
+			"example.com/bar/bar.go": []byte(`package bar
+
+type Pool struct{}
+
+func NewPool() *Pool { return &Pool{} }
+`),
+			"example.com/wire.go": []byte(`//go:build wireinject
+
+package main
+
+import (
+	"example.com/bar"
+	"github.com/google/wire"
+)
+
+func injectGreeter() *Greeter {
+	panic(wire.Build(bar.NewPool, NewGreeter))
+}
+`),
+		},
 	}
-	lineEnd := n
-	// Skip second colon and run of digits.
-	for n++; len(s) > n && '0' <= s[n] && s[n] <= '9'; {
-		n++
+	generate := func(checkout string) GenerateResult {
+		t.Helper()
+		gopath := t.TempDir()
+		if err := test.materialize(gopath); err != nil {
+			t.Fatal(err)
+		}
+		wd := filepath.Join(gopath, "src", checkout)
+		if err := os.Rename(filepath.Join(gopath, "src", "example.com"), wd); err != nil {
+			t.Fatal(err)
+		}
+		env := append(os.Environ(), "GOPATH="+gopath)
+		gens, errs := Generate(context.Background(), wd, env, []string{"."}, &GenerateOptions{AnnotateOutput: true})
+		if len(errs) > 0 {
+			t.Fatalf("Generate in %s: %v", wd, errs)
+		}
+		if len(gens) != 1 || len(gens[0].Errs) > 0 {
+			t.Fatalf("Generate in %s = %+v; want one result without errors", wd, gens)
+		}
+		return gens[0]
+	}
+	want := generate("checkout1")
+	got := generate("checkout2")
+	if !bytes.Equal(got.Content, want.Content) {
+		t.Errorf("the generated file differs between checkouts:\n%s\nwant:\n%s", got.Content, want.Content)
+	}
+	if !bytes.Contains(want.Content, []byte("(NewGreeter, foo.go:")) {
+		t.Errorf("annotations do not name foo.go relative to the module root:\n%s", want.Content)
+	}
+}
+
+func TestGenerateWorkspace(t *testing.T) {
+	// The workspace has three modules: app, whose injector uses a set from
+	// greet, greet, and wire. Neither module is in the module cache, so
+	// both have to come from the workspace.
+	wireGo, err := ioutil.ReadFile(filepath.Join("..", "..", "wire.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := copyDir(dir, filepath.Join("testdata", "_workspace")); err != nil {
+		t.Fatal(err)
+	}
+	wireDir := filepath.Join(dir, "wire")
+	if err := os.MkdirAll(wireDir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(wireDir, "go.mod"), []byte("module github.com/google/wire\n\ngo 1.19\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(wireDir, "wire.go"), wireGo, 0666); err != nil {
+		t.Fatal(err)
+	}
+	wd := filepath.Join(dir, "app")
+	env := append(os.Environ(), "GOWORK="+filepath.Join(dir, "go.work"))
+
+	generate := func(lazy bool, want string) {
+		t.Helper()
+		gens, errs := Generate(context.Background(), wd, env, []string{"."}, &GenerateOptions{LazyLoad: lazy})
+		if len(errs) > 0 {
+			t.Fatalf("Generate(LazyLoad: %t): %v", lazy, errs)
+		}
+		if len(gens) != 1 || len(gens[0].Errs) > 0 {
+			t.Fatalf("Generate(LazyLoad: %t) = %+v; want one result without errors", lazy, gens)
+		}
+		if !bytes.Contains(gens[0].Content, []byte(want)) {
+			t.Errorf("Generate(LazyLoad: %t) does not call %s:\n%s", lazy, want, gens[0].Content)
+		}
+	}
+	loadSet := func() (ProviderSetKey, *ProviderSet, []string) {
+		t.Helper()
+		info, errs := Load(context.Background(), wd, env, "", []string{"."})
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		set := info.Sets[ProviderSetID{ImportPath: "example.com/app", VarName: "Set"}]
+		if set == nil {
+			t.Fatalf("Load did not find example.com/app.Set; sets: %v", info.Sets)
+		}
+		key := ProviderSetKey{PkgPath: set.PkgPath, VarName: set.VarName, Filename: canonicalPath(info.Fset.Position(set.Pos).Filename)}
+		return key, set, ProviderSetFiles(info.Fset, set)
+	}
+
+	for _, lazy := range []bool{false, true} {
+		generate(lazy, "greet.NewGreeting()")
+	}
+	key, set, files := loadSet()
+	greetFile := filepath.Join(dir, "greet", "greet.go")
+	want := []string{filepath.Join(wd, "app.go"), greetFile}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("ProviderSetFiles = %q; want %q", files, want)
+	}
+	cache := NewProviderSetCache()
+	cache.CacheSet(key, set, files)
+	if _, ok := cache.GetCachedSet(key, files); !ok {
+		t.Fatal("GetCachedSet missed before the edit")
+	}
+
+	// Edit the set in the greet module.
+	src, err := ioutil.ReadFile(greetFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src = bytes.ReplaceAll(src, []byte("NewGreeting"), []byte("NewHello"))
+	if err := ioutil.WriteFile(greetFile, src, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.GetCachedSet(key, files); ok {
+		t.Error("GetCachedSet hit after the greet module was edited; want a miss")
+	}
+	for _, lazy := range []bool{false, true} {
+		generate(lazy, "greet.NewHello()")
+	}
+}
+
+// copyDir copies the files in the directory tree src to dst.
+func copyDir(dst, src string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0777)
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, content, 0666)
+	})
+}
+
+func TestProviderSetCacheOverlay(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "foo.go")
+	if err := ioutil.WriteFile(file, []byte("package foo\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	set := &ProviderSet{PkgPath: "example.com/foo", VarName: "Set"}
+	key := ProviderSetKey{PkgPath: "example.com/foo", VarName: "Set", Filename: file}
+	buffer := map[string][]byte{file: []byte("package foo\n\nvar Set = wire.NewSet()\n")}
+
+	cache := NewProviderSetCache()
+	cache.CacheSetWithOverlay(key, set, []string{file}, buffer)
+	if _, ok := cache.GetCachedSetWithOverlay(key, []string{file}, buffer); !ok {
+		t.Error("GetCachedSetWithOverlay with the same overlay missed")
+	}
+	if _, ok := cache.GetCachedSet(key, []string{file}); ok {
+		t.Error("GetCachedSet without the overlay hit; want a miss, since the file on disk differs")
+	}
+	edited := map[string][]byte{file: []byte("package foo\n\nvar Set = wire.NewSet(provideFoo)\n")}
+	if _, ok := cache.GetCachedSetWithOverlay(key, []string{file}, edited); ok {
+		t.Error("GetCachedSetWithOverlay with an edited overlay hit; want a miss")
+	}
+
+	cache.CacheSet(key, set, []string{file})
+	if _, ok := cache.GetCachedSetWithOverlay(key, []string{file}, buffer); ok {
+		t.Error("GetCachedSetWithOverlay of a set cached from disk hit; want a miss, since the overlay differs")
+	}
+}
+
+func TestGenerateOverlay(t *testing.T) {
+	const providers = "package main\n\ntype Foo int\n\nfunc provideFoo() Foo { return 42 }\n\nfunc main() {}\n"
+	wd, env := materialize(t, map[string]string{
+		"example.com/foo/foo.go": providers,
+		"example.com/foo/wire.go": `//go:build wireinject
+
+package main
+
+import "github.com/google/wire"
+
+func injectFoo() (Foo, error) {
+	panic(wire.Build(provideFoo))
+}
+`,
+	})
+	// The unsaved buffer makes the provider fail.
+	fooGo := filepath.Join(wd, "foo", "foo.go")
+	opts := &GenerateOptions{Overlay: map[string][]byte{
+		fooGo: []byte("package main\n\ntype Foo int\n\nfunc provideFoo() (Foo, error) { return 42, nil }\n\nfunc main() {}\n"),
+	}}
+	gens, errs := Generate(context.Background(), wd, env, []string{"./foo"}, opts)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if len(gens) != 1 || len(gens[0].Errs) > 0 {
+		t.Fatalf("Generate = %+v; want one result without errors", gens)
+	}
+	if !bytes.Contains(gens[0].Content, []byte("foo, err := provideFoo()")) {
+		t.Errorf("generated injector does not use the overlaid provider:\n%s", gens[0].Content)
+	}
+
+	if got, err := ioutil.ReadFile(fooGo); err != nil || string(got) != providers {
+		t.Errorf("foo.go on disk = %q, %v; want it untouched", got, err)
+	}
+	if _, err := os.Stat(gens[0].OutputPath); !os.IsNotExist(err) {
+		t.Errorf("Stat(%s) = %v; want Generate not to write it", gens[0].OutputPath, err)
+	}
+}
+
+func TestGenerateNoGoroutineLeaks(t *testing.T) {
+	wireGo, err := ioutil.ReadFile(filepath.Join("..", "..", "wire.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type workspace struct {
+		wd      string
+		env     []string
+		pattern string
+	}
+	// Chain generates; MultipleBindings fails to solve.
+	workspaces := make(map[string]workspace)
+	for _, name := range []string{"Chain", "MultipleBindings"} {
+		test, err := loadTestCase(filepath.Join("testdata", name), wireGo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gopath := t.TempDir()
+		if err := test.materialize(gopath); err != nil {
+			t.Fatal(err)
+		}
+		workspaces[name] = workspace{
+			wd:      filepath.Join(gopath, "src", "example.com"),
+			env:     append(os.Environ(), "GOPATH="+gopath),
+			pattern: test.pkg,
+		}
+	}
+
+	host := Platform{GOOS: runtime.GOOS, GOARCH: runtime.GOARCH}
+	entryPoints := map[string]func(ctx context.Context, ws workspace){
+		"Generate": func(ctx context.Context, ws workspace) {
+			Generate(ctx, ws.wd, ws.env, []string{ws.pattern}, &GenerateOptions{})
+		},
+		"GenerateConcurrent": func(ctx context.Context, ws workspace) {
+			Generate(ctx, ws.wd, ws.env, []string{ws.pattern}, &GenerateOptions{Concurrency: 2})
+		},
+		"GenerateParallel": func(ctx context.Context, ws workspace) {
+			GenerateParallel(ctx, ws.wd, ws.env, []string{ws.pattern}, nil, 2)
+		},
+		"GenerateOptimized": func(ctx context.Context, ws workspace) {
+			GenerateOptimized(ctx, ws.wd, ws.env, []string{ws.pattern}, nil)
+		},
+		"GenerateWithLazyLoad": func(ctx context.Context, ws workspace) {
+			GenerateWithLazyLoad(ctx, ws.wd, ws.env, []string{ws.pattern}, nil)
+		},
+		"GenerateParallelWithLazyLoad": func(ctx context.Context, ws workspace) {
+			GenerateParallelWithLazyLoad(ctx, ws.wd, ws.env, []string{ws.pattern}, nil, 2)
+		},
+		"GenerateForPlatforms": func(ctx context.Context, ws workspace) {
+			GenerateForPlatforms(ctx, ws.wd, ws.env, []string{ws.pattern}, nil, []Platform{host})
+		},
+		"GenerateStream": func(ctx context.Context, ws workspace) {
+			// Take one result and leave the rest unread, as a consumer that
+			// gives up would.
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			results := make(chan *GenerateResult)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				if _, ok := <-results; ok {
+					cancel()
+				}
+				for range results {
+				}
+			}()
+			GenerateStream(ctx, ws.wd, ws.env, []string{ws.pattern}, &GenerateOptions{Concurrency: 2}, results)
+			<-done
+		},
+		"Load": func(ctx context.Context, ws workspace) {
+			Load(ctx, ws.wd, ws.env, "", []string{ws.pattern})
+		},
+	}
+
+	// goroutinesSettled waits for the number of goroutines to drop to
+	// want. Goroutines that have finished their work may take a moment to
+	// exit, so a few retries are allowed.
+	goroutinesSettled := func(want int) (int, bool) {
+		var n int
+		for i := 0; i < 50; i++ {
+			if n = runtime.NumGoroutine(); n <= want {
+				return n, true
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		return n, false
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for name, run := range entryPoints {
+		for wsName, ws := range workspaces {
+			for _, ctx := range []context.Context{context.Background(), canceled} {
+				before := runtime.NumGoroutine()
+				run(ctx, ws)
+				if n, ok := goroutinesSettled(before); !ok {
+					buf := make([]byte, 1<<20)
+					buf = buf[:runtime.Stack(buf, true)]
+					t.Errorf("%s on %s (context error %v) left %d goroutines running; want at most %d:\n%s", name, wsName, ctx.Err(), n, before, buf)
+				}
+			}
+		}
+	}
+}
+
+func TestGenerateDependentPackages(t *testing.T) {
+	// Package a uses the injector of package b, which is also generated, as
+	// a provider. Injector files are loaded without the generated files, so
+	// a sees b's injector template whether or not b's output exists yet.
+	wd, env := materialize(t, map[string]string{
+		"example.com/b/b.go": "package b\n\ntype Foo int\n\nfunc provideFoo() Foo { return 42 }\n",
+		"example.com/b/wire.go": `//go:build wireinject
+
+package b
+
+import "github.com/google/wire"
+
+func InitFoo() Foo {
+	panic(wire.Build(provideFoo))
+}
+`,
+		"example.com/a/a.go": "package main\n\nimport \"example.com/b\"\n\ntype App struct {\n\tFoo b.Foo\n}\n\nfunc main() {}\n",
+		"example.com/a/wire.go": `//go:build wireinject
+
+package main
+
+import (
+	"example.com/b"
+	"github.com/google/wire"
+)
+
+func initApp() *App {
+	panic(wire.Build(b.InitFoo, wire.Struct(new(App), "*")))
+}
+`,
+	})
+	patterns := []string{"./a", "./b"}
+
+	want, errs := Generate(context.Background(), wd, env, patterns, &GenerateOptions{VerifyOutput: true})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	for _, gen := range want {
+		if len(gen.Errs) > 0 {
+			t.Fatalf("%s: %v", gen.PkgPath, gen.Errs)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		got, errs := Generate(context.Background(), wd, env, patterns, &GenerateOptions{VerifyOutput: true, Concurrency: 2})
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		for j := range want {
+			if len(got[j].Errs) > 0 || !bytes.Equal(got[j].Content, want[j].Content) {
+				t.Fatalf("concurrent run %d: %s = %q, %v; want %q", i, got[j].PkgPath, got[j].Content, got[j].Errs, want[j].Content)
+			}
+		}
+		// Later runs see the output of earlier ones on disk, as when a
+		// previous generation is already committed.
+		for _, gen := range got {
+			if err := gen.Commit(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestGenerateProgress(t *testing.T) {
+	injectors := func(names ...string) string {
+		src := "//go:build wireinject\n\npackage main\n\nimport \"github.com/google/wire\"\n"
+		for _, name := range names {
+			src += fmt.Sprintf("\nfunc %s() Foo {\n\tpanic(wire.Build(provideFoo))\n}\n", name)
+		}
+		return src
+	}
+	const providers = "package main\n\ntype Foo int\n\nfunc provideFoo() Foo { return 42 }\n\nfunc main() {}\n"
+	wd, env := materialize(t, map[string]string{
+		"example.com/foo/foo.go":  providers,
+		"example.com/foo/wire.go": injectors("injectFoo", "injectOtherFoo"),
+		"example.com/bar/bar.go":  providers,
+		"example.com/bar/wire.go": injectors("injectBar"),
+	})
+
+	var (
+		events []ProgressEvent
+		calls  int32
+	)
+	opts := &GenerateOptions{
+		Concurrency: 2,
+		Progress: func(e ProgressEvent) {
+			if atomic.AddInt32(&calls, 1) != 1 {
+				t.Error("Progress called concurrently")
+			}
+			events = append(events, e)
+			atomic.AddInt32(&calls, -1)
+		},
+	}
+	gens, errs := Generate(context.Background(), wd, env, []string{"./foo", "./bar"}, opts)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if len(gens) != 2 {
+		t.Fatalf("Generate returned %d results; want 2", len(gens))
+	}
+
+	if len(events) != 7 {
+		t.Fatalf("got %d events; want 7: %+v", len(events), events)
+	}
+	if e := events[0]; e != (ProgressEvent{Phase: ProgressLoading}) {
+		t.Errorf("first event = %+v; want loading with no counts", e)
+	}
+	if e := events[1]; e != (ProgressEvent{Phase: ProgressLoaded, TotalPackages: 2, TotalInjectors: 3}) {
+		t.Errorf("second event = %+v; want loaded with 2 packages and 3 injectors", e)
+	}
+	injectorsOf := map[string][]string{}
+	generated := map[string]bool{}
+	prev := events[1]
+	for _, e := range events[2:] {
+		if e.TotalPackages != 2 || e.TotalInjectors != 3 {
+			t.Errorf("event %+v does not have the totals of the loaded event", e)
+		}
+		switch e.Phase {
+		case ProgressInjector:
+			if e.Injectors != prev.Injectors+1 || e.Packages != prev.Packages {
+				t.Errorf("injector event %+v after %+v; want one more injector", e, prev)
+			}
+			if generated[e.PkgPath] {
+				t.Errorf("injector event %+v after its package was generated", e)
+			}
+			injectorsOf[e.PkgPath] = append(injectorsOf[e.PkgPath], e.Injector)
+		case ProgressPackage:
+			if e.Packages != prev.Packages+1 || e.Injectors != prev.Injectors {
+				t.Errorf("package event %+v after %+v; want one more package", e, prev)
+			}
+			generated[e.PkgPath] = true
+		default:
+			t.Errorf("unexpected event %+v", e)
+		}
+		prev = e
+	}
+	if prev.Packages != 2 || prev.Injectors != 3 {
+		t.Errorf("last event = %+v; want all packages and injectors done", prev)
+	}
+	if !generated["example.com/foo"] || !generated["example.com/bar"] {
+		t.Errorf("generated packages = %v; want both", generated)
+	}
+	want := map[string][]string{
+		"example.com/foo": {"injectFoo", "injectOtherFoo"},
+		"example.com/bar": {"injectBar"},
+	}
+	if !reflect.DeepEqual(injectorsOf, want) {
+		t.Errorf("solved injectors = %q; want %q", injectorsOf, want)
+	}
+}
+
+func TestGenerateFormat(t *testing.T) {
+	const injectors = "//go:build wireinject\n\npackage main\n\nimport \"github.com/google/wire\"\n\nfunc injectFoo() Foo {\n\tpanic(wire.Build(provideFoo))\n}\n"
+	const providers = "package main\n\ntype Foo int\n\nfunc provideFoo() Foo { return 42 }\n\nfunc main() {}\n"
+	wd, env := materialize(t, map[string]string{
+		"example.com/foo/foo.go":  providers,
+		"example.com/foo/wire.go": injectors,
+		"example.com/bar/bar.go":  providers,
+		"example.com/bar/wire.go": injectors,
+	})
+
+	const trailer = "\n// Post-processed.\n"
+	opts := &GenerateOptions{
+		Format: func(filename string, src []byte) ([]byte, error) {
+			if filepath.Base(filepath.Dir(filename)) == "bar" {
+				return nil, errors.New("cannot format")
+			}
+			if bytes.Contains(src, []byte("\t\t")) || !bytes.Contains(src, []byte("func injectFoo() Foo {\n\tfoo := provideFoo()")) {
+				t.Errorf("Format got source that was not gofmt'd:\n%s", src)
+			}
+			return append(src, trailer...), nil
+		},
+	}
+	gens, errs := Generate(context.Background(), wd, env, []string{"./foo", "./bar"}, opts)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if len(gens) != 2 {
+		t.Fatalf("got %d results; want 2", len(gens))
+	}
+	foo, bar := gens[0], gens[1]
+	if len(foo.Errs) > 0 {
+		t.Fatalf("foo: %v", foo.Errs)
+	}
+	if !bytes.HasSuffix(foo.Content, []byte(trailer)) {
+		t.Errorf("foo content does not end with the output of Format:\n%s", foo.Content)
+	}
+	if editedByHand(foo.Content) {
+		t.Error("foo content does not match its checksum")
+	}
+	if err := foo.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if len(bar.Errs) != 1 {
+		t.Fatalf("bar errors = %v; want one", bar.Errs)
+	}
+	if want := bar.OutputPath + ": cannot format"; bar.Errs[0].Error() != want {
+		t.Errorf("bar error = %q; want %q", bar.Errs[0], want)
+	}
+	if len(bar.Content) > 0 {
+		t.Error("bar has content despite the Format error")
+	}
+}
+
+func TestLoadInterrupted(t *testing.T) {
+	wd, env := materialize(t, map[string]string{
+		"example.com/foo/foo.go": `package main
+
+func main() {}
+
+func provideMessage() string { return "hello" }
+`,
+		"example.com/foo/wire.go": `//go:build wireinject
+
+package main
+
+import "github.com/google/wire"
+
+func injectMessage() string {
+	panic(wire.Build(provideMessage))
+}
+`,
+		"example.com/bar/bar.go": `package bar
+`,
+	})
+
+	checkInterrupted := func(t *testing.T, err error, want error) {
+		t.Helper()
+		var interrupted *LoadInterruptedError
+		if !errors.As(err, &interrupted) {
+			t.Fatalf("got error %v; want a *LoadInterruptedError", err)
+		}
+		if !errors.Is(err, want) {
+			t.Errorf("got error %v; want it to wrap %v", err, want)
+		}
+	}
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, errs := Generate(ctx, wd, env, []string{"example.com/foo"}, nil)
+		if len(errs) != 1 {
+			t.Fatalf("got errors %v; want 1 error", errs)
+		}
+		checkInterrupted(t, errs[0], context.Canceled)
+	})
+	t.Run("DeadlineExceeded", func(t *testing.T) {
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+		_, errs := Generate(ctx, wd, env, []string{"example.com/foo"}, nil)
+		if len(errs) != 1 {
+			t.Fatalf("got errors %v; want 1 error", errs)
+		}
+		checkInterrupted(t, errs[0], context.DeadlineExceeded)
+	})
+	t.Run("LazyLoad", func(t *testing.T) {
+		pkgs, errs := load(context.Background(), wd, env, defaultBuildTag, "", nil, []string{"example.com/foo"})
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		oc := newObjectCacheWithLazyLoad(pkgs, newImportCache(ctx, wd, env, defaultBuildTag, "", nil))
+		_, err := oc.getPackage("example.com/bar")
+		checkInterrupted(t, err, context.Canceled)
+	})
+}
+
+func TestGenerateInvalidIdentifierPrefix(t *testing.T) {
+	for _, prefix := range []string{"1gen", "gen-", "gen.", "gen prefix"} {
+		// The options are checked before anything is loaded.
+		_, errs := Generate(context.Background(), t.TempDir(), nil, []string{"example.com/missing"}, &GenerateOptions{IdentifierPrefix: prefix})
+		if len(errs) != 1 || !strings.Contains(errs[0].Error(), "identifier prefix") {
+			t.Errorf("IdentifierPrefix %q: errors = %v; want an identifier prefix error", prefix, errs)
+		}
+	}
+	for _, prefix := range []string{"gen", "_gen", "Gen2_", "genè"} {
+		if !isIdentifierPrefix(prefix) {
+			t.Errorf("isIdentifierPrefix(%q) = false; want true", prefix)
+		}
+	}
+}
+
+func TestGenerateBuildTag(t *testing.T) {
+	wireGo, err := ioutil.ReadFile(filepath.Join("..", "..", "wire.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Chain, with its injector file constrained to di_spec instead.
+	test, err := loadTestCase(filepath.Join("testdata", "Chain"), wireGo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range test.goFiles {
+		test.goFiles[name] = bytes.ReplaceAll(src, []byte("wireinject"), []byte("di_spec"))
+	}
+	want, err := ioutil.ReadFile(filepath.Join("testdata", "Chain", "want", "wire_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = bytes.ReplaceAll(stripGeneratedHeader(want), []byte("wireinject"), []byte("di_spec"))
+	gopath := t.TempDir()
+	if err := test.materialize(gopath); err != nil {
+		t.Fatal(err)
+	}
+	wd := filepath.Join(gopath, "src", "example.com")
+	env := append(os.Environ(), "GOPATH="+gopath)
+	opts := &GenerateOptions{BuildTag: "di_spec", VerifyOutput: true}
+
+	generators := map[string]func() ([]GenerateResult, []error){
+		"Generate": func() ([]GenerateResult, []error) {
+			return Generate(context.Background(), wd, env, []string{test.pkg}, opts)
+		},
+		"GenerateParallel": func() ([]GenerateResult, []error) {
+			return GenerateParallel(context.Background(), wd, env, []string{test.pkg}, opts, 0)
+		},
+		"GenerateOptimized": func() ([]GenerateResult, []error) {
+			return GenerateOptimized(context.Background(), wd, env, []string{test.pkg}, opts)
+		},
+		"GenerateWithLazyLoad": func() ([]GenerateResult, []error) {
+			return GenerateWithLazyLoad(context.Background(), wd, env, []string{test.pkg}, opts)
+		},
+		"GenerateParallelWithLazyLoad": func() ([]GenerateResult, []error) {
+			return GenerateParallelWithLazyLoad(context.Background(), wd, env, []string{test.pkg}, opts, 0)
+		},
+	}
+	for name, generate := range generators {
+		t.Run(name, func(t *testing.T) {
+			gens, errs := generate()
+			if len(errs) > 0 {
+				t.Fatal(errs)
+			}
+			if len(gens) != 1 || len(gens[0].Errs) > 0 {
+				t.Fatalf("got %d results; first has errors %v", len(gens), gens[0].Errs)
+			}
+			if diff := cmp.Diff(string(want), string(stripGeneratedHeader(gens[0].Content))); diff != "" {
+				t.Errorf("output differs from Chain with the tag replaced (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("Load", func(t *testing.T) {
+		info, errs := LoadWithBuildTag(context.Background(), wd, env, "di_spec", "", []string{test.pkg})
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		if len(info.Injectors) != 1 {
+			t.Errorf("found %d injectors; want 1", len(info.Injectors))
+		}
+	})
+
+	t.Run("Mixed", func(t *testing.T) {
+		// A second injector file that still uses the default tag.
+		other := filepath.Join(gopath, "src", "example.com", "foo", "other_wire.go")
+		src := "//go:build wireinject\n\npackage main\n"
+		if err := ioutil.WriteFile(other, []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(other)
+		gens, errs := Generate(context.Background(), wd, env, []string{test.pkg}, opts)
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		if len(gens[0].Errs) != 1 || !strings.Contains(gens[0].Errs[0].Error(), "other_wire.go:3:1: file is constrained to the wireinject build tag") {
+			t.Errorf("errors = %v; want one for other_wire.go", gens[0].Errs)
+		}
+	})
+}
+
+func TestGenerateInvalidBuildTag(t *testing.T) {
+	for _, tag := range []string{"di spec", "!di_spec", "di,spec"} {
+		_, errs := Generate(context.Background(), t.TempDir(), nil, []string{"example.com/missing"}, &GenerateOptions{BuildTag: tag})
+		if len(errs) != 1 || !strings.Contains(errs[0].Error(), "build tag") {
+			t.Errorf("BuildTag %q: errors = %v; want a build tag error", tag, errs)
+		}
 	}
-	if n == lineEnd+1 {
-		// No digits followed second colon.
-		return ":x", lineEnd
+}
+
+func TestGenerateInvalidWrapErrors(t *testing.T) {
+	for _, tmpl := range []string{"initializing {{.Type", "initializing {{.Kind}}"} {
+		_, errs := Generate(context.Background(), t.TempDir(), nil, []string{"example.com/missing"}, &GenerateOptions{WrapErrors: tmpl})
+		if len(errs) != 1 || !strings.Contains(errs[0].Error(), "invalid WrapErrors template") {
+			t.Errorf("WrapErrors %q: errors = %v; want a template error", tmpl, errs)
+		}
 	}
-	return ":x:y", n
 }
 
-type testCase struct {
-	name                 string
-	pkg                  string
-	header               []byte
-	goFiles              map[string][]byte
-	wantProgramOutput    []byte
-	wantWireOutput       []byte
-	wantWireError        bool
-	wantWireErrorStrings []string
+func TestValidateOptions(t *testing.T) {
+	var nilOpts *GenerateOptions
+	if err := nilOpts.Validate(); err != nil {
+		t.Errorf("nil options: Validate() = %v; want nil", err)
+	}
+	if err := new(GenerateOptions).Validate(); err != nil {
+		t.Errorf("zero options: Validate() = %v; want nil", err)
+	}
+	tests := []struct {
+		opts GenerateOptions
+		want []string
+	}{
+		{opts: GenerateOptions{PrefixOutputFile: "gen_", Tags: "integration,linux", GoCache: t.TempDir(), RequireVersion: ">=v0.6.0"}},
+		{opts: GenerateOptions{PrefixOutputFile: "gen/"}, want: []string{"GenerateOptions.PrefixOutputFile: "}},
+		{opts: GenerateOptions{Tags: "integration -race"}, want: []string{`GenerateOptions.Tags: "-race" is not a valid build tag`}},
+		{opts: GenerateOptions{GoCache: "cache"}, want: []string{`GenerateOptions.GoCache: "cache" is not an absolute path`}},
+		{opts: GenerateOptions{Overlay: map[string][]byte{"foo.go": nil}}, want: []string{`GenerateOptions.Overlay: "foo.go" is not an absolute path`}},
+		{opts: GenerateOptions{RequireVersion: "=>v0.6.0"}, want: []string{`GenerateOptions.RequireVersion: invalid version constraint "=>v0.6.0": unknown operator "=>"`}},
+		{
+			opts: GenerateOptions{IdentifierPrefix: "1x", BuildTag: "di spec", WrapErrors: "{{.Kind}}"},
+			want: []string{"GenerateOptions.IdentifierPrefix: ", "GenerateOptions.BuildTag: ", "GenerateOptions.WrapErrors: "},
+		},
+	}
+	for _, test := range tests {
+		errs := test.opts.validate()
+		if len(errs) != len(test.want) {
+			t.Errorf("%+v: problems = %v; want %d", test.opts, errs, len(test.want))
+			continue
+		}
+		for i, err := range errs {
+			if !strings.HasPrefix(err.Error(), test.want[i]) {
+				t.Errorf("%+v: problem %d = %q; want it to start with %q", test.opts, i, err, test.want[i])
+			}
+		}
+		if err := test.opts.Validate(); (err != nil) != (len(errs) > 0) || err != nil && strings.Count(err.Error(), "\n") != len(errs)-1 {
+			t.Errorf("%+v: Validate() = %v; want the problems one per line", test.opts, err)
+		}
+		if len(errs) == 0 {
+			continue
+		}
+		// Every entry point reports all of the problems before loading.
+		_, genErrs := Generate(context.Background(), t.TempDir(), nil, []string{"example.com/missing"}, &test.opts)
+		if len(genErrs) != len(errs) {
+			t.Errorf("%+v: Generate errors = %v; want %v", test.opts, genErrs, errs)
+		}
+	}
 }
 
-// loadTestCase reads a test case from a directory.
-//
-// The directory structure is:
-//
-//	root/
-//
-//		pkg
-//			file containing the package name containing the inject function
-//			(must also be package main)
-//
-//		...
-//			any Go files found recursively placed under GOPATH/src/...
-//
-//		want/
-//
-//			wire_errs.txt
-//					Expected errors from the Wire Generate function,
-//					missing if no errors expected.
-//					Distinct errors are separated by a blank line,
-//					and line numbers and line positions are scrubbed
-//					(e.g. "$GOPATH/src/foo.go:52:8" --> "foo.go:x:y").
-//
-//			wire_gen.go
-//					verified output of wire from a test run with
-//					-record, missing if wire_errs.txt is present
-//
-//			program_out.txt
-//					expected output from the final compiled program,
-//					missing if wire_errs.txt is present
-func loadTestCase(root string, wireGoSrc []byte) (*testCase, error) {
-	name := filepath.Base(root)
-	pkg, err := ioutil.ReadFile(filepath.Join(root, "pkg"))
+// TestGenerateZeroOptions checks that the zero value of GenerateOptions, and
+// a nil *GenerateOptions, keep generating the recorded output of test cases
+// that set no options.
+func TestGenerateZeroOptions(t *testing.T) {
+	wireGo, err := ioutil.ReadFile(filepath.Join("..", "..", "wire.go"))
 	if err != nil {
-		return nil, fmt.Errorf("load test case %s: %v", name, err)
+		t.Fatal(err)
 	}
-	header, _ := ioutil.ReadFile(filepath.Join(root, "header"))
-	var wantProgramOutput []byte
-	var wantWireOutput []byte
-	wireErrb, err := ioutil.ReadFile(filepath.Join(root, "want", "wire_errs.txt"))
-	wantWireError := err == nil
-	var wantWireErrorStrings []string
-	if wantWireError {
-		for _, errs := range strings.Split(string(wireErrb), "\n\n") {
-			// Allow for trailing newlines, which can be hard to remove in some editors.
-			wantWireErrorStrings = append(wantWireErrorStrings, strings.TrimRight(errs, "\n\r"))
+	for _, name := range []string{"Chain", "Cleanup", "InterfaceBinding", "Struct"} {
+		test, err := loadTestCase(filepath.Join("testdata", name), wireGo)
+		if err != nil {
+			t.Fatal(err)
 		}
-	} else {
-		if !*record {
-			wantWireOutput, err = ioutil.ReadFile(filepath.Join(root, "want", "wire_gen.go"))
-			if err != nil {
-				return nil, fmt.Errorf("load test case %s: %v, if this is a new testcase, run with -record to generate the wire_gen.go file", name, err)
+		gopath := t.TempDir()
+		if err := test.materialize(gopath); err != nil {
+			t.Fatal(err)
+		}
+		wd := filepath.Join(gopath, "src", "example.com")
+		env := append(os.Environ(), "GOPATH="+gopath)
+		for _, opts := range []*GenerateOptions{{}, nil} {
+			gens, errs := Generate(context.Background(), wd, env, []string{test.pkg}, opts)
+			if len(errs) > 0 {
+				t.Fatalf("%s: %v", name, errs)
+			}
+			if len(gens) != 1 || len(gens[0].Errs) > 0 {
+				t.Fatalf("%s: results = %+v; want one without errors", name, gens)
+			}
+			if diff := cmp.Diff(string(test.wantWireOutput), string(gens[0].Content)); diff != "" {
+				t.Errorf("%s with options %v differs from want/wire_gen.go (-want +got):\n%s", name, opts, diff)
 			}
 		}
-		wantProgramOutput, err = ioutil.ReadFile(filepath.Join(root, "want", "program_out.txt"))
+	}
+}
+
+// TestGenerateAliasGODEBUG checks that the generated code does not depend on
+// whether the type checker materializes type aliases, which Go 1.22 to 1.26
+// let GODEBUG=gotypesalias choose. Where the code is named after an alias,
+// it must still compile when the type checker does not know the alias.
+// Other toolchains support only one of the settings, and skip the test.
+func TestGenerateAliasGODEBUG(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	materialized := func() bool {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "alias.go", "package p\n\ntype A = int\n\nvar V A\n", 0)
 		if err != nil {
-			return nil, fmt.Errorf("load test case %s: %v", name, err)
+			t.Fatal(err)
+		}
+		pkg, err := new(types.Config).Check("p", fset, []*ast.File{f}, nil)
+		if err != nil {
+			t.Fatal(err)
 		}
+		return aliasObj(pkg.Scope().Lookup("V").Type()) != nil
 	}
-	goFiles := map[string][]byte{
-		"github.com/google/wire/wire.go": wireGoSrc,
+	t.Setenv("GODEBUG", "gotypesalias=1")
+	on := materialized()
+	t.Setenv("GODEBUG", "gotypesalias=0")
+	if off := materialized(); !on || off {
+		t.Skipf("%s does not support both gotypesalias settings", runtime.Version())
 	}
-	err = filepath.Walk(root, func(src string, info os.FileInfo, err error) error {
+
+	wireGo, err := ioutil.ReadFile(filepath.Join("..", "..", "wire.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		// sameOutput is whether the output with gotypesalias=0 is the
+		// recorded one. Variables named after an alias are otherwise named
+		// after the type that it denotes.
+		sameOutput bool
+	}{
+		{"GenericInjector", true},
+		{"GenericProviders", true},
+		{"Struct", true},
+		{"UnnamedTypeOtherPackage", true},
+		{"StructAliasInstance", false},
+		{"TypeAliases", false},
+	}
+	for _, test := range tests {
+		tc, err := loadTestCase(filepath.Join("testdata", test.name), wireGo)
 		if err != nil {
-			return err
+			t.Fatal(err)
 		}
-		rel, err := filepath.Rel(root, src)
-		if err != nil {
-			return err // unlikely
+		gopath := t.TempDir()
+		if err := tc.materialize(gopath); err != nil {
+			t.Fatal(err)
 		}
-		if info.Mode().IsDir() && rel == "want" {
-			// The "want" directory should not be included in goFiles.
-			return filepath.SkipDir
+		wd := filepath.Join(gopath, "src", "example.com")
+		env := append(os.Environ(), "GOPATH="+gopath)
+		gens, errs := Generate(context.Background(), wd, env, []string{tc.pkg}, &GenerateOptions{VerifyOutput: true})
+		var content []byte
+		for _, gen := range gens {
+			content = gen.Content
+			errs = append(errs, gen.Errs...)
 		}
-		if !info.Mode().IsRegular() || filepath.Ext(src) != ".go" {
-			return nil
+		if tc.wantWireError {
+			got := make([]string, len(errs))
+			for i, e := range errs {
+				got[i] = scrubError(gopath, e.Error())
+			}
+			if diff := cmp.Diff(tc.wantWireErrorStrings, got); diff != "" {
+				t.Errorf("%s with gotypesalias=0 reports different errors (-want +got):\n%s", test.name, diff)
+			}
+			continue
 		}
-		data, err := ioutil.ReadFile(src)
-		if err != nil {
-			return err
+		if len(errs) > 0 {
+			t.Errorf("%s with gotypesalias=0: %v", test.name, errs)
+			continue
 		}
-		goFiles["example.com/"+filepath.ToSlash(rel)] = data
-		return nil
+		if diff := cmp.Diff(string(tc.wantWireOutput), string(content)); test.sameOutput && diff != "" {
+			t.Errorf("%s with gotypesalias=0 differs from want/wire_gen.go (-want +got):\n%s", test.name, diff)
+		}
+	}
+}
+
+func TestGeneratePackageOptions(t *testing.T) {
+	injectorFile := func(pkg, directives string) string {
+		return `//go:build wireinject
+
+` + directives + `
+
+package ` + pkg + `
+
+import "github.com/google/wire"
+
+func injectFoo() Foo {
+	panic(wire.Build(provideFoo))
+}
+`
+	}
+	providerFile := func(pkg string) string {
+		return "package " + pkg + "\n\ntype Foo int\n\nfunc provideFoo() Foo { return 42 }\n"
+	}
+	const header = "// Package foo is generated.\n\n"
+	wd, env := materialize(t, map[string]string{
+		"example.com/header.txt":  header,
+		"example.com/foo/foo.go":  providerFile("foo"),
+		"example.com/foo/wire.go": injectorFile("foo", "//wire:options output=di_gen.go header_file=../header.txt"),
+		"example.com/bar/bar.go":  providerFile("bar"),
+		"example.com/bar/wire.go": injectorFile("bar", ""),
+		"example.com/dup/dup.go":  providerFile("dup"),
+		"example.com/dup/wire.go": injectorFile("dup", "//wire:options annotate=true\n//wire:options annotate=false"),
 	})
-	if err != nil {
-		return nil, fmt.Errorf("load test case %s: %v", name, err)
+	opts := &GenerateOptions{PrefixOutputFile: "gen_"}
+	gens, errs := Generate(context.Background(), wd, env, []string{"./foo", "./bar", "./dup"}, opts)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if opts.outputFile != "" || opts.Header != nil {
+		t.Errorf("Generate changed the caller's options to %+v", opts)
+	}
+
+	foo, bar, dup := gens[0], gens[1], gens[2]
+	if len(foo.Errs) > 0 || len(bar.Errs) > 0 {
+		t.Fatal(foo.Errs, bar.Errs)
+	}
+	if got := filepath.Base(foo.OutputPath); got != "gen_di_gen.go" {
+		t.Errorf("foo output file = %s; want gen_di_gen.go", got)
+	}
+	if !bytes.HasPrefix(foo.Content, []byte(header)) {
+		t.Errorf("foo output does not start with the header file:\n%s", foo.Content)
+	}
+	merged := &GenerateOptions{PrefixOutputFile: "gen_", Header: []byte(header), outputFile: "di_gen.go"}
+	if info, _ := GeneratedFileInfo(foo.Content); info.OptionsFingerprint != merged.OptionsFingerprint() {
+		t.Errorf("foo fingerprint = %s; want that of the merged options, %s", info.OptionsFingerprint, merged.OptionsFingerprint())
+	}
+	if foo.OptionsFingerprint != merged.OptionsFingerprint() {
+		t.Errorf("foo result fingerprint = %s; want that of the merged options, %s", foo.OptionsFingerprint, merged.OptionsFingerprint())
+	}
+	if got := filepath.Base(bar.OutputPath); got != "gen_wire_gen.go" {
+		t.Errorf("bar output file = %s; want gen_wire_gen.go", got)
+	}
+	if info, _ := GeneratedFileInfo(bar.Content); info.OptionsFingerprint != opts.OptionsFingerprint() {
+		t.Errorf("bar fingerprint = %s; want that of the global options, %s", info.OptionsFingerprint, opts.OptionsFingerprint())
+	}
+	if len(dup.Errs) != 1 || !strings.Contains(dup.Errs[0].Error(), "dup/wire.go:4:1: //wire:options: annotate is set more than once") {
+		t.Errorf("dup errors = %v; want one for the second annotate", dup.Errs)
 	}
-	return &testCase{
-		name:                 name,
-		pkg:                  string(bytes.TrimSpace(pkg)),
-		header:               header,
-		goFiles:              goFiles,
-		wantWireOutput:       wantWireOutput,
-		wantProgramOutput:    wantProgramOutput,
-		wantWireError:        wantWireError,
-		wantWireErrorStrings: wantWireErrorStrings,
-	}, nil
 }
 
-// materialize creates a new GOPATH at the given directory, which may or
-// may not exist.
-func (test *testCase) materialize(gopath string) error {
-	for name, content := range test.goFiles {
-		dst := filepath.Join(gopath, "src", filepath.FromSlash(name))
-		if err := os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
-			return fmt.Errorf("materialize GOPATH: %v", err)
+func TestSplitPackageOptions(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    [][2]string
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{in: "output=di_gen.go  annotate=true", want: [][2]string{{"output", "di_gen.go"}, {"annotate", "true"}}},
+		{in: `wrap_errors="initializing {{.Type}}" output=a.go`, want: [][2]string{{"wrap_errors", "initializing {{.Type}}"}, {"output", "a.go"}}},
+		{in: "identifier_prefix=", want: [][2]string{{"identifier_prefix", ""}}},
+		{in: "annotate", wantErr: true},
+		{in: "=true", wantErr: true},
+		{in: `wrap_errors="unterminated`, wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := splitPackageOptions(test.in)
+		if (err != nil) != test.wantErr {
+			t.Errorf("splitPackageOptions(%q) error = %v; want error %t", test.in, err, test.wantErr)
+			continue
 		}
-		if err := ioutil.WriteFile(dst, content, 0666); err != nil {
-			return fmt.Errorf("materialize GOPATH: %v", err)
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("splitPackageOptions(%q) (-want +got):\n%s", test.in, diff)
 		}
 	}
+}
 
-	// Add go.mod files to example.com and github.com/google/wire.
-	const importPath = "example.com"
-	const depPath = "github.com/google/wire"
-	depLoc := filepath.Join(gopath, "src", filepath.FromSlash(depPath))
-	example := fmt.Sprintf("module %s\n\nrequire %s v0.1.0\nreplace %s => %s\n", importPath, depPath, depPath, depLoc)
-	gomod := filepath.Join(gopath, "src", filepath.FromSlash(importPath), "go.mod")
-	if err := ioutil.WriteFile(gomod, []byte(example), 0666); err != nil {
-		return fmt.Errorf("generate go.mod for %s: %v", gomod, err)
+func TestGenerateMulti(t *testing.T) {
+	injector := func(pkg string) string {
+		return `//go:build wireinject
+
+package ` + pkg + `
+
+import (
+	"example.com/shared"
+	"github.com/google/wire"
+)
+
+func injectFoo() shared.Foo {
+	panic(wire.Build(shared.Set))
+}
+`
 	}
-	if err := ioutil.WriteFile(filepath.Join(depLoc, "go.mod"), []byte("module "+depPath+"\n"), 0666); err != nil {
-		return fmt.Errorf("generate go.mod for %s: %v", depPath, err)
+	root, env := materialize(t, map[string]string{
+		"example.com/shared/shared.go": `package shared
+
+import "github.com/google/wire"
+
+type Foo int
+
+func provideFoo() Foo { return 42 }
+
+var Set = wire.NewSet(provideFoo)
+`,
+		"example.com/a/wire.go":       injector("a"),
+		"example.com/other/b/wire.go": injector("b"),
+	})
+	gopath := gopathOf(root)
+	// example.com/other is a second module, which uses the first.
+	other := filepath.Join(root, "other")
+	wireDir := filepath.Join(gopath, "src", "github.com", "google", "wire")
+	gomod := "module example.com/other\n\ngo 1.19\n\nrequire (\n\texample.com v0.1.0\n\tgithub.com/google/wire v0.1.0\n)\n\nreplace example.com => ../\n\nreplace github.com/google/wire => " + wireDir + "\n"
+	if err := ioutil.WriteFile(filepath.Join(other, "go.mod"), []byte(gomod), 0666); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	missing := filepath.Join(gopath, "missing")
+	dirs := []string{root, missing, other}
+	roots, errs := GenerateMulti(ctx, dirs, env, []string{"./..."}, &GenerateOptions{Concurrency: 2})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if len(roots) != len(dirs) {
+		t.Fatalf("GenerateMulti returned %d roots; want %d", len(roots), len(dirs))
+	}
+	if roots[1].Dir != missing || len(roots[1].Errs) == 0 || roots[1].Results != nil {
+		t.Errorf("GenerateMulti for a missing directory = %+v; want errors and no results", roots[1])
+	}
+	for _, i := range []int{0, 2} {
+		want, errs := Generate(ctx, dirs[i], env, []string{"./..."}, &GenerateOptions{})
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		got := roots[i]
+		if got.Dir != dirs[i] || len(got.Errs) > 0 {
+			t.Errorf("root %d = %+v; want %s without errors", i, got, dirs[i])
+			continue
+		}
+		if diff := cmp.Diff(generateOutcome(gopath, want, nil), generateOutcome(gopath, got.Results, nil)); diff != "" {
+			t.Errorf("GenerateMulti for %s differs from Generate (-want +got):\n%s", dirs[i], diff)
+		}
+	}
+	if sum := Summarize(roots[2].Results); !reflect.DeepEqual(sum.WithInjectors, []string{"example.com/other/b"}) {
+		t.Errorf("WithInjectors for %s = %q; want example.com/other/b", other, sum.WithInjectors)
+	}
+}
+
+func TestGenerateIgnore(t *testing.T) {
+	// Each ignored package has an injector that fails, so scanning it
+	// would be an error.
+	broken := func(directive, pkg string) string {
+		return directive + `package ` + pkg + `
+
+import "github.com/google/wire"
+
+type Bar int
+
+func injectBar() Bar {
+	panic(wire.Build())
+}
+`
+	}
+	wd, env := materialize(t, map[string]string{
+		"example.com/foo/foo.go": `package foo
+
+import "example.com/mocks"
+
+type Foo int
+
+func provideFoo() Foo { return Foo(mocks.Answer) }
+`,
+		"example.com/foo/wire.go": `//go:build wireinject
+
+package foo
+
+import "github.com/google/wire"
+
+func injectFoo() Foo {
+	panic(wire.Build(provideFoo))
+}
+`,
+		"example.com/mocks/answer.go": "package mocks\n\nconst Answer = 42\n",
+		"example.com/mocks/mocks.go":  broken("// Code generated by mockgen. DO NOT EDIT.\n\n//wire:ignore generated mocks\n\n", "mocks"),
+		"example.com/gen/a/a.go":      broken("", "a"),
+		"example.com/gen/a/b/b.go":    broken("", "b"),
+		"example.com/tools/x/x.go":    broken("", "x"),
+		// Neither tools/* nor a directive after the package clause
+		// ignores a package.
+		"example.com/tools/x/y/y.go": "package y\n\n//wire:ignore\n\nconst Y = 1\n",
+	})
+	ignoreFile := filepath.Join(wd, ignoreFileName)
+	if err := ioutil.WriteFile(ignoreFile, []byte("# Generated code.\ngen/...\n\ntools/*\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	gens, errs := Generate(ctx, wd, env, []string{"./..."}, &GenerateOptions{})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	for _, gen := range gens {
+		if len(gen.Errs) > 0 {
+			t.Errorf("%s: %v", gen.PkgPath, gen.Errs)
+		}
+	}
+	sum := Summarize(gens)
+	if diff := cmp.Diff([]string{"example.com/foo", "example.com/tools/x/y"}, sum.Scanned); diff != "" {
+		t.Errorf("Scanned (-want +got):\n%s", diff)
+	}
+	wantIgnored := []string{"example.com/gen/a", "example.com/gen/a/b", "example.com/mocks", "example.com/tools/x"}
+	got := append([]string(nil), sum.Ignored...)
+	sort.Strings(got)
+	if diff := cmp.Diff(wantIgnored, got); diff != "" {
+		t.Errorf("Ignored (-want +got):\n%s", diff)
+	}
+
+	if err := ioutil.WriteFile(ignoreFile, []byte("gen/...\ntools/[x\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	_, errs = Generate(ctx, wd, env, []string{"./..."}, &GenerateOptions{})
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), ignoreFileName+`:2: invalid glob "tools/[x"`) {
+		t.Errorf("Generate with an invalid glob: errors = %v; want one for line 2", errs)
+	}
+}
+
+func TestOptionsFingerprint(t *testing.T) {
+	// outputAffecting classifies every field of GenerateOptions by whether
+	// it changes the generated files. A new field must be added here, and
+	// to OptionsFingerprint if it affects output.
+	outputAffecting := map[string]bool{
+		"Header":              true,
+		"PrefixOutputFile":    true,
+		"Tags":                true,
+		"GOOS":                true,
+		"GOARCH":              true,
+		"GoCache":             false,
+		"Overlay":             false,
+		"VerifyOutput":        false,
+		"DeprecatedAsError":   true,
+		"UnusedParamsAsError": true,
+		"NearestWins":         true,
+		"AnnotateOutput":      true,
+		"UngroupedErrors":     false,
+		"RequireVersion":      false,
+		"IdentifierPrefix":    true,
+		"CheckCleanupRecover": false,
+		"CheckGlobalReads":    false,
+		"CheckConversions":    false,
+		"EmitBenchmarks":      true,
+		"UseRuntimeCleanup":   true,
+		"BuildTag":            true,
+		"WrapErrors":          true,
+		"Concurrency":         false,
+		"LazyLoad":            false,
+		"Force":               false,
+		"Format":              false, // a func cannot be fingerprinted
+		"Progress":            false,
+		"LoadStats":           false,
+		"platformSuffix":      true,
+		"outputFile":          true,
+		"progress":            false,
+		"loader":              false,
+	}
+	base := new(GenerateOptions).OptionsFingerprint()
+	typ := reflect.TypeOf(GenerateOptions{})
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		affects, ok := outputAffecting[field.Name]
+		if !ok {
+			t.Errorf("GenerateOptions.%s is not classified as output-affecting or not", field.Name)
+			continue
+		}
+		opts := new(GenerateOptions)
+		v := reflect.ValueOf(opts).Elem().Field(i)
+		switch {
+		case field.Name == "platformSuffix":
+			opts.platformSuffix = true
+		case field.Name == "outputFile":
+			opts.outputFile = "x.go"
+		case field.Name == "Overlay":
+			opts.Overlay = map[string][]byte{"/x.go": []byte("package x")}
+		case field.Name == "Format":
+			opts.Format = func(_ string, src []byte) ([]byte, error) { return src, nil }
+		case field.Name == "Progress":
+			opts.Progress = func(ProgressEvent) {}
+		case field.Name == "LoadStats":
+			opts.LoadStats = func(LoadStats) {}
+		case field.Name == "progress":
+			opts.progress = newProgress(func(ProgressEvent) {})
+		case field.Name == "loader":
+			opts.loader = packages.Load
+		case v.Kind() == reflect.String:
+			v.SetString("x")
+		case v.Kind() == reflect.Bool:
+			v.SetBool(true)
+		case v.Kind() == reflect.Int:
+			v.SetInt(1)
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+			v.SetBytes([]byte("x"))
+		default:
+			t.Errorf("GenerateOptions.%s has type %v, which the test does not know how to set", field.Name, field.Type)
+			continue
+		}
+		if got := opts.OptionsFingerprint() != base; got != affects {
+			t.Errorf("setting GenerateOptions.%s changes the fingerprint = %t; want %t", field.Name, got, affects)
+		}
+	}
+}
+
+func TestTestOnlySetInTestFile(t *testing.T) {
+	const src = `package foo
+
+import "github.com/google/wire"
+
+type Foo int
+
+func provideFoo() Foo { return 1 }
+
+//wire:testonly
+var FakeSet = wire.NewSet(provideFoo)
+
+func injectFoo() Foo {
+	wire.Build(FakeSet)
+	return 0
+}
+`
+	for _, test := range []struct {
+		filename string
+		wantErr  bool
+	}{
+		{"foo.go", true},
+		{"foo_test.go", false},
+	} {
+		fset := token.NewFileSet()
+		wirePkg := checkSource(t, fset, "github.com/google/wire", filepath.Join("..", "..", "wire.go"), nil, nil)
+		pkg := checkSource(t, fset, "example.com/foo", test.filename, src, map[string]*packages.Package{
+			wirePkg.PkgPath: wirePkg,
+		})
+		if pkg == nil {
+			t.Fatal("test package does not type-check")
+		}
+		injectors, errs := FindInjectors(pkg)
+		if len(errs) > 0 || len(injectors) != 1 {
+			t.Fatalf("FindInjectors(...) = %d injectors, %v; want 1 injector", len(injectors), errs)
+		}
+		inj := injectors[0]
+		_, errs = newObjectCache([]*packages.Package{pkg}).checkInjector(pkg, inj.Func, inj.Build)
+		if gotErr := len(errs) > 0; gotErr != test.wantErr {
+			t.Errorf("injector in %s: errors = %v; want error = %t", test.filename, errs, test.wantErr)
+		}
 	}
-	return nil
 }
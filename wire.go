@@ -30,15 +30,17 @@ type ProviderSet struct{}
 
 // NewSet creates a new provider set that includes the providers in its
 // arguments. Each argument is a function value, a provider set, a call to
-// Struct, a call to Bind, a call to Value, a call to InterfaceValue or a call
-// to FieldsOf.
-//
-// Passing a function value to NewSet declares that the function's first
-// return value type will be provided by calling the function. The arguments
-// to the function will come from the providers for their types. As such, all
-// the function's parameters must be of non-identical types. The function may
-// optionally return an error as its last return value and a cleanup function
-// as the second return value. A cleanup function must be of type func() and is
+// Struct, a call to Bind, a call to Value, a call to InterfaceValue, a call
+// to FieldsOf, a call to Slice, a call to Map or a call to Key.
+//
+// Passing a function value to NewSet declares that the function's return
+// value types will be provided by calling the function. The function usually
+// returns one value, but may return several of non-identical types, all of
+// which are provided by a single call. The arguments to the function will
+// come from the providers for their types. As such, all the function's
+// parameters must be of non-identical types. The function may optionally
+// return an error as its last return value, preceded by an optional cleanup
+// function. A cleanup function must be of type func() and is
 // guaranteed to be called before the cleanup function of any of the
 // provider's inputs. If any provider returns an error, the injector function
 // will call all the appropriate cleanup functions and return the error from
@@ -123,6 +125,8 @@ type ProvidedValue struct{}
 
 // Value binds an expression to provide the type of the expression.
 // The expression may not be an interface value; use InterfaceValue for that.
+// As an exception, a value of a named error type (such as
+// "type ShutdownError error") may be passed to Value.
 //
 // Example:
 //
@@ -194,3 +198,238 @@ type StructFields struct{}
 func FieldsOf(structType interface{}, fieldNames ...string) StructFields {
 	return StructFields{}
 }
+
+// AllFieldsOf declares that every exported field of the given struct type,
+// other than those named in excludeFieldNames, will be used to provide the
+// type of that field. The structType argument is the same as for FieldsOf.
+//
+// Fields whose type is a basic type such as string or int are skipped, since
+// providing them is usually ambiguous; use FieldsOf to provide them
+// explicitly. Fields tagged with `wire:"-"` are skipped as well.
+//
+// The following example would provide DBConfig and CacheConfig using
+// Config.DB and Config.Cache, but not Config.Name or Config.Debug:
+//
+//	type Config struct {
+//		Name  string
+//		DB    DBConfig
+//		Cache CacheConfig
+//		Debug DebugConfig
+//	}
+//
+//	var Set = wire.NewSet(wire.AllFieldsOf(new(Config), "Debug"))
+func AllFieldsOf(structType interface{}, excludeFieldNames ...string) StructFields {
+	return StructFields{}
+}
+
+// A SliceProvider is the result of Slice.
+type SliceProvider struct{}
+
+// Slice declares that a slice type is provided by a slice literal with one
+// element for each provider of its element type. The argument is a pointer
+// to the slice type, such as new([]Middleware).
+//
+// Once a provider set declares or includes Slice, whatever provides the
+// element type in it (provider functions, values, fields, injector
+// arguments and bindings) contributes an element to the slice instead of
+// providing the element type itself, so several of them do not conflict.
+// A provider function with several results cannot contribute.
+// The elements of an included set come first, in the order the sets are
+// included, followed by the set's own providers in source order. A
+// provider that contributes to the slice more than once, for instance
+// through a set that is included twice, is an error.
+//
+// Example:
+//
+//	var MiddlewareSet = wire.NewSet(
+//		NewLogging,
+//		NewTracing,
+//		wire.Slice(new([]Middleware)))
+func Slice(sliceType interface{}) SliceProvider {
+	return SliceProvider{}
+}
+
+// A MapProvider is the result of Map.
+type MapProvider struct{}
+
+// Map declares that a map type with string keys is provided by a map
+// literal with one entry for each provider of its value type that is
+// passed to Key. The argument is a pointer to the map type, such as
+// new(map[string]Codec).
+//
+// The entries are those of the whole set that declares or includes Map, so
+// two providers for the same key are an error. A keyed provider still
+// provides the value type itself, but injecting the value type when there
+// are several keyed providers of it is an error, since none of them is more
+// appropriate than the others.
+//
+// Example:
+//
+//	var CodecSet = wire.NewSet(
+//		wire.Key("json", NewJSONCodec),
+//		wire.Key("xml", NewXMLCodec),
+//		wire.Map(new(map[string]Codec)))
+func Map(mapType interface{}) MapProvider {
+	return MapProvider{}
+}
+
+// A KeyedProvider is the result of Key.
+type KeyedProvider struct{}
+
+// Key declares that provider, a provider function with one result, also
+// provides the entry with the given key of the maps declared with Map whose
+// value type is the result type. The key must be a constant.
+func Key(key string, provider interface{}) KeyedProvider {
+	return KeyedProvider{}
+}
+
+// A CleanupRegistry is the result of CleanupInto.
+type CleanupRegistry struct{}
+
+// CleanupInto makes an injector hand the cleanup function of each provider
+// to a registry instead of returning a func() that runs them. The registry
+// argument is a pointer to the registry type, such as new(*lifecycle.Registry),
+// which must have a method Append(func()) and be an argument of the injector
+// or provided by the set. CleanupInto may only be used in a call to Build.
+//
+// The injector calls Append with each cleanup function as soon as both it
+// and the registry exist, so the registry owns them from then on: if a
+// provider fails, the injector only runs the cleanup functions that it has
+// not handed over yet.
+//
+// Example:
+//
+//	func initServer(reg *lifecycle.Registry) (*Server, error) {
+//		panic(wire.Build(serverSet, wire.CleanupInto(new(*lifecycle.Registry))))
+//	}
+func CleanupInto(registry interface{}) CleanupRegistry {
+	return CleanupRegistry{}
+}
+
+// Cleanups is the cleanup that an injector returns instead of a func() when
+// it is marked with a //wire:named-cleanup directive:
+//
+//	//wire:named-cleanup
+//	func initApp() (*App, *wire.Cleanups, error) {
+//		panic(wire.Build(appSet))
+//	}
+//
+// It holds one cleanup function for each provider that returned one, named
+// after the type that the provider provides, such as "*db.Pool". Close runs
+// them all, while Func and Skip handle them one at a time, for instance to
+// keep a test fixture alive. Injectors without the directive do not depend on
+// this type. A nil *Cleanups has no cleanup functions. A Cleanups is not
+// safe for concurrent use.
+type Cleanups struct {
+	names []string
+	funcs []func()
+	done  []bool
+}
+
+// NewCleanups returns the Cleanups of the cleanup functions funcs, given in
+// the order the resources they clean up were created, named by the
+// corresponding element of names. It is called by generated injectors.
+func NewCleanups(names []string, funcs []func()) *Cleanups {
+	if len(names) != len(funcs) {
+		panic("wire: NewCleanups called with different numbers of names and functions")
+	}
+	return &Cleanups{names: names, funcs: funcs, done: make([]bool, len(funcs))}
+}
+
+// Close runs the cleanup functions that have not run or been skipped yet, in
+// the reverse of the order the resources were created.
+func (c *Cleanups) Close() {
+	if c == nil {
+		return
+	}
+	for i := len(c.funcs) - 1; i >= 0; i-- {
+		c.run(i)
+	}
+}
+
+// Names returns the names of the cleanup functions in the order the
+// resources were created.
+func (c *Cleanups) Names() []string {
+	if c == nil {
+		return nil
+	}
+	return append([]string(nil), c.names...)
+}
+
+// Func returns a function that runs the cleanup function with the given
+// name, unless it has already run or been skipped. Close does not run it
+// again. Func returns nil if there is no cleanup function with that name.
+func (c *Cleanups) Func(name string) func() {
+	i := c.index(name)
+	if i < 0 {
+		return nil
+	}
+	return func() { c.run(i) }
+}
+
+// Skip keeps the cleanup function with the given name from running, and
+// reports whether there is one.
+func (c *Cleanups) Skip(name string) bool {
+	i := c.index(name)
+	if i < 0 {
+		return false
+	}
+	c.done[i] = true
+	return true
+}
+
+func (c *Cleanups) index(name string) int {
+	if c == nil {
+		return -1
+	}
+	for i, n := range c.names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// run runs the i'th cleanup function if it has not run or been skipped.
+func (c *Cleanups) run(i int) {
+	if !c.done[i] {
+		c.done[i] = true
+		c.funcs[i]()
+	}
+}
+
+// CleanupFuncs is a list of cleanup functions, in the order the resources
+// they clean up were created. Injectors generated with the runtime cleanup
+// option return the Run method of a CleanupFuncs as their cleanup function,
+// and call it when a provider fails, instead of calling each cleanup
+// function in turn.
+type CleanupFuncs []func()
+
+// Run runs the cleanup functions in the reverse of the order the resources
+// were created. A cleanup function that panics does not keep the others
+// from running: Run recovers the panic, runs the rest, and then panics again
+// with the value of the first panic it recovered.
+func (c CleanupFuncs) Run() {
+	var first interface{}
+	panicked := false
+	for i := len(c) - 1; i >= 0; i-- {
+		if p, ok := runIsolated(c[i]); ok && !panicked {
+			first, panicked = p, true
+		}
+	}
+	if panicked {
+		panic(first)
+	}
+}
+
+// runIsolated calls f and returns the value it panicked with, if it did.
+func runIsolated(f func()) (p interface{}, panicked bool) {
+	panicked = true
+	defer func() {
+		if panicked {
+			p = recover()
+		}
+	}()
+	f()
+	return nil, false
+}
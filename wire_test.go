@@ -0,0 +1,109 @@
+// Copyright 2018 The Wire Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCleanupFuncsRun(t *testing.T) {
+	var ran []string
+	c := CleanupFuncs{
+		func() { ran = append(ran, "first") },
+		func() { ran = append(ran, "second"); panic("second failed") },
+		func() { ran = append(ran, "third"); panic("third failed") },
+		func() { ran = append(ran, "fourth") },
+	}
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		c.Run()
+	}()
+	if want := []string{"fourth", "third", "second", "first"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %q; want %q", ran, want)
+	}
+	// third runs before second, so its panic is the first one.
+	if recovered != "third failed" {
+		t.Errorf("Run panicked with %v; want %q", recovered, "third failed")
+	}
+}
+
+func TestCleanupFuncsRunNilPanic(t *testing.T) {
+	ran := false
+	c := CleanupFuncs{
+		func() { ran = true },
+		func() { panic(nil) },
+	}
+	panicked := true
+	func() {
+		defer func() { recover() }()
+		c.Run()
+		panicked = false
+	}()
+	if !ran || !panicked {
+		t.Errorf("after a cleanup function panicked with nil, ran = %t, Run panicked = %t; want true, true", ran, panicked)
+	}
+}
+
+func TestCleanupFuncsRunEmpty(t *testing.T) {
+	CleanupFuncs(nil).Run()
+}
+
+// BenchmarkCleanup compares the closure that injectors return by default
+// with the CleanupFuncs.Run method value that they return with
+// UseRuntimeCleanup, for an injector with eight cleanup functions. Both
+// have the shape of the generated code.
+func BenchmarkCleanup(b *testing.B) {
+	count := 0
+	provide := func() func() { return func() { count++ } }
+	b.Run("Closure", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cleanup := provide()
+			cleanup2 := provide()
+			cleanup3 := provide()
+			cleanup4 := provide()
+			cleanup5 := provide()
+			cleanup6 := provide()
+			cleanup7 := provide()
+			cleanup8 := provide()
+			f := func() {
+				cleanup8()
+				cleanup7()
+				cleanup6()
+				cleanup5()
+				cleanup4()
+				cleanup3()
+				cleanup2()
+				cleanup()
+			}
+			f()
+		}
+	})
+	b.Run("CleanupFuncs", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cleanup := provide()
+			cleanup2 := provide()
+			cleanup3 := provide()
+			cleanup4 := provide()
+			cleanup5 := provide()
+			cleanup6 := provide()
+			cleanup7 := provide()
+			cleanup8 := provide()
+			f := CleanupFuncs{cleanup, cleanup2, cleanup3, cleanup4, cleanup5, cleanup6, cleanup7, cleanup8}.Run
+			f()
+		}
+	})
+}